@@ -148,6 +148,8 @@ func (b *SimulatedBackend) Commit(accept bool) {
 		if err := b.blockchain.Accept(b.acceptedBlock); err != nil {
 			panic(err)
 		}
+		// Make the receipts and logs of the block available to the caller
+		b.blockchain.DrainAcceptorQueue()
 	}
 	// Using the last inserted block here makes it possible to build on a side
 	// chain after a fork.
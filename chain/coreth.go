@@ -141,7 +141,7 @@ func (self *ETHChain) Reject(block *types.Block) error {
 
 // LastAcceptedBlock returns the last block to be marked as accepted.
 func (self *ETHChain) LastAcceptedBlock() *types.Block {
-	return self.BlockChain().LastAcceptedBlock()
+	return self.BlockChain().LastConsensusAcceptedBlock()
 }
 
 // RemoveRejectedBlocks removes the rejected blocks between heights
@@ -114,6 +114,7 @@ func insertAndAccept(t *testing.T, chain *ETHChain, block *types.Block) {
 	if err := chain.Accept(block); err != nil {
 		t.Fatal(err)
 	}
+	chain.BlockChain().DrainAcceptorQueue()
 }
 
 func insertAndSetPreference(t *testing.T, chain *ETHChain, block *types.Block) {
@@ -0,0 +1,102 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package commontype contains the types shared by the chain config and the
+// stateful precompiles, which cannot depend on each other.
+package commontype
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var errNilField = errors.New("must be specified")
+
+// FeeConfig contains the parameters of the dynamic fee algorithm, which
+// determines the base fee and the block gas cost of each block from the gas
+// consumed by its ancestors.
+type FeeConfig struct {
+	// TargetGas is the amount of gas that can be consumed within the rollup
+	// window (10 seconds) before the base fee increases.
+	TargetGas *big.Int `json:"targetGas"`
+	// BaseFeeChangeDenominator bounds the change of the base fee from one block
+	// to the next to 1/BaseFeeChangeDenominator of the parent base fee.
+	BaseFeeChangeDenominator *big.Int `json:"baseFeeChangeDenominator"`
+	// MinBaseFee and MaxBaseFee bound the base fee (nil MaxBaseFee = no upper bound).
+	MinBaseFee *big.Int `json:"minBaseFee"`
+	MaxBaseFee *big.Int `json:"maxBaseFee,omitempty"`
+
+	// TargetBlockRate is the target number of seconds between blocks. The block
+	// gas cost increases by BlockGasCostStep for each second below the target,
+	// and decreases by BlockGasCostStep for each second above the target.
+	TargetBlockRate  uint64   `json:"targetBlockRate"`
+	MinBlockGasCost  *big.Int `json:"minBlockGasCost"`
+	MaxBlockGasCost  *big.Int `json:"maxBlockGasCost"`
+	BlockGasCostStep *big.Int `json:"blockGasCostStep"`
+}
+
+// Verify returns an error if [f] does not describe a usable fee algorithm.
+func (f *FeeConfig) Verify() error {
+	switch {
+	case f.TargetGas == nil:
+		return fmt.Errorf("targetGas %w", errNilField)
+	case f.TargetGas.Sign() <= 0 || !f.TargetGas.IsUint64():
+		return fmt.Errorf("targetGas = %d must be positive and fit in a uint64", f.TargetGas)
+	case f.BaseFeeChangeDenominator == nil:
+		return fmt.Errorf("baseFeeChangeDenominator %w", errNilField)
+	case f.BaseFeeChangeDenominator.Sign() <= 0:
+		return fmt.Errorf("baseFeeChangeDenominator = %d must be positive", f.BaseFeeChangeDenominator)
+	case f.MinBaseFee == nil:
+		return fmt.Errorf("minBaseFee %w", errNilField)
+	case f.MinBaseFee.Sign() < 0:
+		return fmt.Errorf("minBaseFee = %d cannot be negative", f.MinBaseFee)
+	case f.MaxBaseFee != nil && (f.MaxBaseFee.Sign() <= 0 || f.MaxBaseFee.Cmp(f.MinBaseFee) < 0):
+		return fmt.Errorf("maxBaseFee = %d must be positive and at least minBaseFee = %d", f.MaxBaseFee, f.MinBaseFee)
+	case f.TargetBlockRate == 0:
+		return errors.New("targetBlockRate must be positive")
+	case f.MinBlockGasCost == nil:
+		return fmt.Errorf("minBlockGasCost %w", errNilField)
+	case f.MinBlockGasCost.Sign() < 0:
+		return fmt.Errorf("minBlockGasCost = %d cannot be negative", f.MinBlockGasCost)
+	case f.MaxBlockGasCost == nil:
+		return fmt.Errorf("maxBlockGasCost %w", errNilField)
+	case f.MaxBlockGasCost.Cmp(f.MinBlockGasCost) < 0 || !f.MaxBlockGasCost.IsUint64():
+		return fmt.Errorf("maxBlockGasCost = %d must be at least minBlockGasCost = %d and fit in a uint64", f.MaxBlockGasCost, f.MinBlockGasCost)
+	case f.BlockGasCostStep == nil:
+		return fmt.Errorf("blockGasCostStep %w", errNilField)
+	case f.BlockGasCostStep.Sign() < 0:
+		return fmt.Errorf("blockGasCostStep = %d cannot be negative", f.BlockGasCostStep)
+	}
+	// The parameters are stored in the state of the fee manager precompile
+	for _, value := range []*big.Int{f.BaseFeeChangeDenominator, f.MinBaseFee, f.MaxBaseFee, f.BlockGasCostStep} {
+		if value != nil && value.BitLen() > common.HashLength*8 {
+			return fmt.Errorf("fee config value %d exceeds 256 bits", value)
+		}
+	}
+	return nil
+}
+
+// Equal returns true if [f] and [other] contain the same parameters.
+func (f *FeeConfig) Equal(other *FeeConfig) bool {
+	if f == nil || other == nil {
+		return f == other
+	}
+	return bigEqual(f.TargetGas, other.TargetGas) &&
+		bigEqual(f.BaseFeeChangeDenominator, other.BaseFeeChangeDenominator) &&
+		bigEqual(f.MinBaseFee, other.MinBaseFee) &&
+		bigEqual(f.MaxBaseFee, other.MaxBaseFee) &&
+		f.TargetBlockRate == other.TargetBlockRate &&
+		bigEqual(f.MinBlockGasCost, other.MinBlockGasCost) &&
+		bigEqual(f.MaxBlockGasCost, other.MaxBlockGasCost) &&
+		bigEqual(f.BlockGasCostStep, other.BlockGasCostStep)
+}
+
+func bigEqual(a, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(b) == 0
+}
@@ -30,6 +30,7 @@ package consensus
 import (
 	"math/big"
 
+	"github.com/ava-labs/coreth/commontype"
 	"github.com/ava-labs/coreth/core/state"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/params"
@@ -53,6 +54,9 @@ type ChainHeaderReader interface {
 
 	// GetHeaderByHash retrieves a block header from the database by its hash.
 	GetHeaderByHash(hash common.Hash) *types.Header
+
+	// GetFeeConfigAt retrieves the fee config in force for the children of parent.
+	GetFeeConfigAt(parent *types.Header) (commontype.FeeConfig, error)
 }
 
 // ChainReader defines a small collection of methods needed to access the local
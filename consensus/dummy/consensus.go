@@ -85,8 +85,11 @@ func NewFullFaker() *DummyEngine {
 	}
 }
 
-func (self *DummyEngine) verifyHeaderGasFields(config *params.ChainConfig, header *types.Header, parent *types.Header) error {
-	timestamp := new(big.Int).SetUint64(header.Time)
+func (self *DummyEngine) verifyHeaderGasFields(chain consensus.ChainHeaderReader, header *types.Header, parent *types.Header) error {
+	var (
+		config    = chain.Config()
+		timestamp = new(big.Int).SetUint64(header.Time)
+	)
 
 	// Verify that the gas limit is <= 2^63-1
 	if header.GasLimit > params.MaxGasLimit {
@@ -118,48 +121,44 @@ func (self *DummyEngine) verifyHeaderGasFields(config *params.ChainConfig, heade
 		if header.BaseFee != nil {
 			return fmt.Errorf("invalid baseFee before fork: have %d, want <nil>", header.BaseFee)
 		}
-	} else {
-		// Verify baseFee and rollupWindow encoding as part of header verification
-		// starting in AP3
-		expectedRollupWindowBytes, expectedBaseFee, err := CalcBaseFee(config, parent, header.Time)
-		if err != nil {
-			return fmt.Errorf("failed to calculate base fee: %w", err)
-		}
-		if !bytes.Equal(expectedRollupWindowBytes, header.Extra) {
-			return fmt.Errorf("expected rollup window bytes: %x, found %x", expectedRollupWindowBytes, header.Extra)
-		}
-		if header.BaseFee == nil {
-			return errors.New("expected baseFee to be non-nil")
-		}
-		if bfLen := header.BaseFee.BitLen(); bfLen > 256 {
-			return fmt.Errorf("too large base fee: bitlen %d", bfLen)
-		}
-		if header.BaseFee.Cmp(expectedBaseFee) != 0 {
-			return fmt.Errorf("expected base fee (%d), found (%d)", expectedBaseFee, header.BaseFee)
-		}
+		// Verify BlockGasCost, ExtDataGasUsed not present before AP4
+		return verifyNoBlockGasCost(header)
+	}
+
+	feeConfig, err := chain.GetFeeConfigAt(parent)
+	if err != nil {
+		return err
+	}
+	// Verify baseFee and rollupWindow encoding as part of header verification
+	// starting in AP3, using the fee config in force at the parent
+	expectedRollupWindowBytes, expectedBaseFee, err := CalcBaseFee(config, feeConfig, parent, header.Time)
+	if err != nil {
+		return fmt.Errorf("failed to calculate base fee: %w", err)
+	}
+	if !bytes.Equal(expectedRollupWindowBytes, header.Extra) {
+		return fmt.Errorf("expected rollup window bytes: %x, found %x", expectedRollupWindowBytes, header.Extra)
+	}
+	if header.BaseFee == nil {
+		return errors.New("expected baseFee to be non-nil")
+	}
+	if bfLen := header.BaseFee.BitLen(); bfLen > 256 {
+		return fmt.Errorf("too large base fee: bitlen %d", bfLen)
+	}
+	if header.BaseFee.Cmp(expectedBaseFee) != 0 {
+		return fmt.Errorf("expected base fee (%d), found (%d)", expectedBaseFee, header.BaseFee)
 	}
 
 	// Verify BlockGasCost, ExtDataGasUsed not present before AP4
 	if !config.IsApricotPhase4(timestamp) {
-		if header.BlockGasCost != nil {
-			return fmt.Errorf("invalid blockGasCost before fork: have %d, want <nil>", header.BlockGasCost)
-		}
-		if header.ExtDataGasUsed != nil {
-			return fmt.Errorf("invalid extDataGasUsed before fork: have %d, want <nil>", header.ExtDataGasUsed)
-		}
-		return nil
+		return verifyNoBlockGasCost(header)
 	}
 
 	// Enforce BlockGasCost constraints
-	blockGasCostStep := ApricotPhase4BlockGasCostStep
-	if config.IsApricotPhase5(timestamp) {
-		blockGasCostStep = ApricotPhase5BlockGasCostStep
-	}
 	expectedBlockGasCost := calcBlockGasCost(
-		ApricotPhase4TargetBlockRate,
-		ApricotPhase4MinBlockGasCost,
-		ApricotPhase4MaxBlockGasCost,
-		blockGasCostStep,
+		feeConfig.TargetBlockRate,
+		feeConfig.MinBlockGasCost,
+		feeConfig.MaxBlockGasCost,
+		blockGasCostStep(config, feeConfig, parent, header.Time),
 		parent.BlockGasCost,
 		parent.Time, header.Time,
 	)
@@ -183,6 +182,18 @@ func (self *DummyEngine) verifyHeaderGasFields(config *params.ChainConfig, heade
 	return nil
 }
 
+// verifyNoBlockGasCost returns an error if [header] contains the fields
+// introduced in AP4.
+func verifyNoBlockGasCost(header *types.Header) error {
+	if header.BlockGasCost != nil {
+		return fmt.Errorf("invalid blockGasCost before fork: have %d, want <nil>", header.BlockGasCost)
+	}
+	if header.ExtDataGasUsed != nil {
+		return fmt.Errorf("invalid extDataGasUsed before fork: have %d, want <nil>", header.ExtDataGasUsed)
+	}
+	return nil
+}
+
 // modified from consensus.go
 func (self *DummyEngine) verifyHeader(chain consensus.ChainHeaderReader, header *types.Header, parent *types.Header, uncle bool) error {
 	var (
@@ -204,7 +215,7 @@ func (self *DummyEngine) verifyHeader(chain consensus.ChainHeaderReader, header
 		}
 	}
 	// Ensure gas-related header fields are correct
-	if err := self.verifyHeaderGasFields(config, header, parent); err != nil {
+	if err := self.verifyHeaderGasFields(chain, header, parent); err != nil {
 		return err
 	}
 	// Verify the header's timestamp
@@ -339,15 +350,15 @@ func (self *DummyEngine) Finalize(chain consensus.ChainHeaderReader, block *type
 		if blockExtDataGasUsed := block.ExtDataGasUsed(); blockExtDataGasUsed == nil || !blockExtDataGasUsed.IsUint64() || blockExtDataGasUsed.Cmp(extDataGasUsed) != 0 {
 			return fmt.Errorf("invalid extDataGasUsed: have %d, want %d", blockExtDataGasUsed, extDataGasUsed)
 		}
-		blockGasCostStep := ApricotPhase4BlockGasCostStep
-		if chain.Config().IsApricotPhase5(new(big.Int).SetUint64(block.Time())) {
-			blockGasCostStep = ApricotPhase5BlockGasCostStep
+		feeConfig, err := chain.GetFeeConfigAt(parent)
+		if err != nil {
+			return err
 		}
 		blockGasCost := calcBlockGasCost(
-			ApricotPhase4TargetBlockRate,
-			ApricotPhase4MinBlockGasCost,
-			ApricotPhase4MaxBlockGasCost,
-			blockGasCostStep,
+			feeConfig.TargetBlockRate,
+			feeConfig.MinBlockGasCost,
+			feeConfig.MaxBlockGasCost,
+			blockGasCostStep(chain.Config(), feeConfig, parent, block.Time()),
 			parent.BlockGasCost,
 			parent.Time, block.Time(),
 		)
@@ -386,15 +397,15 @@ func (self *DummyEngine) FinalizeAndAssemble(chain consensus.ChainHeaderReader,
 		if header.ExtDataGasUsed == nil {
 			header.ExtDataGasUsed = new(big.Int).Set(common.Big0)
 		}
-		blockGasCostStep := ApricotPhase4BlockGasCostStep
-		if chain.Config().IsApricotPhase5(new(big.Int).SetUint64(header.Time)) {
-			blockGasCostStep = ApricotPhase5BlockGasCostStep
+		feeConfig, err := chain.GetFeeConfigAt(parent)
+		if err != nil {
+			return nil, err
 		}
 		header.BlockGasCost = calcBlockGasCost(
-			ApricotPhase4TargetBlockRate,
-			ApricotPhase4MinBlockGasCost,
-			ApricotPhase4MaxBlockGasCost,
-			blockGasCostStep,
+			feeConfig.TargetBlockRate,
+			feeConfig.MinBlockGasCost,
+			feeConfig.MaxBlockGasCost,
+			blockGasCostStep(chain.Config(), feeConfig, parent, header.Time),
 			parent.BlockGasCost,
 			parent.Time, header.Time,
 		)
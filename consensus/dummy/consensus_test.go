@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/params"
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -179,10 +180,10 @@ func TestVerifyBlockFee(t *testing.T) {
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			blockGasCost := calcBlockGasCost(
-				ApricotPhase4TargetBlockRate,
-				ApricotPhase4MinBlockGasCost,
-				ApricotPhase4MaxBlockGasCost,
-				ApricotPhase4BlockGasCostStep,
+				params.ApricotPhase4FeeConfig.TargetBlockRate,
+				params.ApricotPhase4FeeConfig.MinBlockGasCost,
+				params.ApricotPhase4FeeConfig.MaxBlockGasCost,
+				params.ApricotPhase4FeeConfig.BlockGasCostStep,
 				test.parentBlockGasCost,
 				test.parentTime, test.currentTime,
 			)
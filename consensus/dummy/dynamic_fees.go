@@ -9,35 +9,25 @@ import (
 	"math/big"
 
 	"github.com/ava-labs/avalanchego/utils/wrappers"
+	"github.com/ava-labs/coreth/commontype"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/params"
+	"github.com/ava-labs/coreth/precompile"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
 )
 
 var (
-	ApricotPhase3MinBaseFee = big.NewInt(params.ApricotPhase3MinBaseFee)
-	ApricotPhase3MaxBaseFee = big.NewInt(params.ApricotPhase3MaxBaseFee)
-	ApricotPhase4MinBaseFee = big.NewInt(params.ApricotPhase4MinBaseFee)
-	ApricotPhase4MaxBaseFee = big.NewInt(params.ApricotPhase4MaxBaseFee)
-
-	ApricotPhase4BaseFeeChangeDenominator = new(big.Int).SetUint64(params.ApricotPhase4BaseFeeChangeDenominator)
-	ApricotPhase5BaseFeeChangeDenominator = new(big.Int).SetUint64(params.ApricotPhase5BaseFeeChangeDenominator)
-
-	ApricotPhase3BlockGasFee      uint64 = 1_000_000
-	ApricotPhase4MinBlockGasCost         = new(big.Int).Set(common.Big0)
-	ApricotPhase4MaxBlockGasCost         = big.NewInt(1_000_000)
-	ApricotPhase4BlockGasCostStep        = big.NewInt(50_000)
-	ApricotPhase4TargetBlockRate  uint64 = 2 // in seconds
-	ApricotPhase5BlockGasCostStep        = big.NewInt(200_000)
-	rollupWindow                  uint64 = 10
+	ApricotPhase3BlockGasFee uint64 = 1_000_000
+	rollupWindow             uint64 = 10
 )
 
 // CalcBaseFee takes the previous header and the timestamp of its child block
 // and calculates the expected base fee as well as the encoding of the past
-// pricing information for the child block.
+// pricing information for the child block, using [feeConfig], the fee config
+// in force at [parent].
 // CalcBaseFee should only be called if [timestamp] >= [config.ApricotPhase3Timestamp]
-func CalcBaseFee(config *params.ChainConfig, parent *types.Header, timestamp uint64) ([]byte, *big.Int, error) {
+func CalcBaseFee(config *params.ChainConfig, feeConfig commontype.FeeConfig, parent *types.Header, timestamp uint64) ([]byte, *big.Int, error) {
 	// If the current block is the first EIP-1559 block, or it is the genesis block
 	// return the initial slice and initial base fee.
 	bigTimestamp := new(big.Int).SetUint64(parent.Time)
@@ -67,18 +57,12 @@ func CalcBaseFee(config *params.ChainConfig, parent *types.Header, timestamp uin
 		return nil, nil, err
 	}
 
-	// If AP5, use a less responsive [BaseFeeChangeDenominator] and a higher gas
-	// block limit
 	var (
 		baseFee                  = new(big.Int).Set(parent.BaseFee)
-		baseFeeChangeDenominator = ApricotPhase4BaseFeeChangeDenominator
-		parentGasTarget          = params.ApricotPhase3TargetGas
+		baseFeeChangeDenominator = feeConfig.BaseFeeChangeDenominator
+		parentGasTarget          = feeConfig.TargetGas.Uint64()
+		parentGasTargetBig       = feeConfig.TargetGas
 	)
-	if isApricotPhase5 {
-		baseFeeChangeDenominator = ApricotPhase5BaseFeeChangeDenominator
-		parentGasTarget = params.ApricotPhase5TargetGas
-	}
-	parentGasTargetBig := new(big.Int).SetUint64(parentGasTarget)
 
 	// Add in the gas used by the parent block in the correct place
 	// If the parent consumed gas within the rollup window, add the consumed
@@ -98,10 +82,10 @@ func CalcBaseFee(config *params.ChainConfig, parent *types.Header, timestamp uin
 			// The [blockGasCost] is paid by the effective tips in the block using
 			// the block's value of [baseFee].
 			blockGasCost = calcBlockGasCost(
-				ApricotPhase4TargetBlockRate,
-				ApricotPhase4MinBlockGasCost,
-				ApricotPhase4MaxBlockGasCost,
-				ApricotPhase4BlockGasCostStep,
+				feeConfig.TargetBlockRate,
+				feeConfig.MinBlockGasCost,
+				feeConfig.MaxBlockGasCost,
+				feeConfig.BlockGasCostStep,
 				parent.BlockGasCost,
 				parent.Time, timestamp,
 			).Uint64()
@@ -174,28 +158,21 @@ func CalcBaseFee(config *params.ChainConfig, parent *types.Header, timestamp uin
 	}
 
 	// Ensure that the base fee does not increase/decrease outside of the bounds
-	switch {
-	case isApricotPhase5:
-		baseFee = selectBigWithinBounds(ApricotPhase4MinBaseFee, baseFee, nil)
-	case isApricotPhase4:
-		baseFee = selectBigWithinBounds(ApricotPhase4MinBaseFee, baseFee, ApricotPhase4MaxBaseFee)
-	default:
-		baseFee = selectBigWithinBounds(ApricotPhase3MinBaseFee, baseFee, ApricotPhase3MaxBaseFee)
-	}
+	baseFee = selectBigWithinBounds(feeConfig.MinBaseFee, baseFee, feeConfig.MaxBaseFee)
 
 	return newRollupWindow, baseFee, nil
 }
 
 // EstiamteNextBaseFee attempts to estimate the next base fee based on a block with [parent] being built at
-// [timestamp].
+// [timestamp], using [feeConfig], the fee config in force at [parent].
 // If [timestamp] is less than the timestamp of [parent], then it uses the same timestamp as parent.
 // Warning: This function should only be used in estimation and should not be used when calculating the canonical
 // base fee for a subsequent block.
-func EstimateNextBaseFee(config *params.ChainConfig, parent *types.Header, timestamp uint64) ([]byte, *big.Int, error) {
+func EstimateNextBaseFee(config *params.ChainConfig, feeConfig commontype.FeeConfig, parent *types.Header, timestamp uint64) ([]byte, *big.Int, error) {
 	if timestamp < parent.Time {
 		timestamp = parent.Time
 	}
-	return CalcBaseFee(config, parent, timestamp)
+	return CalcBaseFee(config, feeConfig, parent, timestamp)
 }
 
 // selectBigWithinBounds returns [value] if it is within the bounds:
@@ -319,6 +296,18 @@ func calcBlockGasCost(
 	return blockGasCost
 }
 
+// blockGasCostStep returns the step of the block gas cost of a block with
+// [timestamp], given [feeConfig], the fee config in force at [parent].
+// Chains following the fee config of the Apricot phases use the step of the
+// phase active at [timestamp] rather than at [parent], such that the step of
+// Apricot Phase 5 applies from its first block.
+func blockGasCostStep(config *params.ChainConfig, feeConfig commontype.FeeConfig, parent *types.Header, timestamp uint64) *big.Int {
+	if config.FeeConfig != nil || config.IsPrecompileEnabled(precompile.FeeConfigManagerAddress, new(big.Int).SetUint64(parent.Time)) {
+		return feeConfig.BlockGasCostStep
+	}
+	return config.GetFeeConfig(new(big.Int).SetUint64(timestamp)).BlockGasCostStep
+}
+
 // MinRequiredTip is the estimated minimum tip a transaction would have
 // needed to pay to be included in a given block (assuming it paid a tip
 // proportional to its gas usage). In reality, there is no minimum tip that
@@ -203,7 +203,7 @@ func testDynamicFeesStaysWithinRange(t *testing.T, test test) {
 	}
 
 	for index, block := range blocks[1:] {
-		nextExtraData, nextBaseFee, err := CalcBaseFee(params.TestApricotPhase3Config, header, block.timestamp)
+		nextExtraData, nextBaseFee, err := CalcBaseFee(params.TestApricotPhase3Config, params.ApricotPhase3FeeConfig, header, block.timestamp)
 		if err != nil {
 			t.Fatalf("Failed to calculate base fee at index %d: %s", index, err)
 		}
@@ -408,7 +408,7 @@ func TestCalcBaseFeeAP4(t *testing.T) {
 
 	for index, event := range events {
 		block := event.block
-		nextExtraData, nextBaseFee, err := CalcBaseFee(params.TestApricotPhase4Config, header, block.timestamp)
+		nextExtraData, nextBaseFee, err := CalcBaseFee(params.TestApricotPhase4Config, params.ApricotPhase4FeeConfig, header, block.timestamp)
 		assert.NoError(t, err)
 		log.Info("Update", "baseFee", nextBaseFee)
 		header = &types.Header{
@@ -419,7 +419,7 @@ func TestCalcBaseFeeAP4(t *testing.T) {
 			Extra:   nextExtraData,
 		}
 
-		nextExtraData, nextBaseFee, err = CalcBaseFee(params.TestApricotPhase4Config, extDataHeader, block.timestamp)
+		nextExtraData, nextBaseFee, err = CalcBaseFee(params.TestApricotPhase4Config, params.ApricotPhase4FeeConfig, extDataHeader, block.timestamp)
 		assert.NoError(t, err)
 		log.Info("Update", "baseFee (w/extData)", nextBaseFee)
 		extDataHeader = &types.Header{
@@ -446,7 +446,7 @@ func TestCalcBlockGasCost(t *testing.T) {
 			parentBlockGasCost: nil,
 			parentTime:         1,
 			currentTime:        1,
-			expected:           ApricotPhase4MinBlockGasCost,
+			expected:           params.ApricotPhase4FeeConfig.MinBlockGasCost,
 		},
 		"Same timestamp from 0": {
 			parentBlockGasCost: big.NewInt(0),
@@ -525,10 +525,10 @@ func TestCalcBlockGasCost(t *testing.T) {
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			assert.Zero(t, test.expected.Cmp(calcBlockGasCost(
-				ApricotPhase4TargetBlockRate,
-				ApricotPhase4MinBlockGasCost,
-				ApricotPhase4MaxBlockGasCost,
-				ApricotPhase4BlockGasCostStep,
+				params.ApricotPhase4FeeConfig.TargetBlockRate,
+				params.ApricotPhase4FeeConfig.MinBlockGasCost,
+				params.ApricotPhase4FeeConfig.MaxBlockGasCost,
+				params.ApricotPhase4FeeConfig.BlockGasCostStep,
 				test.parentBlockGasCost,
 				test.parentTime,
 				test.currentTime,
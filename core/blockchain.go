@@ -41,6 +41,7 @@ import (
 	"github.com/ava-labs/coreth/consensus"
 	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/state"
+	"github.com/ava-labs/coreth/core/state/pruner"
 	"github.com/ava-labs/coreth/core/state/snapshot"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/core/vm"
@@ -50,25 +51,33 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	lru "github.com/hashicorp/golang-lru"
 )
 
 var (
+	acceptorQueueGauge = metrics.NewRegisteredGauge("chain/acceptor/queue/size", nil)
+
 	removeTxIndicesKey = []byte("removed_tx_indices")
 
 	ErrRefuseToCorruptArchiver = errors.New("node has operated with pruning disabled, shutting down to prevent missing tries")
 
 	errFutureBlockUnsupported  = errors.New("future block insertion not supported")
 	errCacheConfigNotSpecified = errors.New("must specify cache config")
+	errInvalidPruningConfig    = errors.New("pruning requires a positive commit interval and tip buffer size")
+	errInvalidStateDiffsConfig = errors.New("state diffs require pruning and snapshots")
+	errOnlinePruningDisabled   = errors.New("online pruning requires pruning to be enabled without state diffs")
+	errStateDiffsPruning       = errors.New("state diffs cannot be enabled while an online pruning is in progress")
 )
 
 const (
-	bodyCacheLimit     = 256
-	blockCacheLimit    = 256
-	receiptsCacheLimit = 32
-	txLookupCacheLimit = 1024
-	badBlockLimit      = 10
-	TriesInMemory      = 128
+	bodyCacheLimit      = 256
+	blockCacheLimit     = 256
+	receiptsCacheLimit  = 32
+	txLookupCacheLimit  = 1024
+	feeConfigCacheLimit = 256
+	badBlockLimit       = 10
+	TriesInMemory       = 128
 
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
 	//
@@ -103,22 +112,37 @@ const (
 // CacheConfig contains the configuration values for the trie caching/pruning
 // that's resident in a blockchain.
 type CacheConfig struct {
-	TrieCleanLimit                  int     // Memory allowance (MB) to use for caching trie nodes in memory
-	TrieDirtyLimit                  int     // Memory limit (MB) at which to start flushing dirty trie nodes to disk
-	Pruning                         bool    // Whether to disable trie write caching and GC altogether (archive node)
-	PopulateMissingTries            *uint64 // If non-nil, sets the starting height for re-generating historical tries.
-	PopulateMissingTriesParallelism int     // Is the number of readers to use when trying to populate missing tries.
-	AllowMissingTries               bool    // Whether to allow an archive node to run with pruning enabled
-	SnapshotLimit                   int     // Memory allowance (MB) to use for caching snapshot entries in memory
-	SnapshotAsync                   bool    // Generate snapshot tree async
-	SnapshotVerify                  bool    // Verify generated snapshots
-	Preimages                       bool    // Whether to store preimage of trie key to the disk
+	TrieCleanLimit                  int           // Memory allowance (MB) to use for caching trie nodes in memory
+	TrieDirtyLimit                  int           // Memory limit (MB) at which to start flushing dirty trie nodes to disk
+	TrieDirtyCommitTarget           int           // Memory limit (MB) to target for the dirty trie nodes when a trie is committed
+	Pruning                         bool          // Whether to disable trie write caching and GC altogether (archive node)
+	CommitInterval                  uint64        // Number of accepted blocks between trie commits when pruning
+	SyncableInterval                uint64        // Blocks at heights divisible by this interval are always committed when pruning to serve state sync (0 = disabled)
+	TipBufferSize                   int           // Number of recent accepted state roots kept in memory when pruning
+	PopulateMissingTries            *uint64       // If non-nil, sets the starting height for re-generating historical tries.
+	PopulateMissingTriesParallelism int           // Is the number of readers to use when trying to populate missing tries.
+	AllowMissingTries               bool          // Whether to allow an archive node to run with pruning enabled
+	SnapshotLimit                   int           // Memory allowance (MB) to use for caching snapshot entries in memory
+	SnapshotAsync                   bool          // Generate snapshot tree async
+	SnapshotVerify                  bool          // Verify generated snapshots
+	Preimages                       bool          // Whether to store preimage of trie key to the disk
+	AcceptorQueueLimit              int           // Number of accepted blocks queued for processing before Accept blocks
+	TxLookupLimit                   uint64        // Number of recent accepted blocks for which to maintain transaction lookup indices (0 = all blocks)
+	StateDiffs                      bool          // Whether to persist the state diff of each accepted block to serve historical state when pruning, which disables online pruning
+	OnlinePruningBloomSize          uint64        // Size (MB) of the bloom filter of the state kept by online pruning
+	OnlinePruningThrottle           time.Duration // Pause between the batches of trie nodes deleted by online pruning
 }
 
 var DefaultCacheConfig = &CacheConfig{
-	TrieCleanLimit: 256,
-	TrieDirtyLimit: 256,
-	SnapshotLimit:  256,
+	TrieCleanLimit:         256,
+	TrieDirtyLimit:         256,
+	TrieDirtyCommitTarget:  20,
+	CommitInterval:         4096,
+	TipBufferSize:          16,
+	SnapshotLimit:          256,
+	AcceptorQueueLimit:     64,
+	OnlinePruningBloomSize: 512,
+	OnlinePruningThrottle:  100 * time.Millisecond,
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -162,14 +186,19 @@ type BlockChain struct {
 
 	currentBlock atomic.Value // Current head of the block chain
 
-	stateCache    state.Database // State database to reuse between imports (contains state cache)
-	stateManager  TrieWriter
-	bodyCache     *lru.Cache // Cache for the most recent block bodies
-	receiptsCache *lru.Cache // Cache for the most recent receipts per block
-	blockCache    *lru.Cache // Cache for the most recent entire blocks
-	txLookupCache *lru.Cache // Cache for the most recent transaction lookup data.
+	stateCache     state.Database // State database to reuse between imports (contains state cache)
+	stateManager   TrieWriter
+	bodyCache      *lru.Cache // Cache for the most recent block bodies
+	receiptsCache  *lru.Cache // Cache for the most recent receipts per block
+	blockCache     *lru.Cache // Cache for the most recent entire blocks
+	txLookupCache  *lru.Cache // Cache for the most recent transaction lookup data.
+	feeConfigCache *lru.Cache // Cache for the fee config stored by the fee manager precompile per state root
+
+	onlinePruner *pruner.OnlinePruner // Online pruner of the stale trie nodes, nil unless pruning without state diffs
 
-	running int32 // 0 if chain is running, 1 when stopped
+	running int32          // 0 if chain is running, 1 when stopped
+	quit    chan struct{}  // shutdown signal, closed in Stop.
+	wg      sync.WaitGroup // chain processing wait group for shutting down
 
 	engine     consensus.Engine
 	validator  Validator  // Block and state validator interface
@@ -181,6 +210,28 @@ type BlockChain struct {
 
 	lastAccepted *types.Block // Prevents reorgs past this height
 
+	// [acceptorQueue] holds the accepted blocks whose side effects (trie and
+	// snapshot updates, tx lookup entries and accepted events) have not been
+	// processed yet. The blocks are processed in order by the acceptor
+	// goroutine, and all queued blocks are processed on a clean shutdown.
+	acceptorQueue chan *types.Block
+
+	// [acceptorClosingLock] synchronizes the closing of [acceptorQueue], which
+	// is marked by [acceptorClosed] since a closed channel cannot be detected
+	// without reading from it.
+	acceptorClosingLock sync.RWMutex
+	acceptorClosed      bool
+
+	// [acceptorWg] tracks the queued blocks to wait for the acceptor to catch
+	// up during shutdown, before consistent reads and in tests.
+	acceptorWg sync.WaitGroup
+
+	// [acceptorTip] is the last accepted block processed by the acceptor. It
+	// is returned by LastAcceptedBlock so that readers only observe accepted
+	// blocks whose side effects are complete, and may lag [lastAccepted].
+	acceptorTip     *types.Block
+	acceptorTipLock sync.Mutex
+
 	senderCacher *TxSenderCacher
 }
 
@@ -194,29 +245,44 @@ func NewBlockChain(
 	if cacheConfig == nil {
 		return nil, errCacheConfigNotSpecified
 	}
+	if cacheConfig.Pruning && (cacheConfig.CommitInterval == 0 || cacheConfig.TipBufferSize < 1) {
+		return nil, errInvalidPruningConfig
+	}
+	if cacheConfig.StateDiffs && (!cacheConfig.Pruning || cacheConfig.SnapshotLimit <= 0) {
+		return nil, errInvalidStateDiffsConfig
+	}
+	// Online pruning deletes the committed tries the state diffs are applied
+	// to, so a pruning interrupted by a shutdown cannot be resumed.
+	if _, pruning := rawdb.ReadOnlinePruning(db); cacheConfig.StateDiffs && pruning {
+		return nil, errStateDiffsPruning
+	}
 	bodyCache, _ := lru.New(bodyCacheLimit)
 	receiptsCache, _ := lru.New(receiptsCacheLimit)
 	blockCache, _ := lru.New(blockCacheLimit)
 	txLookupCache, _ := lru.New(txLookupCacheLimit)
+	feeConfigCache, _ := lru.New(feeConfigCacheLimit)
 	badBlocks, _ := lru.New(badBlockLimit)
 
 	bc := &BlockChain{
-		chainConfig: chainConfig,
-		cacheConfig: cacheConfig,
-		db:          db,
-		stateCache: state.NewDatabaseWithConfig(db, &trie.Config{
-			Cache:     cacheConfig.TrieCleanLimit,
-			Preimages: cacheConfig.Preimages,
-		}),
-		bodyCache:     bodyCache,
-		receiptsCache: receiptsCache,
-		blockCache:    blockCache,
-		txLookupCache: txLookupCache,
-		engine:        engine,
-		vmConfig:      vmConfig,
-		badBlocks:     badBlocks,
-		senderCacher:  newTxSenderCacher(runtime.NumCPU()),
-	}
+		chainConfig:    chainConfig,
+		cacheConfig:    cacheConfig,
+		db:             db,
+		bodyCache:      bodyCache,
+		receiptsCache:  receiptsCache,
+		blockCache:     blockCache,
+		txLookupCache:  txLookupCache,
+		feeConfigCache: feeConfigCache,
+		engine:         engine,
+		vmConfig:       vmConfig,
+		badBlocks:      badBlocks,
+		senderCacher:   newTxSenderCacher(runtime.NumCPU()),
+		acceptorQueue:  make(chan *types.Block, cacheConfig.AcceptorQueueLimit),
+		quit:           make(chan struct{}),
+	}
+	if cacheConfig.Pruning && !cacheConfig.StateDiffs {
+		bc.onlinePruner = pruner.NewOnlinePruner(db, cacheConfig.OnlinePruningBloomSize, cacheConfig.OnlinePruningThrottle)
+	}
+	bc.stateCache = bc.newStateCache()
 	bc.validator = NewBlockValidator(chainConfig, bc, engine)
 	bc.prefetcher = newStatePrefetcher(chainConfig, bc, engine)
 	bc.processor = NewStateProcessor(chainConfig, bc, engine)
@@ -241,6 +307,8 @@ func NewBlockChain(
 	if err := bc.loadLastState(lastAcceptedHash); err != nil {
 		return nil, err
 	}
+	// The side effects of all accepted blocks are written by loadLastState
+	bc.acceptorTip = bc.lastAccepted
 
 	// Make sure the state associated with the block is available
 	head := bc.CurrentBlock()
@@ -270,9 +338,311 @@ func NewBlockChain(
 		}
 	}
 
+	// Start processing the side effects of accepted blocks in the background
+	go bc.startAcceptor()
+
+	// Start the indexer keeping the tx lookup entries of the last
+	// [TxLookupLimit] accepted blocks, unless the transactions of all blocks
+	// should be and are already indexed.
+	if tail := rawdb.ReadTxIndexTail(bc.db); bc.cacheConfig.TxLookupLimit != 0 || (tail != nil && *tail > 0) {
+		bc.wg.Add(1)
+		go bc.maintainTxIndex()
+	}
+
 	return bc, nil
 }
 
+// startAcceptor processes the blocks added to [acceptorQueue] in order until
+// the queue is closed by stopAcceptor.
+func (bc *BlockChain) startAcceptor() {
+	log.Info("Starting acceptor", "queueLimit", bc.cacheConfig.AcceptorQueueLimit)
+
+	for next := range bc.acceptorQueue {
+		acceptorQueueGauge.Dec(1)
+
+		if err := bc.acceptTrieAndFlatten(next); err != nil {
+			log.Crit("Failed to process accepted block", "number", next.NumberU64(), "hash", next.Hash(), "err", err)
+		}
+		if err := bc.writeBlockAcceptedIndices(next); err != nil {
+			log.Crit("Failed to write accepted block indices", "number", next.NumberU64(), "hash", next.Hash(), "err", err)
+		}
+
+		// Fetch block logs
+		logs := bc.gatherBlockLogs(next.Hash(), next.NumberU64(), false)
+
+		// Update accepted feeds
+		bc.chainAcceptedFeed.Send(ChainEvent{Block: next, Hash: next.Hash(), Logs: logs})
+		if len(logs) > 0 {
+			bc.logsAcceptedFeed.Send(logs)
+		}
+		if len(next.Transactions()) != 0 {
+			bc.txAcceptedFeed.Send(NewTxsEvent{next.Transactions()})
+		}
+
+		bc.acceptorTipLock.Lock()
+		bc.acceptorTip = next
+		bc.acceptorTipLock.Unlock()
+		bc.acceptorWg.Done()
+	}
+}
+
+// addAcceptorQueue adds [b] to [acceptorQueue], blocking while the queue is
+// full. Blocks added after the acceptor is stopped are dropped.
+func (bc *BlockChain) addAcceptorQueue(b *types.Block) {
+	// Only a read lock is needed since adding blocks concurrently is safe.
+	bc.acceptorClosingLock.RLock()
+	defer bc.acceptorClosingLock.RUnlock()
+
+	if bc.acceptorClosed {
+		return
+	}
+
+	acceptorQueueGauge.Inc(1)
+	bc.acceptorWg.Add(1)
+	bc.acceptorQueue <- b
+}
+
+// DrainAcceptorQueue blocks until all the blocks in [acceptorQueue] have been
+// processed, so that the side effects of every block accepted so far are
+// visible.
+func (bc *BlockChain) DrainAcceptorQueue() {
+	bc.acceptorClosingLock.Lock()
+	defer bc.acceptorClosingLock.Unlock()
+
+	if bc.acceptorClosed {
+		return
+	}
+
+	bc.acceptorWg.Wait()
+}
+
+// stopAcceptor waits for the acceptor to process all the blocks in
+// [acceptorQueue] and stops it.
+func (bc *BlockChain) stopAcceptor() {
+	bc.acceptorClosingLock.Lock()
+	defer bc.acceptorClosingLock.Unlock()
+
+	// Closing [acceptorQueue] more than once would panic
+	if bc.acceptorClosed {
+		return
+	}
+
+	bc.acceptorWg.Wait()
+	bc.acceptorClosed = true
+	close(bc.acceptorQueue)
+}
+
+// acceptTrieAndFlatten marks the state of [b] as accepted in the trie database
+// and flattens the snapshot layers up to [b] into the disk layer.
+func (bc *BlockChain) acceptTrieAndFlatten(b *types.Block) error {
+	// Abort snapshot generation before pruning anything from trie database
+	// (could occur in AcceptTrie)
+	if bc.snaps != nil {
+		bc.snaps.AbortGeneration()
+	}
+
+	// Persist the state diff of the block while it is still held by a diff
+	// layer, so its state can be recovered once its trie is pruned.
+	if bc.cacheConfig.StateDiffs {
+		bc.writeStateDiff(b)
+	}
+
+	// Accept Trie
+	if err := bc.stateManager.AcceptTrie(b); err != nil {
+		return fmt.Errorf("unable to accept trie: %w", err)
+	}
+
+	// Start a requested online pruning, keeping the state of [b]
+	if bc.onlinePruner != nil && bc.onlinePruner.Pending() {
+		bc.startOnlinePruning(b)
+	}
+
+	// Flatten the entire snap Trie to disk
+	//
+	// Note: This resumes snapshot generation.
+	if bc.snaps != nil {
+		if err := bc.snaps.Flatten(b.Hash()); err != nil {
+			return fmt.Errorf("unable to flatten trie: %w", err)
+		}
+	}
+	return nil
+}
+
+// newStateCache creates the state database of the chain. When pruning, its
+// trie nodes are written through the online pruner, which keeps the nodes
+// written while it runs.
+func (bc *BlockChain) newStateCache() state.Database {
+	db := bc.db
+	if bc.onlinePruner != nil {
+		db = bc.onlinePruner.Database()
+	}
+	return state.NewDatabaseWithConfig(db, &trie.Config{
+		Cache:     bc.cacheConfig.TrieCleanLimit,
+		Preimages: bc.cacheConfig.Preimages,
+	})
+}
+
+// startOnlinePruning commits the state of the accepted block [b] to disk and
+// starts the requested online pruning keeping it. A pruning that fails to
+// start is cancelled rather than retried at every accepted block.
+func (bc *BlockChain) startOnlinePruning(b *types.Block) {
+	triedb := bc.stateCache.TrieDB()
+	err := triedb.Commit(b.Root(), true, nil)
+	if err == nil {
+		err = bc.onlinePruner.Start(b.Root(), triedb)
+	}
+	if err == nil {
+		return
+	}
+	log.Error("Failed to start online pruning", "number", b.NumberU64(), "hash", b.Hash(), "err", err)
+	if err := bc.onlinePruner.Cancel(); err != nil {
+		log.Error("Failed to cancel online pruning", "err", err)
+	}
+}
+
+// StartOnlinePruning requests an online pruning of the stale trie nodes, which
+// starts at the next accepted block.
+func (bc *BlockChain) StartOnlinePruning() error {
+	if bc.onlinePruner == nil {
+		return errOnlinePruningDisabled
+	}
+	return bc.onlinePruner.Request()
+}
+
+// StopOnlinePruning stops a running or requested online pruning.
+func (bc *BlockChain) StopOnlinePruning() error {
+	if bc.onlinePruner == nil {
+		return errOnlinePruningDisabled
+	}
+	return bc.onlinePruner.Cancel()
+}
+
+// OnlinePruningStatus returns the progress of the current or last online
+// pruning.
+func (bc *BlockChain) OnlinePruningStatus() (pruner.OnlinePruningStatus, error) {
+	if bc.onlinePruner == nil {
+		return pruner.OnlinePruningStatus{}, errOnlinePruningDisabled
+	}
+	return bc.onlinePruner.Status(), nil
+}
+
+// writeStateDiff persists the state diff of the accepted block [b]. A missing
+// diff only makes the state of the blocks after the previous committed trie
+// unavailable, so failures are logged rather than returned.
+func (bc *BlockChain) writeStateDiff(b *types.Block) {
+	if bc.snaps == nil {
+		log.Warn("Skipping state diff without snapshots", "number", b.NumberU64(), "hash", b.Hash())
+		return
+	}
+	diff, err := bc.snaps.StateDiff(b.Hash())
+	if err != nil {
+		log.Warn("Failed to read state diff", "number", b.NumberU64(), "hash", b.Hash(), "err", err)
+		return
+	}
+	rawdb.WriteStateDiff(bc.db, b.NumberU64(), diff)
+}
+
+// writeBlockAcceptedIndices writes the tx lookup entries of the accepted
+// block [b] and marks it as the acceptor tip.
+func (bc *BlockChain) writeBlockAcceptedIndices(b *types.Block) error {
+	batch := bc.db.NewBatch()
+	rawdb.WriteTxLookupEntriesByBlock(batch, b)
+	rawdb.WriteAcceptorTip(batch, b.Hash())
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("failed to write accepted indices batch: %w", err)
+	}
+	return nil
+}
+
+// maintainTxIndex is responsible for the construction and deletion of the
+// transaction index, so that only the transactions of the last
+// [TxLookupLimit] accepted blocks are indexed (all blocks if it is 0). The
+// index tail is stored in the database, so the work is resumed after a restart
+// and the missing blocks are re-indexed if the limit is raised.
+func (bc *BlockChain) maintainTxIndex() {
+	defer bc.wg.Done()
+
+	txLookupLimit := bc.cacheConfig.TxLookupLimit
+
+	// indexBlocks reindexes or unindexes transactions depending on user configuration
+	indexBlocks := func(tail *uint64, head uint64, done chan struct{}) {
+		defer func() { done <- struct{}{} }()
+
+		// If the user just upgraded to a version which supports transaction
+		// index pruning, write the new tail and remove anything older.
+		if tail == nil {
+			if txLookupLimit == 0 || head < txLookupLimit {
+				// Nothing to delete, write the tail and return
+				rawdb.WriteTxIndexTail(bc.db, 0)
+			} else {
+				// Prune all stale tx indices and record the tx index tail
+				rawdb.UnindexTransactions(bc.db, 0, head-txLookupLimit+1, bc.quit)
+			}
+			return
+		}
+		// If a previous indexing existed, make sure that we fill in any missing entries
+		if txLookupLimit == 0 || head < txLookupLimit {
+			if *tail > 0 {
+				// It can happen when chain is rewound to a historical point which
+				// is even lower than the indexes tail, recap the indexing target
+				// to new head to avoid reading non-existent block bodies.
+				end := *tail
+				if end > head+1 {
+					end = head + 1
+				}
+				rawdb.IndexTransactions(bc.db, 0, end, bc.quit)
+			}
+			return
+		}
+		// Update the transaction index to the new chain state
+		if head-txLookupLimit+1 < *tail {
+			// Reindex a part of missing indices and rewind index tail to HEAD-limit
+			rawdb.IndexTransactions(bc.db, head-txLookupLimit+1, *tail, bc.quit)
+		} else {
+			// Unindex a part of stale indices and forward index tail to HEAD-limit
+			rawdb.UnindexTransactions(bc.db, *tail, head-txLookupLimit+1, bc.quit)
+		}
+	}
+
+	// Bring the index in line with the configured limit at startup, then
+	// move the index window as blocks are accepted. Accepted events received
+	// while the index is being updated are coalesced into a single update to
+	// the most recent accepted block.
+	var (
+		done    = make(chan struct{})                // Non-nil if background unindexing or reindexing routine is active.
+		head    = bc.LastAcceptedBlock().NumberU64() // Height of the most recent accepted block
+		indexed = head                               // Height of the accepted block the index was last updated to
+		headCh  = make(chan ChainEvent, 1)           // Buffered to avoid locking up the event feed
+	)
+	sub := bc.SubscribeChainAcceptedEvent(headCh)
+	if sub == nil {
+		return
+	}
+	defer sub.Unsubscribe()
+
+	go indexBlocks(rawdb.ReadTxIndexTail(bc.db), head, done)
+
+	for {
+		select {
+		case event := <-headCh:
+			head = event.Block.NumberU64()
+		case <-done:
+			done = nil
+		case <-bc.quit:
+			if done != nil {
+				log.Info("Waiting background transaction indexer to exit")
+				<-done
+			}
+			return
+		}
+		if done == nil && head != indexed {
+			indexed = head
+			done = make(chan struct{})
+			go indexBlocks(rawdb.ReadTxIndexTail(bc.db), head, done)
+		}
+	}
+}
+
 // SenderCacher returns the *TxSenderCacher used within the core package.
 func (bc *BlockChain) SenderCacher() *TxSenderCacher {
 	return bc.senderCacher
@@ -339,10 +709,16 @@ func (bc *BlockChain) loadLastState(lastAcceptedHash common.Hash) error {
 		return fmt.Errorf("failed to set preference to last accepted block while loading last state: %w", err)
 	}
 
+	// Write the indices of the blocks accepted before the last shutdown that
+	// were not processed by the acceptor.
+	if err := bc.indexAcceptedBlocks(); err != nil {
+		return err
+	}
+
 	// reprocessState is necessary to ensure that the last accepted state is
 	// available. The state may not be available if it was not committed due
 	// to an unclean shutdown.
-	return bc.reprocessState(bc.lastAccepted, 2*commitInterval)
+	return bc.reprocessState(bc.lastAccepted, 2*bc.cacheConfig.CommitInterval)
 }
 
 // removeIndices removes all transaction lookup entries for the transactions contained in the canonical chain
@@ -366,6 +742,35 @@ func (bc *BlockChain) removeIndices(from, to uint64) (int, error) {
 	return indicesRemoved, nil
 }
 
+// indexAcceptedBlocks writes the indices of the canonical blocks above the
+// acceptor tip stored on disk, up to the last accepted block.
+func (bc *BlockChain) indexAcceptedBlocks() error {
+	acceptorTip := rawdb.ReadAcceptorTip(bc.db)
+	if acceptorTip == (common.Hash{}) || acceptorTip == bc.lastAccepted.Hash() {
+		// Databases written before blocks were processed asynchronously have
+		// no acceptor tip, but their accepted blocks are all indexed.
+		return nil
+	}
+	tipHeader := bc.GetHeaderByHash(acceptorTip)
+	if tipHeader == nil {
+		return fmt.Errorf("could not load acceptor tip %s", acceptorTip.Hex())
+	}
+	from, to := tipHeader.Number.Uint64()+1, bc.lastAccepted.NumberU64()
+	if from <= to {
+		log.Info("Writing indices of accepted blocks", "from", from, "to", to)
+	}
+	for number := from; number <= to; number++ {
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			return fmt.Errorf("could not load canonical block at height %d", number)
+		}
+		if err := bc.writeBlockAcceptedIndices(block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (bc *BlockChain) loadGenesisState() error {
 	// Prepare the genesis block and reinitialise the chain
 	batch := bc.db.NewBatch()
@@ -440,6 +845,9 @@ func (bc *BlockChain) writeHeadBlock(block *types.Block) {
 
 // ValidateCanonicalChain confirms a canonical chain is well-formed.
 func (bc *BlockChain) ValidateCanonicalChain() error {
+	// Ensure all accepted blocks are indexed before checking their transactions
+	bc.DrainAcceptorQueue()
+
 	current := bc.CurrentBlock()
 	i := 0
 	log.Info("Beginning to validate canonical chain", "startBlock", current.NumberU64())
@@ -538,6 +946,20 @@ func (bc *BlockChain) Stop() {
 		return
 	}
 
+	// Wait for accepted blocks to be processed and stop the acceptor
+	log.Info("Closing acceptor queue")
+	bc.stopAcceptor()
+
+	// Stop the transaction indexer once the accepted blocks are indexed
+	close(bc.quit)
+	bc.wg.Wait()
+
+	// Stop a running online pruning, which resumes after a restart
+	if bc.onlinePruner != nil {
+		log.Info("Stopping online pruning")
+		bc.onlinePruner.Stop()
+	}
+
 	log.Info("Shutting down state manager")
 	if err := bc.stateManager.Shutdown(); err != nil {
 		log.Error("Failed to Shutdown state manager", "err", err)
@@ -598,17 +1020,29 @@ func (bc *BlockChain) setPreference(block *types.Block) error {
 	return nil
 }
 
-// LastAcceptedBlock returns the last block to be marked as accepted.
-func (bc *BlockChain) LastAcceptedBlock() *types.Block {
+// LastConsensusAcceptedBlock returns the last block to be marked as accepted.
+// Its side effects may not have been processed yet.
+func (bc *BlockChain) LastConsensusAcceptedBlock() *types.Block {
 	bc.chainmu.Lock()
 	defer bc.chainmu.Unlock()
 
 	return bc.lastAccepted
 }
 
+// LastAcceptedBlock returns the last block to be marked as accepted whose
+// side effects (tx lookup entries, accepted events) have been processed.
+func (bc *BlockChain) LastAcceptedBlock() *types.Block {
+	bc.acceptorTipLock.Lock()
+	defer bc.acceptorTipLock.Unlock()
+
+	return bc.acceptorTip
+}
+
 // Accept sets a minimum height at which no reorg can pass. Additionally,
 // this function may trigger a reorg if the block being accepted is not in the
 // canonical chain.
+// The side effects of accepting [block] are processed asynchronously by the
+// acceptor, see DrainAcceptorQueue.
 //
 // Assumes [bc.chainmu] is not held by the caller.
 func (bc *BlockChain) Accept(block *types.Block) error {
@@ -637,43 +1071,7 @@ func (bc *BlockChain) Accept(block *types.Block) error {
 	}
 
 	bc.lastAccepted = block
-
-	// Abort snapshot generation before pruning anything from trie database
-	// (could occur in AcceptTrie)
-	if bc.snaps != nil {
-		bc.snaps.AbortGeneration()
-	}
-
-	// Accept Trie
-	if err := bc.stateManager.AcceptTrie(block); err != nil {
-		return fmt.Errorf("unable to accept trie: %w", err)
-	}
-
-	// Flatten the entire snap Trie to disk
-	if bc.snaps != nil {
-		if err := bc.snaps.Flatten(block.Hash()); err != nil {
-			return fmt.Errorf("unable to flatten trie: %w", err)
-		}
-	}
-
-	// Update transaction lookup index
-	batch := bc.db.NewBatch()
-	rawdb.WriteTxLookupEntriesByBlock(batch, block)
-	if err := batch.Write(); err != nil {
-		return fmt.Errorf("failed to write tx lookup entries batch: %w", err)
-	}
-
-	// Fetch block logs
-	logs := bc.gatherBlockLogs(block.Hash(), block.NumberU64(), false)
-
-	// Update accepted feeds
-	bc.chainAcceptedFeed.Send(ChainEvent{Block: block, Hash: block.Hash(), Logs: logs})
-	if len(logs) > 0 {
-		bc.logsAcceptedFeed.Send(logs)
-	}
-	if len(block.Transactions()) != 0 {
-		bc.txAcceptedFeed.Send(NewTxsEvent{block.Transactions()})
-	}
+	bc.addAcceptorQueue(block)
 
 	return nil
 }
@@ -1444,8 +1842,10 @@ func (bc *BlockChain) CleanBlockRootsAboveLastAccepted() error {
 // consensus engine will reject the lowest ancestor first. In this case, these blocks will not be considered acceptable in
 // the future.
 // Ex.
-//    A
-//  /   \
+//
+//	  A
+//	/   \
+//
 // B     C
 // |
 // D
@@ -1489,26 +1889,38 @@ func (bc *BlockChain) ResetState(block *types.Block) error {
 	bc.chainmu.Lock()
 	defer bc.chainmu.Unlock()
 
-	// Update head block and snapshot pointers on disk
+	// Finish processing the blocks accepted before the state is replaced
+	bc.DrainAcceptorQueue()
+
+	// Stop generating the snapshot of the replaced state
+	if bc.snaps != nil {
+		bc.snaps.AbortGeneration()
+	}
+
+	// Update head block pointers on disk. The blocks below [block] are not
+	// indexed, so it becomes the acceptor tip. The snapshot pointers are
+	// removed, since state sync does not write the snapshot of the synced
+	// state, so that the snapshot is regenerated from its trie.
 	batch := bc.db.NewBatch()
 	rawdb.WriteHeadBlockHash(batch, block.Hash())
 	rawdb.WriteHeadHeaderHash(batch, block.Hash())
-	rawdb.WriteSnapshotBlockHash(batch, block.Hash())
-	rawdb.WriteSnapshotRoot(batch, block.Root())
+	rawdb.WriteAcceptorTip(batch, block.Hash())
+	rawdb.DeleteSnapshotBlockHash(batch)
+	rawdb.DeleteSnapshotRoot(batch)
 	if err := batch.Write(); err != nil {
 		return err
 	}
 
 	// Update all in-memory chain markers
 	bc.lastAccepted = block
+	bc.acceptorTipLock.Lock()
+	bc.acceptorTip = block
+	bc.acceptorTipLock.Unlock()
 	bc.currentBlock.Store(block)
 	bc.hc.SetCurrentHeader(block.Header())
 
 	lastAcceptedHash := block.Hash()
-	bc.stateCache = state.NewDatabaseWithConfig(bc.db, &trie.Config{
-		Cache:     bc.cacheConfig.TrieCleanLimit,
-		Preimages: bc.cacheConfig.Preimages,
-	})
+	bc.stateCache = bc.newStateCache()
 	if err := bc.loadLastState(lastAcceptedHash); err != nil {
 		return err
 	}
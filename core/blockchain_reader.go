@@ -27,6 +27,10 @@
 package core
 
 import (
+	"fmt"
+	"math/big"
+
+	"github.com/ava-labs/coreth/commontype"
 	"github.com/ava-labs/coreth/consensus"
 	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/state"
@@ -34,6 +38,7 @@ import (
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/core/vm"
 	"github.com/ava-labs/coreth/params"
+	"github.com/ava-labs/coreth/precompile"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/event"
 )
@@ -257,9 +262,80 @@ func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
 	return state.New(root, bc.stateCache, bc.snaps)
 }
 
+// HistoricalStateAt returns a new mutable state at [header]. If the trie of
+// [header] was pruned and state diffs are enabled, the state is rebuilt from
+// the nearest preceding retained trie and the state diffs of the accepted
+// blocks since, applying at most twice the commit interval of diffs.
+func (bc *BlockChain) HistoricalStateAt(header *types.Header) (*state.StateDB, error) {
+	if !bc.cacheConfig.StateDiffs || bc.HasState(header.Root) {
+		return bc.StateAt(header.Root)
+	}
+	number := header.Number.Uint64()
+	if number > bc.LastAcceptedBlock().NumberU64() || bc.GetCanonicalHash(number) != header.Hash() {
+		return nil, fmt.Errorf("missing trie for non-accepted block %d (%s)", number, header.Hash())
+	}
+	var (
+		diffs    [][]byte
+		current  = header
+		maxDepth = 2 * bc.cacheConfig.CommitInterval
+	)
+	for {
+		if uint64(len(diffs)) >= maxDepth {
+			return nil, fmt.Errorf("required historical state unavailable within %d state diffs of block %d", maxDepth, number)
+		}
+		diff := rawdb.ReadStateDiff(bc.db, current.Number.Uint64())
+		if len(diff) == 0 {
+			return nil, fmt.Errorf("missing state diff for block %d to rebuild the state of block %d", current.Number.Uint64(), number)
+		}
+		diffs = append(diffs, diff)
+
+		parent := bc.GetHeader(current.ParentHash, current.Number.Uint64()-1)
+		if parent == nil {
+			return nil, fmt.Errorf("missing header %d (%s)", current.Number.Uint64()-1, current.ParentHash)
+		}
+		current = parent
+		if bc.HasState(current.Root) {
+			break
+		}
+	}
+	// Apply the diffs oldest first
+	for i, j := 0, len(diffs)-1; i < j; i, j = i+1, j-1 {
+		diffs[i], diffs[j] = diffs[j], diffs[i]
+	}
+	return state.NewWithStateDiffs(header.Root, current.Root, bc.stateCache, diffs)
+}
+
 // Config retrieves the chain's fork configuration.
 func (bc *BlockChain) Config() *params.ChainConfig { return bc.chainConfig }
 
+// GetFeeConfigAt returns the fee config in force for the children of [parent].
+func (bc *BlockChain) GetFeeConfigAt(parent *types.Header) (commontype.FeeConfig, error) {
+	if cached, ok := bc.feeConfigCache.Get(parent.Root); ok {
+		return cached.(commontype.FeeConfig), nil
+	}
+	feeConfig, err := getFeeConfigAt(bc.chainConfig, parent, bc.StateAt)
+	if err != nil {
+		return commontype.FeeConfig{}, err
+	}
+	bc.feeConfigCache.Add(parent.Root, feeConfig)
+	return feeConfig, nil
+}
+
+// getFeeConfigAt returns the fee config of [config] in force for the children
+// of [parent]. The state of [parent] is only opened with [stateAt] once the fee
+// manager precompile is enabled.
+func getFeeConfigAt(config *params.ChainConfig, parent *types.Header, stateAt func(common.Hash) (*state.StateDB, error)) (commontype.FeeConfig, error) {
+	timestamp := new(big.Int).SetUint64(parent.Time)
+	if !config.IsPrecompileEnabled(precompile.FeeConfigManagerAddress, timestamp) {
+		return config.GetFeeConfig(timestamp), nil
+	}
+	statedb, err := stateAt(parent.Root)
+	if err != nil {
+		return commontype.FeeConfig{}, fmt.Errorf("could not read fee config at block %s: %w", parent.Hash(), err)
+	}
+	return config.GetActiveFeeConfig(statedb, timestamp), nil
+}
+
 // Engine retrieves the blockchain's consensus engine.
 func (bc *BlockChain) Engine() consensus.Engine { return bc.engine }
 
@@ -571,7 +571,9 @@ func testRepair(t *testing.T, tt *rewindTest, snapshots bool) {
 		t.Fatalf("Failed to import canonical chain tail: %v", err)
 	}
 
-	// Pull the plug on the database, simulating a hard crash
+	// Pull the plug on the database, simulating a hard crash after the
+	// accepted blocks were processed
+	chain.DrainAcceptorQueue()
 	db.Close()
 
 	// Start a new blockchain back up and see where the repait leads us
@@ -116,6 +116,7 @@ func (basic *snapshotTestBasic) prepare(t *testing.T) (*BlockChain, []*types.Blo
 				}
 				basic.lastAcceptedHash = blocks[i].Hash()
 			}
+			chain.DrainAcceptorQueue()
 
 			diskRoot, blockRoot := chain.snaps.DiskRoot(), blocks[point-1].Root()
 			if !bytes.Equal(diskRoot.Bytes(), blockRoot.Bytes()) {
@@ -356,6 +357,7 @@ func (snaptest *restartCrashSnapshotTest) test(t *testing.T) {
 		}
 		snaptest.lastAcceptedHash = newBlocks[i].Hash()
 	}
+	newchain.DrainAcceptorQueue()
 
 	// Simulate the blockchain crash
 	// Don't call chain.Stop here, so that no snapshot
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ava-labs/coreth/consensus/dummy"
 	"github.com/ava-labs/coreth/core/rawdb"
@@ -15,24 +16,30 @@ import (
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/core/vm"
 	"github.com/ava-labs/coreth/ethdb"
+	"github.com/ava-labs/coreth/ethdb/memorydb"
 	"github.com/ava-labs/coreth/params"
+	"github.com/ava-labs/coreth/trie"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
 var (
 	archiveConfig = &CacheConfig{
-		TrieCleanLimit: 256,
-		TrieDirtyLimit: 256,
-		Pruning:        false, // Archive mode
-		SnapshotLimit:  256,
+		TrieCleanLimit:     256,
+		TrieDirtyLimit:     256,
+		Pruning:            false, // Archive mode
+		SnapshotLimit:      256,
+		AcceptorQueueLimit: 64,
 	}
 
 	pruningConfig = &CacheConfig{
-		TrieCleanLimit: 256,
-		TrieDirtyLimit: 256,
-		Pruning:        true, // Enable pruning
-		SnapshotLimit:  256,
+		TrieCleanLimit:     256,
+		TrieDirtyLimit:     256,
+		Pruning:            true, // Enable pruning
+		CommitInterval:     4096,
+		TipBufferSize:      16,
+		SnapshotLimit:      256,
+		AcceptorQueueLimit: 64,
 	}
 )
 
@@ -114,7 +121,9 @@ func TestPruningBlockChainSnapsDisabled(t *testing.T) {
 				TrieCleanLimit: 256,
 				TrieDirtyLimit: 256,
 				Pruning:        true, // Enable pruning
-				SnapshotLimit:  0,    // Disable snapshots
+				CommitInterval: 4096,
+				TipBufferSize:  16,
+				SnapshotLimit:  0, // Disable snapshots
 			},
 			chainConfig,
 			lastAcceptedHash,
@@ -160,7 +169,9 @@ func TestPruningBlockChainUngracefulShutdownSnapsDisabled(t *testing.T) {
 				TrieCleanLimit: 256,
 				TrieDirtyLimit: 256,
 				Pruning:        true, // Enable pruning
-				SnapshotLimit:  0,    // Disable snapshots
+				CommitInterval: 4096,
+				TipBufferSize:  16,
+				SnapshotLimit:  0, // Disable snapshots
 			},
 			chainConfig,
 			lastAcceptedHash,
@@ -191,7 +202,9 @@ func TestEnableSnapshots(t *testing.T) {
 			&CacheConfig{
 				TrieCleanLimit: 256,
 				TrieDirtyLimit: 256,
-				Pruning:        true,      // Enable pruning
+				Pruning:        true, // Enable pruning
+				CommitInterval: 4096,
+				TipBufferSize:  16,
 				SnapshotLimit:  snapLimit, // Disable snapshots
 			},
 			chainConfig,
@@ -315,6 +328,8 @@ func testRepopulateMissingTriesParallel(t *testing.T, parallelism int) {
 		}
 	}
 
+	blockchain.DrainAcceptorQueue()
+
 	lastAcceptedHash = blockchain.LastAcceptedBlock().Hash()
 	blockchain.Stop()
 
@@ -363,3 +378,545 @@ func TestRepopulateMissingTries(t *testing.T) {
 		testRepopulateMissingTriesParallel(t, parallelism)
 	}
 }
+
+func TestAcceptorQueue(t *testing.T) {
+	var (
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2   = crypto.PubkeyToAddress(key2.PublicKey)
+		genDB   = rawdb.NewMemoryDatabase()
+		chainDB = rawdb.NewMemoryDatabase()
+	)
+	gspec := &Genesis{
+		Config: &params.ChainConfig{ChainID: big.NewInt(1), HomesteadBlock: new(big.Int)},
+		Alloc:  GenesisAlloc{addr1: {Balance: big.NewInt(1000000)}},
+	}
+	genesis := gspec.MustCommit(genDB)
+	_ = gspec.MustCommit(chainDB)
+
+	blockchain, err := createBlockChain(chainDB, pruningConfig, gspec.Config, common.Hash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+
+	signer := types.HomesteadSigner{}
+	chain, _, err := GenerateChain(gspec.Config, genesis, blockchain.engine, genDB, 10, 10, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr1), addr2, big.NewInt(10000), params.TxGas, nil, nil), signer, key1)
+		gen.AddTxWithChain(blockchain, tx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatal(err)
+	}
+
+	acceptedCh := make(chan ChainEvent, len(chain))
+	sub := blockchain.SubscribeChainAcceptedEvent(acceptedCh)
+	defer sub.Unsubscribe()
+	for _, block := range chain {
+		if err := blockchain.Accept(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lastAccepted := chain[len(chain)-1]
+	if have := blockchain.LastConsensusAcceptedBlock().Hash(); have != lastAccepted.Hash() {
+		t.Fatalf("expected last consensus accepted block %s, have %s", lastAccepted.Hash(), have)
+	}
+
+	// Once the queue is drained, the side effects of all accepted blocks are
+	// visible and the accepted events have been delivered in order.
+	blockchain.DrainAcceptorQueue()
+	if have := blockchain.LastAcceptedBlock().Hash(); have != lastAccepted.Hash() {
+		t.Fatalf("expected last accepted block %s, have %s", lastAccepted.Hash(), have)
+	}
+	if have := rawdb.ReadAcceptorTip(chainDB); have != lastAccepted.Hash() {
+		t.Fatalf("expected acceptor tip %s, have %s", lastAccepted.Hash(), have)
+	}
+	for _, block := range chain {
+		event := <-acceptedCh
+		if event.Hash != block.Hash() {
+			t.Fatalf("expected accepted event for block %d, have block %d", block.NumberU64(), event.Block.NumberU64())
+		}
+		for _, tx := range block.Transactions() {
+			if blockchain.GetTransactionLookup(tx.Hash()) == nil {
+				t.Fatalf("missing tx lookup entry for tx %s of block %d", tx.Hash(), block.NumberU64())
+			}
+		}
+	}
+	blockchain.Stop()
+
+	// Simulate a shutdown before the acceptor processed the last blocks: the
+	// missing indices are written on restart.
+	rawdb.WriteAcceptorTip(chainDB, chain[4].Hash())
+	for _, block := range chain[5:] {
+		for _, tx := range block.Transactions() {
+			rawdb.DeleteTxLookupEntry(chainDB, tx.Hash())
+		}
+	}
+	blockchain, err = createBlockChain(chainDB, pruningConfig, gspec.Config, lastAccepted.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+
+	if have := rawdb.ReadAcceptorTip(chainDB); have != lastAccepted.Hash() {
+		t.Fatalf("expected acceptor tip %s after restart, have %s", lastAccepted.Hash(), have)
+	}
+	for _, block := range chain {
+		for _, tx := range block.Transactions() {
+			if rawdb.ReadTxLookupEntry(chainDB, tx.Hash()) == nil {
+				t.Fatalf("missing tx lookup entry for tx %s of block %d after restart", tx.Hash(), block.NumberU64())
+			}
+		}
+	}
+}
+
+func TestTransactionIndices(t *testing.T) {
+	var (
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2   = crypto.PubkeyToAddress(key2.PublicKey)
+		genDB   = rawdb.NewMemoryDatabase()
+		chainDB = rawdb.NewMemoryDatabase()
+	)
+	gspec := &Genesis{
+		Config: &params.ChainConfig{ChainID: big.NewInt(1), HomesteadBlock: new(big.Int)},
+		Alloc:  GenesisAlloc{addr1: {Balance: big.NewInt(1000000)}},
+	}
+	genesis := gspec.MustCommit(genDB)
+	_ = gspec.MustCommit(chainDB)
+
+	var chain []*types.Block
+
+	// check waits for the indexer to move the index tail to [tail] and
+	// verifies that exactly the transactions from [tail] are indexed.
+	check := func(tail uint64) {
+		t.Helper()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			stored := rawdb.ReadTxIndexTail(chainDB)
+			if stored != nil && *stored == tail {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected tx index tail %d, have %v", tail, stored)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		for _, block := range chain {
+			for _, tx := range block.Transactions() {
+				indexed := rawdb.ReadTxLookupEntry(chainDB, tx.Hash()) != nil
+				if expected := block.NumberU64() >= tail; indexed != expected {
+					t.Fatalf("expected tx of block %d indexed = %t with tail %d", block.NumberU64(), expected, tail)
+				}
+			}
+		}
+	}
+	config := func(limit uint64) *CacheConfig {
+		config := *pruningConfig
+		config.TxLookupLimit = limit
+		return &config
+	}
+
+	// Only the transactions of the last 4 accepted blocks are indexed
+	blockchain, err := createBlockChain(chainDB, config(4), gspec.Config, common.Hash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := types.HomesteadSigner{}
+	chain, _, err = GenerateChain(gspec.Config, genesis, blockchain.engine, genDB, 10, 10, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr1), addr2, big.NewInt(10000), params.TxGas, nil, nil), signer, key1)
+		gen.AddTxWithChain(blockchain, tx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range chain {
+		if err := blockchain.Accept(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blockchain.DrainAcceptorQueue()
+	check(7)
+	blockchain.Stop()
+
+	// Raising the limit re-indexes the missing blocks on restart, and a limit
+	// of 0 indexes all blocks.
+	lastAccepted := chain[len(chain)-1].Hash()
+	for _, test := range []struct {
+		limit uint64
+		tail  uint64
+	}{
+		{limit: 6, tail: 5},
+		{limit: 2, tail: 9},
+		{limit: 0, tail: 0},
+	} {
+		blockchain, err := createBlockChain(chainDB, config(test.limit), gspec.Config, lastAccepted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(test.tail)
+		blockchain.Stop()
+	}
+}
+
+func TestBlockChainFreezer(t *testing.T) {
+	var (
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2   = crypto.PubkeyToAddress(key2.PublicKey)
+		genDB   = rawdb.NewMemoryDatabase()
+		kvDB    = memorydb.New()
+	)
+	// Use a high threshold so that blocks are only frozen by the manual trigger
+	chainDB, err := rawdb.NewDatabaseWithFreezer(kvDB, t.TempDir(), "", false, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chainDB.Close()
+
+	gspec := &Genesis{
+		Config: &params.ChainConfig{ChainID: big.NewInt(1), HomesteadBlock: new(big.Int)},
+		Alloc:  GenesisAlloc{addr1: {Balance: big.NewInt(1000000)}},
+	}
+	genesis := gspec.MustCommit(genDB)
+	_ = gspec.MustCommit(chainDB)
+
+	blockchain, err := createBlockChain(chainDB, pruningConfig, gspec.Config, common.Hash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+
+	signer := types.HomesteadSigner{}
+	chain, _, err := GenerateChain(gspec.Config, genesis, blockchain.engine, genDB, 10, 10, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr1), addr2, big.NewInt(10000), params.TxGas, nil, nil), signer, key1)
+		gen.AddTxWithChain(blockchain, tx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range chain {
+		if err := blockchain.Accept(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blockchain.DrainAcceptorQueue()
+
+	// Freeze the accepted blocks more than 2 blocks behind the last accepted
+	// block, which are removed from the key-value store apart from the genesis.
+	if err := chainDB.(interface{ Freeze(uint64) error }).Freeze(2); err != nil {
+		t.Fatal(err)
+	}
+	if frozen, err := chainDB.Ancients(); err != nil || frozen != 9 {
+		t.Fatalf("expected 9 frozen blocks, have %d (err: %v)", frozen, err)
+	}
+	kvOnly := rawdb.NewDatabase(kvDB)
+	for _, block := range chain {
+		inKV := rawdb.HasHeader(kvOnly, block.Hash(), block.NumberU64())
+		if expected := block.NumberU64() > 8; inKV != expected {
+			t.Fatalf("expected block %d in key-value store = %t", block.NumberU64(), expected)
+		}
+	}
+
+	// The frozen blocks are read transparently
+	check := func(blockchain *BlockChain) {
+		t.Helper()
+
+		for _, block := range chain {
+			if have := blockchain.GetBlockByNumber(block.NumberU64()); have == nil || have.Hash() != block.Hash() {
+				t.Fatalf("expected block %d to be readable by number", block.NumberU64())
+			}
+			if have := rawdb.ReadBlock(chainDB, block.Hash(), block.NumberU64()); have == nil || have.Hash() != block.Hash() {
+				t.Fatalf("expected block %d to be readable by hash", block.NumberU64())
+			}
+			receipts := rawdb.ReadReceipts(chainDB, block.Hash(), block.NumberU64(), gspec.Config)
+			if len(receipts) != len(block.Transactions()) {
+				t.Fatalf("expected %d receipts for block %d, have %d", len(block.Transactions()), block.NumberU64(), len(receipts))
+			}
+			for _, tx := range block.Transactions() {
+				if have, blockHash, _, _ := rawdb.ReadTransaction(chainDB, tx.Hash()); have == nil || blockHash != block.Hash() {
+					t.Fatalf("expected tx %s of block %d to be readable", tx.Hash(), block.NumberU64())
+				}
+			}
+		}
+	}
+	check(blockchain)
+
+	// The chain can be restarted on top of the frozen blocks
+	blockchain.Stop()
+	blockchain, err = createBlockChain(chainDB, pruningConfig, gspec.Config, chain[len(chain)-1].Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+
+	check(blockchain)
+}
+
+func TestStateDiffs(t *testing.T) {
+	var (
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2   = crypto.PubkeyToAddress(key2.PublicKey)
+		genDB   = rawdb.NewMemoryDatabase()
+		chainDB = rawdb.NewMemoryDatabase()
+	)
+	gspec := &Genesis{
+		Config: &params.ChainConfig{ChainID: big.NewInt(1), HomesteadBlock: new(big.Int)},
+		Alloc:  GenesisAlloc{addr1: {Balance: big.NewInt(1000000)}},
+	}
+	genesis := gspec.MustCommit(genDB)
+	_ = gspec.MustCommit(chainDB)
+
+	// Commit a trie every 4 blocks and only keep the last accepted state in
+	// memory, so most states must be rebuilt from the diffs.
+	config := *pruningConfig
+	config.CommitInterval = 4
+	config.TipBufferSize = 1
+	config.StateDiffs = true
+	blockchain, err := createBlockChain(chainDB, &config, gspec.Config, common.Hash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+
+	signer := types.HomesteadSigner{}
+	chain, _, err := GenerateChain(gspec.Config, genesis, blockchain.engine, genDB, 10, 10, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr1), addr2, big.NewInt(10000), params.TxGas, nil, nil), signer, key1)
+		gen.AddTxWithChain(blockchain, tx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range chain {
+		if err := blockchain.Accept(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blockchain.DrainAcceptorQueue()
+
+	var pruned *types.Block
+	for _, block := range chain {
+		if !blockchain.HasState(block.Root()) {
+			pruned = block
+			break
+		}
+	}
+	if pruned == nil {
+		t.Fatal("expected the tries of some blocks to be pruned")
+	}
+	for _, block := range chain {
+		number := block.NumberU64()
+		if !rawdb.HasStateDiff(chainDB, number) {
+			t.Fatalf("missing state diff of block %d", number)
+		}
+		statedb, err := blockchain.HistoricalStateAt(block.Header())
+		if err != nil {
+			t.Fatalf("failed to get state of block %d: %v", number, err)
+		}
+		if have, want := statedb.GetNonce(addr1), number; have != want {
+			t.Fatalf("block %d: expected nonce %d, have %d", number, want, have)
+		}
+		if have, want := statedb.GetBalance(addr2), big.NewInt(int64(10000*number)); have.Cmp(want) != 0 {
+			t.Fatalf("block %d: expected balance %d, have %d", number, want, have)
+		}
+		multiCoin := statedb.GetBalanceMultiCoin(common.HexToAddress("0xdeadbeef"), common.HexToHash("0xdeadbeef"))
+		if have, want := multiCoin, new(big.Int).SetUint64(number); have.Cmp(want) != 0 {
+			t.Fatalf("block %d: expected multicoin balance %d, have %d", number, want, have)
+		}
+	}
+
+	// The state of a pruned block is read from the trie of an older block, so
+	// it can neither be proven nor committed
+	statedb, err := blockchain.HistoricalStateAt(pruned.Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := statedb.GetProof(addr1); err == nil {
+		t.Fatalf("expected an error for the account proof of block %d", pruned.NumberU64())
+	}
+	if _, err := statedb.GetStorageProof(addr1, common.Hash{}); err == nil {
+		t.Fatalf("expected an error for the storage proof of block %d", pruned.NumberU64())
+	}
+	if _, err := statedb.Commit(false); err == nil {
+		t.Fatalf("expected an error committing the state of block %d", pruned.NumberU64())
+	}
+
+	// Block 3 is rebuilt from the genesis trie, which requires more than
+	// twice a commit interval of 1 diffs
+	blockchain.cacheConfig.CommitInterval = 1
+	if _, err := blockchain.HistoricalStateAt(chain[2].Header()); err == nil {
+		t.Fatal("expected an error for the state of block 3 beyond the maximum depth")
+	}
+	blockchain.cacheConfig.CommitInterval = config.CommitInterval
+
+	// Without its diff, the state of a pruned block is unavailable
+	rawdb.DeleteStateDiff(chainDB, pruned.NumberU64())
+	if _, err := blockchain.HistoricalStateAt(pruned.Header()); err == nil {
+		t.Fatalf("expected an error for the state of block %d without its diff", pruned.NumberU64())
+	}
+}
+
+func TestOnlinePruning(t *testing.T) {
+	var (
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2   = crypto.PubkeyToAddress(key2.PublicKey)
+		genDB   = rawdb.NewMemoryDatabase()
+		chainDB = rawdb.NewMemoryDatabase()
+	)
+	gspec := &Genesis{
+		Config: &params.ChainConfig{ChainID: big.NewInt(1), HomesteadBlock: new(big.Int)},
+		Alloc:  GenesisAlloc{addr1: {Balance: big.NewInt(1000000)}},
+	}
+	genesis := gspec.MustCommit(genDB)
+	_ = gspec.MustCommit(chainDB)
+
+	// Commit a trie every 4 blocks, so the tries of older blocks are left on
+	// disk once they leave the tip buffer.
+	config := *pruningConfig
+	config.CommitInterval = 4
+	config.TipBufferSize = 1
+	config.OnlinePruningThrottle = 0
+	blockchain, err := createBlockChain(chainDB, &config, gspec.Config, common.Hash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+
+	signer := types.HomesteadSigner{}
+	chain, _, err := GenerateChain(gspec.Config, genesis, blockchain.engine, genDB, 20, 10, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr1), addr2, big.NewInt(10000), params.TxGas, nil, nil), signer, key1)
+		gen.AddTxWithChain(blockchain, tx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range chain[:10] {
+		if err := blockchain.Accept(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blockchain.DrainAcceptorQueue()
+
+	var stale []common.Hash
+	for _, block := range chain[:8] {
+		if blockchain.HasState(block.Root()) {
+			stale = append(stale, block.Root())
+		}
+	}
+	if len(stale) == 0 {
+		t.Fatal("expected the tries of some blocks to be committed")
+	}
+
+	// The pruning starts at the next accepted block and keeps running while
+	// the following blocks are accepted.
+	if err := blockchain.StartOnlinePruning(); err != nil {
+		t.Fatal(err)
+	}
+	if err := blockchain.StartOnlinePruning(); err == nil {
+		t.Fatal("expected an error requesting a second pruning")
+	}
+	for _, block := range chain[10:] {
+		if err := blockchain.Accept(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blockchain.DrainAcceptorQueue()
+
+	var status pruner.OnlinePruningStatus
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(10 * time.Millisecond) {
+		if status, err = blockchain.OnlinePruningStatus(); err != nil {
+			t.Fatal(err)
+		}
+		if status.Phase == pruner.OnlinePruningIdle {
+			break
+		}
+	}
+	if status.Phase != pruner.OnlinePruningIdle {
+		t.Fatalf("online pruning did not finish, phase %s", status.Phase)
+	}
+	if status.Root != chain[10].Root() || status.Nodes == 0 {
+		t.Fatalf("unexpected pruning status %+v", status)
+	}
+	if _, ok := rawdb.ReadOnlinePruning(chainDB); ok {
+		t.Fatal("expected the pruning progress to be deleted")
+	}
+
+	// Read the tries without the clean cache of the chain
+	triedb := trie.NewDatabase(chainDB)
+	for _, root := range stale {
+		if _, err := trie.New(root, triedb); err == nil {
+			t.Fatalf("expected stale root %s to be pruned", root)
+		}
+	}
+	for _, root := range []common.Hash{genesis.Root(), chain[10].Root(), blockchain.LastAcceptedBlock().Root()} {
+		tr, err := trie.New(root, triedb)
+		if err != nil {
+			t.Fatalf("missing kept root %s: %v", root, err)
+		}
+		it := tr.NodeIterator(nil)
+		for it.Next(true) {
+		}
+		if err := it.Error(); err != nil {
+			t.Fatalf("incomplete trie %s: %v", root, err)
+		}
+	}
+	statedb, err := blockchain.StateAt(blockchain.LastAcceptedBlock().Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := statedb.GetNonce(addr1), uint64(len(chain)); have != want {
+		t.Fatalf("expected nonce %d, have %d", want, have)
+	}
+}
+
+func TestOnlinePruningStateDiffs(t *testing.T) {
+	chainDB := rawdb.NewMemoryDatabase()
+	gspec := &Genesis{
+		Config: &params.ChainConfig{ChainID: big.NewInt(1), HomesteadBlock: new(big.Int)},
+	}
+	_ = gspec.MustCommit(chainDB)
+
+	config := *pruningConfig
+	config.StateDiffs = true
+	blockchain, err := createBlockChain(chainDB, &config, gspec.Config, common.Hash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Online pruning would delete the tries the state diffs are applied to
+	if err := blockchain.StartOnlinePruning(); err != errOnlinePruningDisabled {
+		t.Fatalf("expected %v requesting a pruning, have %v", errOnlinePruningDisabled, err)
+	}
+	blockchain.Stop()
+
+	// A pruning interrupted by a shutdown cannot be resumed with state diffs
+	if err := rawdb.WriteOnlinePruning(chainDB, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := createBlockChain(chainDB, &config, gspec.Config, common.Hash{}); err != errStateDiffsPruning {
+		t.Fatalf("expected %v with an interrupted pruning, have %v", errStateDiffsPruning, err)
+	}
+}
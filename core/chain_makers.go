@@ -27,9 +27,11 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 
+	"github.com/ava-labs/coreth/commontype"
 	"github.com/ava-labs/coreth/consensus"
 	"github.com/ava-labs/coreth/consensus/dummy"
 	"github.com/ava-labs/coreth/consensus/misc"
@@ -211,7 +213,7 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 		config = params.TestChainConfig
 	}
 	blocks, receipts := make(types.Blocks, n), make([]types.Receipts, n)
-	chainreader := &fakeChainReader{config: config}
+	chainreader := &fakeChainReader{config: config, db: db}
 	genblock := func(i int, parent *types.Block, statedb *state.StateDB) (*types.Block, types.Receipts, error) {
 		b := &BlockGen{i: i, chain: blocks, parent: parent, statedb: statedb, config: config, engine: engine}
 		b.header = makeHeader(chainreader, config, parent, gap, statedb, b.engine)
@@ -232,6 +234,9 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 		if config.DAOForkSupport && config.DAOForkBlock != nil && config.DAOForkBlock.Cmp(b.header.Number) == 0 {
 			misc.ApplyDAOHardFork(statedb)
 		}
+		if err := ApplyPrecompileActivations(config, new(big.Int).SetUint64(parent.Time()), b.header, statedb); err != nil {
+			return nil, nil, err
+		}
 		// Execute any user modifications to the block
 		if gen != nil {
 			gen(i, b)
@@ -302,8 +307,11 @@ func makeHeader(chain consensus.ChainReader, config *params.ChainConfig, parent
 		Time:     time,
 	}
 	if chain.Config().IsApricotPhase3(timestamp) {
-		var err error
-		header.Extra, header.BaseFee, err = dummy.CalcBaseFee(chain.Config(), parent.Header(), time)
+		feeConfig, err := chain.GetFeeConfigAt(parent.Header())
+		if err != nil {
+			panic(err)
+		}
+		header.Extra, header.BaseFee, err = dummy.CalcBaseFee(chain.Config(), feeConfig, parent.Header(), time)
 		if err != nil {
 			panic(err)
 		}
@@ -313,6 +321,7 @@ func makeHeader(chain consensus.ChainReader, config *params.ChainConfig, parent
 
 type fakeChainReader struct {
 	config *params.ChainConfig
+	db     ethdb.Database // contains the state of the generated blocks, may be nil
 }
 
 // Config returns the chain configuration.
@@ -325,3 +334,14 @@ func (cr *fakeChainReader) GetHeaderByNumber(number uint64) *types.Header
 func (cr *fakeChainReader) GetHeaderByHash(hash common.Hash) *types.Header          { return nil }
 func (cr *fakeChainReader) GetHeader(hash common.Hash, number uint64) *types.Header { return nil }
 func (cr *fakeChainReader) GetBlock(hash common.Hash, number uint64) *types.Block   { return nil }
+
+// GetFeeConfigAt returns the fee config in force for the children of [parent],
+// reading the state of [parent] from [cr.db].
+func (cr *fakeChainReader) GetFeeConfigAt(parent *types.Header) (commontype.FeeConfig, error) {
+	return getFeeConfigAt(cr.config, parent, func(root common.Hash) (*state.StateDB, error) {
+		if cr.db == nil {
+			return nil, errors.New("no database to read the state from")
+		}
+		return state.New(root, state.NewDatabase(cr.db), nil)
+	})
+}
@@ -56,6 +56,10 @@ var (
 	// next one expected based on the local chain.
 	ErrNonceTooHigh = errors.New("nonce too high")
 
+	// ErrSenderAddressNotAllowListed is returned if a transaction is sent by an
+	// address that is not enabled by the tx allow list precompile.
+	ErrSenderAddressNotAllowListed = errors.New("cannot issue transaction from non-allow listed address")
+
 	// ErrNonceMax is returned if the nonce of a transaction sender account has
 	// maximum allowed value and would become invalid if incremented.
 	ErrNonceMax = errors.New("nonce has max value")
@@ -246,6 +246,16 @@ func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 			}
 		}
 	}
+	if g.Config != nil {
+		// Configure the stateful precompiles enabled at genesis
+		genesisHeader := &types.Header{
+			Number: new(big.Int).SetUint64(g.Number),
+			Time:   g.Timestamp,
+		}
+		if err := ApplyPrecompileActivations(g.Config, nil, genesisHeader, statedb); err != nil {
+			panic(fmt.Sprintf("unable to configure precompiles in genesis: %s", err))
+		}
+	}
 	root := statedb.IntermediateRoot(false)
 	head := &types.Header{
 		Number:     new(big.Int).SetUint64(g.Number),
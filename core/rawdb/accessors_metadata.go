@@ -220,6 +220,29 @@ func DeleteOfflinePruning(db ethdb.KeyValueStore) error {
 	return DeleteTimeMarker(db, offlinePruningKey)
 }
 
+// WriteOnlinePruning writes the progress of a running online pruning, which is
+// the database key the deletion of stale trie nodes resumes from (empty until
+// the deletion starts).
+func WriteOnlinePruning(db ethdb.KeyValueWriter, marker []byte) error {
+	return db.Put(onlinePruningKey, marker)
+}
+
+// ReadOnlinePruning reads the progress of a running online pruning, returning
+// false if no online pruning is running.
+func ReadOnlinePruning(db ethdb.KeyValueReader) ([]byte, bool) {
+	marker, err := db.Get(onlinePruningKey)
+	if err != nil {
+		return nil, false
+	}
+	return marker, true
+}
+
+// DeleteOnlinePruning deletes the progress of an online pruning once it
+// completes.
+func DeleteOnlinePruning(db ethdb.KeyValueWriter) error {
+	return db.Delete(onlinePruningKey)
+}
+
 // WritePopulateMissingTries writes a marker for the current attempt to populate
 // missing tries.
 func WritePopulateMissingTries(db ethdb.KeyValueStore) error {
@@ -118,3 +118,33 @@ func DeleteTrieNode(db ethdb.KeyValueWriter, hash common.Hash) {
 		log.Crit("Failed to delete trie node", "err", err)
 	}
 }
+
+// ReadStateDiff retrieves the encoded state diff of the accepted block with
+// the given number.
+func ReadStateDiff(db ethdb.KeyValueReader, number uint64) []byte {
+	data, _ := db.Get(stateDiffKey(number))
+	return data
+}
+
+// HasStateDiff checks if the state diff of the accepted block with the given
+// number is present in db.
+func HasStateDiff(db ethdb.KeyValueReader, number uint64) bool {
+	ok, _ := db.Has(stateDiffKey(number))
+	return ok
+}
+
+// WriteStateDiff writes the encoded state diff of the accepted block with the
+// given number.
+func WriteStateDiff(db ethdb.KeyValueWriter, number uint64, diff []byte) {
+	if err := db.Put(stateDiffKey(number), diff); err != nil {
+		log.Crit("Failed to store state diff", "err", err)
+	}
+}
+
+// DeleteStateDiff deletes the state diff of the accepted block with the given
+// number.
+func DeleteStateDiff(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Delete(stateDiffKey(number)); err != nil {
+		log.Crit("Failed to delete state diff", "err", err)
+	}
+}
@@ -0,0 +1,184 @@
+// (c) 2021-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package rawdb
+
+import (
+	"github.com/ava-labs/coreth/ethdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ReadSyncRoot retrieves the root of the state trie being synced from peers,
+// or an empty hash if no state sync is in progress.
+func ReadSyncRoot(db ethdb.KeyValueReader) common.Hash {
+	data, _ := db.Get(syncRootKey)
+	if len(data) != common.HashLength {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteSyncRoot stores the root of the state trie being synced from peers.
+func WriteSyncRoot(db ethdb.KeyValueWriter, root common.Hash) {
+	if err := db.Put(syncRootKey, root[:]); err != nil {
+		log.Crit("Failed to store sync root", "err", err)
+	}
+}
+
+// DeleteSyncRoot removes the root of the state trie being synced, marking
+// the state sync as complete.
+func DeleteSyncRoot(db ethdb.KeyValueWriter) {
+	if err := db.Delete(syncRootKey); err != nil {
+		log.Crit("Failed to remove sync root", "err", err)
+	}
+}
+
+// WriteSyncSegment stores [last] as the last key synced of the segment of the
+// trie at [root] beginning at [start]. The segment ends where the next segment
+// of the trie begins.
+// [account] is the hash of the account owning the storage trie or an empty
+// hash for the account trie.
+func WriteSyncSegment(db ethdb.KeyValueWriter, account common.Hash, root common.Hash, start []byte, last []byte) {
+	if err := db.Put(syncSegmentKey(account, root, start), last); err != nil {
+		log.Crit("Failed to store sync segment", "err", err)
+	}
+}
+
+// IterateSyncSegments returns an iterator over the segments of the trie at [root]
+// stored by WriteSyncSegment, ordered by segment start. The keys returned by the
+// iterator are the segment start prefixed by SyncSegmentsPrefixLength bytes and
+// the values are the last key synced of the segment.
+func IterateSyncSegments(db ethdb.Iteratee, account common.Hash, root common.Hash) ethdb.Iterator {
+	return db.NewIterator(syncSegmentsKey(account, root), nil)
+}
+
+// SyncSegmentsPrefixLength is the length of the prefix of the keys returned by
+// IterateSyncSegments.
+var SyncSegmentsPrefixLength = len(syncSegmentsPrefix) + 2*common.HashLength
+
+// ClearSyncSegments removes the segments of the trie at [root].
+func ClearSyncSegments(db ethdb.KeyValueStore, account common.Hash, root common.Hash) error {
+	return ClearPrefix(db, syncSegmentsKey(account, root))
+}
+
+// ReadSyncStackTrie retrieves the serialized trie.StackTrie holding the leafs
+// of the first segment of the trie at [root] synced before a restart, or nil
+// if none is stored.
+func ReadSyncStackTrie(db ethdb.KeyValueReader, account common.Hash, root common.Hash) []byte {
+	data, _ := db.Get(syncStackTrieKey(account, root))
+	return data
+}
+
+// WriteSyncStackTrie stores the serialized trie.StackTrie holding the leafs of
+// the first segment of the trie at [root] synced so far. Since the stack trie
+// only keeps the nodes that have not been written to disk yet, the trie can be
+// rebuilt after a restart without keeping a copy of its leafs.
+func WriteSyncStackTrie(db ethdb.KeyValueWriter, account common.Hash, root common.Hash, data []byte) {
+	if err := db.Put(syncStackTrieKey(account, root), data); err != nil {
+		log.Crit("Failed to store sync stack trie", "err", err)
+	}
+}
+
+// DeleteSyncStackTrie removes the serialized trie.StackTrie of the trie at [root].
+func DeleteSyncStackTrie(db ethdb.KeyValueWriter, account common.Hash, root common.Hash) {
+	if err := db.Delete(syncStackTrieKey(account, root)); err != nil {
+		log.Crit("Failed to remove sync stack trie", "err", err)
+	}
+}
+
+// WriteSyncLeaf stores a leaf of the trie owned by [account] fetched by state sync
+// out of order, such that it can be inserted into the trie once the leafs
+// preceding it have been synced.
+func WriteSyncLeaf(db ethdb.KeyValueWriter, account common.Hash, key []byte, value []byte) {
+	if err := db.Put(append(syncLeafsKey(account), key...), value); err != nil {
+		log.Crit("Failed to store sync leaf", "err", err)
+	}
+}
+
+// IterateSyncLeafs returns an iterator over the leafs of the trie owned by
+// [account] stored by WriteSyncLeaf, in key order. The keys returned by the
+// iterator include the prefix of length SyncLeafsPrefixLength.
+func IterateSyncLeafs(db ethdb.Iteratee, account common.Hash) ethdb.Iterator {
+	return db.NewIterator(syncLeafsKey(account), nil)
+}
+
+// SyncLeafsPrefixLength is the length of the prefix of the keys returned by
+// IterateSyncLeafs.
+var SyncLeafsPrefixLength = len(syncLeafsPrefix) + common.HashLength
+
+// ClearSyncLeafs removes the leafs of the trie owned by [account] stored by WriteSyncLeaf.
+func ClearSyncLeafs(db ethdb.KeyValueStore, account common.Hash) error {
+	return ClearPrefix(db, syncLeafsKey(account))
+}
+
+// ClearSyncProgress removes all synced ranges, stack tries, leafs and code markers
+// stored by state sync. Trie nodes and code written by state sync are not removed.
+func ClearSyncProgress(db ethdb.KeyValueStore) error {
+	for _, prefix := range [][]byte{syncSegmentsPrefix, syncStackTriePrefix, syncLeafsPrefix} {
+		if err := ClearPrefix(db, prefix); err != nil {
+			return err
+		}
+	}
+	// Trie nodes are keyed by their hash, which may begin with the short
+	// prefix of the code markers, so only keys of that length are removed.
+	return clearPrefix(db, codeToFetchPrefix, len(codeToFetchPrefix)+common.HashLength)
+}
+
+// WriteCodeToFetch marks the code with [hash] as referenced by a synced
+// account and not yet fetched.
+func WriteCodeToFetch(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Put(codeToFetchKey(hash), nil); err != nil {
+		log.Crit("Failed to store code to fetch", "err", err)
+	}
+}
+
+// DeleteCodeToFetch removes the marker for the code with [hash] once it has been fetched.
+func DeleteCodeToFetch(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Delete(codeToFetchKey(hash)); err != nil {
+		log.Crit("Failed to remove code to fetch", "err", err)
+	}
+}
+
+// IterateCodeToFetch returns an iterator over the code hashes marked by
+// WriteCodeToFetch. The keys returned by the iterator are prefixed by
+// CodeToFetchPrefixLength bytes.
+func IterateCodeToFetch(db ethdb.Iteratee) ethdb.Iterator {
+	return db.NewIterator(codeToFetchPrefix, nil)
+}
+
+// CodeToFetchPrefixLength is the length of the prefix of the keys returned by
+// IterateCodeToFetch.
+var CodeToFetchPrefixLength = len(codeToFetchPrefix)
+
+// ClearPrefix removes all keys in [db] beginning with [prefix].
+func ClearPrefix(db ethdb.KeyValueStore, prefix []byte) error {
+	return clearPrefix(db, prefix, 0)
+}
+
+// clearPrefix removes the keys in [db] beginning with [prefix] that are
+// [keyLen] bytes long, or all of them if [keyLen] is zero.
+func clearPrefix(db ethdb.KeyValueStore, prefix []byte, keyLen int) error {
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	batch := db.NewBatch()
+	for it.Next() {
+		if keyLen > 0 && len(it.Key()) != keyLen {
+			continue
+		}
+		if err := batch.Delete(common.CopyBytes(it.Key())); err != nil {
+			return err
+		}
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	return batch.Write()
+}
@@ -28,29 +28,219 @@ package rawdb
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/ava-labs/coreth/ethdb"
 	"github.com/ava-labs/coreth/ethdb/leveldb"
 	"github.com/ava-labs/coreth/ethdb/memorydb"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/olekukonko/tablewriter"
 )
 
+// freezerdb is a database wrapper that enabled freezer data retrievals.
+type freezerdb struct {
+	ethdb.KeyValueStore
+	ethdb.AncientStore
+}
+
+// Close implements io.Closer, closing both the fast key-value store as well as
+// the slow ancient tables.
+func (frdb *freezerdb) Close() error {
+	var errs []error
+	if err := frdb.AncientStore.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := frdb.KeyValueStore.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// Freeze is a helper method used for external testing to trigger and block until
+// a freeze cycle completes, without having to sleep for a minute to trigger the
+// automatic background run.
+func (frdb *freezerdb) Freeze(threshold uint64) error {
+	if frdb.AncientStore.(*freezer).readonly {
+		return errReadOnly
+	}
+	// Set the freezer threshold to a temporary value
+	defer func(old uint64) {
+		atomic.StoreUint64(&frdb.AncientStore.(*freezer).threshold, old)
+	}(atomic.LoadUint64(&frdb.AncientStore.(*freezer).threshold))
+	atomic.StoreUint64(&frdb.AncientStore.(*freezer).threshold, threshold)
+
+	// Trigger a freeze cycle and block until it's done
+	trigger := make(chan struct{}, 1)
+	frdb.AncientStore.(*freezer).trigger <- trigger
+	<-trigger
+	return nil
+}
+
 // nofreezedb is a database wrapper that disables freezer data retrievals.
 type nofreezedb struct {
 	ethdb.KeyValueStore
 }
 
+// HasAncient returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) HasAncient(kind string, number uint64) (bool, error) {
+	return false, errNotSupported
+}
+
+// Ancient returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) Ancient(kind string, number uint64) ([]byte, error) {
+	return nil, errNotSupported
+}
+
+// AncientRange returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) AncientRange(kind string, start, max, maxByteSize uint64) ([][]byte, error) {
+	return nil, errNotSupported
+}
+
+// Ancients returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) Ancients() (uint64, error) {
+	return 0, errNotSupported
+}
+
+// AncientSize returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) AncientSize(kind string) (uint64, error) {
+	return 0, errNotSupported
+}
+
+// ModifyAncients is not supported.
+func (db *nofreezedb) ModifyAncients(func(ethdb.AncientWriteOp) error) (int64, error) {
+	return 0, errNotSupported
+}
+
+// TruncateAncients returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) TruncateAncients(items uint64) error {
+	return errNotSupported
+}
+
+// Sync returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) Sync() error {
+	return errNotSupported
+}
+
+func (db *nofreezedb) ReadAncients(fn func(reader ethdb.AncientReader) error) (err error) {
+	// Unlike other ancient-related methods, this method does not return
+	// errNotSupported when invoked.
+	// The reason for this is that the caller might want to do several things:
+	// 1. Check if something is in freezer,
+	// 2. If not, check leveldb.
+	//
+	// This will work, since the ancient-checks inside 'fn' will return errors,
+	// and the leveldb work will continue.
+	//
+	// If we instead were to return errNotSupported here, then the caller would
+	// have to explicitly check for that, having an extra clause to do the
+	// non-ancient operations.
+	return fn(db)
+}
+
 // NewDatabase creates a high level database on top of a given key-value data
 // store without a freezer moving immutable chain segments into cold storage.
 func NewDatabase(db ethdb.KeyValueStore) ethdb.Database {
 	return &nofreezedb{KeyValueStore: db}
 }
 
+// NewDatabaseWithFreezer creates a high level database on top of a given key-
+// value data store with a freezer moving immutable chain segments into cold
+// storage. The blocks more than [threshold] blocks behind the last accepted
+// block are moved into the freezer.
+func NewDatabaseWithFreezer(db ethdb.KeyValueStore, freezer string, namespace string, readonly bool, threshold uint64) (ethdb.Database, error) {
+	// Create the idle freezer instance
+	frdb, err := newFreezer(freezer, namespace, readonly, freezerTableSize, FreezerNoSnappy)
+	if err != nil {
+		return nil, err
+	}
+	frdb.threshold = threshold
+	// Since the freezer can be stored separately from the user's key-value database,
+	// there's a fairly high probability that the user requests invalid combinations
+	// of the freezer and database. Ensure that we don't shoot ourselves in the foot
+	// by serving up conflicting data, leading to both datastores getting corrupted.
+	//
+	//   - If both the freezer and key-value store is empty (no genesis), we just
+	//     initialized a new empty freezer, so everything's fine.
+	//   - If the key-value store is empty, but the freezer is not, we need to make
+	//     sure the user's genesis matches the freezer. That will be checked in the
+	//     blockchain, since we don't have the genesis block here (nor should we at
+	//     this point care, the key-value/freezer combo is valid).
+	//   - If neither the key-value store nor the freezer is empty, cross validate
+	//     the genesis hashes to make sure they are compatible. If they are, also
+	//     ensure that there's no gap between the freezer and sunsequently leveldb.
+	//   - If the key-value store is not empty, but the freezer is we might just be
+	//     upgrading to the freezer release, or we might have had a small chain and
+	//     not frozen anything yet. Ensure that no blocks are missing yet from the
+	//     key-value store, since that would mean we already had an old freezer.
+
+	// If the genesis hash is empty, we have a new key-value store, so nothing to
+	// validate in this method. If, however, the genesis hash is not nil, compare
+	// it to the freezer content.
+	if kvgenesis, _ := db.Get(headerHashKey(0)); len(kvgenesis) > 0 {
+		if frozen, _ := frdb.Ancients(); frozen > 0 {
+			// If the freezer already contains something, ensure that the genesis blocks
+			// match, otherwise we might mix up freezers across chains and destroy both
+			// the freezer and the key-value store.
+			frgenesis, err := frdb.Ancient(freezerHashTable, 0)
+			if err != nil {
+				return nil, fmt.Errorf("failed to retrieve genesis from ancient %v", err)
+			} else if !bytes.Equal(kvgenesis, frgenesis) {
+				return nil, fmt.Errorf("genesis mismatch: %#x (leveldb) != %#x (ancients)", kvgenesis, frgenesis)
+			}
+			// Key-value store and freezer belong to the same network. Ensure that they
+			// are contiguous, otherwise we might end up with a non-functional freezer.
+			if kvhash, _ := db.Get(headerHashKey(frozen)); len(kvhash) == 0 {
+				// Subsequent header after the freezer limit is missing from the database.
+				// Reject startup is the database has a more recent head.
+				if *ReadHeaderNumber(db, ReadHeadHeaderHash(db)) > frozen-1 {
+					return nil, fmt.Errorf("gap (#%d) in the chain between ancients and leveldb", frozen)
+				}
+				// Database contains only older data than the freezer, this happens if the
+				// state was wiped and reinited from an existing freezer.
+			}
+			// Otherwise, key-value store continues where the freezer left off, all is fine.
+			// We might have duplicate blocks (crash after freezer write but before key-value
+			// store deletion, but that's fine).
+		} else {
+			// If the freezer is empty, ensure nothing was moved yet from the key-value
+			// store, otherwise we'll end up missing data. We check block #1 to decide
+			// if we froze anything previously or not, but do take care of databases with
+			// only the genesis block.
+			if ReadHeadHeaderHash(db) != common.BytesToHash(kvgenesis) {
+				// Key-value store contains more data than the genesis block, make sure we
+				// didn't freeze anything yet.
+				if kvblob, _ := db.Get(headerHashKey(1)); len(kvblob) == 0 {
+					return nil, errors.New("ancient chain segments already extracted, please set the freezer directory to the correct path")
+				}
+				// Block #1 is still in the database, we're allowed to init a new feezer
+			}
+			// Otherwise, the head header is still the genesis, we're allowed to init a new
+			// feezer.
+		}
+	}
+	// Freezer is consistent with the key-value database, permit combining the two
+	if !frdb.readonly {
+		frdb.wg.Add(1)
+		go func() {
+			frdb.freeze(db)
+			frdb.wg.Done()
+		}()
+	}
+	return &freezerdb{
+		KeyValueStore: db,
+		AncientStore:  frdb,
+	}, nil
+}
+
 // NewMemoryDatabase creates an ephemeral in-memory key-value database without a
 // freezer moving immutable chain segments into cold storage.
 func NewMemoryDatabase() ethdb.Database {
@@ -74,6 +264,21 @@ func NewLevelDBDatabase(file string, cache int, handles int, namespace string, r
 	return NewDatabase(db), nil
 }
 
+// NewLevelDBDatabaseWithFreezer creates a persistent key-value database with a
+// freezer moving immutable chain segments into cold storage.
+func NewLevelDBDatabaseWithFreezer(file string, cache int, handles int, freezer string, namespace string, readonly bool, threshold uint64) (ethdb.Database, error) {
+	kvdb, err := leveldb.New(file, cache, handles, namespace, readonly)
+	if err != nil {
+		return nil, err
+	}
+	frdb, err := NewDatabaseWithFreezer(kvdb, freezer, namespace, readonly, threshold)
+	if err != nil {
+		kvdb.Close()
+		return nil, err
+	}
+	return frdb, nil
+}
+
 type counter uint64
 
 func (c counter) String() string {
@@ -104,9 +309,59 @@ func (s *stat) Count() string {
 	return s.count.String()
 }
 
+// DatabaseStat is the size of a category of data in a database.
+type DatabaseStat struct {
+	Database string             `json:"database"`
+	Category string             `json:"category"`
+	Size     common.StorageSize `json:"size"`
+	Count    uint64             `json:"count"`
+}
+
+// errInspectionAborted is returned by the inspections of a database aborted
+// before they completed.
+var errInspectionAborted = errors.New("database inspection aborted")
+
 // InspectDatabase traverses the entire database and checks the size
 // of all different categories of data.
 func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
+	stats, err := ReadDatabaseStats(db, keyPrefix, keyStart, nil)
+	if err != nil {
+		return err
+	}
+	var (
+		total       common.StorageSize
+		unaccounted DatabaseStat
+		rows        = make([][]string, 0, len(stats))
+	)
+	for _, stat := range stats {
+		total += stat.Size
+		if stat.Category == unaccountedCategory {
+			unaccounted = stat
+			continue
+		}
+		rows = append(rows, []string{stat.Database, stat.Category, stat.Size.String(), fmt.Sprintf("%d", stat.Count)})
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Database", "Category", "Size", "Items"})
+	table.SetFooter([]string{"", "Total", total.String(), " "})
+	table.AppendBulk(rows)
+	table.Render()
+
+	if unaccounted.Size > 0 {
+		log.Error("Database contains unaccounted data", "size", unaccounted.Size, "count", unaccounted.Count)
+	}
+
+	return nil
+}
+
+// unaccountedCategory is the category of the data not matching any known key
+// of the database.
+const unaccountedCategory = "Unaccounted"
+
+// ReadDatabaseStats traverses the entire database and returns the size of all
+// different categories of data. The traversal stops with an error if [abort]
+// is closed.
+func ReadDatabaseStats(db ethdb.Database, keyPrefix, keyStart []byte, abort <-chan struct{}) ([]DatabaseStat, error) {
 	it := db.NewIterator(keyPrefix, keyStart)
 	defer it.Release()
 
@@ -127,9 +382,17 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		accountSnaps    stat
 		storageSnaps    stat
 		preimages       stat
+		stateDiffs      stat
+		syncProgress    stat
 		bloomBits       stat
 		cliqueSnaps     stat
 
+		// Ancient store statistics
+		ancientHeadersSize  common.StorageSize
+		ancientBodiesSize   common.StorageSize
+		ancientReceiptsSize common.StorageSize
+		ancientHashesSize   common.StorageSize
+
 		// Les statistic
 		chtTrieNodes   stat
 		bloomTrieNodes stat
@@ -137,9 +400,6 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		// Meta- and unaccounted data
 		metadata    stat
 		unaccounted stat
-
-		// Totals
-		total common.StorageSize
 	)
 	// Inspect key-value database first.
 	for it.Next() {
@@ -147,7 +407,6 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			key  = it.Key()
 			size = common.StorageSize(len(key) + len(it.Value()))
 		)
-		total += size
 		switch {
 		case bytes.HasPrefix(key, headerPrefix) && len(key) == (len(headerPrefix)+8+common.HashLength):
 			headers.Add(size)
@@ -171,6 +430,10 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			storageSnaps.Add(size)
 		case bytes.HasPrefix(key, preimagePrefix) && len(key) == (len(preimagePrefix)+common.HashLength):
 			preimages.Add(size)
+		case bytes.HasPrefix(key, stateDiffPrefix) && len(key) == (len(stateDiffPrefix)+8):
+			stateDiffs.Add(size)
+		case bytes.HasPrefix(key, syncSegmentsPrefix) || bytes.HasPrefix(key, syncLeafsPrefix) || bytes.HasPrefix(key, syncStackTriePrefix) || bytes.HasPrefix(key, codeToFetchPrefix):
+			syncProgress.Add(size)
 		case bytes.HasPrefix(key, configPrefix) && len(key) == (len(configPrefix)+common.HashLength):
 			metadata.Add(size)
 		case bytes.HasPrefix(key, bloomBitsPrefix) && len(key) == (len(bloomBitsPrefix)+10+common.HashLength):
@@ -192,6 +455,9 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			for _, meta := range [][]byte{
 				databaseVersionKey, headHeaderKey, headBlockKey,
 				snapshotRootKey, snapshotGeneratorKey, uncleanShutdownKey,
+				onlinePruningKey, acceptorTipKey, txIndexTailKey,
+				snapshotBlockHashKey, offlinePruningKey, populateMissingTriesKey,
+				pruningDisabledKey, syncRootKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
@@ -204,39 +470,131 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			}
 		}
 		count++
-		if count%1000 == 0 && time.Since(logged) > 8*time.Second {
-			log.Info("Inspecting database", "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
-			logged = time.Now()
+		if count%1000 == 0 {
+			select {
+			case <-abort:
+				return nil, errInspectionAborted
+			default:
+			}
+			if time.Since(logged) > 8*time.Second {
+				log.Info("Inspecting database", "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+				logged = time.Now()
+			}
 		}
 	}
-	// Display the database statistic.
-	stats := [][]string{
-		{"Key-Value store", "Headers", headers.Size(), headers.Count()},
-		{"Key-Value store", "Bodies", bodies.Size(), bodies.Count()},
-		{"Key-Value store", "Receipt lists", receipts.Size(), receipts.Count()},
-		{"Key-Value store", "Block number->hash", numHashPairings.Size(), numHashPairings.Count()},
-		{"Key-Value store", "Block hash->number", hashNumPairings.Size(), hashNumPairings.Count()},
-		{"Key-Value store", "Transaction index", txLookups.Size(), txLookups.Count()},
-		{"Key-Value store", "Bloombit index", bloomBits.Size(), bloomBits.Count()},
-		{"Key-Value store", "Contract codes", codes.Size(), codes.Count()},
-		{"Key-Value store", "Trie nodes", tries.Size(), tries.Count()},
-		{"Key-Value store", "Trie preimages", preimages.Size(), preimages.Count()},
-		{"Key-Value store", "Account snapshot", accountSnaps.Size(), accountSnaps.Count()},
-		{"Key-Value store", "Storage snapshot", storageSnaps.Size(), storageSnaps.Count()},
-		{"Key-Value store", "Clique snapshots", cliqueSnaps.Size(), cliqueSnaps.Count()},
-		{"Key-Value store", "Singleton metadata", metadata.Size(), metadata.Count()},
-		{"Light client", "CHT trie nodes", chtTrieNodes.Size(), chtTrieNodes.Count()},
-		{"Light client", "Bloom trie nodes", bloomTrieNodes.Size(), bloomTrieNodes.Count()},
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	// Inspect append-only file store then.
+	ancientSizes := []*common.StorageSize{&ancientHeadersSize, &ancientBodiesSize, &ancientReceiptsSize, &ancientHashesSize}
+	for i, category := range []string{freezerHeaderTable, freezerBodiesTable, freezerReceiptTable, freezerHashTable} {
+		if size, err := db.AncientSize(category); err == nil {
+			*ancientSizes[i] += common.StorageSize(size)
+		}
 	}
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Database", "Category", "Size", "Items"})
-	table.SetFooter([]string{"", "Total", total.String(), " "})
-	table.AppendBulk(stats)
-	table.Render()
-
-	if unaccounted.size > 0 {
-		log.Error("Database contains unaccounted data", "size", unaccounted.size, "count", unaccounted.count)
+	// Get number of ancient rows inside the freezer
+	ancients := counter(0)
+	if count, err := db.Ancients(); err == nil {
+		ancients = counter(count)
 	}
+	kvStat := func(category string, s stat) DatabaseStat {
+		return DatabaseStat{Database: "Key-Value store", Category: category, Size: s.size, Count: uint64(s.count)}
+	}
+	ancientStat := func(category string, size common.StorageSize) DatabaseStat {
+		return DatabaseStat{Database: "Ancient store", Category: category, Size: size, Count: uint64(ancients)}
+	}
+	return []DatabaseStat{
+		kvStat("Headers", headers),
+		kvStat("Bodies", bodies),
+		kvStat("Receipt lists", receipts),
+		kvStat("Block number->hash", numHashPairings),
+		kvStat("Block hash->number", hashNumPairings),
+		kvStat("Transaction index", txLookups),
+		kvStat("Bloombit index", bloomBits),
+		kvStat("Contract codes", codes),
+		kvStat("Trie nodes", tries),
+		kvStat("Trie preimages", preimages),
+		kvStat("State diffs", stateDiffs),
+		kvStat("State sync progress", syncProgress),
+		kvStat("Account snapshot", accountSnaps),
+		kvStat("Storage snapshot", storageSnaps),
+		kvStat("Clique snapshots", cliqueSnaps),
+		kvStat("Singleton metadata", metadata),
+		ancientStat("Headers", ancientHeadersSize),
+		ancientStat("Bodies", ancientBodiesSize),
+		ancientStat("Receipt lists", ancientReceiptsSize),
+		ancientStat("Block number->hash", ancientHashesSize),
+		{Database: "Light client", Category: "CHT trie nodes", Size: chtTrieNodes.size, Count: uint64(chtTrieNodes.count)},
+		{Database: "Light client", Category: "Bloom trie nodes", Size: bloomTrieNodes.size, Count: uint64(bloomTrieNodes.count)},
+		kvStat(unaccountedCategory, unaccounted),
+	}, nil
+}
 
-	return nil
+// maxCorruptKeys is the maximum number of corrupt entries listed by
+// CheckStateContent.
+const maxCorruptKeys = 128
+
+// StateContentCheck is the result of CheckStateContent.
+type StateContentCheck struct {
+	TrieNodes uint64        `json:"trieNodes"` // Number of trie nodes checked
+	Codes     uint64        `json:"codes"`     // Number of contract codes checked
+	Corrupt   uint64        `json:"corrupt"`   // Number of trie nodes and codes not matching their hash
+	Keys      []common.Hash `json:"keys"`      // Hashes of the first corrupt entries
+}
+
+// CheckStateContent traverses the entire database and checks that the content
+// of every trie node and contract code matches its hash. The traversal stops
+// with an error if [abort] is closed.
+func CheckStateContent(db ethdb.Iteratee, abort <-chan struct{}) (*StateContentCheck, error) {
+	it := db.NewIterator(nil, nil)
+	defer it.Release()
+
+	var (
+		check  = &StateContentCheck{Keys: []common.Hash{}}
+		hasher = crypto.NewKeccakState()
+		hash   common.Hash
+		count  int64
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for it.Next() {
+		key := it.Key()
+		var expected []byte
+		switch {
+		case len(key) == common.HashLength:
+			expected = key
+			check.TrieNodes++
+		case bytes.HasPrefix(key, CodePrefix) && len(key) == len(CodePrefix)+common.HashLength:
+			expected = key[len(CodePrefix):]
+			check.Codes++
+		}
+		if expected != nil {
+			hasher.Reset()
+			hasher.Write(it.Value())
+			hasher.Read(hash[:])
+			if !bytes.Equal(hash[:], expected) {
+				log.Error("Found corrupt state entry", "key", common.BytesToHash(expected), "hash", hash)
+				check.Corrupt++
+				if len(check.Keys) < maxCorruptKeys {
+					check.Keys = append(check.Keys, common.BytesToHash(expected))
+				}
+			}
+		}
+		count++
+		if count%1000 == 0 {
+			select {
+			case <-abort:
+				return nil, errInspectionAborted
+			default:
+			}
+			if time.Since(logged) > 8*time.Second {
+				log.Info("Checking state content", "count", count, "corrupt", check.Corrupt, "elapsed", common.PrettyDuration(time.Since(start)))
+				logged = time.Now()
+			}
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	return check, nil
 }
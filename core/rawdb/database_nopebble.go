@@ -0,0 +1,29 @@
+// (c) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+//go:build !((arm64 || amd64) && !openbsd)
+// +build !arm64,!amd64 openbsd
+
+package rawdb
+
+import (
+	"errors"
+
+	"github.com/ava-labs/coreth/ethdb"
+)
+
+// errPebbleUnsupported is returned when opening a pebble database on a
+// platform pebble does not support.
+var errPebbleUnsupported = errors.New("pebble is not supported on this platform")
+
+// NewPebbleDBDatabase returns an error, since pebble is only supported on
+// 64-bit platforms.
+func NewPebbleDBDatabase(file string, cache int, handles int, namespace string, readonly bool) (ethdb.Database, error) {
+	return nil, errPebbleUnsupported
+}
+
+// NewPebbleDBDatabaseWithFreezer returns an error, since pebble is only
+// supported on 64-bit platforms.
+func NewPebbleDBDatabaseWithFreezer(file string, cache int, handles int, freezer string, namespace string, readonly bool, threshold uint64) (ethdb.Database, error) {
+	return nil, errPebbleUnsupported
+}
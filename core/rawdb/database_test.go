@@ -0,0 +1,90 @@
+// (c) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package rawdb
+
+import (
+	"testing"
+
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestReadDatabaseStats(t *testing.T) {
+	db := NewMemoryDatabase()
+	WriteHeader(db, &types.Header{Number: common.Big1})
+	node := []byte{0x01, 0x02}
+	WriteTrieNode(db, crypto.Keccak256Hash(node), node)
+	WriteCode(db, crypto.Keccak256Hash([]byte{0x03}), []byte{0x03})
+	if err := db.Put([]byte("unknown"), []byte{0x04}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := ReadDatabaseStats(db, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]uint64)
+	for _, stat := range stats {
+		if stat.Database == "Key-Value store" {
+			counts[stat.Category] = stat.Count
+		}
+	}
+	for category, want := range map[string]uint64{
+		"Headers":            1,
+		"Block hash->number": 1,
+		"Trie nodes":         1,
+		"Contract codes":     1,
+		"Unaccounted":        1,
+		"Receipt lists":      0,
+	} {
+		if have := counts[category]; have != want {
+			t.Errorf("%s: expected %d items, have %d", category, want, have)
+		}
+	}
+
+	abort := make(chan struct{})
+	close(abort)
+	for i := 0; i < 1000; i++ {
+		WriteCanonicalHash(db, common.Hash{}, uint64(i))
+	}
+	if _, err := ReadDatabaseStats(db, nil, nil, abort); err != errInspectionAborted {
+		t.Fatalf("expected %v, have %v", errInspectionAborted, err)
+	}
+}
+
+func TestCheckStateContent(t *testing.T) {
+	db := NewMemoryDatabase()
+	node := []byte{0x01, 0x02}
+	WriteTrieNode(db, crypto.Keccak256Hash(node), node)
+	WriteCode(db, crypto.Keccak256Hash([]byte{0x03}), []byte{0x03})
+
+	check, err := CheckStateContent(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.TrieNodes != 1 || check.Codes != 1 || check.Corrupt != 0 {
+		t.Fatalf("unexpected check result %+v", check)
+	}
+
+	corruptNode, corruptCode := common.HexToHash("0x01"), common.HexToHash("0x02")
+	WriteTrieNode(db, corruptNode, node)
+	WriteCode(db, corruptCode, []byte{0x03})
+	if check, err = CheckStateContent(db, nil); err != nil {
+		t.Fatal(err)
+	}
+	if check.TrieNodes != 2 || check.Codes != 2 || check.Corrupt != 2 {
+		t.Fatalf("unexpected check result %+v", check)
+	}
+	corrupt := map[common.Hash]bool{corruptNode: true, corruptCode: true}
+	for _, key := range check.Keys {
+		if !corrupt[key] {
+			t.Fatalf("unexpected corrupt key %s", key)
+		}
+		delete(corrupt, key)
+	}
+	if len(corrupt) != 0 {
+		t.Fatalf("missing corrupt keys %v", corrupt)
+	}
+}
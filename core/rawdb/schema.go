@@ -46,6 +46,13 @@ var (
 	// headBlockKey tracks the latest known full block's hash.
 	headBlockKey = []byte("LastBlock")
 
+	// acceptorTipKey tracks the hash of the last accepted block whose
+	// side effects (tx lookup entries) have been written.
+	acceptorTipKey = []byte("AcceptorTipKey")
+
+	// txIndexTailKey tracks the oldest block whose transactions have been indexed.
+	txIndexTailKey = []byte("TransactionIndexTail")
+
 	// snapshotRootKey tracks the hash of the last snapshot.
 	snapshotRootKey = []byte("SnapshotRoot")
 
@@ -61,6 +68,9 @@ var (
 	// offlinePruningKey tracks runs of offline pruning
 	offlinePruningKey = []byte("OfflinePruning")
 
+	// onlinePruningKey tracks the progress of a running online pruning
+	onlinePruningKey = []byte("OnlinePruning")
+
 	// populateMissingTriesKey tracks runs of trie backfills
 	populateMissingTriesKey = []byte("PopulateMissingTries")
 
@@ -68,6 +78,9 @@ var (
 	// to ensure that a user does not accidentally corrupt an archival node.
 	pruningDisabledKey = []byte("PruningDisabled")
 
+	// syncRootKey tracks the root of the state trie being synced from peers.
+	syncRootKey = []byte("sync_root")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerHashSuffix   = []byte("n") // headerPrefix + num (uint64 big endian) + headerHashSuffix -> hash
@@ -82,6 +95,14 @@ var (
 	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
 	CodePrefix            = []byte("c") // CodePrefix + code hash -> account code
 
+	// State sync progress prefixes, removed once the state sync completes.
+	syncSegmentsPrefix  = []byte("sync_segments")  // syncSegmentsPrefix + account hash + trie root + segment start -> last synced key of the segment
+	syncLeafsPrefix     = []byte("sync_leafs")     // syncLeafsPrefix + account hash + leaf key -> leaf value
+	syncStackTriePrefix = []byte("sync_stacktrie") // syncStackTriePrefix + account hash + trie root -> serialized stack trie of the first segment
+	codeToFetchPrefix   = []byte("CP")             // codeToFetchPrefix + code hash -> empty value
+
+	stateDiffPrefix = []byte("state-diff-") // stateDiffPrefix + num (uint64 big endian) -> state diff of the accepted block
+
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
@@ -92,6 +113,29 @@ var (
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
 )
 
+const (
+	// freezerHeaderTable indicates the name of the freezer header table.
+	freezerHeaderTable = "headers"
+
+	// freezerHashTable indicates the name of the freezer canonical hash table.
+	freezerHashTable = "hashes"
+
+	// freezerBodiesTable indicates the name of the freezer block body table.
+	freezerBodiesTable = "bodies"
+
+	// freezerReceiptTable indicates the name of the freezer receipts table.
+	freezerReceiptTable = "receipts"
+)
+
+// FreezerNoSnappy configures whether compression is disabled for the ancient-tables.
+// Hashes don't compress well.
+var FreezerNoSnappy = map[string]bool{
+	freezerHeaderTable:  false,
+	freezerHashTable:    true,
+	freezerBodiesTable:  false,
+	freezerReceiptTable: false,
+}
+
 // LegacyTxLookupEntry is the legacy TxLookupEntry definition with some unnecessary
 // fields.
 type LegacyTxLookupEntry struct {
@@ -167,6 +211,11 @@ func bloomBitsKey(bit uint, section uint64, hash common.Hash) []byte {
 	return key
 }
 
+// stateDiffKey = stateDiffPrefix + num (uint64 big endian)
+func stateDiffKey(number uint64) []byte {
+	return append(append([]byte{}, stateDiffPrefix...), encodeBlockNumber(number)...)
+}
+
 // preimageKey = preimagePrefix + hash
 func preimageKey(hash common.Hash) []byte {
 	return append(preimagePrefix, hash.Bytes()...)
@@ -186,6 +235,31 @@ func IsCodeKey(key []byte) (bool, []byte) {
 	return false, nil
 }
 
+// syncSegmentsKey = syncSegmentsPrefix + account hash + trie root
+func syncSegmentsKey(account common.Hash, root common.Hash) []byte {
+	return append(append(common.CopyBytes(syncSegmentsPrefix), account.Bytes()...), root.Bytes()...)
+}
+
+// syncSegmentKey = syncSegmentsPrefix + account hash + trie root + segment start
+func syncSegmentKey(account common.Hash, root common.Hash, start []byte) []byte {
+	return append(syncSegmentsKey(account, root), start...)
+}
+
+// syncStackTrieKey = syncStackTriePrefix + account hash + trie root
+func syncStackTrieKey(account common.Hash, root common.Hash) []byte {
+	return append(append(common.CopyBytes(syncStackTriePrefix), account.Bytes()...), root.Bytes()...)
+}
+
+// syncLeafsKey = syncLeafsPrefix + account hash
+func syncLeafsKey(account common.Hash) []byte {
+	return append(common.CopyBytes(syncLeafsPrefix), account.Bytes()...)
+}
+
+// codeToFetchKey = codeToFetchPrefix + code hash
+func codeToFetchKey(hash common.Hash) []byte {
+	return append(common.CopyBytes(codeToFetchPrefix), hash.Bytes()...)
+}
+
 // configKey = configPrefix + hash
 func configKey(hash common.Hash) []byte {
 	return append(configPrefix, hash.Bytes()...)
@@ -0,0 +1,473 @@
+// (c) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package pruner
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/ethdb"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// onlinePruningBatchKeys is the number of database keys scanned by the online
+// pruner between two pauses.
+const onlinePruningBatchKeys = 10_000
+
+// Phases of an online pruning
+const (
+	OnlinePruningIdle     = "idle"     // No pruning is running
+	OnlinePruningPending  = "pending"  // A pruning starts at the next accepted block
+	OnlinePruningMarking  = "marking"  // The nodes of the kept states are added to the bloom filter
+	OnlinePruningSweeping = "sweeping" // The trie nodes missing from the bloom filter are deleted
+)
+
+var (
+	errOnlinePruningRunning = errors.New("online pruning is already running")
+	errOnlinePruningStopped = errors.New("online pruning stopped")
+)
+
+// OnlinePruningStatus is the progress of an online pruning.
+type OnlinePruningStatus struct {
+	Phase  string             `json:"phase"`
+	Root   common.Hash        `json:"root"`   // Root of the state kept by the pruning
+	Marker hexutil.Bytes      `json:"marker"` // Database key the deletion resumes from
+	Nodes  uint64             `json:"nodes"`  // Number of trie nodes deleted
+	Size   common.StorageSize `json:"size"`   // Size of the trie nodes deleted
+}
+
+// OnlinePruner deletes the stale trie nodes in the background while the chain
+// keeps accepting blocks. A pruning keeps the state of an accepted block,
+// committed to disk when the pruning starts, together with the states still
+// held in memory and all the trie nodes written while it runs:
+//
+//   - the nodes of the kept states are added to a bloom filter
+//   - the database is scanned in throttled batches, deleting the trie nodes
+//     missing from the bloom filter
+//
+// Trie nodes must be written through Database for the nodes written while a
+// pruning runs to be kept. Contract code is never deleted.
+//
+// The progress of the deletion is persisted, so that a pruning interrupted by
+// a shutdown resumes from the next accepted block after a restart.
+type OnlinePruner struct {
+	db        ethdb.Database
+	bloomSize uint64
+	throttle  time.Duration
+
+	lock   sync.Mutex  // Protects the fields below and makes deletions atomic with node writes
+	bloom  *stateBloom // Trie nodes to keep, nil unless a pruning is running
+	status OnlinePruningStatus
+	quit   chan struct{}
+
+	wg sync.WaitGroup
+}
+
+// NewOnlinePruner creates an online pruner of the trie nodes in [db], using a
+// bloom filter of [bloomSize] MB and pausing for [throttle] between batches.
+func NewOnlinePruner(db ethdb.Database, bloomSize uint64, throttle time.Duration) *OnlinePruner {
+	if bloomSize < 256 {
+		log.Warn("Sanitizing bloomfilter size", "provided(MB)", bloomSize, "updated(MB)", 256)
+		bloomSize = 256
+	}
+	p := &OnlinePruner{
+		db:        db,
+		bloomSize: bloomSize,
+		throttle:  throttle,
+		status:    OnlinePruningStatus{Phase: OnlinePruningIdle},
+	}
+	// Resume a pruning interrupted by a shutdown
+	if marker, ok := rawdb.ReadOnlinePruning(db); ok {
+		log.Info("Resuming online pruning at the next accepted block", "marker", hexutil.Bytes(marker))
+		p.status.Phase = OnlinePruningPending
+	}
+	return p
+}
+
+// Database returns the database of the pruner wrapped so that the trie nodes
+// written to it are kept by a running pruning. It must be used as the disk
+// database of the trie database whose tries are pruned.
+func (p *OnlinePruner) Database() ethdb.Database {
+	return &keptDatabase{Database: p.db, pruner: p}
+}
+
+// Request requests a pruning, to be started with Start at the next accepted
+// block.
+func (p *OnlinePruner) Request() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.status.Phase != OnlinePruningIdle {
+		return errOnlinePruningRunning
+	}
+	p.status = OnlinePruningStatus{Phase: OnlinePruningPending}
+	return nil
+}
+
+// Pending returns true if a pruning was requested and not started yet.
+func (p *OnlinePruner) Pending() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.status.Phase == OnlinePruningPending
+}
+
+// Status returns the progress of the current or last pruning.
+func (p *OnlinePruner) Status() OnlinePruningStatus {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.status
+}
+
+// Start starts the requested pruning in the background, keeping the state
+// [root], which must be committed to the disk database of [triedb], and the
+// tries held in memory by [triedb].
+func (p *OnlinePruner) Start(root common.Hash, triedb *trie.Database) error {
+	bloom, err := newStateBloomWithSize(p.bloomSize)
+	if err != nil {
+		return err
+	}
+	marker, resumed := rawdb.ReadOnlinePruning(p.db)
+	if !resumed {
+		if err := rawdb.WriteOnlinePruning(p.db, nil); err != nil {
+			return err
+		}
+	}
+
+	// From now on, all trie nodes written are kept
+	p.lock.Lock()
+	p.bloom = bloom
+	p.status = OnlinePruningStatus{Phase: OnlinePruningMarking, Root: root, Marker: marker}
+	p.quit = make(chan struct{})
+	p.lock.Unlock()
+
+	// The tries held in memory may reference nodes flushed to disk before the
+	// pruning started, so they are referenced until their nodes are kept.
+	roots := triedb.Roots()
+	for _, memRoot := range roots {
+		triedb.Reference(memRoot, common.Hash{})
+	}
+	log.Info("Starting online pruning", "root", root, "memoryRoots", len(roots), "resumed", resumed)
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.run(root, roots, triedb, marker)
+	}()
+	return nil
+}
+
+// Stop stops a running pruning, keeping its progress so that it resumes after
+// a restart.
+func (p *OnlinePruner) Stop() {
+	p.lock.Lock()
+	if p.status.Phase == OnlinePruningMarking || p.status.Phase == OnlinePruningSweeping {
+		select {
+		case <-p.quit: // Already stopping
+		default:
+			close(p.quit)
+		}
+	}
+	p.lock.Unlock()
+
+	p.wg.Wait()
+}
+
+// Cancel stops a running or requested pruning and discards its progress.
+func (p *OnlinePruner) Cancel() error {
+	p.Stop()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.status.Phase = OnlinePruningIdle
+	return rawdb.DeleteOnlinePruning(p.db)
+}
+
+// run keeps the nodes of the state [root] and of the tries [roots] held in
+// memory, then deletes all other trie nodes from [marker] on.
+func (p *OnlinePruner) run(root common.Hash, roots []common.Hash, triedb *trie.Database, marker []byte) {
+	start := time.Now()
+	err := p.mark(emptyRoot, root, triedb)
+	for _, memRoot := range roots {
+		if err != nil {
+			break
+		}
+		if memRoot != root {
+			err = p.mark(root, memRoot, triedb)
+		}
+	}
+	for _, memRoot := range roots {
+		triedb.Dereference(memRoot)
+	}
+	if err == nil {
+		// Keep the genesis state as the offline pruner does
+		err = extractGenesis(p.db, keeper{p})
+	}
+	if err == nil {
+		log.Info("Marked online pruning state", "root", root, "elapsed", common.PrettyDuration(time.Since(start)))
+		err = p.sweep(marker)
+	}
+
+	p.lock.Lock()
+	p.bloom = nil
+	p.status.Phase = OnlinePruningIdle
+	status := p.status
+	p.lock.Unlock()
+
+	switch {
+	case errors.Is(err, errOnlinePruningStopped):
+		log.Info("Online pruning stopped", "nodes", status.Nodes, "size", status.Size, "elapsed", common.PrettyDuration(time.Since(start)))
+	case err != nil:
+		log.Error("Online pruning failed", "root", root, "err", err)
+		if err := rawdb.DeleteOnlinePruning(p.db); err != nil {
+			log.Error("Failed to delete online pruning progress", "err", err)
+		}
+	default:
+		if err := rawdb.DeleteOnlinePruning(p.db); err != nil {
+			log.Error("Failed to delete online pruning progress", "err", err)
+		}
+		log.Info("Online pruning finished", "nodes", status.Nodes, "size", status.Size, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+}
+
+// mark keeps the nodes of the state [root] that are not part of the state
+// [base], comparing both tries path by path to skip the subtries they share.
+// With an empty [base], all nodes of [root] are kept.
+func (p *OnlinePruner) mark(base, root common.Hash, triedb *trie.Database) error {
+	baseTrie, err := trie.New(base, triedb)
+	if err != nil {
+		return err
+	}
+	it, err := differenceIterator(baseTrie, root, triedb)
+	if err != nil {
+		return err
+	}
+	var (
+		nodes  int
+		logged = time.Now()
+	)
+	for it.Next(true) {
+		select {
+		case <-p.quit:
+			return errOnlinePruningStopped
+		default:
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Marking online pruning state", "root", root, "nodes", nodes)
+			logged = time.Now()
+		}
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			p.keep(hash[:])
+			nodes++
+		}
+		if !it.Leaf() {
+			continue
+		}
+		var account types.StateAccount
+		if err := rlp.DecodeBytes(it.LeafBlob(), &account); err != nil {
+			return err
+		}
+		if account.Root == emptyRoot {
+			continue
+		}
+		baseStorage := emptyRoot
+		if base != emptyRoot {
+			blob, err := baseTrie.TryGet(it.LeafKey())
+			if err != nil {
+				return err
+			}
+			if len(blob) > 0 {
+				var baseAccount types.StateAccount
+				if err := rlp.DecodeBytes(blob, &baseAccount); err != nil {
+					return err
+				}
+				baseStorage = baseAccount.Root
+			}
+		}
+		if baseStorage == account.Root {
+			continue
+		}
+		baseStorageTrie, err := trie.New(baseStorage, triedb)
+		if err != nil {
+			return err
+		}
+		storageIt, err := differenceIterator(baseStorageTrie, account.Root, triedb)
+		if err != nil {
+			return err
+		}
+		for storageIt.Next(true) {
+			if hash := storageIt.Hash(); hash != (common.Hash{}) {
+				p.keep(hash[:])
+				nodes++
+			}
+		}
+		if err := storageIt.Error(); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// differenceIterator returns an iterator over the nodes of the trie [root]
+// that are not in [baseTrie].
+func differenceIterator(baseTrie *trie.Trie, root common.Hash, triedb *trie.Database) (trie.NodeIterator, error) {
+	tr, err := trie.New(root, triedb)
+	if err != nil {
+		return nil, err
+	}
+	if baseTrie.Hash() == emptyRoot {
+		return tr.NodeIterator(nil), nil
+	}
+	it, _ := trie.NewDifferenceIterator(baseTrie.NodeIterator(nil), tr.NodeIterator(nil))
+	return it, nil
+}
+
+// sweep deletes the trie nodes missing from the bloom filter, from the
+// database key [marker] on.
+func (p *OnlinePruner) sweep(marker []byte) error {
+	p.lock.Lock()
+	p.status.Phase = OnlinePruningSweeping
+	p.lock.Unlock()
+
+	var (
+		logged = time.Now()
+		keys   [][]byte
+		sizes  []common.StorageSize
+	)
+	for {
+		var (
+			iter    = p.db.NewIterator(nil, marker)
+			scanned int
+			done    = true
+		)
+		keys, sizes = keys[:0], sizes[:0]
+		for iter.Next() {
+			key := iter.Key()
+			if len(key) == common.HashLength {
+				keys = append(keys, common.CopyBytes(key))
+				sizes = append(sizes, common.StorageSize(len(key)+len(iter.Value())))
+			}
+			if scanned++; scanned == onlinePruningBatchKeys {
+				// Resume from the key following the last one scanned
+				marker = append(common.CopyBytes(key), 0)
+				done = false
+				break
+			}
+		}
+		err := iter.Error()
+		iter.Release()
+		if err != nil {
+			return fmt.Errorf("failed to iterate db during online pruning: %w", err)
+		}
+		if done {
+			marker = nil
+		}
+		if err := p.deleteStale(keys, sizes, marker); err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		if time.Since(logged) > 8*time.Second {
+			status := p.Status()
+			log.Info("Pruning state data online", "nodes", status.Nodes, "size", status.Size, "marker", status.Marker)
+			logged = time.Now()
+		}
+		select {
+		case <-p.quit:
+			return errOnlinePruningStopped
+		case <-time.After(p.throttle):
+		}
+	}
+}
+
+// deleteStale deletes the trie nodes [keys] missing from the bloom filter and
+// persists [marker] as the progress of the pruning. The lock is held so that
+// no node is written between checking the bloom filter and deleting it.
+func (p *OnlinePruner) deleteStale(keys [][]byte, sizes []common.StorageSize, marker []byte) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var (
+		batch = p.db.NewBatch()
+		nodes uint64
+		size  common.StorageSize
+	)
+	for i, key := range keys {
+		if ok, _ := p.bloom.Contain(key); ok {
+			continue
+		}
+		if err := batch.Delete(key); err != nil {
+			return err
+		}
+		nodes++
+		size += sizes[i]
+	}
+	if err := rawdb.WriteOnlinePruning(batch, marker); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	p.status.Nodes += nodes
+	p.status.Size += size
+	p.status.Marker = marker
+	return nil
+}
+
+// keep adds the trie node [key] to the nodes kept by a running pruning.
+func (p *OnlinePruner) keep(key []byte) {
+	if len(key) != common.HashLength {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.bloom != nil {
+		p.bloom.Put(key, nil)
+	}
+}
+
+// keeper is a key-value writer adding the keys written to the nodes kept by
+// a running pruning.
+type keeper struct {
+	pruner *OnlinePruner
+}
+
+func (k keeper) Put(key []byte, value []byte) error {
+	k.pruner.keep(key)
+	return nil
+}
+
+func (k keeper) Delete(key []byte) error { panic("not supported") }
+
+// keptDatabase is a database whose written trie nodes are kept by a running
+// pruning.
+type keptDatabase struct {
+	ethdb.Database
+	pruner *OnlinePruner
+}
+
+func (db *keptDatabase) Put(key []byte, value []byte) error {
+	db.pruner.keep(key)
+	return db.Database.Put(key, value)
+}
+
+func (db *keptDatabase) NewBatch() ethdb.Batch {
+	return ethdb.HookedBatch{
+		Batch: db.Database.NewBatch(),
+		OnPut: func(key []byte, _ []byte) { db.pruner.keep(key) },
+	}
+}
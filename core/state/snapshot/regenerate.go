@@ -0,0 +1,57 @@
+// (c) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/ethdb"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// regenerateCache is the size (MB) of the disk layer cache used while
+// regenerating a trie, which reads every entry once.
+const regenerateCache = 16
+
+// RegenerateTrie rebuilds the state trie of the block [blockHash] with the
+// state root [root] from the snapshot persisted in [diskdb], writing all the
+// trie nodes of the account and storage tries back to [diskdb]. Since trie
+// nodes are keyed by their hash, this restores missing and corrupt nodes
+// without touching any other state.
+//
+// The snapshot must be fully generated and its disk layer must belong to
+// [blockHash]. The trie is never read, so it can be used when the trie
+// database was partially lost but the snapshot is intact. The regenerated
+// root is checked against [root], failing if the snapshot is inconsistent.
+func RegenerateTrie(diskdb ethdb.Database, blockHash, root common.Hash) error {
+	blob := rawdb.ReadSnapshotGenerator(diskdb)
+	if len(blob) == 0 {
+		return errors.New("missing snapshot generator")
+	}
+	var generator journalGenerator
+	if err := rlp.DecodeBytes(blob, &generator); err != nil {
+		return fmt.Errorf("failed to decode snapshot generator: %w", err)
+	}
+	// Resuming the generation of the snapshot would read the trie
+	if !generator.Done {
+		return errors.New("cannot regenerate trie from an incomplete snapshot")
+	}
+	snaptree, err := New(diskdb, trie.NewDatabase(diskdb), regenerateCache, blockHash, root, false, false, false)
+	if err != nil {
+		return fmt.Errorf("failed to load snapshot: %w", err)
+	}
+	log.Info("Regenerating state trie from snapshot", "hash", blockHash, "root", root)
+	start := time.Now()
+	if err := GenerateTrie(snaptree, root, diskdb, diskdb); err != nil {
+		return err
+	}
+	log.Info("Regenerated state trie from snapshot", "root", root, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
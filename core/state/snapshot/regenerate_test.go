@@ -0,0 +1,60 @@
+// (c) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package snapshot
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that a trie with missing and corrupt nodes is restored from a fully
+// generated snapshot.
+func TestRegenerateTrie(t *testing.T) {
+	helper := newHelper()
+	stRoot := helper.makeStorageTrie([]string{"key-1", "key-2", "key-3"}, []string{"val-1", "val-2", "val-3"})
+	helper.addTrieAccount("acc-1", &Account{Balance: big.NewInt(1), Root: stRoot, CodeHash: emptyCode.Bytes()})
+	helper.addTrieAccount("acc-2", &Account{Balance: big.NewInt(2), Root: emptyRoot.Bytes(), CodeHash: emptyCode.Bytes()})
+	helper.addTrieAccount("acc-3", &Account{Balance: big.NewInt(3), Root: stRoot, CodeHash: emptyCode.Bytes()})
+
+	blockHash := common.HexToHash("0xdeadbeef")
+	root, snap := helper.Generate()
+	select {
+	case <-snap.genPending:
+	case <-time.After(250 * time.Millisecond):
+		t.Fatal("snapshot generation failed")
+	}
+	stop := make(chan struct{})
+	snap.genAbort <- stop
+	<-stop
+
+	// Lose the account trie root and corrupt a storage trie node
+	rawdb.DeleteTrieNode(helper.diskdb, root)
+	rawdb.WriteTrieNode(helper.diskdb, common.BytesToHash(stRoot), []byte{0x01})
+
+	db := rawdb.NewDatabase(helper.diskdb)
+	if err := RegenerateTrie(db, blockHash, common.HexToHash("0x01")); err == nil {
+		t.Fatal("expected an error regenerating a trie not held by the snapshot")
+	}
+	if err := RegenerateTrie(db, blockHash, root); err != nil {
+		t.Fatal(err)
+	}
+	triedb := trie.NewDatabase(helper.diskdb)
+	for _, hash := range []common.Hash{root, common.BytesToHash(stRoot)} {
+		tr, err := trie.New(hash, triedb)
+		if err != nil {
+			t.Fatalf("failed to open trie %s: %v", hash, err)
+		}
+		it := tr.NodeIterator(nil)
+		for it.Next(true) {
+		}
+		if err := it.Error(); err != nil {
+			t.Fatalf("incomplete trie %s: %v", hash, err)
+		}
+	}
+}
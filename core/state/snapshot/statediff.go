@@ -0,0 +1,264 @@
+// (c) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package snapshot
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// stateDiffAccount is an account changed by a block, in the slim snapshot
+// format. An empty blob means the account was deleted.
+type stateDiffAccount struct {
+	Hash common.Hash
+	Blob []byte
+}
+
+// stateDiffStorage is the set of storage slots of an account changed by a
+// block. An empty value means the slot was deleted.
+type stateDiffStorage struct {
+	Hash common.Hash
+	Keys []common.Hash
+	Vals [][]byte
+}
+
+// stateDiff is the persisted form of the changes made by a single block, as
+// passed to Tree.Update.
+type stateDiff struct {
+	Destructs []common.Hash
+	Accounts  []stateDiffAccount
+	Storage   []stateDiffStorage
+}
+
+// StateDiff returns the RLP encoded changes made by the block [blockHash], as
+// passed to Update. The block must still be held by a diff layer, so this has
+// to be called before the block is flattened.
+func (t *Tree) StateDiff(blockHash common.Hash) ([]byte, error) {
+	t.lock.RLock()
+	snap, ok := t.blockLayers[blockHash]
+	t.lock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("missing snapshot: %s", blockHash)
+	}
+	diff, ok := snap.(*diffLayer)
+	if !ok {
+		return nil, fmt.Errorf("snapshot is not a diff layer: (%s, %s)", blockHash, snap.Root())
+	}
+	diff.lock.RLock()
+	defer diff.lock.RUnlock()
+
+	return encodeStateDiff(diff.destructSet, diff.accountData, diff.storageData)
+}
+
+// encodeStateDiff RLP encodes the given changes, sorting all of them so the
+// encoding is deterministic.
+func encodeStateDiff(destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) ([]byte, error) {
+	diff := stateDiff{
+		Destructs: make([]common.Hash, 0, len(destructs)),
+		Accounts:  make([]stateDiffAccount, 0, len(accounts)),
+		Storage:   make([]stateDiffStorage, 0, len(storage)),
+	}
+	for hash := range destructs {
+		diff.Destructs = append(diff.Destructs, hash)
+	}
+	sort.Sort(hashes(diff.Destructs))
+
+	accountHashes := make([]common.Hash, 0, len(accounts))
+	for hash := range accounts {
+		accountHashes = append(accountHashes, hash)
+	}
+	sort.Sort(hashes(accountHashes))
+	for _, hash := range accountHashes {
+		diff.Accounts = append(diff.Accounts, stateDiffAccount{Hash: hash, Blob: accounts[hash]})
+	}
+
+	storageHashes := make([]common.Hash, 0, len(storage))
+	for hash := range storage {
+		storageHashes = append(storageHashes, hash)
+	}
+	sort.Sort(hashes(storageHashes))
+	for _, hash := range storageHashes {
+		slots := storage[hash]
+		entry := stateDiffStorage{
+			Hash: hash,
+			Keys: make([]common.Hash, 0, len(slots)),
+			Vals: make([][]byte, 0, len(slots)),
+		}
+		for key := range slots {
+			entry.Keys = append(entry.Keys, key)
+		}
+		sort.Sort(hashes(entry.Keys))
+		for _, key := range entry.Keys {
+			entry.Vals = append(entry.Vals, slots[key])
+		}
+		diff.Storage = append(diff.Storage, entry)
+	}
+	return rlp.EncodeToBytes(&diff)
+}
+
+// diffOverlay is a Snapshot of the state at [root], built by merging the
+// state diffs of consecutive blocks on top of the trie of an older state.
+// Anything not changed by the diffs is read from the base trie.
+type diffOverlay struct {
+	root   common.Hash
+	triedb *trie.Database
+
+	destructs map[common.Hash]struct{}               // Accounts deleted since the base state
+	accounts  map[common.Hash][]byte                 // Accounts changed since the base state (nil means deleted)
+	storage   map[common.Hash]map[common.Hash][]byte // Slots changed since the base state (nil means deleted)
+
+	base         *trie.Trie                 // Account trie of the base state
+	storageTries map[common.Hash]*trie.Trie // Storage tries of the base state opened so far
+
+	lock sync.Mutex // Protects the base tries, which cache resolved nodes
+}
+
+// NewDiffOverlay returns a Snapshot of the state at [root], made of the
+// encoded state diffs [diffs] of the blocks following the state [baseRoot],
+// oldest first, applied on top of the trie of [baseRoot] in [triedb].
+func NewDiffOverlay(triedb *trie.Database, baseRoot, root common.Hash, diffs [][]byte) (Snapshot, error) {
+	base, err := trie.New(baseRoot, triedb)
+	if err != nil {
+		return nil, err
+	}
+	overlay := &diffOverlay{
+		root:         root,
+		triedb:       triedb,
+		destructs:    make(map[common.Hash]struct{}),
+		accounts:     make(map[common.Hash][]byte),
+		storage:      make(map[common.Hash]map[common.Hash][]byte),
+		base:         base,
+		storageTries: make(map[common.Hash]*trie.Trie),
+	}
+	for i, blob := range diffs {
+		var diff stateDiff
+		if err := rlp.DecodeBytes(blob, &diff); err != nil {
+			return nil, fmt.Errorf("failed to decode state diff %d: %w", i, err)
+		}
+		overlay.apply(&diff)
+	}
+	return overlay, nil
+}
+
+// apply merges [diff] into the overlay. Destructs are applied first, since
+// any account and storage data in the same diff belongs to a recreated
+// account.
+func (o *diffOverlay) apply(diff *stateDiff) {
+	for _, hash := range diff.Destructs {
+		o.destructs[hash] = struct{}{}
+		delete(o.accounts, hash)
+		delete(o.storage, hash)
+	}
+	for _, account := range diff.Accounts {
+		var blob []byte
+		if len(account.Blob) > 0 {
+			blob = account.Blob
+		}
+		o.accounts[account.Hash] = blob
+	}
+	for _, entry := range diff.Storage {
+		slots, ok := o.storage[entry.Hash]
+		if !ok {
+			slots = make(map[common.Hash][]byte, len(entry.Keys))
+			o.storage[entry.Hash] = slots
+		}
+		for i, key := range entry.Keys {
+			var val []byte
+			if len(entry.Vals[i]) > 0 {
+				val = entry.Vals[i]
+			}
+			slots[key] = val
+		}
+	}
+}
+
+// Root returns the root hash of the state the overlay represents.
+func (o *diffOverlay) Root() common.Hash {
+	return o.root
+}
+
+// Account directly retrieves the account associated with a particular hash in
+// the snapshot slim data format.
+func (o *diffOverlay) Account(hash common.Hash) (*Account, error) {
+	data, err := o.AccountRLP(hash)
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+	account := new(Account)
+	if err := rlp.DecodeBytes(data, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// AccountRLP directly retrieves the account RLP associated with a particular
+// hash in the snapshot slim data format.
+func (o *diffOverlay) AccountRLP(hash common.Hash) ([]byte, error) {
+	if data, ok := o.accounts[hash]; ok {
+		return data, nil
+	}
+	if _, destructed := o.destructs[hash]; destructed {
+		return nil, nil
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	account, err := o.baseAccount(hash)
+	if err != nil || account == nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(SlimAccount(account.Nonce, account.Balance, common.BytesToHash(account.Root), account.CodeHash, account.IsMultiCoin))
+}
+
+// Storage directly retrieves the storage data associated with a particular hash,
+// within a particular account.
+func (o *diffOverlay) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	if slots, ok := o.storage[accountHash]; ok {
+		if data, ok := slots[storageHash]; ok {
+			return data, nil
+		}
+	}
+	if _, destructed := o.destructs[accountHash]; destructed {
+		return nil, nil
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	storageTrie, ok := o.storageTries[accountHash]
+	if !ok {
+		account, err := o.baseAccount(accountHash)
+		if err != nil {
+			return nil, err
+		}
+		if account != nil && common.BytesToHash(account.Root) != emptyRoot {
+			if storageTrie, err = trie.New(common.BytesToHash(account.Root), o.triedb); err != nil {
+				return nil, err
+			}
+		}
+		o.storageTries[accountHash] = storageTrie
+	}
+	if storageTrie == nil {
+		return nil, nil
+	}
+	return storageTrie.TryGet(storageHash[:])
+}
+
+// baseAccount returns the account [hash] in the consensus format from the
+// base trie, or nil if it does not exist. Assumes the lock is held.
+func (o *diffOverlay) baseAccount(hash common.Hash) (*Account, error) {
+	data, err := o.base.TryGet(hash[:])
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+	account := new(Account)
+	if err := rlp.DecodeBytes(data, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
@@ -0,0 +1,69 @@
+// (c) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package snapshot
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ava-labs/coreth/ethdb/memorydb"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that merging state diffs on top of an empty base keeps the newest
+// data, and that a destruct hides all older data of the account.
+func TestDiffOverlay(t *testing.T) {
+	var (
+		acc1  = common.HexToHash("0x01")
+		acc2  = common.HexToHash("0x02")
+		slot1 = common.HexToHash("0x11")
+		slot2 = common.HexToHash("0x12")
+	)
+	first := randomAccountSet("0x01", "0x02")
+	second := randomAccountSet("0x02")
+
+	firstStorage := randomStorageSet([]string{"0x01", "0x02"}, [][]string{{"0x11", "0x12"}, {"0x11"}}, nil)
+	diff1, err := encodeStateDiff(nil, first, firstStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second block deletes slot2 of acc1 and recreates acc2 with only
+	// slot2 in storage
+	secondStorage := randomStorageSet([]string{"0x01", "0x02"}, [][]string{{}, {"0x12"}}, [][]string{{"0x12"}})
+	diff2, err := encodeStateDiff(map[common.Hash]struct{}{acc2: {}}, second, secondStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	triedb := trie.NewDatabase(memorydb.New())
+	overlay, err := NewDiffOverlay(triedb, emptyRoot, common.HexToHash("0xff"), [][]byte{diff1, diff2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root := overlay.Root(); root != common.HexToHash("0xff") {
+		t.Fatalf("unexpected root %s", root)
+	}
+	for hash, want := range map[common.Hash][]byte{acc1: first[acc1], acc2: second[acc2]} {
+		if have, err := overlay.AccountRLP(hash); err != nil || !bytes.Equal(have, want) {
+			t.Fatalf("account %s: expected %x, have %x (err: %v)", hash, want, have, err)
+		}
+	}
+	if have, err := overlay.AccountRLP(common.HexToHash("0x03")); err != nil || have != nil {
+		t.Fatalf("expected missing account, have %x (err: %v)", have, err)
+	}
+	for _, test := range []struct {
+		account, slot common.Hash
+		want          []byte
+	}{
+		{acc1, slot1, firstStorage[acc1][slot1]},
+		{acc1, slot2, nil},
+		{acc2, slot1, nil},
+		{acc2, slot2, secondStorage[acc2][slot2]},
+	} {
+		if have, err := overlay.Storage(test.account, test.slot); err != nil || !bytes.Equal(have, test.want) {
+			t.Fatalf("slot %s of %s: expected %x, have %x (err: %v)", test.slot, test.account, test.want, have, err)
+		}
+	}
+}
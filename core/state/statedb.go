@@ -53,6 +53,10 @@ type revision struct {
 var (
 	// emptyRoot is the known root hash of an empty trie.
 	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// errStateFromDiffs is returned when proving or committing a state rebuilt
+	// from state diffs, whose trie is the trie of an older state.
+	errStateFromDiffs = errors.New("state rebuilt from state diffs cannot be proven or committed")
 )
 
 type proofList [][]byte
@@ -78,6 +82,11 @@ type StateDB struct {
 	trie         Trie
 	hasher       crypto.KeccakState
 
+	// fromStateDiffs is set if [trie] is the trie of an older state and the
+	// state was rebuilt by applying state diffs, so it cannot be proven or
+	// committed.
+	fromStateDiffs bool
+
 	snap          snapshot.Snapshot
 	snapDestructs map[common.Hash]struct{}
 	snapAccounts  map[common.Hash][]byte
@@ -147,7 +156,30 @@ func New(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error)
 // an error. If snap is nil, then no snapshot will be used and CommitWithSnapshot
 // cannot be called on the returned StateDB.
 func NewWithSnapshot(root common.Hash, db Database, snap snapshot.Snapshot) (*StateDB, error) {
-	tr, err := db.OpenTrie(root)
+	return newWithSnapshot(root, root, db, snap)
+}
+
+// NewWithStateDiffs creates a new read only state for [root] from the trie of
+// the older state [baseRoot] and the encoded state diffs [diffs] of the
+// blocks in between, oldest first. All reads are served by the diffs and the
+// trie of [baseRoot], so the state can neither be committed nor proven.
+func NewWithStateDiffs(root, baseRoot common.Hash, db Database, diffs [][]byte) (*StateDB, error) {
+	overlay, err := snapshot.NewDiffOverlay(db.TrieDB(), baseRoot, root, diffs)
+	if err != nil {
+		return nil, err
+	}
+	sdb, err := newWithSnapshot(root, baseRoot, db, overlay)
+	if err != nil {
+		return nil, err
+	}
+	sdb.fromStateDiffs = true
+	return sdb, nil
+}
+
+// newWithSnapshot creates a new state for [root] reading from [snap] and
+// falling back to the trie of [trieRoot].
+func newWithSnapshot(root, trieRoot common.Hash, db Database, snap snapshot.Snapshot) (*StateDB, error) {
+	tr, err := db.OpenTrie(trieRoot)
 	if err != nil {
 		return nil, err
 	}
@@ -337,6 +369,17 @@ func (s *StateDB) GetCodeHash(addr common.Address) common.Hash {
 	return common.BytesToHash(stateObject.CodeHash())
 }
 
+// GetStorageRoot retrieves the storage root of the account [addr], read from
+// the snapshot if the state is backed by one, or an empty hash if the account
+// does not exist.
+func (s *StateDB) GetStorageRoot(addr common.Address) common.Hash {
+	stateObject := s.getStateObject(addr)
+	if stateObject == nil {
+		return common.Hash{}
+	}
+	return stateObject.data.Root
+}
+
 // GetState retrieves a value from the given account's storage trie.
 func (s *StateDB) GetState(addr common.Address, hash common.Hash) common.Hash {
 	stateObject := s.getStateObject(addr)
@@ -354,6 +397,9 @@ func (s *StateDB) GetProof(addr common.Address) ([][]byte, error) {
 
 // GetProofByHash returns the Merkle proof for a given account.
 func (s *StateDB) GetProofByHash(addrHash common.Hash) ([][]byte, error) {
+	if s.fromStateDiffs {
+		return nil, errStateFromDiffs
+	}
 	var proof proofList
 	err := s.trie.Prove(addrHash[:], 0, &proof)
 	return proof, err
@@ -361,6 +407,9 @@ func (s *StateDB) GetProofByHash(addrHash common.Hash) ([][]byte, error) {
 
 // GetStorageProof returns the Merkle proof for given storage slot.
 func (s *StateDB) GetStorageProof(a common.Address, key common.Hash) ([][]byte, error) {
+	if s.fromStateDiffs {
+		return nil, errStateFromDiffs
+	}
 	var proof proofList
 	trie := s.StorageTrie(a)
 	if trie == nil {
@@ -717,6 +766,7 @@ func (s *StateDB) Copy() *StateDB {
 	state := &StateDB{
 		db:                  s.db,
 		trie:                s.db.CopyTrie(s.trie),
+		fromStateDiffs:      s.fromStateDiffs,
 		stateObjects:        make(map[common.Address]*stateObject, len(s.journal.dirties)),
 		stateObjectsPending: make(map[common.Address]struct{}, len(s.stateObjectsPending)),
 		stateObjectsDirty:   make(map[common.Address]struct{}, len(s.journal.dirties)),
@@ -976,6 +1026,9 @@ func (s *StateDB) CommitWithSnap(deleteEmptyObjects bool, snaps *snapshot.Tree,
 
 // Commit writes the state to the underlying in-memory trie database.
 func (s *StateDB) commit(deleteEmptyObjects bool, snaps *snapshot.Tree, blockHash, parentHash common.Hash) (common.Hash, error) {
+	if s.fromStateDiffs {
+		return common.Hash{}, errStateFromDiffs
+	}
 	if s.dbErr != nil {
 		return common.Hash{}, fmt.Errorf("commit aborted due to earlier error: %v", s.dbErr)
 	}
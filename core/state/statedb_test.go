@@ -1073,3 +1073,51 @@ func TestGenerateMultiCoinAccounts(t *testing.T) {
 		t.Fatalf("Expected asset balance: %v, found %v", assetBalance, actualAssetBalance)
 	}
 }
+
+func TestStorageFromSnapshot(t *testing.T) {
+	var (
+		diskdb   = rawdb.NewMemoryDatabase()
+		database = NewDatabase(diskdb)
+
+		addr  = common.BytesToAddress([]byte("addr1"))
+		key   = common.BytesToHash([]byte("key1"))
+		value = common.BytesToHash([]byte("value1"))
+	)
+
+	stateDB, err := New(common.Hash{}, database, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateDB.SetState(addr, key, value)
+	root, err := stateDB.Commit(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.TrieDB().Commit(root, true, nil); err != nil {
+		t.Fatal(err)
+	}
+	storageRoot := stateDB.StorageTrie(addr).Hash()
+	snaps, err := snapshot.New(diskdb, database.TrieDB(), 16, common.Hash{}, root, false, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the storage trie, the storage is served by the snapshot
+	rawdb.DeleteTrieNode(diskdb, storageRoot)
+	stateDB, err = New(root, NewDatabase(diskdb), snaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := stateDB.GetStorageRoot(addr); have != storageRoot {
+		t.Fatalf("expected storage root %s, have %s", storageRoot, have)
+	}
+	if have := stateDB.GetState(addr, key); have != value {
+		t.Fatalf("expected value %s, have %s", value, have)
+	}
+	if err := stateDB.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if have := stateDB.GetStorageRoot(common.BytesToAddress([]byte("addr2"))); have != (common.Hash{}) {
+		t.Fatalf("expected an empty storage root for a missing account, have %s", have)
+	}
+}
@@ -35,10 +35,16 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 )
 
-const (
-	commitInterval = 4096
-	tipBufferSize  = 16
-)
+// flushWindow returns the number of blocks before a commit during which the
+// dirty trie nodes are progressively flushed to disk, so the commit itself only
+// needs to write [TrieDirtyCommitTarget] of nodes. The window spans the last
+// 3/16 of the [interval] between commits (768 blocks of the default 4096).
+func flushWindow(interval uint64) uint64 {
+	if window := interval * 3 / 16; window > 0 {
+		return window
+	}
+	return 1
+}
 
 type TrieWriter interface {
 	InsertTrie(block *types.Block) error // Insert reference to trie [root]
@@ -57,14 +63,25 @@ type TrieDB interface {
 
 func NewTrieWriter(db TrieDB, config *CacheConfig) TrieWriter {
 	if config.Pruning {
-		return &cappedMemoryTrieWriter{
+		cm := &cappedMemoryTrieWriter{
 			TrieDB:             db,
 			memoryCap:          common.StorageSize(config.TrieDirtyLimit) * 1024 * 1024,
+			targetCommitSize:   common.StorageSize(config.TrieDirtyCommitTarget) * 1024 * 1024,
 			imageCap:           4 * 1024 * 1024,
-			commitInterval:     commitInterval,
-			tipBuffer:          make([]common.Hash, tipBufferSize),
-			randomizedInterval: uint64(rand.Int63n(commitInterval)) + commitInterval,
+			commitInterval:     config.CommitInterval,
+			syncableInterval:   config.SyncableInterval,
+			tipBuffer:          make([]common.Hash, config.TipBufferSize),
+			randomizedInterval: uint64(rand.Int63n(int64(config.CommitInterval))) + config.CommitInterval,
+		}
+		interval := cm.commitInterval
+		if cm.syncableInterval > 0 && cm.syncableInterval < interval {
+			interval = cm.syncableInterval
+		}
+		cm.flushWindow = flushWindow(interval)
+		if cm.memoryCap > cm.targetCommitSize {
+			cm.flushStepSize = (cm.memoryCap - cm.targetCommitSize) / common.StorageSize(cm.flushWindow)
 		}
+		return cm
 	} else {
 		return &noPruningTrieWriter{
 			TrieDB: db,
@@ -89,8 +106,12 @@ func (np *noPruningTrieWriter) Shutdown() error { return nil }
 type cappedMemoryTrieWriter struct {
 	TrieDB
 	memoryCap                          common.StorageSize
+	targetCommitSize                   common.StorageSize
+	flushStepSize                      common.StorageSize
 	imageCap                           common.StorageSize
 	commitInterval, randomizedInterval uint64
+	syncableInterval                   uint64
+	flushWindow                        uint64
 
 	lastPos   int
 	tipBuffer []common.Hash
@@ -110,12 +131,12 @@ func (cm *cappedMemoryTrieWriter) InsertTrie(block *types.Block) error {
 func (cm *cappedMemoryTrieWriter) AcceptTrie(block *types.Block) error {
 	root := block.Root()
 
-	// Attempt to dereference roots at least [tipBufferSize] old (so queries at tip
-	// can still be completed).
+	// Attempt to dereference roots at least [len(tipBuffer)] old (so queries at
+	// tip can still be completed).
 	//
 	// Note: It is safe to dereference roots that have been committed to disk
 	// (they are no-ops).
-	nextPos := (cm.lastPos + 1) % tipBufferSize
+	nextPos := (cm.lastPos + 1) % len(cm.tipBuffer)
 	if cm.tipBuffer[nextPos] != (common.Hash{}) {
 		cm.TrieDB.Dereference(cm.tipBuffer[nextPos])
 	}
@@ -126,14 +147,53 @@ func (cm *cappedMemoryTrieWriter) AcceptTrie(block *types.Block) error {
 	// the desired interval.
 	// Note: a randomized interval is added here to ensure that pruning nodes
 	// do not all only commit at the exact same heights.
-	if height := block.NumberU64(); height%cm.commitInterval == 0 || height%cm.randomizedInterval == 0 {
+	// Blocks at multiples of [syncableInterval] are always committed, so that
+	// their state can be served to syncing peers.
+	height := block.NumberU64()
+	if height%cm.commitInterval == 0 || height%cm.randomizedInterval == 0 || cm.isSyncable(height) {
 		if err := cm.TrieDB.Commit(root, true, nil); err != nil {
 			return fmt.Errorf("failed to commit trie for block %s: %w", block.Hash().Hex(), err)
 		}
+		return nil
+	}
+
+	// Regardless of the distance to the next commit, the dirty cache must not
+	// grow beyond [memoryCap].
+	if nodes, _ := cm.TrieDB.Size(); nodes > cm.memoryCap {
+		if err := cm.TrieDB.Cap(cm.targetCommitSize); err != nil {
+			return fmt.Errorf("failed to cap trie for block %s: %w", block.Hash().Hex(), err)
+		}
+		return nil
+	}
+
+	// As the next commit approaches, flush the oldest dirty nodes to disk so
+	// that the dirty cache shrinks by [flushStepSize] per block down to
+	// [targetCommitSize]. This spreads the writes of a full dirty cache over
+	// [flushWindow] blocks instead of stalling the commit.
+	distanceFromCommit := cm.commitInterval - height%cm.commitInterval
+	if cm.syncableInterval > 0 {
+		if distance := cm.syncableInterval - height%cm.syncableInterval; distance < distanceFromCommit {
+			distanceFromCommit = distance
+		}
+	}
+	if distanceFromCommit > cm.flushWindow {
+		return nil
+	}
+	targetMemory := cm.targetCommitSize + cm.flushStepSize*common.StorageSize(distanceFromCommit)
+	if nodes, _ := cm.TrieDB.Size(); nodes <= targetMemory {
+		return nil
+	}
+	if err := cm.TrieDB.Cap(targetMemory - ethdb.IdealBatchSize); err != nil {
+		return fmt.Errorf("failed to cap trie for block %s: %w", block.Hash().Hex(), err)
 	}
 	return nil
 }
 
+// isSyncable returns true if the block at [height] can serve state sync.
+func (cm *cappedMemoryTrieWriter) isSyncable(height uint64) bool {
+	return cm.syncableInterval > 0 && height%cm.syncableInterval == 0
+}
+
 func (cm *cappedMemoryTrieWriter) RejectTrie(block *types.Block) error {
 	cm.TrieDB.Dereference(block.Root())
 	return nil
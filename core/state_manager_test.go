@@ -4,6 +4,7 @@
 package core
 
 import (
+	"math"
 	"math/big"
 	"testing"
 
@@ -17,6 +18,8 @@ type MockTrieDB struct {
 	LastReference   common.Hash
 	LastDereference common.Hash
 	LastCommit      common.Hash
+	LastCap         common.StorageSize
+	DirtySize       common.StorageSize
 }
 
 func (t *MockTrieDB) Reference(child common.Hash, parent common.Hash) {
@@ -30,17 +33,22 @@ func (t *MockTrieDB) Commit(root common.Hash, report bool, callback func(common.
 	return nil
 }
 func (t *MockTrieDB) Size() (common.StorageSize, common.StorageSize) {
-	return 0, 0
+	return t.DirtySize, 0
 }
 func (t *MockTrieDB) Cap(limit common.StorageSize) error {
+	t.LastCap = limit
+	if t.DirtySize > limit {
+		t.DirtySize = limit
+	}
 	return nil
 }
 
 func TestCappedMemoryTrieWriter(t *testing.T) {
 	m := &MockTrieDB{}
-	w := NewTrieWriter(m, &CacheConfig{Pruning: true})
+	config := &CacheConfig{Pruning: true, CommitInterval: 4096, TipBufferSize: 16}
+	w := NewTrieWriter(m, config)
 	assert := assert.New(t)
-	for i := 0; i < commitInterval+1; i++ {
+	for i := 0; i < int(config.CommitInterval)+1; i++ {
 		bigI := big.NewInt(int64(i))
 		block := types.NewBlock(
 			&types.Header{
@@ -58,13 +66,13 @@ func TestCappedMemoryTrieWriter(t *testing.T) {
 
 		w.AcceptTrie(block)
 		assert.Equal(common.Hash{}, m.LastReference, "should not have referenced block on accept")
-		if i < tipBufferSize {
+		if i < config.TipBufferSize {
 			assert.Equal(common.Hash{}, m.LastDereference, "should not have dereferenced block on accept")
 		} else {
-			assert.Equal(common.BigToHash(big.NewInt(int64(i-tipBufferSize))), m.LastDereference, "should have dereferenced old block on last accept")
+			assert.Equal(common.BigToHash(big.NewInt(int64(i-config.TipBufferSize))), m.LastDereference, "should have dereferenced old block on last accept")
 			m.LastDereference = common.Hash{}
 		}
-		if i < commitInterval {
+		if i < int(config.CommitInterval) {
 			assert.Equal(common.Hash{}, m.LastCommit, "should not have committed block on accept")
 		} else {
 			assert.Equal(block.Root(), m.LastCommit, "should have committed block after commitInterval")
@@ -79,11 +87,121 @@ func TestCappedMemoryTrieWriter(t *testing.T) {
 	}
 }
 
+func TestCappedMemoryTrieWriterFlush(t *testing.T) {
+	m := &MockTrieDB{}
+	config := &CacheConfig{
+		Pruning:               true,
+		TrieDirtyLimit:        256,
+		TrieDirtyCommitTarget: 20,
+		CommitInterval:        4096,
+		TipBufferSize:         16,
+	}
+	w := NewTrieWriter(m, config)
+	assert := assert.New(t)
+
+	memoryCap := common.StorageSize(config.TrieDirtyLimit) * 1024 * 1024
+	commitTarget := common.StorageSize(config.TrieDirtyCommitTarget) * 1024 * 1024
+	window := int(flushWindow(config.CommitInterval))
+	for i := 1; i < int(config.CommitInterval); i++ {
+		bigI := big.NewInt(int64(i))
+		block := types.NewBlock(
+			&types.Header{
+				Root:   common.BigToHash(bigI),
+				Number: bigI,
+			},
+			nil, nil, nil, nil, nil, true,
+		)
+		// Refill the dirty cache before each block
+		m.DirtySize, m.LastCap = memoryCap, 0
+
+		assert.NoError(w.InsertTrie(block))
+		assert.NoError(w.AcceptTrie(block))
+		if i <= int(config.CommitInterval)-window {
+			assert.Zero(m.LastCap, "should not have flushed before the flush window at height %d", i)
+			continue
+		}
+		assert.NotZero(m.LastCap, "should have flushed in the flush window at height %d", i)
+		assert.Less(float64(m.DirtySize), float64(memoryCap), "should have reduced the dirty cache at height %d", i)
+	}
+	// The dirty cache must be close to the commit target right before the commit
+	assert.LessOrEqual(float64(m.DirtySize), float64(commitTarget+memoryCap/common.StorageSize(window)))
+}
+
+func TestCappedMemoryTrieWriterMemoryCap(t *testing.T) {
+	m := &MockTrieDB{}
+	config := &CacheConfig{
+		Pruning:               true,
+		TrieDirtyLimit:        256,
+		TrieDirtyCommitTarget: 20,
+		CommitInterval:        4096,
+		TipBufferSize:         16,
+	}
+	w := NewTrieWriter(m, config)
+
+	memoryCap := common.StorageSize(config.TrieDirtyLimit) * 1024 * 1024
+	commitTarget := common.StorageSize(config.TrieDirtyCommitTarget) * 1024 * 1024
+	block := types.NewBlock(
+		&types.Header{
+			Root:   common.BigToHash(big.NewInt(1)),
+			Number: big.NewInt(1),
+		},
+		nil, nil, nil, nil, nil, true,
+	)
+	// The dirty cache exceeds [memoryCap] long before the flush window
+	m.DirtySize = 2 * memoryCap
+	assert.NoError(t, w.AcceptTrie(block))
+	assert.Equal(t, commitTarget, m.LastCap, "should have capped the dirty cache outside of the flush window")
+	assert.Equal(t, commitTarget, m.DirtySize)
+}
+
+func TestCappedMemoryTrieWriterSyncableCommits(t *testing.T) {
+	tests := map[string]struct {
+		commitInterval uint64
+		commitHeights  []uint64
+	}{
+		"commit interval above the syncable interval": {
+			commitInterval: 8192,
+			commitHeights:  []uint64{4096, 8192},
+		},
+		"commit interval not dividing the syncable interval": {
+			commitInterval: 3000,
+			commitHeights:  []uint64{3000, 4096, 6000, 8192},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := &MockTrieDB{}
+			config := &CacheConfig{Pruning: true, CommitInterval: test.commitInterval, SyncableInterval: 4096, TipBufferSize: 16}
+			w := NewTrieWriter(m, config).(*cappedMemoryTrieWriter)
+			// Disable the randomized commits so that only the expected heights are committed
+			w.randomizedInterval = math.MaxUint64
+
+			var commitHeights []uint64
+			for i := uint64(1); i <= 8192; i++ {
+				block := types.NewBlock(
+					&types.Header{
+						Root:   common.BigToHash(new(big.Int).SetUint64(i)),
+						Number: new(big.Int).SetUint64(i),
+					},
+					nil, nil, nil, nil, nil, true,
+				)
+				assert.NoError(t, w.InsertTrie(block))
+				assert.NoError(t, w.AcceptTrie(block))
+				if m.LastCommit != (common.Hash{}) {
+					commitHeights = append(commitHeights, i)
+					m.LastCommit = common.Hash{}
+				}
+			}
+			assert.Equal(t, test.commitHeights, commitHeights)
+		})
+	}
+}
+
 func TestNoPruningTrieWriter(t *testing.T) {
 	m := &MockTrieDB{}
 	w := NewTrieWriter(m, &CacheConfig{})
 	assert := assert.New(t)
-	for i := 0; i < tipBufferSize+1; i++ {
+	for i := 0; i < 16+1; i++ {
 		bigI := big.NewInt(int64(i))
 		block := types.NewBlock(
 			&types.Header{
@@ -38,6 +38,7 @@ import (
 	"github.com/ava-labs/coreth/params"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
 )
 
 // StateProcessor is a basic Processor, which takes care of transitioning
@@ -80,6 +81,10 @@ func (p *StateProcessor) Process(block *types.Block, parent *types.Header, state
 	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
 		misc.ApplyDAOHardFork(statedb)
 	}
+	// Configure any stateful precompiles that are enabled in this block
+	if err := ApplyPrecompileActivations(p.config, new(big.Int).SetUint64(parent.Time), header, statedb); err != nil {
+		return nil, nil, 0, err
+	}
 	blockContext := NewEVMBlockContext(header, p.bc, nil)
 	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, p.config, cfg)
 	// Iterate over and process the individual transactions
@@ -163,3 +168,33 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, config, cfg)
 	return applyTransaction(msg, config, bc, author, gp, statedb, header.Number, header.Hash(), tx, usedGas, vmenv)
 }
+
+// ApplyPrecompileActivations configures the state of each precompile module
+// enabled by [config] in the transition from a block at [parentTimestamp] to
+// [header]. [parentTimestamp] is nil for the genesis block, in which case every
+// module enabled at the timestamp of the genesis block is configured.
+// Modules are configured in the order of their addresses, and the nonce of the
+// address of each module is set to 1 so its account is not removed as empty
+// by EIP-158.
+func ApplyPrecompileActivations(config *params.ChainConfig, parentTimestamp *big.Int, header *types.Header, statedb *state.StateDB) error {
+	blockTimestamp := new(big.Int).SetUint64(header.Time)
+	blockContext := &vm.BlockContext{
+		BlockNumber: header.Number,
+		Time:        blockTimestamp,
+	}
+	for _, module := range config.EnabledPrecompiles(blockTimestamp) {
+		if parentTimestamp != nil && config.IsPrecompileEnabled(module.Address, parentTimestamp) {
+			// enabled in a previous block
+			continue
+		}
+		precompileConfig, _ := config.GetPrecompileConfig(module.ConfigKey)
+		log.Info("Activating precompile", "key", module.ConfigKey, "address", module.Address, "timestamp", blockTimestamp)
+		if statedb.GetNonce(module.Address) == 0 {
+			statedb.SetNonce(module.Address, 1)
+		}
+		if err := precompileConfig.Configure(config, statedb, blockContext); err != nil {
+			return fmt.Errorf("could not configure precompile %s at block %d: %w", module.ConfigKey, header.Number, err)
+		}
+	}
+	return nil
+}
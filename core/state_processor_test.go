@@ -27,15 +27,20 @@
 package core
 
 import (
+	"crypto/ecdsa"
+	"errors"
 	"math/big"
+	"strings"
 	"testing"
 
+	"github.com/ava-labs/coreth/accounts/abi"
 	"github.com/ava-labs/coreth/consensus"
 	"github.com/ava-labs/coreth/consensus/dummy"
 	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/core/vm"
 	"github.com/ava-labs/coreth/params"
+	"github.com/ava-labs/coreth/precompile"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/trie"
@@ -304,6 +309,168 @@ func TestStateProcessorErrors(t *testing.T) {
 	}
 }
 
+// TestStateProcessorAllowLists tests that the allow list precompiles enabled in
+// the genesis restrict who may send transactions and deploy contracts.
+func TestStateProcessorAllowLists(t *testing.T) {
+	var (
+		adminKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		otherKey, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		adminAddr   = crypto.PubkeyToAddress(adminKey.PublicKey)
+		otherAddr   = crypto.PubkeyToAddress(otherKey.PublicKey)
+		config      = *params.TestChainConfig
+		signer      = types.LatestSigner(&config)
+		gasPrice    = big.NewInt(225000000000)
+	)
+	config.Precompiles = params.Precompiles{
+		precompile.TxAllowListConfigKey: &precompile.TxAllowListConfig{
+			UpgradeableConfig: precompile.UpgradeableConfig{BlockTimestamp: big.NewInt(0)},
+			AllowListConfig:   precompile.AllowListConfig{AdminAddresses: []common.Address{adminAddr}},
+		},
+		precompile.ContractDeployerAllowListConfigKey: &precompile.ContractDeployerAllowListConfig{
+			UpgradeableConfig: precompile.UpgradeableConfig{BlockTimestamp: big.NewInt(0)},
+		},
+	}
+	var (
+		db    = rawdb.NewMemoryDatabase()
+		gspec = &Genesis{
+			Config: &config,
+			Alloc: GenesisAlloc{
+				adminAddr: {Balance: big.NewInt(1000000000000000000)},
+				otherAddr: {Balance: big.NewInt(1000000000000000000)},
+			},
+			GasLimit: params.ApricotPhase1GasLimit,
+		}
+		genesis       = gspec.MustCommit(db)
+		blockchain, _ = NewBlockChain(db, DefaultCacheConfig, gspec.Config, dummy.NewFaker(), vm.Config{}, common.Hash{})
+	)
+	defer blockchain.Stop()
+
+	statedb, err := blockchain.StateAt(genesis.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role := precompile.GetTxAllowListStatus(statedb, adminAddr); role != precompile.AllowListAdmin {
+		t.Fatalf("expected genesis admin to have the admin role, have %x", role)
+	}
+	if nonce := statedb.GetNonce(precompile.TxAllowListAddress); nonce != 1 {
+		t.Fatalf("expected nonce of enabled precompile to be 1, have %d", nonce)
+	}
+
+	// Transactions from addresses that are not allow listed are invalid
+	tx, _ := types.SignTx(types.NewTransaction(0, adminAddr, big.NewInt(0), params.TxGas, gasPrice, nil), signer, otherKey)
+	block := GenerateBadBlock(genesis, dummy.NewFaker(), types.Transactions{tx}, gspec.Config)
+	if _, err := blockchain.InsertChain(types.Blocks{block}); !errors.Is(err, ErrSenderAddressNotAllowListed) {
+		t.Fatalf("expected %v, have %v", ErrSenderAddressNotAllowListed, err)
+	}
+
+	// The admin may send transactions, but contract deployments fail since it
+	// is not enabled by the deployer allow list.
+	blocks, receipts, err := GenerateChain(gspec.Config, genesis, dummy.NewFaker(), db, 1, 10, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewContractCreation(0, big.NewInt(0), 100_000, gasPrice, common.FromHex("0x6000")), signer, adminKey)
+		gen.AddTxWithChain(blockchain, tx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	if status := receipts[0][0].Status; status != types.ReceiptStatusFailed {
+		t.Fatalf("expected contract deployment to fail, have status %d", status)
+	}
+}
+
+// TestStateProcessorFeeManager tests that a fee config set through the fee
+// manager precompile is used from the following block.
+func TestStateProcessorFeeManager(t *testing.T) {
+	var (
+		adminKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		otherKey, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		adminAddr   = crypto.PubkeyToAddress(adminKey.PublicKey)
+		otherAddr   = crypto.PubkeyToAddress(otherKey.PublicKey)
+		config      = *params.TestChainConfig
+		signer      = types.LatestSigner(&config)
+		gasPrice    = big.NewInt(225000000000)
+	)
+	config.Precompiles = params.Precompiles{
+		precompile.FeeConfigManagerConfigKey: &precompile.FeeConfigManagerConfig{
+			UpgradeableConfig: precompile.UpgradeableConfig{BlockTimestamp: big.NewInt(0)},
+			AllowListConfig:   precompile.AllowListConfig{AdminAddresses: []common.Address{adminAddr}},
+		},
+	}
+	var (
+		db    = rawdb.NewMemoryDatabase()
+		gspec = &Genesis{
+			Config: &config,
+			Alloc: GenesisAlloc{
+				adminAddr: {Balance: big.NewInt(1000000000000000000)},
+				otherAddr: {Balance: big.NewInt(1000000000000000000)},
+			},
+			GasLimit: params.ApricotPhase1GasLimit,
+		}
+		genesis       = gspec.MustCommit(db)
+		blockchain, _ = NewBlockChain(db, DefaultCacheConfig, gspec.Config, dummy.NewFaker(), vm.Config{}, common.Hash{})
+	)
+	defer blockchain.Stop()
+
+	feeConfig := params.ApricotPhase5FeeConfig
+	feeConfig.MinBaseFee = big.NewInt(500_000_000_000)
+	feeManagerABI, err := abi.JSON(strings.NewReader(precompile.FeeConfigManagerABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input, err := feeManagerABI.Pack("setFeeConfig", feeConfig.TargetGas, feeConfig.BaseFeeChangeDenominator, feeConfig.MinBaseFee, common.Big0,
+		new(big.Int).SetUint64(feeConfig.TargetBlockRate), feeConfig.MinBlockGasCost, feeConfig.MaxBlockGasCost, feeConfig.BlockGasCostStep)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the admin may change the fee config, which is used from the block
+	// following the change.
+	blocks, receipts, err := GenerateChain(gspec.Config, genesis, dummy.NewFaker(), db, 2, 10, func(i int, gen *BlockGen) {
+		if i != 0 {
+			return
+		}
+		for _, key := range []*ecdsa.PrivateKey{otherKey, adminKey} {
+			tx, _ := types.SignTx(types.NewTransaction(0, precompile.FeeConfigManagerAddress, big.NewInt(0), 100_000, gasPrice, input), signer, key)
+			gen.AddTxWithChain(blockchain, tx)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := receipts[0][0].Status; status != types.ReceiptStatusFailed {
+		t.Fatalf("expected fee config change by non-enabled address to fail, have status %d", status)
+	}
+	if status := receipts[0][1].Status; status != types.ReceiptStatusSuccessful {
+		t.Fatalf("expected fee config change by admin to succeed, have status %d", status)
+	}
+	if baseFee := blocks[0].BaseFee(); baseFee.Cmp(feeConfig.MinBaseFee) >= 0 {
+		t.Fatalf("expected base fee of block changing the fee config to be below the new minimum, have %d", baseFee)
+	}
+	if baseFee := blocks[1].BaseFee(); baseFee.Cmp(feeConfig.MinBaseFee) != 0 {
+		t.Fatalf("expected base fee %d, have %d", feeConfig.MinBaseFee, baseFee)
+	}
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+
+	activeFeeConfig, err := blockchain.GetFeeConfigAt(blocks[0].Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !activeFeeConfig.Equal(&feeConfig) {
+		t.Fatalf("expected fee config %v, have %v", feeConfig, activeFeeConfig)
+	}
+	statedb, err := blockchain.StateAt(blocks[1].Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastChangedAt := precompile.GetFeeConfigLastChangedAt(statedb); lastChangedAt.Cmp(common.Big1) != 0 {
+		t.Fatalf("expected fee config to be last changed at block 1, have %d", lastChangedAt)
+	}
+}
+
 // GenerateBadBlock constructs a "block" which contains the transactions. The transactions are not expected to be
 // valid, and no proper post-state can be made. But from the perspective of the blockchain, the block is sufficiently
 // valid to be considered for import:
@@ -312,7 +479,7 @@ func GenerateBadBlock(parent *types.Block, engine consensus.Engine, txs types.Tr
 	header := &types.Header{
 		ParentHash: parent.Hash(),
 		Coinbase:   parent.Coinbase(),
-		Difficulty: engine.CalcDifficulty(&fakeChainReader{config}, parent.Time()+10, &types.Header{
+		Difficulty: engine.CalcDifficulty(&fakeChainReader{config: config}, parent.Time()+10, &types.Header{
 			Number:     parent.Number(),
 			Time:       parent.Time(),
 			Difficulty: parent.Difficulty(),
@@ -324,7 +491,8 @@ func GenerateBadBlock(parent *types.Block, engine consensus.Engine, txs types.Tr
 		UncleHash: types.EmptyUncleHash,
 	}
 	if config.IsApricotPhase3(new(big.Int).SetUint64(header.Time)) {
-		header.Extra, header.BaseFee, _ = dummy.CalcBaseFee(config, parent.Header(), header.Time)
+		feeConfig := config.GetFeeConfig(new(big.Int).SetUint64(parent.Time()))
+		header.Extra, header.BaseFee, _ = dummy.CalcBaseFee(config, feeConfig, parent.Header(), header.Time)
 	}
 	if config.IsApricotPhase4(new(big.Int).SetUint64(header.Time)) {
 		header.BlockGasCost = big.NewInt(0)
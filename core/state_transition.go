@@ -40,6 +40,7 @@ import (
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/core/vm"
 	"github.com/ava-labs/coreth/params"
+	"github.com/ava-labs/coreth/precompile"
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -248,6 +249,12 @@ func (st *StateTransition) preCheck() error {
 		if st.msg.From() == st.evm.Context.Coinbase {
 			return fmt.Errorf("%w: address %v", vm.ErrNoSenderBlackhole, st.msg.From())
 		}
+		// Make sure the sender is enabled by the tx allow list, if it is enabled
+		if st.evm.ChainConfig().IsPrecompileEnabled(precompile.TxAllowListAddress, st.evm.Context.Time) {
+			if !precompile.GetTxAllowListStatus(st.state, st.msg.From()).IsEnabled() {
+				return fmt.Errorf("%w: address %v", ErrSenderAddressNotAllowListed, st.msg.From())
+			}
+		}
 	}
 	// Make sure that transaction gasFeeCap is greater than the baseFee (post london)
 	if st.evm.ChainConfig().IsApricotPhase3(st.evm.Context.Time) {
@@ -100,6 +100,9 @@ func checkBlockChainState(
 	create func(db ethdb.Database, chainConfig *params.ChainConfig, lastAcceptedHash common.Hash) (*BlockChain, error),
 	checkState func(sdb *state.StateDB) error,
 ) (*BlockChain, *BlockChain, *BlockChain) {
+	// Wait for the side effects of all accepted blocks to be processed
+	bc.DrainAcceptorQueue()
+
 	var (
 		chainConfig       = bc.Config()
 		lastAcceptedBlock = bc.LastAcceptedBlock()
@@ -134,6 +137,8 @@ func checkBlockChainState(
 		}
 	}
 
+	newBlockChain.DrainAcceptorQueue()
+
 	newLastAcceptedBlock := newBlockChain.LastAcceptedBlock()
 	if newLastAcceptedBlock.Hash() != lastAcceptedBlock.Hash() {
 		t.Fatalf("Expected new blockchain to have last accepted block %s:%d, but found %s:%d", lastAcceptedBlock.Hash().Hex(), lastAcceptedBlock.NumberU64(), newLastAcceptedBlock.Hash().Hex(), newLastAcceptedBlock.NumberU64())
@@ -346,6 +351,7 @@ func TestInsertLongForkedChain(t *testing.T, create func(db ethdb.Database, chai
 	if err := blockchain.Accept(chain1[0]); err != nil {
 		t.Fatal(err)
 	}
+	blockchain.DrainAcceptorQueue()
 
 	if blockchain.snaps != nil {
 		// Snap layer count should be 1 fewer
@@ -377,6 +383,7 @@ func TestInsertLongForkedChain(t *testing.T, create func(db ethdb.Database, chai
 		if err := blockchain.Accept(chain1[i]); err != nil {
 			t.Fatal(err)
 		}
+		blockchain.DrainAcceptorQueue()
 
 		if blockchain.snaps != nil {
 			// Snap layer count should decrease by 1 per Accept
@@ -386,6 +393,7 @@ func TestInsertLongForkedChain(t *testing.T, create func(db ethdb.Database, chai
 		}
 	}
 
+	blockchain.DrainAcceptorQueue()
 	lastAcceptedBlock := blockchain.LastAcceptedBlock()
 	expectedLastAcceptedBlock := chain1[len(chain1)-1]
 	if lastAcceptedBlock.Hash() != expectedLastAcceptedBlock.Hash() {
@@ -498,6 +506,7 @@ func TestAcceptNonCanonicalBlock(t *testing.T, create func(db ethdb.Database, ch
 		}
 	}
 
+	blockchain.DrainAcceptorQueue()
 	lastAcceptedBlock := blockchain.LastAcceptedBlock()
 	expectedLastAcceptedBlock := chain2[0]
 	if lastAcceptedBlock.Hash() != expectedLastAcceptedBlock.Hash() {
@@ -600,6 +609,7 @@ func TestSetPreferenceRewind(t *testing.T, create func(db ethdb.Database, chainC
 		t.Fatalf("Expected current block to be %s:%d, but found %s%d", expectedCurrentBlock.Hash().Hex(), expectedCurrentBlock.NumberU64(), currentBlock.Hash().Hex(), currentBlock.NumberU64())
 	}
 
+	blockchain.DrainAcceptorQueue()
 	lastAcceptedBlock := blockchain.LastAcceptedBlock()
 	expectedLastAcceptedBlock := blockchain.Genesis()
 	if lastAcceptedBlock.Hash() != expectedLastAcceptedBlock.Hash() {
@@ -633,6 +643,7 @@ func TestSetPreferenceRewind(t *testing.T, create func(db ethdb.Database, chainC
 	if err := blockchain.Accept(chain[0]); err != nil {
 		t.Fatal(err)
 	}
+	blockchain.DrainAcceptorQueue()
 	lastAcceptedBlock = blockchain.LastAcceptedBlock()
 	expectedLastAcceptedBlock = chain[0]
 	if lastAcceptedBlock.Hash() != expectedLastAcceptedBlock.Hash() {
@@ -1079,6 +1090,7 @@ func TestAcceptBlockIdenticalStateRoot(t *testing.T, create func(db ethdb.Databa
 		t.Fatal(err)
 	}
 
+	blockchain.DrainAcceptorQueue()
 	lastAcceptedBlock := blockchain.LastAcceptedBlock()
 	expectedLastAcceptedBlock := chain1[1]
 	if lastAcceptedBlock.Hash() != expectedLastAcceptedBlock.Hash() {
@@ -1245,6 +1257,7 @@ func TestReprocessAcceptBlockIdenticalStateRoot(t *testing.T, create func(db eth
 		t.Fatal(err)
 	}
 
+	blockchain.DrainAcceptorQueue()
 	lastAcceptedBlock := blockchain.LastAcceptedBlock()
 	expectedLastAcceptedBlock := chain1[1]
 	if lastAcceptedBlock.Hash() != expectedLastAcceptedBlock.Hash() {
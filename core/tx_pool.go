@@ -40,6 +40,7 @@ import (
 	"github.com/ava-labs/coreth/core/state"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/params"
+	"github.com/ava-labs/coreth/precompile"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/prque"
 	"github.com/ethereum/go-ethereum/event"
@@ -696,9 +697,16 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if err := pool.CheckNonceOrdering(from, tx.Nonce()); err != nil {
 		return err
 	}
+	// Drop the transaction if the sender is not enabled by the tx allow list
+	pool.currentStateLock.Lock()
+	if pool.chainconfig.IsPrecompileEnabled(precompile.TxAllowListAddress, new(big.Int).SetUint64(pool.currentHead.Time)) {
+		if !precompile.GetTxAllowListStatus(pool.currentState, from).IsEnabled() {
+			pool.currentStateLock.Unlock()
+			return fmt.Errorf("%w: address %s", ErrSenderAddressNotAllowListed, from.Hex())
+		}
+	}
 	// Transactor should have enough funds to cover the costs
 	// cost == V + GP * GL
-	pool.currentStateLock.Lock()
 	if balance, cost := pool.currentState.GetBalance(from), tx.Cost(); balance.Cmp(cost) < 0 {
 		pool.currentStateLock.Unlock()
 		return fmt.Errorf("%w: address %s have (%d) want (%d)", ErrInsufficientFunds, from.Hex(), balance, cost)
@@ -1255,7 +1263,7 @@ func (pool *TxPool) runReorg(done chan struct{}, reset *txpoolResetRequest, dirt
 	if reset != nil {
 		pool.demoteUnexecutables()
 		if reset.newHead != nil && pool.chainconfig.IsApricotPhase3(new(big.Int).SetUint64(reset.newHead.Time)) {
-			_, baseFeeEstimate, err := dummy.EstimateNextBaseFee(pool.chainconfig, reset.newHead, uint64(time.Now().Unix()))
+			_, baseFeeEstimate, err := pool.estimateNextBaseFee(reset.newHead)
 			if err == nil {
 				pool.priced.SetBaseFee(baseFeeEstimate)
 			}
@@ -1698,7 +1706,7 @@ func (pool *TxPool) updateBaseFee() {
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
 
-	_, baseFeeEstimate, err := dummy.EstimateNextBaseFee(pool.chainconfig, pool.currentHead, uint64(time.Now().Unix()))
+	_, baseFeeEstimate, err := pool.estimateNextBaseFee(pool.currentHead)
 	if err == nil {
 		pool.priced.SetBaseFee(baseFeeEstimate)
 	} else {
@@ -1706,6 +1714,16 @@ func (pool *TxPool) updateBaseFee() {
 	}
 }
 
+// estimateNextBaseFee estimates the base fee of a block built on [head] now,
+// using the fee config in force at [head].
+func (pool *TxPool) estimateNextBaseFee(head *types.Header) ([]byte, *big.Int, error) {
+	feeConfig, err := getFeeConfigAt(pool.chainconfig, head, pool.chain.StateAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dummy.EstimateNextBaseFee(pool.chainconfig, feeConfig, head, uint64(time.Now().Unix()))
+}
+
 // addressByHeartbeat is an account address tagged with its last activity timestamp.
 type addressByHeartbeat struct {
 	address   common.Address
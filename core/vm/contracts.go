@@ -134,18 +134,30 @@ func init() {
 	}
 }
 
-// ActivePrecompiles returns the precompiles enabled with the current configuration.
+// ActivePrecompiles returns the precompiles enabled with the current configuration,
+// including the precompile modules enabled by the chain config.
 func ActivePrecompiles(rules params.Rules) []common.Address {
+	var addresses []common.Address
 	switch {
 	case rules.IsApricotPhase2:
-		return PrecompiledAddressesApricotPhase2
+		addresses = PrecompiledAddressesApricotPhase2
 	case rules.IsIstanbul:
-		return PrecompiledAddressesIstanbul
+		addresses = PrecompiledAddressesIstanbul
 	case rules.IsByzantium:
-		return PrecompiledAddressesByzantium
+		addresses = PrecompiledAddressesByzantium
 	default:
-		return PrecompiledAddressesHomestead
+		addresses = PrecompiledAddressesHomestead
 	}
+	if len(rules.Precompiles) == 0 {
+		return addresses
+	}
+
+	active := make([]common.Address, 0, len(addresses)+len(rules.Precompiles))
+	active = append(active, addresses...)
+	for addr := range rules.Precompiles {
+		active = append(active, addr)
+	}
+	return active
 }
 
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
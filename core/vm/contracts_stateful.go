@@ -4,10 +4,12 @@
 package vm
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 
 	"github.com/ava-labs/coreth/params"
+	"github.com/ava-labs/coreth/precompile"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/holiman/uint256"
 )
@@ -49,6 +51,32 @@ func (w *wrappedPrecompiledContract) Run(evm *EVM, caller ContractRef, addr comm
 	return RunPrecompiledContract(w.p, input, suppliedGas)
 }
 
+// wrappedStatefulPrecompile implements StatefulPrecompiledContract by wrapping the
+// contract of a precompile module enabled by the chain config.
+type wrappedStatefulPrecompile struct {
+	p precompile.StatefulPrecompiledContract
+}
+
+func newWrappedStatefulPrecompile(p precompile.StatefulPrecompiledContract) StatefulPrecompiledContract {
+	return &wrappedStatefulPrecompile{p: p}
+}
+
+// Run implements the StatefulPrecompiledContract interface
+func (w *wrappedStatefulPrecompile) Run(evm *EVM, caller ContractRef, addr common.Address, input []byte, suppliedGas uint64, readOnly bool) (ret []byte, remainingGas uint64, err error) {
+	ret, remainingGas, err = w.p.Run(evm, caller.Address(), addr, input, suppliedGas, readOnly)
+	// Translate the errors of the module into the errors handled by the EVM
+	switch {
+	case err == nil:
+	case errors.Is(err, precompile.ErrExecutionReverted):
+		err = ErrExecutionReverted
+	case errors.Is(err, precompile.ErrOutOfGas):
+		err = ErrOutOfGas
+	case errors.Is(err, precompile.ErrWriteProtection):
+		err = ErrWriteProtection
+	}
+	return ret, remainingGas, err
+}
+
 // nativeAssetBalance is a precompiled contract used to retrieve the native asset balance
 type nativeAssetBalance struct {
 	gasCost uint64
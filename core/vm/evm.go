@@ -27,11 +27,13 @@
 package vm
 
 import (
+	"fmt"
 	"math/big"
 	"sync/atomic"
 	"time"
 
 	"github.com/ava-labs/coreth/params"
+	"github.com/ava-labs/coreth/precompile"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/holiman/uint256"
@@ -66,7 +68,25 @@ func (evm *EVM) precompile(addr common.Address) (StatefulPrecompiledContract, bo
 		precompiles = PrecompiledContractsHomestead
 	}
 	p, ok := precompiles[addr]
-	return p, ok
+	if ok {
+		return p, true
+	}
+	// Check the stateful precompile modules enabled by the chain config
+	if module, ok := evm.chainRules.Precompiles[addr]; ok {
+		return newWrappedStatefulPrecompile(module), true
+	}
+	return nil, false
+}
+
+// GetStateDB returns the state made available to stateful precompile modules.
+func (evm *EVM) GetStateDB() precompile.StateDB {
+	return evm.StateDB
+}
+
+// GetBlockContext returns the block information made available to stateful
+// precompile modules.
+func (evm *EVM) GetBlockContext() precompile.BlockContext {
+	return &evm.Context
 }
 
 // BlockContext provides the EVM with auxiliary information. Once provided
@@ -94,6 +114,12 @@ type BlockContext struct {
 	BaseFee     *big.Int       // Provides information for BASEFEE
 }
 
+// Number implements precompile.BlockContext
+func (b *BlockContext) Number() *big.Int { return b.BlockNumber }
+
+// Timestamp implements precompile.BlockContext
+func (b *BlockContext) Timestamp() *big.Int { return b.Time }
+
 // TxContext provides the EVM with information about a transaction.
 // All fields can change between transactions.
 type TxContext struct {
@@ -519,6 +545,13 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 	if address == evm.Context.Coinbase {
 		return nil, common.Address{}, gas, ErrNoSenderBlackhole
 	}
+	// If the contract deployer allow list is enabled, only the addresses it
+	// enables may originate transactions that deploy contracts.
+	if _, ok := evm.chainRules.Precompiles[precompile.ContractDeployerAllowListAddress]; ok {
+		if !precompile.GetContractDeployerAllowListStatus(evm.StateDB, evm.TxContext.Origin).IsEnabled() {
+			return nil, common.Address{}, 0, fmt.Errorf("%w: tx.origin %s", ErrNotAllowedToDeploy, evm.TxContext.Origin)
+		}
+	}
 	nonce := evm.StateDB.GetNonce(caller.Address())
 	if nonce+1 < nonce {
 		return nil, common.Address{}, gas, ErrNonceUintOverflow
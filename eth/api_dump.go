@@ -0,0 +1,390 @@
+// (c) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package eth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/state"
+	"github.com/ava-labs/coreth/core/state/snapshot"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/ethdb"
+	"github.com/ava-labs/coreth/ethdb/memorydb"
+	"github.com/ava-labs/coreth/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	// DumpStateMaxResults is the maximum number of accounts and storage slots
+	// returned per call
+	DumpStateMaxResults = 10_000
+
+	// Formats of the pages returned by debug_dumpState
+	DumpStateFormatJSONL = "jsonl" // One JSON object per account or storage slot and line
+	DumpStateFormatRLP   = "rlp"   // RLP list of dumpStateEntry
+)
+
+var errNoSnapshots = errors.New("state dumps require snapshots to be enabled")
+
+// DumpStateCursor is the position debug_dumpState resumes from in the state
+// [Root]. Without a storage slot, the page starts with the account [Account].
+// Otherwise the account was already returned and the page starts with its
+// storage at [Slot].
+type DumpStateCursor struct {
+	Root    common.Hash  `json:"root"`
+	Account common.Hash  `json:"account"`
+	Slot    *common.Hash `json:"slot,omitempty"`
+}
+
+// DumpStateConfig holds the options of debug_dumpState.
+type DumpStateConfig struct {
+	Format     string           `json:"format"`     // DumpStateFormatJSONL (default) or DumpStateFormatRLP
+	Start      *DumpStateCursor `json:"start"`      // Position to resume from, nil to start from the first account
+	MaxResults int              `json:"maxResults"` // Maximum number of accounts and storage slots returned
+	NoCode     bool             `json:"noCode"`     // Whether to leave out contract code
+	NoStorage  bool             `json:"noStorage"`  // Whether to leave out storage
+	Proof      bool             `json:"proof"`      // Whether to prove the boundaries of the page
+}
+
+// DumpStateProof proves the boundaries of a page in the tries of the dumped
+// state, so the accounts of consecutive pages can be verified as a range.
+type DumpStateProof struct {
+	Accounts []hexutil.Bytes                 `json:"accounts"`          // Nodes proving the start and the last account of the page
+	Storage  map[common.Hash][]hexutil.Bytes `json:"storage,omitempty"` // Nodes proving the first and last slots of the accounts with partial storage in the page
+}
+
+// DumpStatePage is a page of a state dump returned by debug_dumpState.
+type DumpStatePage struct {
+	Root   common.Hash      `json:"root"`
+	Format string           `json:"format"`
+	Data   string           `json:"data"`  // JSONL text, or hex encoded RLP
+	Count  int              `json:"count"` // Number of accounts and storage slots in Data
+	Next   *DumpStateCursor `json:"next"`  // Position of the next page, nil if the dump is complete
+	Proof  *DumpStateProof  `json:"proof,omitempty"`
+}
+
+// dumpStateAccount is a line of a JSONL page holding an account.
+type dumpStateAccount struct {
+	Hash        common.Hash     `json:"hash"`
+	Address     *common.Address `json:"address,omitempty"` // Only present if the preimage is known
+	Nonce       uint64          `json:"nonce"`
+	Balance     *hexutil.Big    `json:"balance"`
+	Root        common.Hash     `json:"root"`
+	CodeHash    common.Hash     `json:"codeHash"`
+	IsMultiCoin bool            `json:"isMultiCoin"`
+	Code        hexutil.Bytes   `json:"code,omitempty"`
+}
+
+// dumpStateSlot is a line of a JSONL page holding a storage slot.
+type dumpStateSlot struct {
+	Account common.Hash `json:"account"`
+	Slot    common.Hash `json:"slot"`
+	Value   common.Hash `json:"value"`
+}
+
+// dumpStateEntry is an element of a RLP page. Accounts have an empty [Slot]
+// and their consensus encoding as [Value], storage slots have the RLP encoded
+// slot value as [Value].
+type dumpStateEntry struct {
+	Account common.Hash
+	Slot    []byte
+	Value   []byte
+	Code    []byte
+}
+
+// stateDumper writes a page of a state dump.
+type stateDumper struct {
+	db     state.Database
+	diskdb ethdb.KeyValueReader
+	config *DumpStateConfig
+
+	jsonl   bytes.Buffer
+	entries []dumpStateEntry
+	count   int
+}
+
+// DumpState returns a page of the accounts and storage of the state of a block
+// read from the snapshot, in the order of their hashes. Only the state of the
+// blocks held by the snapshot can be dumped, which are the last accepted block
+// and the blocks being processed. A cursor only resumes the dump of the state
+// it was returned for, so the pages of a dump should be requested by block
+// hash, and a dump must be restarted once its block is no longer held by the
+// snapshot.
+func (api *PrivateDebugAPI) DumpState(blockNrOrHash rpc.BlockNumberOrHash, config *DumpStateConfig) (*DumpStatePage, error) {
+	snaps := api.eth.blockchain.Snapshots()
+	if snaps == nil {
+		return nil, errNoSnapshots
+	}
+	var block *types.Block
+	if number, ok := blockNrOrHash.Number(); ok {
+		if number.IsAccepted() {
+			block = api.eth.LastAcceptedBlock()
+		} else {
+			block = api.eth.blockchain.GetBlockByNumber(uint64(number))
+		}
+		if block == nil {
+			return nil, fmt.Errorf("block #%d not found", number)
+		}
+	} else if hash, ok := blockNrOrHash.Hash(); ok {
+		block = api.eth.blockchain.GetBlockByHash(hash)
+		if block == nil {
+			return nil, fmt.Errorf("block %s not found", hash.Hex())
+		}
+	} else {
+		return nil, errors.New("either block number or block hash must be specified")
+	}
+
+	if config == nil {
+		config = &DumpStateConfig{}
+	}
+	if err := config.sanitize(); err != nil {
+		return nil, err
+	}
+	dumper := &stateDumper{
+		db:     api.eth.blockchain.StateCache(),
+		diskdb: api.eth.ChainDb(),
+		config: config,
+	}
+	return dumper.dump(snaps, block.Root())
+}
+
+// sanitize sets the defaults of the unset options of [c].
+func (c *DumpStateConfig) sanitize() error {
+	switch c.Format {
+	case "":
+		c.Format = DumpStateFormatJSONL
+	case DumpStateFormatJSONL, DumpStateFormatRLP:
+	default:
+		return fmt.Errorf("unknown state dump format %q", c.Format)
+	}
+	if c.MaxResults > DumpStateMaxResults || c.MaxResults <= 0 {
+		c.MaxResults = DumpStateMaxResults
+	}
+	return nil
+}
+
+// dump writes the page of the state [root] starting at the cursor of the
+// config.
+func (d *stateDumper) dump(snaps *snapshot.Tree, root common.Hash) (*DumpStatePage, error) {
+	var start DumpStateCursor
+	if d.config.Start != nil {
+		start = *d.config.Start
+		if start.Root != root {
+			return nil, fmt.Errorf("cannot resume a dump of state %s in state %s", start.Root.Hex(), root.Hex())
+		}
+	}
+	page := &DumpStatePage{Root: root, Format: d.config.Format}
+
+	var proof *DumpStateProof
+	if d.config.Proof {
+		proof = &DumpStateProof{Storage: make(map[common.Hash][]hexutil.Bytes)}
+	}
+	it, err := snaps.AccountIterator(root, start.Account, false)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Release()
+
+	var (
+		last     *common.Hash // Last account of the page
+		resuming = start.Slot != nil
+	)
+	for d.count < d.config.MaxResults && it.Next() {
+		hash := it.Hash()
+		account, err := snapshot.FullAccount(it.Account())
+		if err != nil {
+			return nil, err
+		}
+		var slot common.Hash
+		if resuming {
+			if hash != start.Account {
+				return nil, fmt.Errorf("account %s of the cursor not found in state %s", start.Account.Hex(), root.Hex())
+			}
+			slot = *start.Slot
+		}
+		if !resuming {
+			if err := d.addAccount(hash, account); err != nil {
+				return nil, err
+			}
+		}
+		last = &hash
+		if !d.config.NoStorage && common.BytesToHash(account.Root) != types.EmptyRootHash {
+			next, err := d.addStorage(snaps, root, hash, common.BytesToHash(account.Root), slot, resuming, proof)
+			if err != nil {
+				return nil, err
+			}
+			if next != nil {
+				page.Next = &DumpStateCursor{Root: root, Account: hash, Slot: next}
+				break
+			}
+		}
+		resuming = false
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	if page.Next == nil && d.count >= d.config.MaxResults && it.Next() {
+		page.Next = &DumpStateCursor{Root: root, Account: it.Hash()}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+
+	if proof != nil {
+		accountTrie, err := d.db.OpenTrie(root)
+		if err != nil {
+			return nil, err
+		}
+		if proof.Accounts, err = proveRange(accountTrie, start.Account, last); err != nil {
+			return nil, err
+		}
+		page.Proof = proof
+	}
+	page.Count = d.count
+	if d.config.Format == DumpStateFormatRLP {
+		data, err := rlp.EncodeToBytes(d.entries)
+		if err != nil {
+			return nil, err
+		}
+		page.Data = hexutil.Encode(data)
+	} else {
+		page.Data = d.jsonl.String()
+	}
+	return page, nil
+}
+
+// addAccount adds the account [hash] to the page.
+func (d *stateDumper) addAccount(hash common.Hash, account snapshot.Account) error {
+	codeHash := common.BytesToHash(account.CodeHash)
+	var code []byte
+	if !d.config.NoCode && codeHash != types.EmptyCodeHash {
+		var err error
+		if code, err = d.db.ContractCode(hash, codeHash); err != nil {
+			return err
+		}
+	}
+	d.count++
+
+	if d.config.Format == DumpStateFormatRLP {
+		value, err := rlp.EncodeToBytes(account)
+		if err != nil {
+			return err
+		}
+		d.entries = append(d.entries, dumpStateEntry{Account: hash, Value: value, Code: code})
+		return nil
+	}
+	line := dumpStateAccount{
+		Hash:        hash,
+		Nonce:       account.Nonce,
+		Balance:     (*hexutil.Big)(new(big.Int).Set(account.Balance)),
+		Root:        common.BytesToHash(account.Root),
+		CodeHash:    codeHash,
+		IsMultiCoin: account.IsMultiCoin,
+		Code:        code,
+	}
+	if preimage := rawdb.ReadPreimage(d.diskdb, hash); len(preimage) == common.AddressLength {
+		address := common.BytesToAddress(preimage)
+		line.Address = &address
+	}
+	return d.writeLine(line)
+}
+
+// addStorage adds the storage of the account [hash] with the storage root
+// [storageRoot] from the slot [start] to the page. If the page is full before
+// the end of the storage, the next slot is returned. With [proof] set, the
+// first and last slots of a partial storage are proven.
+func (d *stateDumper) addStorage(snaps *snapshot.Tree, root, hash, storageRoot, start common.Hash, resuming bool, proof *DumpStateProof) (*common.Hash, error) {
+	it, err := snaps.StorageIterator(root, hash, start, false)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Release()
+
+	var (
+		last *common.Hash
+		next *common.Hash
+	)
+	for it.Next() {
+		slot := it.Hash()
+		if d.count >= d.config.MaxResults {
+			next = &slot
+			break
+		}
+		if err := d.addSlot(hash, slot, it.Slot()); err != nil {
+			return nil, err
+		}
+		last = &slot
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	// A storage starting in the page is only proven if some of its slots are
+	// in the page.
+	if proof != nil && (resuming || (next != nil && last != nil)) {
+		storageTrie, err := d.db.OpenStorageTrie(hash, storageRoot)
+		if err != nil {
+			return nil, err
+		}
+		if proof.Storage[hash], err = proveRange(storageTrie, start, last); err != nil {
+			return nil, err
+		}
+	}
+	return next, nil
+}
+
+// addSlot adds the storage slot [slot] of the account [account] with the RLP
+// encoded value [value] to the page.
+func (d *stateDumper) addSlot(account, slot common.Hash, value []byte) error {
+	d.count++
+
+	if d.config.Format == DumpStateFormatRLP {
+		d.entries = append(d.entries, dumpStateEntry{Account: account, Slot: slot[:], Value: common.CopyBytes(value)})
+		return nil
+	}
+	_, content, _, err := rlp.Split(value)
+	if err != nil {
+		return err
+	}
+	return d.writeLine(dumpStateSlot{Account: account, Slot: slot, Value: common.BytesToHash(content)})
+}
+
+// writeLine adds the JSON encoding of [line] to a JSONL page.
+func (d *stateDumper) writeLine(line interface{}) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	d.jsonl.Write(data)
+	d.jsonl.WriteByte('\n')
+	return nil
+}
+
+// proveRange returns the nodes of [tr] proving the keys [first] and [last],
+// as used to verify a range of keys. A nil [last] only proves [first].
+func proveRange(tr state.Trie, first common.Hash, last *common.Hash) ([]hexutil.Bytes, error) {
+	proofDB := memorydb.New()
+	if err := tr.Prove(first[:], 0, proofDB); err != nil {
+		return nil, err
+	}
+	if last != nil {
+		if err := tr.Prove(last[:], 0, proofDB); err != nil {
+			return nil, err
+		}
+	}
+	it := proofDB.NewIterator(nil, nil)
+	defer it.Release()
+
+	var nodes []hexutil.Bytes
+	for it.Next() {
+		nodes = append(nodes, common.CopyBytes(it.Value()))
+	}
+	return nodes, it.Error()
+}
@@ -0,0 +1,400 @@
+// (c) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package eth
+
+import (
+	"encoding/json"
+	"math/big"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/state"
+	"github.com/ava-labs/coreth/core/state/snapshot"
+	"github.com/ava-labs/coreth/ethdb"
+	"github.com/ava-labs/coreth/ethdb/memorydb"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	dumpTestBlock1 = common.HexToHash("0x01")
+	dumpTestBlock2 = common.HexToHash("0x02")
+)
+
+// dumpTestState is a state with externally owned accounts and contracts with
+// storage, served by a snapshot.
+type dumpTestState struct {
+	diskdb    ethdb.Database
+	db        state.Database
+	snaps     *snapshot.Tree
+	root      common.Hash
+	contracts []common.Address
+	slots     int // Number of storage slots of each contract
+}
+
+func newDumpTestState(t *testing.T) *dumpTestState {
+	diskdb := rawdb.NewMemoryDatabase()
+	db := state.NewDatabase(diskdb)
+	statedb, err := state.New(common.Hash{}, db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &dumpTestState{diskdb: diskdb, db: db, slots: 5}
+	for i := 0; i < 8; i++ {
+		statedb.SetBalance(common.BigToAddress(big.NewInt(int64(i+1))), big.NewInt(int64(1000+i)))
+	}
+	for i := 0; i < 3; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(0x100 + i)))
+		statedb.SetNonce(addr, 1)
+		statedb.SetCode(addr, []byte{0x60, byte(i)})
+		for j := 0; j < s.slots; j++ {
+			statedb.SetState(addr, common.BigToHash(big.NewInt(int64(2*j))), common.BigToHash(big.NewInt(int64(j+1))))
+		}
+		s.contracts = append(s.contracts, addr)
+	}
+	if s.root, err = statedb.Commit(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.TrieDB().Commit(s.root, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if s.snaps, err = snapshot.New(diskdb, db.TrieDB(), 16, dumpTestBlock1, s.root, false, true, false); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// dump returns the page of the state [root] described by [config].
+func (s *dumpTestState) dump(t *testing.T, root common.Hash, config DumpStateConfig) *DumpStatePage {
+	t.Helper()
+	if err := config.sanitize(); err != nil {
+		t.Fatal(err)
+	}
+	dumper := &stateDumper{db: s.db, diskdb: s.diskdb, config: &config}
+	page, err := dumper.dump(s.snaps, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+// dumpedEntry is an account or a storage slot decoded from a page. Accounts
+// have a nil [slot] and their consensus encoding as [value], slots have their
+// RLP encoded value as [value].
+type dumpedEntry struct {
+	account common.Hash
+	slot    *common.Hash
+	value   []byte
+}
+
+// decodePage decodes the entries of [page] in either format.
+func decodePage(t *testing.T, page *DumpStatePage) []dumpedEntry {
+	t.Helper()
+	var entries []dumpedEntry
+	switch page.Format {
+	case DumpStateFormatRLP:
+		data, err := hexutil.Decode(page.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded []dumpStateEntry
+		if err := rlp.DecodeBytes(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range decoded {
+			dumped := dumpedEntry{account: entry.Account, value: entry.Value}
+			if len(entry.Slot) > 0 {
+				slot := common.BytesToHash(entry.Slot)
+				dumped.slot = &slot
+			}
+			entries = append(entries, dumped)
+		}
+	case DumpStateFormatJSONL:
+		for _, line := range strings.Split(strings.TrimSuffix(page.Data, "\n"), "\n") {
+			if line == "" {
+				continue
+			}
+			var slot dumpStateSlot
+			if err := json.Unmarshal([]byte(line), &slot); err != nil {
+				t.Fatal(err)
+			}
+			if slot.Account != (common.Hash{}) {
+				value, err := rlp.EncodeToBytes(common.TrimLeftZeroes(slot.Value[:]))
+				if err != nil {
+					t.Fatal(err)
+				}
+				entries = append(entries, dumpedEntry{account: slot.Account, slot: &slot.Slot, value: value})
+				continue
+			}
+			var account dumpStateAccount
+			if err := json.Unmarshal([]byte(line), &account); err != nil {
+				t.Fatal(err)
+			}
+			value, err := rlp.EncodeToBytes(snapshot.Account{
+				Nonce:       account.Nonce,
+				Balance:     account.Balance.ToInt(),
+				Root:        account.Root[:],
+				CodeHash:    account.CodeHash[:],
+				IsMultiCoin: account.IsMultiCoin,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries = append(entries, dumpedEntry{account: account.Hash, value: value})
+		}
+	default:
+		t.Fatalf("unexpected format %q", page.Format)
+	}
+	if len(entries) != page.Count {
+		t.Fatalf("page holds %d entries, reported %d", len(entries), page.Count)
+	}
+	return entries
+}
+
+// verifyPageProof verifies the range proofs of [page] starting at [start]
+// against the state root. [resumed] is the consensus encoding of the account
+// of the cursor, if it was returned by a previous page and still exists.
+func verifyPageProof(t *testing.T, page *DumpStatePage, start DumpStateCursor, resumed []byte, entries []dumpedEntry) {
+	t.Helper()
+	if page.Proof == nil {
+		t.Fatal("missing proof")
+	}
+	var (
+		keys, values [][]byte
+		storage      = make(map[common.Hash][2][][]byte)
+		accounts     = make(map[common.Hash][]byte)
+	)
+	if resumed != nil {
+		keys, values = append(keys, start.Account[:]), append(values, resumed)
+		accounts[start.Account] = resumed
+	}
+	for _, entry := range entries {
+		account := entry.account
+		if entry.slot == nil {
+			keys, values = append(keys, account[:]), append(values, entry.value)
+			accounts[account] = entry.value
+			continue
+		}
+		slots := storage[account]
+		slots[0], slots[1] = append(slots[0], entry.slot[:]), append(slots[1], entry.value)
+		storage[account] = slots
+	}
+	if len(keys) > 0 {
+		if _, err := trie.VerifyRangeProof(page.Root, start.Account[:], keys[len(keys)-1], keys, values, proofDB(page.Proof.Accounts)); err != nil {
+			t.Fatalf("invalid account range proof: %v", err)
+		}
+	}
+	for account, nodes := range page.Proof.Storage {
+		var full snapshot.Account
+		if err := rlp.DecodeBytes(accounts[account], &full); err != nil {
+			t.Fatalf("proven storage of account %s missing from the page: %v", account, err)
+		}
+		first := common.Hash{}
+		if start.Slot != nil && account == start.Account {
+			first = *start.Slot
+		}
+		slots, last := storage[account], first[:]
+		if len(slots[0]) > 0 {
+			last = slots[0][len(slots[0])-1]
+		}
+		if _, err := trie.VerifyRangeProof(common.BytesToHash(full.Root), first[:], last, slots[0], slots[1], proofDB(nodes)); err != nil {
+			t.Fatalf("invalid storage range proof of account %s: %v", account, err)
+		}
+	}
+}
+
+func proofDB(nodes []hexutil.Bytes) ethdb.KeyValueReader {
+	db := memorydb.New()
+	for _, node := range nodes {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
+
+func TestDumpStatePaging(t *testing.T) {
+	s := newDumpTestState(t)
+
+	// The whole state in a single page
+	full := decodePage(t, s.dump(t, s.root, DumpStateConfig{Format: DumpStateFormatRLP}))
+	var accounts, slots int
+	for i, entry := range full {
+		if entry.slot == nil {
+			accounts++
+		} else {
+			slots++
+		}
+		if i > 0 && entry.slot == nil && !(full[i-1].account.Big().Cmp(entry.account.Big()) < 0) {
+			t.Fatalf("accounts not returned in the order of their hashes at entry %d", i)
+		}
+	}
+	if want := 8 + len(s.contracts); accounts != want {
+		t.Fatalf("expected %d accounts, have %d", want, accounts)
+	}
+	if want := len(s.contracts) * s.slots; slots != want {
+		t.Fatalf("expected %d storage slots, have %d", want, slots)
+	}
+
+	for _, format := range []string{DumpStateFormatJSONL, DumpStateFormatRLP} {
+		var (
+			dumped   []dumpedEntry
+			cursor   *DumpStateCursor
+			resumes  int
+			previous = make(map[common.Hash][]byte)
+		)
+		for pages := 0; ; pages++ {
+			if pages > len(full) {
+				t.Fatalf("%s: dump did not complete", format)
+			}
+			page := s.dump(t, s.root, DumpStateConfig{Format: format, Start: cursor, MaxResults: 3, Proof: true})
+			entries := decodePage(t, page)
+			if page.Next != nil && page.Count != 3 {
+				t.Fatalf("%s: expected a full page before the end of the dump, have %d entries", format, page.Count)
+			}
+			var start DumpStateCursor
+			var resumed []byte
+			if cursor != nil {
+				start = *cursor
+				if cursor.Slot != nil {
+					resumes++
+					resumed = previous[cursor.Account]
+				}
+			}
+			verifyPageProof(t, page, start, resumed, entries)
+			for _, entry := range entries {
+				if entry.slot == nil {
+					previous[entry.account] = entry.value
+				}
+			}
+			dumped = append(dumped, entries...)
+			if cursor = page.Next; cursor == nil {
+				break
+			}
+		}
+		if resumes == 0 {
+			t.Fatalf("%s: expected pages resuming in the middle of a storage", format)
+		}
+		if len(dumped) != len(full) {
+			t.Fatalf("%s: expected %d entries, have %d", format, len(full), len(dumped))
+		}
+		for i := range full {
+			if dumped[i].account != full[i].account || (dumped[i].slot == nil) != (full[i].slot == nil) ||
+				(dumped[i].slot != nil && *dumped[i].slot != *full[i].slot) || string(dumped[i].value) != string(full[i].value) {
+				t.Fatalf("%s: entry %d of the paged dump differs from the full dump", format, i)
+			}
+		}
+	}
+}
+
+func TestDumpStateJSONL(t *testing.T) {
+	s := newDumpTestState(t)
+
+	contract := s.contracts[0]
+	contractHash := crypto.Keccak256Hash(contract[:])
+	rawdb.WritePreimages(s.diskdb, map[common.Hash][]byte{contractHash: contract[:]})
+
+	page := s.dump(t, s.root, DumpStateConfig{})
+	if page.Format != DumpStateFormatJSONL || page.Next != nil {
+		t.Fatalf("unexpected page format %q or cursor %v", page.Format, page.Next)
+	}
+	var found bool
+	for _, line := range strings.Split(strings.TrimSuffix(page.Data, "\n"), "\n") {
+		var account dumpStateAccount
+		if err := json.Unmarshal([]byte(line), &account); err != nil {
+			t.Fatal(err)
+		}
+		if account.Hash != contractHash {
+			continue
+		}
+		found = true
+		if account.Address == nil || *account.Address != contract {
+			t.Fatalf("expected the address %s of the contract, have %v", contract, account.Address)
+		}
+		if want := []byte{0x60, 0x00}; string(account.Code) != string(want) {
+			t.Fatalf("expected code %x, have %x", want, account.Code)
+		}
+	}
+	if !found {
+		t.Fatalf("contract %s missing from the dump", contract)
+	}
+
+	// Without code and storage, only the accounts are returned
+	entries := decodePage(t, s.dump(t, s.root, DumpStateConfig{NoCode: true, NoStorage: true}))
+	if want := 8 + len(s.contracts); len(entries) != want {
+		t.Fatalf("expected %d accounts, have %d entries", want, len(entries))
+	}
+	if strings.Contains(s.dump(t, s.root, DumpStateConfig{NoCode: true}).Data, `"code"`) {
+		t.Fatal("unexpected code in a dump without code")
+	}
+}
+
+func TestDumpStateRootChanged(t *testing.T) {
+	s := newDumpTestState(t)
+
+	// Find the first contract in the order of the dump
+	hashes := make([]common.Hash, 0, len(s.contracts))
+	byHash := make(map[common.Hash]common.Address)
+	for _, contract := range s.contracts {
+		hash := crypto.Keccak256Hash(contract[:])
+		hashes = append(hashes, hash)
+		byHash[hash] = contract
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i].Big().Cmp(hashes[j].Big()) < 0 })
+	deleted := byHash[hashes[0]]
+
+	full := decodePage(t, s.dump(t, s.root, DumpStateConfig{Format: DumpStateFormatRLP}))
+	index := -1
+	for i, entry := range full {
+		if entry.account == hashes[0] && entry.slot == nil {
+			index = i
+		}
+	}
+	if index < 0 {
+		t.Fatalf("contract %s missing from the dump", deleted)
+	}
+
+	// The first page stops after the first slot of the contract
+	page := s.dump(t, s.root, DumpStateConfig{Format: DumpStateFormatRLP, MaxResults: index + 2})
+	if page.Next == nil || page.Next.Root != s.root || page.Next.Account != hashes[0] || page.Next.Slot == nil {
+		t.Fatalf("expected a cursor in the storage of %s, have %v", hashes[0], page.Next)
+	}
+	cursor := *page.Next
+
+	// The contract self-destructs in the next accepted block
+	statedb, err := state.New(s.root, s.db, s.snaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statedb.Suicide(deleted)
+	statedb.Finalise(true)
+	root, err := statedb.CommitWithSnap(true, s.snaps, dumpTestBlock2, dumpTestBlock1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The cursor does not resume the dump in the new state
+	config := DumpStateConfig{Format: DumpStateFormatRLP, Start: &cursor}
+	if err := config.sanitize(); err != nil {
+		t.Fatal(err)
+	}
+	dumper := &stateDumper{db: s.db, diskdb: s.diskdb, config: &config}
+	if _, err := dumper.dump(s.snaps, root); err == nil {
+		t.Fatal("expected an error resuming a dump in a different state")
+	}
+
+	// The dump completes in its original state, which is still held by the snapshot
+	page = s.dump(t, s.root, DumpStateConfig{Format: DumpStateFormatRLP, Start: &cursor, Proof: true})
+	entries := decodePage(t, page)
+	if page.Next != nil {
+		t.Fatalf("unexpected cursor %v", page.Next)
+	}
+	if want := len(full) - index - 2; len(entries) != want {
+		t.Fatalf("expected %d entries after the cursor, have %d", want, len(entries))
+	}
+	verifyPageProof(t, page, cursor, full[index].value, entries)
+}
@@ -41,6 +41,7 @@ import (
 	"github.com/ava-labs/coreth/core/bloombits"
 	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/state/pruner"
+	"github.com/ava-labs/coreth/core/state/snapshot"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/core/vm"
 	"github.com/ava-labs/coreth/eth/ethconfig"
@@ -200,7 +201,12 @@ func New(
 		cacheConfig = &core.CacheConfig{
 			TrieCleanLimit:                  config.TrieCleanCache,
 			TrieDirtyLimit:                  config.TrieDirtyCache,
+			TrieDirtyCommitTarget:           config.TrieDirtyCommitTarget,
 			Pruning:                         config.Pruning,
+			CommitInterval:                  config.CommitInterval,
+			SyncableInterval:                config.SyncableInterval,
+			TipBufferSize:                   config.TipBufferSize,
+			StateDiffs:                      config.StateDiffs,
 			PopulateMissingTries:            config.PopulateMissingTries,
 			PopulateMissingTriesParallelism: config.PopulateMissingTriesParallelism,
 			AllowMissingTries:               config.AllowMissingTries,
@@ -208,6 +214,10 @@ func New(
 			SnapshotAsync:                   config.SnapshotAsync,
 			SnapshotVerify:                  config.SnapshotVerify,
 			Preimages:                       config.Preimages,
+			AcceptorQueueLimit:              config.AcceptorQueueLimit,
+			TxLookupLimit:                   config.TxLookupLimit,
+			OnlinePruningBloomSize:          config.OnlinePruningBloomFilterSize,
+			OnlinePruningThrottle:           config.OnlinePruningThrottle,
 		}
 	)
 
@@ -215,6 +225,10 @@ func New(
 		return nil, err
 	}
 
+	if err := eth.handleTrieRegeneration(lastAcceptedHash); err != nil {
+		return nil, err
+	}
+
 	var err error
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, eth.engine, vmConfig, lastAcceptedHash)
 	if err != nil {
@@ -374,10 +388,11 @@ func (s *Ethereum) Stop() error {
 	s.blockchain.Stop()
 	s.engine.Close()
 
-	// Clean shutdown marker as the last thing before closing db
+	// Clean shutdown marker as the last thing before the db is closed. The
+	// chain database is owned by the caller of New, which closes it once it
+	// is no longer used.
 	s.shutdownTracker.Stop()
 
-	s.chainDb.Close()
 	s.eventMux.Stop()
 	return nil
 }
@@ -416,6 +431,43 @@ func (s *Ethereum) precheckPopulateMissingTries() error {
 	return nil
 }
 
+// handleTrieRegeneration rebuilds the state trie of the disk layer of the
+// snapshot if trie regeneration is enabled, restoring the trie nodes lost or
+// corrupted in the database before the chain is loaded. The disk layer may be
+// behind [lastAcceptedHash] after an unclean shutdown, in which case the
+// following blocks are re-executed when the chain is loaded.
+func (s *Ethereum) handleTrieRegeneration(lastAcceptedHash common.Hash) error {
+	if !s.config.TrieRegeneration {
+		return nil
+	}
+	root, blockHash := rawdb.ReadSnapshotRoot(s.chainDb), rawdb.ReadSnapshotBlockHash(s.chainDb)
+	if root == (common.Hash{}) || blockHash == (common.Hash{}) {
+		return errors.New("cannot regenerate trie without a snapshot")
+	}
+	number := rawdb.ReadHeaderNumber(s.chainDb, blockHash)
+	if number == nil {
+		return fmt.Errorf("missing header of snapshot block %s", blockHash)
+	}
+	header := rawdb.ReadHeader(s.chainDb, blockHash, *number)
+	if header == nil {
+		return fmt.Errorf("missing header of snapshot block %s", blockHash)
+	}
+	if header.Root != root {
+		return fmt.Errorf("snapshot root %s does not match the root %s of snapshot block %s", root, header.Root, blockHash)
+	}
+	if canonical := rawdb.ReadCanonicalHash(s.chainDb, *number); canonical != blockHash {
+		return fmt.Errorf("snapshot block %s is not the accepted block %s at height %d", blockHash, canonical, *number)
+	}
+	if blockHash != lastAcceptedHash {
+		log.Warn("Regenerating trie of the snapshot block preceding the last accepted block", "number", *number, "hash", blockHash, "lastAccepted", lastAcceptedHash)
+	}
+	if err := snapshot.RegenerateTrie(s.chainDb, blockHash, root); err != nil {
+		return fmt.Errorf("failed to regenerate trie of block %s (root %s): %w", blockHash, root, err)
+	}
+	log.Warn("Trie regeneration is not meant to be left enabled permanently, please disable it before restarting the node")
+	return nil
+}
+
 func (s *Ethereum) handleOfflinePruning(cacheConfig *core.CacheConfig, chainConfig *params.ChainConfig, vmConfig vm.Config, lastAcceptedHash common.Hash) error {
 	if s.config.OfflinePruning && !s.config.Pruning {
 		return core.ErrRefuseToCorruptArchiver
@@ -51,19 +51,23 @@ var DefaultConfig = NewDefaultConfig()
 
 func NewDefaultConfig() Config {
 	return Config{
-		NetworkId:          1,
-		LightPeers:         100,
-		UltraLightFraction: 75,
-		DatabaseCache:      512,
-		TrieCleanCache:     128,
-		TrieDirtyCache:     256,
-		SnapshotCache:      128,
-		Miner:              miner.Config{},
-		TxPool:             core.DefaultTxPoolConfig,
-		RPCGasCap:          25000000,
-		RPCEVMTimeout:      5 * time.Second,
-		GPO:                DefaultFullGPOConfig,
-		RPCTxFeeCap:        1, // 1 AVAX
+		NetworkId:             1,
+		LightPeers:            100,
+		UltraLightFraction:    75,
+		DatabaseCache:         512,
+		TrieCleanCache:        128,
+		TrieDirtyCache:        256,
+		TrieDirtyCommitTarget: 20,
+		SnapshotCache:         128,
+		CommitInterval:        4096,
+		TipBufferSize:         16,
+		AcceptorQueueLimit:    64,
+		Miner:                 miner.Config{},
+		TxPool:                core.DefaultTxPoolConfig,
+		RPCGasCap:             25000000,
+		RPCEVMTimeout:         5 * time.Second,
+		GPO:                   DefaultFullGPOConfig,
+		RPCTxFeeCap:           1, // 1 AVAX
 	}
 }
 
@@ -83,11 +87,17 @@ type Config struct {
 	DiscoveryURLs []string
 
 	Pruning                         bool    // Whether to disable pruning and flush everything to disk
+	CommitInterval                  uint64  // Number of accepted blocks between trie commits when pruning
+	SyncableInterval                uint64  // Blocks at heights divisible by this interval are always committed when pruning to serve state sync
+	TipBufferSize                   int     // Number of recent accepted state roots kept in memory when pruning
+	StateDiffs                      bool    // Whether to persist per-block state diffs to serve historical state when pruning
 	PopulateMissingTries            *uint64 // Height at which to start re-populating missing tries on startup.
 	PopulateMissingTriesParallelism int     // Number of concurrent readers to use when re-populating missing tries on startup.
 	AllowMissingTries               bool    // Whether to allow an archival node to run with pruning enabled and corrupt a complete index.
 	SnapshotAsync                   bool    // Whether to generate the initial snapshot in async mode
 	SnapshotVerify                  bool    // Whether to verify generated snapshots
+	AcceptorQueueLimit              int     // Number of accepted blocks queued for processing before acceptance blocks
+	TxLookupLimit                   uint64  // The maximum number of blocks from head whose tx indices are reserved.
 
 	// Whitelist of required block number -> hash values to accept
 	Whitelist map[uint64]common.Hash `toml:"-"`
@@ -110,10 +120,11 @@ type Config struct {
 	DatabaseCache      int
 	// DatabaseFreezer    string
 
-	TrieCleanCache int
-	TrieDirtyCache int
-	SnapshotCache  int
-	Preimages      bool
+	TrieCleanCache        int
+	TrieDirtyCache        int
+	TrieDirtyCommitTarget int
+	SnapshotCache         int
+	Preimages             bool
 
 	// Mining options
 	Miner miner.Config
@@ -148,9 +159,23 @@ type Config struct {
 	// replay protection.
 	AllowUnprotectedTxs bool
 
+	// StandardTraceDirectory is the directory of the standard JSON traces
+	// written to files by the tracing API.
+	StandardTraceDirectory string
+
 	// OfflinePruning enables offline pruning on startup of the node. If a node is started
 	// with this configuration option, it must finish pruning before resuming normal operation.
 	OfflinePruning                bool
 	OfflinePruningBloomFilterSize uint64
 	OfflinePruningDataDirectory   string
+
+	// TrieRegeneration rebuilds the state trie of the block of the snapshot
+	// disk layer from the snapshot on startup, restoring missing or corrupt
+	// trie nodes.
+	TrieRegeneration bool
+
+	// OnlinePruning* configure the online pruner, which deletes the stale trie
+	// nodes in the background when started through the admin API.
+	OnlinePruningBloomFilterSize uint64
+	OnlinePruningThrottle        time.Duration
 }
@@ -31,6 +31,7 @@ import (
 	"math/big"
 	"testing"
 
+	"github.com/ava-labs/coreth/commontype"
 	"github.com/ava-labs/coreth/consensus/dummy"
 	"github.com/ava-labs/coreth/core"
 	"github.com/ava-labs/coreth/core/rawdb"
@@ -158,6 +159,10 @@ func (b *testBackend) MinRequiredTip(ctx context.Context, header *types.Header)
 	return dummy.MinRequiredTip(b.chain.Config(), header)
 }
 
+func (b *testBackend) GetFeeConfigAt(parent *types.Header) (commontype.FeeConfig, error) {
+	return b.chain.GetFeeConfigAt(parent)
+}
+
 func (b *testBackend) CurrentHeader() *types.Header {
 	return b.chain.CurrentHeader()
 }
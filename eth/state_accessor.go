@@ -187,6 +187,10 @@ func (eth *Ethereum) stateAtTransaction(block *types.Block, txIndex int, reexec
 	if err != nil {
 		return nil, vm.BlockContext{}, nil, err
 	}
+	// Configure any stateful precompiles that are enabled in this block
+	if err := core.ApplyPrecompileActivations(eth.blockchain.Config(), new(big.Int).SetUint64(parent.Time()), block.Header(), statedb); err != nil {
+		return nil, vm.BlockContext{}, nil, err
+	}
 	if txIndex == 0 && len(block.Transactions()) == 0 {
 		return nil, vm.BlockContext{}, statedb, nil
 	}
@@ -30,11 +30,15 @@ import (
 	"bytes"
 	"context"
 	"crypto/ecdsa"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/big"
+	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/ava-labs/coreth/consensus"
@@ -47,6 +51,7 @@ import (
 	"github.com/ava-labs/coreth/ethdb"
 	"github.com/ava-labs/coreth/internal/ethapi"
 	"github.com/ava-labs/coreth/params"
+	"github.com/ava-labs/coreth/precompile"
 	"github.com/ava-labs/coreth/rpc"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -64,26 +69,25 @@ type testBackend struct {
 	engine      consensus.Engine
 	chaindb     ethdb.Database
 	chain       *core.BlockChain
+
+	maxBlocksPerRequest int64
+	standardTraceDir    string
 }
 
-func newTestBackend(t *testing.T, n int, gspec *core.Genesis, generator func(i int, b *core.BlockGen)) *testBackend {
+func newTestBackend(t *testing.T, n int, gspec *core.Genesis, generator func(i int, b *core.BlockGen, chain *core.BlockChain)) *testBackend {
 	backend := &testBackend{
 		chainConfig: params.TestChainConfig,
 		engine:      dummy.NewETHFaker(),
 		chaindb:     rawdb.NewMemoryDatabase(),
 	}
-	// Generate blocks for testing
+	if gspec.Config != nil {
+		backend.chainConfig = gspec.Config
+	}
 	gspec.Config = backend.chainConfig
 	var (
 		gendb   = rawdb.NewMemoryDatabase()
 		genesis = gspec.MustCommit(gendb)
 	)
-	blocks, _, err := core.GenerateChain(backend.chainConfig, genesis, backend.engine, gendb, n, 10, generator)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Import the canonical chain
 	gspec.MustCommit(backend.chaindb)
 	cacheConfig := &core.CacheConfig{
 		TrieCleanLimit: 256,
@@ -95,6 +99,16 @@ func newTestBackend(t *testing.T, n int, gspec *core.Genesis, generator func(i i
 	if err != nil {
 		t.Fatalf("failed to create tester chain: %v", err)
 	}
+	// Generate blocks for testing, executing their transactions with the chain
+	// as the chain context
+	blocks, _, err := core.GenerateChain(backend.chainConfig, genesis, backend.engine, gendb, n, 10, func(i int, b *core.BlockGen) {
+		generator(i, b, chain)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Import the canonical chain
 	if n, err := chain.InsertChain(blocks); err != nil {
 		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
 	}
@@ -103,6 +117,8 @@ func newTestBackend(t *testing.T, n int, gspec *core.Genesis, generator func(i i
 			t.Fatalf("block %d: failed to accept block in chain: %v", n, err)
 		}
 	}
+	// Index the transactions of the accepted blocks
+	chain.DrainAcceptorQueue()
 	backend.chain = chain
 	return backend
 }
@@ -155,6 +171,14 @@ func (b *testBackend) ChainDb() ethdb.Database {
 	return b.chaindb
 }
 
+func (b *testBackend) GetMaxBlocksPerRequest() int64 {
+	return b.maxBlocksPerRequest
+}
+
+func (b *testBackend) StandardTraceDirectory() string {
+	return b.standardTraceDir
+}
+
 func (b *testBackend) StateAtBlock(ctx context.Context, block *types.Block, reexec uint64, base *state.StateDB, checkLive bool, preferDisk bool) (*state.StateDB, error) {
 	statedb, err := b.chain.StateAt(block.Root())
 	if err != nil {
@@ -172,6 +196,9 @@ func (b *testBackend) StateAtTransaction(ctx context.Context, block *types.Block
 	if err != nil {
 		return nil, vm.BlockContext{}, nil, errStateNotFound
 	}
+	if err := core.ApplyPrecompileActivations(b.chainConfig, new(big.Int).SetUint64(parent.Time()), block.Header(), statedb); err != nil {
+		return nil, vm.BlockContext{}, nil, err
+	}
 	if txIndex == 0 && len(block.Transactions()) == 0 {
 		return nil, vm.BlockContext{}, statedb, nil
 	}
@@ -205,12 +232,12 @@ func TestTraceCall(t *testing.T) {
 	}}
 	genBlocks := 10
 	signer := types.HomesteadSigner{}
-	api := NewAPI(newTestBackend(t, genBlocks, genesis, func(i int, b *core.BlockGen) {
+	api := NewAPI(newTestBackend(t, genBlocks, genesis, func(i int, b *core.BlockGen, chain *core.BlockChain) {
 		// Transfer from account[0] to account[1]
 		//    value: 1000 wei
 		//    fee:   0 wei
 		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, new(big.Int).Add(b.BaseFee(), big.NewInt(int64(500*params.GWei))), nil), signer, accounts[0].key)
-		b.AddTx(tx)
+		b.AddTxWithChain(chain, tx)
 	}))
 
 	var testSuite = []struct {
@@ -334,12 +361,12 @@ func TestTraceTransaction(t *testing.T) {
 	}}
 	target := common.Hash{}
 	signer := types.HomesteadSigner{}
-	api := NewAPI(newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+	api := NewAPI(newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen, chain *core.BlockChain) {
 		// Transfer from account[0] to account[1]
 		//    value: 1000 wei
 		//    fee:   0 wei
 		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, new(big.Int).Add(b.BaseFee(), big.NewInt(int64(500*params.GWei))), nil), signer, accounts[0].key)
-		b.AddTx(tx)
+		b.AddTxWithChain(chain, tx)
 		target = tx.Hash()
 	}))
 	result, err := api.TraceTransaction(context.Background(), target, nil)
@@ -368,12 +395,12 @@ func TestTraceBlock(t *testing.T) {
 	}}
 	genBlocks := 10
 	signer := types.HomesteadSigner{}
-	api := NewAPI(newTestBackend(t, genBlocks, genesis, func(i int, b *core.BlockGen) {
+	api := NewAPI(newTestBackend(t, genBlocks, genesis, func(i int, b *core.BlockGen, chain *core.BlockChain) {
 		// Transfer from account[0] to account[1]
 		//    value: 1000 wei
 		//    fee:   0 wei
 		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, new(big.Int).Add(b.BaseFee(), big.NewInt(int64(500*params.GWei))), nil), signer, accounts[0].key)
-		b.AddTx(tx)
+		b.AddTxWithChain(chain, tx)
 	}))
 
 	var testSuite = []struct {
@@ -487,3 +514,182 @@ func newAccounts(n int) (accounts Accounts) {
 	sort.Sort(accounts)
 	return accounts
 }
+
+// TestTracePrecompileActivation tests that the blocks enabling a stateful
+// precompile are traced on top of the state configured by the precompile.
+func TestTracePrecompileActivation(t *testing.T) {
+	t.Parallel()
+
+	// The transaction allow list is enabled in block 2, which is only valid if
+	// the sender of its transaction is given the admin role when it is enabled
+	accounts := newAccounts(2)
+	config := *params.TestChainConfig
+	config.Precompiles = params.Precompiles{
+		precompile.TxAllowListConfigKey: &precompile.TxAllowListConfig{
+			UpgradeableConfig: precompile.UpgradeableConfig{BlockTimestamp: big.NewInt(20)},
+			AllowListConfig:   precompile.AllowListConfig{AdminAddresses: []common.Address{accounts[0].addr}},
+		},
+	}
+	genesis := &core.Genesis{
+		Config: &config,
+		Alloc: core.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, 3, genesis, func(i int, b *core.BlockGen, chain *core.BlockChain) {
+		gasPrice := new(big.Int).Add(b.BaseFee(), big.NewInt(int64(500*params.GWei)))
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, gasPrice, nil), signer, accounts[0].key)
+		b.AddTxWithChain(chain, tx)
+	})
+	backend.standardTraceDir = t.TempDir()
+	api := NewAPI(backend)
+	block := backend.chain.GetBlockByNumber(2)
+	if parent := backend.chain.GetBlockByNumber(1); config.IsPrecompileEnabled(precompile.TxAllowListAddress, new(big.Int).SetUint64(parent.Time())) ||
+		!config.IsPrecompileEnabled(precompile.TxAllowListAddress, new(big.Int).SetUint64(block.Time())) {
+		t.Fatalf("expected the allow list to be enabled in block 2")
+	}
+
+	results, err := api.TraceBlockByHash(context.Background(), block.Hash(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Error != "" {
+		t.Fatalf("unexpected trace results %+v", results)
+	}
+	if result := results[0].Result.(*ethapi.ExecutionResult); result.Failed || result.Gas != params.TxGas {
+		t.Fatalf("unexpected execution result %+v", result)
+	}
+
+	roots, err := api.IntermediateRoots(context.Background(), block.Hash(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 1 || roots[0] != block.Root() {
+		t.Fatalf("intermediate roots %v do not end with the block root %s", roots, block.Root())
+	}
+
+	files, err := api.StandardTraceBlockToFile(context.Background(), block.Hash(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected 1 trace file, have %d", len(files))
+	}
+}
+
+func TestStandardTraceBlockToFile(t *testing.T) {
+	t.Parallel()
+
+	// Initialize test accounts
+	accounts := newAccounts(2)
+	contract := common.HexToAddress("0x00000000000000000000000000000000deadbeef")
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		accounts[1].addr: {Balance: big.NewInt(params.Ether)},
+		contract:         {Balance: common.Big0, Nonce: 1, Code: []byte{byte(vm.PUSH1), 0x1, byte(vm.POP), byte(vm.STOP)}},
+	}}
+	var txHashes []common.Hash
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen, chain *core.BlockChain) {
+		// Transfer from account[0] to account[1], then call the contract
+		gasPrice := new(big.Int).Add(b.BaseFee(), big.NewInt(int64(500*params.GWei)))
+		tx, _ := types.SignTx(types.NewTransaction(0, accounts[1].addr, big.NewInt(1000), params.TxGas, gasPrice, nil), signer, accounts[0].key)
+		b.AddTxWithChain(chain, tx)
+		txHashes = append(txHashes, tx.Hash())
+		tx, _ = types.SignTx(types.NewTransaction(1, contract, big.NewInt(0), 50000, gasPrice, nil), signer, accounts[0].key)
+		b.AddTxWithChain(chain, tx)
+		txHashes = append(txHashes, tx.Hash())
+	})
+	api := NewAPI(backend)
+	block := backend.chain.GetBlockByNumber(1)
+
+	// The traces are not written without a directory managed by the node
+	if _, err := api.StandardTraceBlockToFile(context.Background(), block.Hash(), nil); err == nil {
+		t.Fatal("expected an error without a standard trace directory")
+	}
+	backend.standardTraceDir = t.TempDir()
+
+	// readTrace returns the JSON lines of a trace file, checking that it is in
+	// the standard trace directory.
+	readTrace := func(file string) []map[string]interface{} {
+		if filepath.Dir(file) != backend.standardTraceDir {
+			t.Fatalf("trace file %s written outside of %s", file, backend.standardTraceDir)
+		}
+		blob, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var lines []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(string(blob)), "\n") {
+			var entry map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("invalid trace line %q: %v", line, err)
+			}
+			lines = append(lines, entry)
+		}
+		return lines
+	}
+
+	// checkTrace checks that [file] is named after the transaction [index] of
+	// the block and holds one EIP-3155 line per executed opcode of [ops],
+	// followed by the result line.
+	checkTrace := func(file string, index int, ops []string) {
+		prefix := fmt.Sprintf("block_%#x-%d-%#x-", block.Hash().Bytes()[:4], index, txHashes[index].Bytes()[:4])
+		if !strings.HasPrefix(filepath.Base(file), prefix) {
+			t.Fatalf("trace file %s does not start with %s", file, prefix)
+		}
+		lines := readTrace(file)
+		if len(lines) != len(ops)+1 {
+			t.Fatalf("trace %d: expected %d lines, have %d", index, len(ops)+1, len(lines))
+		}
+		for i, op := range ops {
+			if lines[i]["opName"] != op {
+				t.Fatalf("trace %d: expected opcode %s at line %d, have %v", index, op, i, lines[i]["opName"])
+			}
+			for _, field := range []string{"pc", "op", "gas", "gasCost", "memSize", "stack", "depth", "refund"} {
+				if _, ok := lines[i][field]; !ok {
+					t.Fatalf("trace %d: missing field %s at line %d", index, field, i)
+				}
+			}
+		}
+		result := lines[len(ops)]
+		if _, ok := result["gasUsed"]; !ok {
+			t.Fatalf("trace %d: missing result line", index)
+		}
+		if _, ok := result["opName"]; ok {
+			t.Fatalf("trace %d: unexpected opcode in the result line", index)
+		}
+	}
+	callOps := []string{"PUSH1", "POP", "STOP"}
+
+	files, err := api.StandardTraceBlockToFile(context.Background(), block.Hash(), nil)
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 trace files, have %d", len(files))
+	}
+	// The transfer only has the result line, the call its 3 opcodes as well
+	checkTrace(files[0], 0, nil)
+	checkTrace(files[1], 1, callOps)
+
+	// Trace a single transaction
+	files, err = api.StandardTraceBlockToFile(context.Background(), block.Hash(), &StdTraceConfig{TxHash: txHashes[1]})
+	if err != nil {
+		t.Fatalf("failed to trace transaction: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected 1 trace file, have %v", files)
+	}
+	checkTrace(files[0], 1, callOps)
+	if entries, err := ioutil.ReadDir(backend.standardTraceDir); err != nil || len(entries) != 3 {
+		t.Fatalf("expected 3 trace files in total, have %d (err %v)", len(entries), err)
+	}
+	if _, err := api.StandardTraceBlockToFile(context.Background(), block.Hash(), &StdTraceConfig{TxHash: common.HexToHash("0x01")}); err == nil {
+		t.Fatal("expected an error tracing a transaction missing from the block")
+	}
+	if _, err := api.StandardTraceBadBlockToFile(context.Background(), block.Hash(), nil); err == nil {
+		t.Fatal("expected an error tracing a block missing from the bad blocks")
+	}
+}
@@ -0,0 +1,539 @@
+// (c) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/core/vm"
+	"github.com/ava-labs/coreth/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var (
+	callTracerName     = "callTracer"
+	prestateTracerName = "prestateTracer"
+
+	// prestateDiffConfig configures the prestateTracer to return the state
+	// modifications of the transactions.
+	prestateDiffConfig = json.RawMessage(`{"diffMode": true}`)
+
+	errVMTraceUnsupported = errors.New("vmTrace is not supported")
+)
+
+// TraceAPI is the collection of the Parity-style tracing APIs, serving flat
+// traces built from the call frames of the callTracer.
+type TraceAPI struct {
+	api *API
+}
+
+// NewTraceAPI creates a new API definition for the Parity-style tracing
+// methods of the Ethereum service.
+func NewTraceAPI(backend Backend) *TraceAPI {
+	return &TraceAPI{api: NewAPI(backend)}
+}
+
+// TraceFilterArgs holds the criteria of the traces returned by trace_filter.
+type TraceFilterArgs struct {
+	FromBlock   *rpc.BlockNumber `json:"fromBlock"`   // First block to trace, defaults to the latest block
+	ToBlock     *rpc.BlockNumber `json:"toBlock"`     // Last block to trace, defaults to the latest block
+	FromAddress []common.Address `json:"fromAddress"` // Senders of the traced calls, any sender if empty
+	ToAddress   []common.Address `json:"toAddress"`   // Recipients of the traced calls, any recipient if empty
+	After       *uint64          `json:"after"`       // Number of matching traces to skip
+	Count       *uint64          `json:"count"`       // Maximum number of traces to return
+}
+
+// callTracerFrame is a call frame as returned by the callTracer.
+type callTracerFrame struct {
+	Type    string            `json:"type"`
+	From    common.Address    `json:"from"`
+	To      *common.Address   `json:"to"`
+	Value   *hexutil.Big      `json:"value"`
+	Gas     hexutil.Uint64    `json:"gas"`
+	GasUsed hexutil.Uint64    `json:"gasUsed"`
+	Input   hexutil.Bytes     `json:"input"`
+	Output  hexutil.Bytes     `json:"output"`
+	Error   string            `json:"error"`
+	Calls   []callTracerFrame `json:"calls"`
+}
+
+// prestateTracerAccount is an account of the pre or post state returned by the
+// prestateTracer in diff mode, where the unmodified fields of the post state
+// are omitted.
+type prestateTracerAccount struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   *uint64                     `json:"nonce"`
+	Code    *hexutil.Bytes              `json:"code"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// prestateTracerDiff is the result of the prestateTracer in diff mode.
+type prestateTracerDiff struct {
+	Pre  map[common.Address]*prestateTracerAccount `json:"pre"`
+	Post map[common.Address]*prestateTracerAccount `json:"post"`
+}
+
+type flatCallAction struct {
+	CallType string         `json:"callType"`
+	From     common.Address `json:"from"`
+	Gas      hexutil.Uint64 `json:"gas"`
+	Input    hexutil.Bytes  `json:"input"`
+	To       common.Address `json:"to"`
+	Value    *hexutil.Big   `json:"value"`
+}
+
+type flatCallResult struct {
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+	Output  hexutil.Bytes  `json:"output"`
+}
+
+type flatCreateAction struct {
+	From  common.Address `json:"from"`
+	Gas   hexutil.Uint64 `json:"gas"`
+	Init  hexutil.Bytes  `json:"init"`
+	Value *hexutil.Big   `json:"value"`
+}
+
+type flatCreateResult struct {
+	Address common.Address `json:"address"`
+	Code    hexutil.Bytes  `json:"code"`
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+}
+
+type flatSuicideAction struct {
+	Address       common.Address `json:"address"`
+	Balance       *hexutil.Big   `json:"balance"`
+	RefundAddress common.Address `json:"refundAddress"`
+}
+
+// flatTrace is a single call frame of a transaction, located in the call tree
+// of the transaction by its trace address.
+type flatTrace struct {
+	Action              interface{}  `json:"action"`
+	BlockHash           *common.Hash `json:"blockHash,omitempty"`
+	BlockNumber         *uint64      `json:"blockNumber,omitempty"`
+	Error               string       `json:"error,omitempty"`
+	Result              interface{}  `json:"result"`
+	Subtraces           int          `json:"subtraces"`
+	TraceAddress        []int        `json:"traceAddress"`
+	TransactionHash     *common.Hash `json:"transactionHash,omitempty"`
+	TransactionPosition *uint64      `json:"transactionPosition,omitempty"`
+	Type                string       `json:"type"`
+
+	from common.Address // Sender of the call, matched by trace_filter
+	to   common.Address // Recipient of the call, matched by trace_filter
+}
+
+// traceResults is the result of replaying a transaction, holding the
+// requested trace types.
+type traceResults struct {
+	Output          hexutil.Bytes                        `json:"output"`
+	StateDiff       map[common.Address]*accountStateDiff `json:"stateDiff"`
+	Trace           []*flatTrace                         `json:"trace"`
+	TransactionHash common.Hash                          `json:"transactionHash"`
+	VMTrace         interface{}                          `json:"vmTrace"`
+}
+
+// accountStateDiff holds the changes of the fields of an account, each being
+// "=" when unchanged, {"+": value} when created, {"-": value} when deleted and
+// {"*": {"from": value, "to": value}} when modified.
+type accountStateDiff struct {
+	Balance interface{}                 `json:"balance"`
+	Code    interface{}                 `json:"code"`
+	Nonce   interface{}                 `json:"nonce"`
+	Storage map[common.Hash]interface{} `json:"storage"`
+}
+
+// Block returns the flat traces of all the transactions of a block.
+func (api *TraceAPI) Block(ctx context.Context, number rpc.BlockNumber) ([]*flatTrace, error) {
+	block, err := api.api.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return api.traceBlock(ctx, block)
+}
+
+// Transaction returns the flat traces of a transaction.
+func (api *TraceAPI) Transaction(ctx context.Context, hash common.Hash) ([]*flatTrace, error) {
+	tx, blockHash, blockNumber, index, err := api.api.backend.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, nil
+	}
+	res, err := api.api.TraceTransaction(ctx, hash, &TraceConfig{Tracer: &callTracerName})
+	if err != nil {
+		return nil, err
+	}
+	frame, err := decodeCallFrame(res)
+	if err != nil {
+		return nil, err
+	}
+	traces := flattenCallFrame(frame, []int{}, nil)
+	for _, trace := range traces {
+		trace.BlockHash = &blockHash
+		trace.BlockNumber = &blockNumber
+		trace.TransactionHash = &hash
+		trace.TransactionPosition = &index
+	}
+	return traces, nil
+}
+
+// Filter returns the flat traces of the calls of a range of blocks matching
+// the senders and recipients of [args]. The range is bounded by the maximum
+// number of blocks per request.
+func (api *TraceAPI) Filter(ctx context.Context, args TraceFilterArgs) ([]*flatTrace, error) {
+	begin, err := api.resolveBlockNumber(ctx, args.FromBlock)
+	if err != nil {
+		return nil, err
+	}
+	end, err := api.resolveBlockNumber(ctx, args.ToBlock)
+	if err != nil {
+		return nil, err
+	}
+	if end < begin {
+		return nil, fmt.Errorf("begin block %d is greater than end block %d", begin, end)
+	}
+	if maxBlocks := api.api.backend.GetMaxBlocksPerRequest(); int64(end-begin) > maxBlocks && maxBlocks > 0 {
+		return nil, fmt.Errorf("requested too many blocks from %d to %d, maximum is set to %d", begin, end, maxBlocks)
+	}
+	// The genesis has no transactions to trace
+	if begin == 0 {
+		begin = 1
+	}
+	var (
+		fromAddresses = addressSet(args.FromAddress)
+		toAddresses   = addressSet(args.ToAddress)
+		skip          uint64
+		traces        = []*flatTrace{}
+	)
+	if args.After != nil {
+		skip = *args.After
+	}
+	for number := begin; number <= end; number++ {
+		block, err := api.api.blockByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return nil, err
+		}
+		blockTraces, err := api.traceBlock(ctx, block)
+		if err != nil {
+			return nil, err
+		}
+		for _, trace := range blockTraces {
+			if len(fromAddresses) > 0 && !fromAddresses[trace.from] {
+				continue
+			}
+			if len(toAddresses) > 0 && !toAddresses[trace.to] {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if args.Count != nil && uint64(len(traces)) >= *args.Count {
+				return traces, nil
+			}
+			traces = append(traces, trace)
+		}
+	}
+	return traces, nil
+}
+
+// ReplayBlockTransactions replays all the transactions of a block, returning
+// the requested [traceTypes] of each transaction: its flat traces ("trace")
+// and its state modifications ("stateDiff").
+func (api *TraceAPI) ReplayBlockTransactions(ctx context.Context, number rpc.BlockNumber, traceTypes []string) ([]*traceResults, error) {
+	var withTrace, withStateDiff bool
+	for _, typ := range traceTypes {
+		switch typ {
+		case "trace":
+			withTrace = true
+		case "stateDiff":
+			withStateDiff = true
+		case "vmTrace":
+			return nil, errVMTraceUnsupported
+		default:
+			return nil, fmt.Errorf("unknown trace type %q", typ)
+		}
+	}
+	block, err := api.api.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	txs := block.Transactions()
+	results := make([]*traceResults, len(txs))
+	if len(txs) == 0 {
+		return results, nil
+	}
+	// The output of the transactions is only known from their call frames
+	frames, err := api.api.traceBlock(ctx, block, &TraceConfig{Tracer: &callTracerName})
+	if err != nil {
+		return nil, err
+	}
+	for i, res := range frames {
+		frame, err := decodeCallFrame(res)
+		if err != nil {
+			return nil, fmt.Errorf("failed to trace transaction %s: %w", txs[i].Hash(), err)
+		}
+		results[i] = &traceResults{Output: frame.Output, TransactionHash: txs[i].Hash()}
+		if withTrace {
+			results[i].Trace = flattenCallFrame(frame, []int{}, nil)
+		}
+	}
+	if !withStateDiff {
+		return results, nil
+	}
+	diffs, err := api.api.traceBlock(ctx, block, &TraceConfig{Tracer: &prestateTracerName, TracerConfig: prestateDiffConfig})
+	if err != nil {
+		return nil, err
+	}
+	for i, res := range diffs {
+		diff, err := decodePrestateDiff(res)
+		if err != nil {
+			return nil, fmt.Errorf("failed to trace transaction %s: %w", txs[i].Hash(), err)
+		}
+		results[i].StateDiff = newStateDiff(diff)
+	}
+	return results, nil
+}
+
+// traceBlock returns the flat traces of all the transactions of [block].
+func (api *TraceAPI) traceBlock(ctx context.Context, block *types.Block) ([]*flatTrace, error) {
+	txs := block.Transactions()
+	traces := []*flatTrace{}
+	// Skip the state regeneration of blocks without transactions
+	if len(txs) == 0 {
+		return traces, nil
+	}
+	results, err := api.api.traceBlock(ctx, block, &TraceConfig{Tracer: &callTracerName})
+	if err != nil {
+		return nil, err
+	}
+	var (
+		blockHash   = block.Hash()
+		blockNumber = block.NumberU64()
+	)
+	for i, res := range results {
+		frame, err := decodeCallFrame(res)
+		if err != nil {
+			return nil, fmt.Errorf("failed to trace transaction %s: %w", txs[i].Hash(), err)
+		}
+		var (
+			txHash  = txs[i].Hash()
+			txIndex = uint64(i)
+		)
+		for _, trace := range flattenCallFrame(frame, []int{}, nil) {
+			trace.BlockHash = &blockHash
+			trace.BlockNumber = &blockNumber
+			trace.TransactionHash = &txHash
+			trace.TransactionPosition = &txIndex
+			traces = append(traces, trace)
+		}
+	}
+	return traces, nil
+}
+
+// resolveBlockNumber returns the number of the block [number], defaulting to
+// the latest block.
+func (api *TraceAPI) resolveBlockNumber(ctx context.Context, number *rpc.BlockNumber) (uint64, error) {
+	if number == nil {
+		latest := rpc.LatestBlockNumber
+		number = &latest
+	}
+	if *number >= 0 {
+		return uint64(*number), nil
+	}
+	header, err := api.api.backend.HeaderByNumber(ctx, *number)
+	if err != nil {
+		return 0, err
+	}
+	if header == nil {
+		return 0, fmt.Errorf("block #%d not found", *number)
+	}
+	return header.Number.Uint64(), nil
+}
+
+// decodeCallFrame decodes the top call frame returned by the callTracer for a
+// transaction traced by [API].
+func decodeCallFrame(res interface{}) (*callTracerFrame, error) {
+	var frame callTracerFrame
+	if err := decodeTraceResult(res, &frame); err != nil {
+		return nil, err
+	}
+	return &frame, nil
+}
+
+// decodePrestateDiff decodes the state modifications returned by the
+// prestateTracer in diff mode for a transaction traced by [API].
+func decodePrestateDiff(res interface{}) (*prestateTracerDiff, error) {
+	var diff prestateTracerDiff
+	if err := decodeTraceResult(res, &diff); err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+// decodeTraceResult decodes the result [res] of a tracer into [v], where [res]
+// is either the result of a single transaction or a transaction of a block.
+func decodeTraceResult(res interface{}, v interface{}) error {
+	if txResult, ok := res.(*txTraceResult); ok {
+		if txResult.Error != "" {
+			return errors.New(txResult.Error)
+		}
+		res = txResult.Result
+	}
+	raw, ok := res.(json.RawMessage)
+	if !ok {
+		return fmt.Errorf("unexpected tracer result type %T", res)
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// flattenCallFrame appends to [traces] the flat traces of [frame] and all its
+// subcalls, in depth-first order, where [frame] is located at [traceAddress].
+func flattenCallFrame(frame *callTracerFrame, traceAddress []int, traces []*flatTrace) []*flatTrace {
+	trace := &flatTrace{
+		Subtraces:    len(frame.Calls),
+		TraceAddress: traceAddress,
+		Error:        frame.Error,
+	}
+	if frame.Error == vm.ErrExecutionReverted.Error() {
+		trace.Error = "Reverted"
+	}
+	value := frame.Value
+	if value == nil {
+		value = (*hexutil.Big)(new(big.Int))
+	}
+	var to common.Address
+	if frame.To != nil {
+		to = *frame.To
+	}
+	switch frame.Type {
+	case vm.CREATE.String(), vm.CREATE2.String():
+		trace.Type = "create"
+		trace.Action = &flatCreateAction{From: frame.From, Gas: frame.Gas, Init: frame.Input, Value: value}
+		if frame.Error == "" {
+			trace.Result = &flatCreateResult{Address: to, Code: frame.Output, GasUsed: frame.GasUsed}
+		}
+	case vm.SELFDESTRUCT.String():
+		trace.Type = "suicide"
+		trace.Action = &flatSuicideAction{Address: frame.From, Balance: value, RefundAddress: to}
+	default:
+		trace.Type = "call"
+		trace.Action = &flatCallAction{CallType: strings.ToLower(frame.Type), From: frame.From, Gas: frame.Gas, Input: frame.Input, To: to, Value: value}
+		if frame.Error == "" {
+			trace.Result = &flatCallResult{GasUsed: frame.GasUsed, Output: frame.Output}
+		}
+	}
+	trace.from, trace.to = frame.From, to
+	traces = append(traces, trace)
+
+	for i := range frame.Calls {
+		subAddress := make([]int, len(traceAddress)+1)
+		copy(subAddress, traceAddress)
+		subAddress[len(traceAddress)] = i
+		traces = flattenCallFrame(&frame.Calls[i], subAddress, traces)
+	}
+	return traces
+}
+
+// newStateDiff converts the state modifications of a transaction returned by
+// the prestateTracer into a Parity state diff. The accounts missing from the
+// pre state were created by the transaction, while the accounts missing from
+// the post state were deleted.
+func newStateDiff(diff *prestateTracerDiff) map[common.Address]*accountStateDiff {
+	stateDiff := make(map[common.Address]*accountStateDiff)
+	for addr, post := range diff.Post {
+		pre, ok := diff.Pre[addr]
+		accountDiff := &accountStateDiff{Storage: make(map[common.Hash]interface{})}
+		if !ok {
+			accountDiff.Balance = map[string]string{"+": encodeBalance(post.Balance)}
+			accountDiff.Nonce = map[string]string{"+": encodeNonce(post.Nonce)}
+			accountDiff.Code = map[string]string{"+": encodeCode(post.Code)}
+			for key, val := range post.Storage {
+				accountDiff.Storage[key] = map[string]string{"+": val.Hex()}
+			}
+			stateDiff[addr] = accountDiff
+			continue
+		}
+		accountDiff.Balance = diffField(encodeBalance(pre.Balance), post.Balance != nil, encodeBalance(post.Balance))
+		accountDiff.Nonce = diffField(encodeNonce(pre.Nonce), post.Nonce != nil, encodeNonce(post.Nonce))
+		accountDiff.Code = diffField(encodeCode(pre.Code), post.Code != nil, encodeCode(post.Code))
+		// Slots cleared by the transaction are omitted from the post state,
+		// and slots previously empty from the pre state
+		for key, val := range pre.Storage {
+			accountDiff.Storage[key] = diffField(val.Hex(), true, post.Storage[key].Hex())
+		}
+		for key, val := range post.Storage {
+			if _, ok := pre.Storage[key]; !ok {
+				accountDiff.Storage[key] = diffField(common.Hash{}.Hex(), true, val.Hex())
+			}
+		}
+		stateDiff[addr] = accountDiff
+	}
+	for addr, pre := range diff.Pre {
+		if _, ok := diff.Post[addr]; ok {
+			continue
+		}
+		accountDiff := &accountStateDiff{
+			Balance: map[string]string{"-": encodeBalance(pre.Balance)},
+			Nonce:   map[string]string{"-": encodeNonce(pre.Nonce)},
+			Code:    map[string]string{"-": encodeCode(pre.Code)},
+			Storage: make(map[common.Hash]interface{}),
+		}
+		for key, val := range pre.Storage {
+			if val != (common.Hash{}) {
+				accountDiff.Storage[key] = map[string]string{"-": val.Hex()}
+			}
+		}
+		stateDiff[addr] = accountDiff
+	}
+	return stateDiff
+}
+
+// diffField returns the change of a field from [from] to [to], where [to] is
+// only set if [modified].
+func diffField(from string, modified bool, to string) interface{} {
+	if !modified || from == to {
+		return "="
+	}
+	return map[string]map[string]string{"*": {"from": from, "to": to}}
+}
+
+func encodeBalance(balance *hexutil.Big) string {
+	if balance == nil {
+		return "0x0"
+	}
+	return balance.String()
+}
+
+func encodeNonce(nonce *uint64) string {
+	if nonce == nil {
+		return "0x0"
+	}
+	return hexutil.EncodeUint64(*nonce)
+}
+
+func encodeCode(code *hexutil.Bytes) string {
+	if code == nil {
+		return "0x"
+	}
+	return code.String()
+}
+
+func addressSet(addrs []common.Address) map[common.Address]bool {
+	set := make(map[common.Address]bool, len(addrs))
+	for _, addr := range addrs {
+		set[addr] = true
+	}
+	return set
+}
@@ -0,0 +1,411 @@
+// (c) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tracers_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/core/vm"
+	"github.com/ava-labs/coreth/eth/tracers"
+	_ "github.com/ava-labs/coreth/eth/tracers/native"
+	"github.com/ava-labs/coreth/params"
+	"github.com/ava-labs/coreth/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	callerAddr   = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	storeAddr    = common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	reverterAddr = common.HexToAddress("0x00000000000000000000000000000000000000cc")
+)
+
+// traceTestChain is a chain of two blocks exercising value transfers, nested
+// calls and reverts. The first block holds a transfer from keys[0] to keys[1]
+// and a call with 5 wei from keys[0] to the caller contract, which forwards 1
+// wei to the store contract writing a storage slot and then calls the reverter
+// contract. The second block holds a transfer from keys[1] to keys[2].
+type traceTestChain struct {
+	api      *tracers.TraceAPI
+	backend  tracers.Backend
+	addrs    []common.Address
+	txHashes []common.Hash
+}
+
+// assertJSON checks that [have] marshals to the same JSON as [want], once the
+// placeholders of [want] are replaced with the accounts, transactions and
+// blocks of the chain: $ADDR<i> for addrs[i], $TX<i> for txHashes[i] and
+// $BLOCK<i> for the hash of block i.
+func (c *traceTestChain) assertJSON(t *testing.T, have interface{}, want string) {
+	t.Helper()
+	var replacements []string
+	for i, addr := range c.addrs {
+		replacements = append(replacements, fmt.Sprintf("$ADDR%d", i), strings.ToLower(addr.Hex()))
+	}
+	for i, hash := range c.txHashes {
+		replacements = append(replacements, fmt.Sprintf("$TX%d", i), hash.Hex())
+	}
+	for i := int64(0); i <= 2; i++ {
+		block, err := c.backend.BlockByNumber(context.Background(), rpc.BlockNumber(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		replacements = append(replacements, fmt.Sprintf("$BLOCK%d", i), block.Hash().Hex())
+	}
+	want = strings.NewReplacer(replacements...).Replace(want)
+
+	haveJSON, err := json.Marshal(have)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var haveVal, wantVal interface{}
+	if err := json.Unmarshal(haveJSON, &haveVal); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantVal); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(haveVal, wantVal) {
+		t.Fatalf("result mismatch:\nhave %s\nwant %s", haveJSON, want)
+	}
+}
+
+func newTraceTestChain(t *testing.T) *traceTestChain {
+	var (
+		keys  []*ecdsa.PrivateKey
+		addrs []common.Address
+	)
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateKey()
+		keys = append(keys, key)
+		addrs = append(addrs, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	// call performs a call to [addr] with [value] wei, discarding its result
+	call := func(addr common.Address, value byte) []byte {
+		code := []byte{
+			byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+			byte(vm.PUSH1), value, byte(vm.PUSH20),
+		}
+		code = append(code, addr.Bytes()...)
+		return append(code, byte(vm.GAS), byte(vm.CALL), byte(vm.POP))
+	}
+	callerCode := append(call(storeAddr, 1), call(reverterAddr, 0)...)
+	callerCode = append(callerCode, byte(vm.STOP))
+
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		addrs[0]:     {Balance: big.NewInt(params.Ether)},
+		addrs[1]:     {Balance: big.NewInt(params.Ether)},
+		callerAddr:   {Balance: common.Big0, Nonce: 1, Code: callerCode},
+		storeAddr:    {Balance: common.Big0, Nonce: 1, Code: []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}},
+		reverterAddr: {Balance: common.Big0, Nonce: 1, Code: []byte{byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.REVERT)}},
+	}}
+	var txHashes []common.Hash
+	signer := types.HomesteadSigner{}
+	backend := tracers.NewTestBackend(t, 2, genesis, func(i int, b *core.BlockGen, chain *core.BlockChain) {
+		gasPrice := new(big.Int).Add(b.BaseFee(), big.NewInt(int64(500*params.GWei)))
+		switch i {
+		case 0:
+			tx, _ := types.SignTx(types.NewTransaction(0, addrs[1], big.NewInt(1000), params.TxGas, gasPrice, nil), signer, keys[0])
+			b.AddTxWithChain(chain, tx)
+			txHashes = append(txHashes, tx.Hash())
+			tx, _ = types.SignTx(types.NewTransaction(1, callerAddr, big.NewInt(5), 100000, gasPrice, nil), signer, keys[0])
+			b.AddTxWithChain(chain, tx)
+			txHashes = append(txHashes, tx.Hash())
+		case 1:
+			tx, _ := types.SignTx(types.NewTransaction(0, addrs[2], big.NewInt(7), params.TxGas, gasPrice, nil), signer, keys[1])
+			b.AddTxWithChain(chain, tx)
+			txHashes = append(txHashes, tx.Hash())
+		}
+	})
+	return &traceTestChain{
+		api:      tracers.NewTraceAPI(backend),
+		backend:  backend,
+		addrs:    addrs,
+		txHashes: txHashes,
+	}
+}
+
+// The flat traces of the chain, shared by the tests of the trace namespace.
+const (
+	transferTrace = `{
+		"action": {"callType": "call", "from": "$ADDR0", "gas": "0x0", "input": "0x", "to": "$ADDR1", "value": "0x3e8"},
+		"blockHash": "$BLOCK1", "blockNumber": 1,
+		"result": {"gasUsed": "0x0", "output": "0x"},
+		"subtraces": 0, "traceAddress": [],
+		"transactionHash": "$TX0", "transactionPosition": 0, "type": "call"
+	}`
+	callerTrace = `{
+		"action": {"callType": "call", "from": "$ADDR0", "gas": "0x13498", "input": "0x", "to": "0x00000000000000000000000000000000000000aa", "value": "0x5"},
+		"blockHash": "$BLOCK1", "blockNumber": 1,
+		"result": {"gasUsed": "0x8508", "output": "0x"},
+		"subtraces": 2, "traceAddress": [],
+		"transactionHash": "$TX1", "transactionPosition": 1, "type": "call"
+	}`
+	storeTrace = `{
+		"action": {"callType": "call", "from": "0x00000000000000000000000000000000000000aa", "gas": "0x10c14", "input": "0x", "to": "0x00000000000000000000000000000000000000bb", "value": "0x1"},
+		"blockHash": "$BLOCK1", "blockNumber": 1,
+		"result": {"gasUsed": "0x565a", "output": "0x"},
+		"subtraces": 0, "traceAddress": [0],
+		"transactionHash": "$TX1", "transactionPosition": 1, "type": "call"
+	}`
+	reverterTrace = `{
+		"action": {"callType": "call", "from": "0x00000000000000000000000000000000000000aa", "gas": "0xacda", "input": "0x", "to": "0x00000000000000000000000000000000000000cc", "value": "0x0"},
+		"blockHash": "$BLOCK1", "blockNumber": 1,
+		"error": "Reverted", "result": null,
+		"subtraces": 0, "traceAddress": [1],
+		"transactionHash": "$TX1", "transactionPosition": 1, "type": "call"
+	}`
+	secondTransferTrace = `{
+		"action": {"callType": "call", "from": "$ADDR1", "gas": "0x0", "input": "0x", "to": "$ADDR2", "value": "0x7"},
+		"blockHash": "$BLOCK2", "blockNumber": 2,
+		"result": {"gasUsed": "0x0", "output": "0x"},
+		"subtraces": 0, "traceAddress": [],
+		"transactionHash": "$TX2", "transactionPosition": 0, "type": "call"
+	}`
+)
+
+func TestTraceBlock(t *testing.T) {
+	t.Parallel()
+
+	c := newTraceTestChain(t)
+	for _, tt := range []struct {
+		number rpc.BlockNumber
+		want   string
+	}{
+		{number: 0, want: `[]`},
+		{number: 1, want: "[" + strings.Join([]string{transferTrace, callerTrace, storeTrace, reverterTrace}, ",") + "]"},
+		{number: 2, want: "[" + secondTransferTrace + "]"},
+		{number: rpc.LatestBlockNumber, want: "[" + secondTransferTrace + "]"},
+	} {
+		traces, err := c.api.Block(context.Background(), tt.number)
+		if err != nil {
+			t.Fatalf("failed to trace block %d: %v", tt.number, err)
+		}
+		c.assertJSON(t, traces, tt.want)
+	}
+	if _, err := c.api.Block(context.Background(), 3); err == nil {
+		t.Fatal("expected an error tracing a missing block")
+	}
+}
+
+func TestTraceTransaction(t *testing.T) {
+	t.Parallel()
+
+	c := newTraceTestChain(t)
+	for i, want := range []string{
+		"[" + transferTrace + "]",
+		"[" + strings.Join([]string{callerTrace, storeTrace, reverterTrace}, ",") + "]",
+		"[" + secondTransferTrace + "]",
+	} {
+		traces, err := c.api.Transaction(context.Background(), c.txHashes[i])
+		if err != nil {
+			t.Fatalf("failed to trace transaction %d: %v", i, err)
+		}
+		c.assertJSON(t, traces, want)
+	}
+	if _, err := c.api.Transaction(context.Background(), common.Hash{0x01}); err == nil {
+		t.Fatal("expected an error tracing a missing transaction")
+	}
+}
+
+func TestTraceFilter(t *testing.T) {
+	t.Parallel()
+
+	c := newTraceTestChain(t)
+	uint64Ptr := func(n uint64) *uint64 { return &n }
+	blockNumber := func(n rpc.BlockNumber) *rpc.BlockNumber { return &n }
+	for _, tt := range []struct {
+		name string
+		args tracers.TraceFilterArgs
+		want []string
+	}{
+		{
+			name: "all",
+			want: []string{transferTrace, callerTrace, storeTrace, reverterTrace, secondTransferTrace},
+		},
+		{
+			name: "latest block",
+			args: tracers.TraceFilterArgs{FromBlock: blockNumber(rpc.LatestBlockNumber)},
+			want: []string{secondTransferTrace},
+		},
+		{
+			name: "first block",
+			args: tracers.TraceFilterArgs{FromBlock: blockNumber(1), ToBlock: blockNumber(1)},
+			want: []string{transferTrace, callerTrace, storeTrace, reverterTrace},
+		},
+		{
+			name: "from contract",
+			args: tracers.TraceFilterArgs{FromAddress: []common.Address{callerAddr}},
+			want: []string{storeTrace, reverterTrace},
+		},
+		{
+			name: "from any of two accounts",
+			args: tracers.TraceFilterArgs{FromAddress: []common.Address{c.addrs[0], c.addrs[1]}},
+			want: []string{transferTrace, callerTrace, secondTransferTrace},
+		},
+		{
+			name: "to reverted contract",
+			args: tracers.TraceFilterArgs{ToAddress: []common.Address{reverterAddr}},
+			want: []string{reverterTrace},
+		},
+		{
+			name: "from and to",
+			args: tracers.TraceFilterArgs{FromAddress: []common.Address{c.addrs[1]}, ToAddress: []common.Address{c.addrs[2]}},
+			want: []string{secondTransferTrace},
+		},
+		{
+			name: "from and to without match",
+			args: tracers.TraceFilterArgs{FromAddress: []common.Address{c.addrs[0]}, ToAddress: []common.Address{c.addrs[2]}},
+		},
+		{
+			name: "after and count",
+			args: tracers.TraceFilterArgs{After: uint64Ptr(1), Count: uint64Ptr(2)},
+			want: []string{callerTrace, storeTrace},
+		},
+		{
+			name: "after across blocks",
+			args: tracers.TraceFilterArgs{After: uint64Ptr(3)},
+			want: []string{reverterTrace, secondTransferTrace},
+		},
+		{
+			name: "after filtered traces",
+			args: tracers.TraceFilterArgs{FromAddress: []common.Address{c.addrs[0], c.addrs[1]}, After: uint64Ptr(1), Count: uint64Ptr(1)},
+			want: []string{callerTrace},
+		},
+		{
+			name: "after all traces",
+			args: tracers.TraceFilterArgs{After: uint64Ptr(5)},
+		},
+		{
+			name: "zero count",
+			args: tracers.TraceFilterArgs{Count: uint64Ptr(0)},
+		},
+	} {
+		// The filter defaults to the latest block only
+		if tt.args.FromBlock == nil {
+			genesis := rpc.BlockNumber(0)
+			tt.args.FromBlock = &genesis
+		}
+		t.Run(tt.name, func(t *testing.T) {
+			traces, err := c.api.Filter(context.Background(), tt.args)
+			if err != nil {
+				t.Fatalf("failed to filter traces: %v", err)
+			}
+			c.assertJSON(t, traces, "["+strings.Join(tt.want, ",")+"]")
+		})
+	}
+}
+
+func TestTraceReplayBlockTransactions(t *testing.T) {
+	t.Parallel()
+
+	c := newTraceTestChain(t)
+	results, err := c.api.ReplayBlockTransactions(context.Background(), 1, []string{"trace", "stateDiff"})
+	if err != nil {
+		t.Fatalf("failed to replay block: %v", err)
+	}
+	// The fees are paid to the zero coinbase, the reverted call leaves the
+	// state of the reverter contract untouched.
+	c.assertJSON(t, results, `[{
+		"output": "0x",
+		"stateDiff": {
+			"0x0000000000000000000000000000000000000000": {
+				"balance": {"*": {"from": "0x0", "to": "0x36170e8cb49000"}},
+				"code": "=", "nonce": "=", "storage": {}
+			},
+			"$ADDR0": {
+				"balance": {"*": {"from": "0xde0b6b3a7640000", "to": "0xdaa9fa51aaf6c18"}},
+				"code": "=", "nonce": {"*": {"from": "0x0", "to": "0x1"}}, "storage": {}
+			},
+			"$ADDR1": {
+				"balance": {"*": {"from": "0xde0b6b3a7640000", "to": "0xde0b6b3a76403e8"}},
+				"code": "=", "nonce": "=", "storage": {}
+			}
+		},
+		"trace": [{
+			"action": {"callType": "call", "from": "$ADDR0", "gas": "0x0", "input": "0x", "to": "$ADDR1", "value": "0x3e8"},
+			"result": {"gasUsed": "0x0", "output": "0x"},
+			"subtraces": 0, "traceAddress": [], "type": "call"
+		}],
+		"transactionHash": "$TX0",
+		"vmTrace": null
+	}, {
+		"output": "0x",
+		"stateDiff": {
+			"0x0000000000000000000000000000000000000000": {
+				"balance": {"*": {"from": "0x36170e8cb49000", "to": "0xc3e61563b3b000"}},
+				"code": "=", "nonce": "=", "storage": {}
+			},
+			"0x00000000000000000000000000000000000000aa": {
+				"balance": {"*": {"from": "0x0", "to": "0x4"}},
+				"code": "=", "nonce": "=", "storage": {}
+			},
+			"0x00000000000000000000000000000000000000bb": {
+				"balance": {"*": {"from": "0x0", "to": "0x1"}},
+				"code": "=", "nonce": "=",
+				"storage": {
+					"0x0000000000000000000000000000000000000000000000000000000000000000": {"*": {
+						"from": "0x0000000000000000000000000000000000000000000000000000000000000000",
+						"to": "0x0000000000000000000000000000000000000000000000000000000000000001"
+					}}
+				}
+			},
+			"$ADDR0": {
+				"balance": {"*": {"from": "0xdaa9fa51aaf6c18", "to": "0xd1cd09e43b04c13"}},
+				"code": "=", "nonce": {"*": {"from": "0x1", "to": "0x2"}}, "storage": {}
+			}
+		},
+		"trace": [{
+			"action": {"callType": "call", "from": "$ADDR0", "gas": "0x13498", "input": "0x", "to": "0x00000000000000000000000000000000000000aa", "value": "0x5"},
+			"result": {"gasUsed": "0x8508", "output": "0x"},
+			"subtraces": 2, "traceAddress": [], "type": "call"
+		}, {
+			"action": {"callType": "call", "from": "0x00000000000000000000000000000000000000aa", "gas": "0x10c14", "input": "0x", "to": "0x00000000000000000000000000000000000000bb", "value": "0x1"},
+			"result": {"gasUsed": "0x565a", "output": "0x"},
+			"subtraces": 0, "traceAddress": [0], "type": "call"
+		}, {
+			"action": {"callType": "call", "from": "0x00000000000000000000000000000000000000aa", "gas": "0xacda", "input": "0x", "to": "0x00000000000000000000000000000000000000cc", "value": "0x0"},
+			"error": "Reverted", "result": null,
+			"subtraces": 0, "traceAddress": [1], "type": "call"
+		}],
+		"transactionHash": "$TX1",
+		"vmTrace": null
+	}]`)
+
+	// Only the requested trace types are returned
+	results, err = c.api.ReplayBlockTransactions(context.Background(), 2, []string{"stateDiff"})
+	if err != nil {
+		t.Fatalf("failed to replay block: %v", err)
+	}
+	if len(results) != 1 || results[0].Trace != nil || len(results[0].StateDiff) == 0 {
+		t.Fatalf("unexpected results of a state diff replay: %v", results)
+	}
+	results, err = c.api.ReplayBlockTransactions(context.Background(), 2, []string{"trace"})
+	if err != nil {
+		t.Fatalf("failed to replay block: %v", err)
+	}
+	if len(results) != 1 || results[0].StateDiff != nil {
+		t.Fatalf("unexpected results of a trace replay: %v", results)
+	}
+	c.assertJSON(t, results[0].Trace, `[{
+		"action": {"callType": "call", "from": "$ADDR1", "gas": "0x0", "input": "0x", "to": "$ADDR2", "value": "0x7"},
+		"result": {"gasUsed": "0x0", "output": "0x"},
+		"subtraces": 0, "traceAddress": [], "type": "call"
+	}]`)
+
+	for _, traceTypes := range [][]string{{"vmTrace"}, {"trace", "unknown"}} {
+		if _, err := c.api.ReplayBlockTransactions(context.Background(), 1, traceTypes); err == nil {
+			t.Fatalf("expected an error replaying with trace types %v", traceTypes)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// (c) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/params"
+	"github.com/ava-labs/coreth/rpc"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// assertJSONEqual checks that [have] marshals to the same JSON as [want].
+func assertJSONEqual(t *testing.T, have interface{}, want string) {
+	t.Helper()
+	haveJSON, err := json.Marshal(have)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var haveVal, wantVal interface{}
+	if err := json.Unmarshal(haveJSON, &haveVal); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantVal); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(haveVal, wantVal) {
+		t.Fatalf("result mismatch:\nhave %s\nwant %s", haveJSON, want)
+	}
+}
+
+func TestFlattenCallFrame(t *testing.T) {
+	t.Parallel()
+
+	// A calls B, which creates C self-destructing to A, then calls D reverting
+	frame, err := decodeCallFrame(json.RawMessage(`{
+		"type": "CALL", "from": "0x00000000000000000000000000000000000000aa", "to": "0x00000000000000000000000000000000000000bb",
+		"value": "0x1", "gas": "0x100", "gasUsed": "0x50", "input": "0x01", "output": "0x02",
+		"calls": [
+			{
+				"type": "CREATE", "from": "0x00000000000000000000000000000000000000bb", "to": "0x00000000000000000000000000000000000000cc",
+				"value": "0x0", "gas": "0x10", "gasUsed": "0x8", "input": "0x6000", "output": "0x00",
+				"calls": [
+					{
+						"type": "SELFDESTRUCT", "from": "0x00000000000000000000000000000000000000cc", "to": "0x00000000000000000000000000000000000000aa",
+						"value": "0x0", "gas": "0x0", "gasUsed": "0x0", "input": "0x"
+					}
+				]
+			},
+			{
+				"type": "STATICCALL", "from": "0x00000000000000000000000000000000000000bb", "to": "0x00000000000000000000000000000000000000dd",
+				"gas": "0x10", "gasUsed": "0x10", "input": "0x", "error": "execution reverted"
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	traces := flattenCallFrame(frame, []int{}, nil)
+	assertJSONEqual(t, traces, `[
+		{
+			"action": {"callType": "call", "from": "0x00000000000000000000000000000000000000aa", "gas": "0x100", "input": "0x01", "to": "0x00000000000000000000000000000000000000bb", "value": "0x1"},
+			"result": {"gasUsed": "0x50", "output": "0x02"},
+			"subtraces": 2, "traceAddress": [], "type": "call"
+		},
+		{
+			"action": {"from": "0x00000000000000000000000000000000000000bb", "gas": "0x10", "init": "0x6000", "value": "0x0"},
+			"result": {"address": "0x00000000000000000000000000000000000000cc", "code": "0x00", "gasUsed": "0x8"},
+			"subtraces": 1, "traceAddress": [0], "type": "create"
+		},
+		{
+			"action": {"address": "0x00000000000000000000000000000000000000cc", "balance": "0x0", "refundAddress": "0x00000000000000000000000000000000000000aa"},
+			"result": null,
+			"subtraces": 0, "traceAddress": [0, 0], "type": "suicide"
+		},
+		{
+			"action": {"callType": "staticcall", "from": "0x00000000000000000000000000000000000000bb", "gas": "0x10", "input": "0x", "to": "0x00000000000000000000000000000000000000dd", "value": "0x0"},
+			"error": "Reverted",
+			"result": null,
+			"subtraces": 0, "traceAddress": [1], "type": "call"
+		}
+	]`)
+	if traces[2].from != common.HexToAddress("0xcc") || traces[2].to != common.HexToAddress("0xaa") {
+		t.Fatalf("unexpected filtered addresses of the self-destruct: from %s, to %s", traces[2].from, traces[2].to)
+	}
+}
+
+func TestNewStateDiff(t *testing.T) {
+	t.Parallel()
+
+	var (
+		slot1, slot2 = common.HexToHash("0x01"), common.HexToHash("0x02")
+		val1, val2   = common.HexToHash("0x0a"), common.HexToHash("0x0b")
+		zero         = common.Hash{}
+	)
+	// A sends a transaction, B has a slot cleared and another one set, C is
+	// created and E is deleted
+	diff, err := decodePrestateDiff(json.RawMessage(fmt.Sprintf(`{
+		"pre": {
+			"0x00000000000000000000000000000000000000aa": {"balance": "0x10", "nonce": 1, "code": "0x", "storage": {}},
+			"0x00000000000000000000000000000000000000bb": {"balance": "0x0", "nonce": 1, "code": "0x60", "storage": {"%[1]s": "%[3]s"}},
+			"0x00000000000000000000000000000000000000ee": {"balance": "0x5", "nonce": 0, "code": "0x", "storage": {"%[1]s": "%[3]s"}}
+		},
+		"post": {
+			"0x00000000000000000000000000000000000000aa": {"balance": "0x8", "nonce": 2},
+			"0x00000000000000000000000000000000000000bb": {"storage": {"%[2]s": "%[4]s"}},
+			"0x00000000000000000000000000000000000000cc": {"balance": "0x1", "nonce": 1, "code": "0x00", "storage": {"%[1]s": "%[3]s"}}
+		}
+	}`, slot1.Hex(), slot2.Hex(), val1.Hex(), val2.Hex())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSONEqual(t, newStateDiff(diff), fmt.Sprintf(`{
+		"0x00000000000000000000000000000000000000aa": {
+			"balance": {"*": {"from": "0x10", "to": "0x8"}}, "code": "=", "nonce": {"*": {"from": "0x1", "to": "0x2"}}, "storage": {}
+		},
+		"0x00000000000000000000000000000000000000bb": {
+			"balance": "=", "code": "=", "nonce": "=",
+			"storage": {"%[1]s": {"*": {"from": "%[3]s", "to": "%[5]s"}}, "%[2]s": {"*": {"from": "%[5]s", "to": "%[4]s"}}}
+		},
+		"0x00000000000000000000000000000000000000cc": {
+			"balance": {"+": "0x1"}, "code": {"+": "0x00"}, "nonce": {"+": "0x1"}, "storage": {"%[1]s": {"+": "%[3]s"}}
+		},
+		"0x00000000000000000000000000000000000000ee": {
+			"balance": {"-": "0x5"}, "code": {"-": "0x"}, "nonce": {"-": "0x0"}, "storage": {"%[1]s": {"-": "%[3]s"}}
+		}
+	}`, slot1.Hex(), slot2.Hex(), val1.Hex(), val2.Hex(), zero.Hex()))
+}
+
+func TestTraceFilterRange(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(1)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+	}}
+	backend := newTestBackend(t, 3, genesis, func(i int, b *core.BlockGen, chain *core.BlockChain) {})
+	backend.maxBlocksPerRequest = 2
+	api := NewTraceAPI(backend)
+
+	blockNumber := func(n int64) *rpc.BlockNumber {
+		number := rpc.BlockNumber(n)
+		return &number
+	}
+	for _, tt := range []struct {
+		args      TraceFilterArgs
+		expectErr error
+	}{
+		// The range is bounded by the maximum number of blocks per request
+		{
+			args:      TraceFilterArgs{FromBlock: blockNumber(0), ToBlock: blockNumber(3)},
+			expectErr: errors.New("requested too many blocks from 0 to 3, maximum is set to 2"),
+		},
+		{
+			args:      TraceFilterArgs{FromBlock: blockNumber(0), ToBlock: blockNumber(int64(rpc.LatestBlockNumber))},
+			expectErr: errors.New("requested too many blocks from 0 to 3, maximum is set to 2"),
+		},
+		{
+			args:      TraceFilterArgs{FromBlock: blockNumber(3), ToBlock: blockNumber(2)},
+			expectErr: errors.New("begin block 3 is greater than end block 2"),
+		},
+		// Blocks without transactions have no traces
+		{
+			args: TraceFilterArgs{FromBlock: blockNumber(0), ToBlock: blockNumber(2)},
+		},
+		{
+			args: TraceFilterArgs{},
+		},
+	} {
+		traces, err := api.Filter(context.Background(), tt.args)
+		if tt.expectErr != nil {
+			if err == nil || err.Error() != tt.expectErr.Error() {
+				t.Fatalf("error mismatch: have %v, want %v", err, tt.expectErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("failed to filter traces: %v", err)
+		}
+		if len(traces) != 0 {
+			t.Fatalf("unexpected traces %v", traces)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+// (c) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tracers
+
+import (
+	"testing"
+
+	"github.com/ava-labs/coreth/core"
+)
+
+// NewTestBackend exposes the test backend to the external test package, which
+// can register the native tracers without an import cycle.
+func NewTestBackend(t *testing.T, n int, gspec *core.Genesis, generator func(i int, b *core.BlockGen, chain *core.BlockChain)) Backend {
+	return newTestBackend(t, n, gspec, generator)
+}
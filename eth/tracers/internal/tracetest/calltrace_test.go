@@ -182,7 +182,7 @@ func testCallTracer(tracerName string, dirPath string, t *testing.T) {
 				}
 				_, statedb = tests.MakePreState(rawdb.NewMemoryDatabase(), test.Genesis.Alloc, false)
 			)
-			tracer, err := tracers.New(tracerName, new(tracers.Context))
+			tracer, err := tracers.New(tracerName, new(tracers.Context), nil)
 			if err != nil {
 				t.Fatalf("failed to create call tracer: %v", err)
 			}
@@ -297,7 +297,7 @@ func benchTracer(tracerName string, test *callTracerTest, b *testing.B) {
 	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		tracer, err := tracers.New(tracerName, new(tracers.Context))
+		tracer, err := tracers.New(tracerName, new(tracers.Context), nil)
 		if err != nil {
 			b.Fatalf("failed to create call tracer: %v", err)
 		}
@@ -363,7 +363,7 @@ func TestZeroValueToNotExitCall(t *testing.T) {
 	}
 	_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), alloc, false)
 	// Create the tracer, the EVM environment and run it
-	tracer, err := tracers.New("callTracer", nil)
+	tracer, err := tracers.New("callTracer", nil, nil)
 	if err != nil {
 		t.Fatalf("failed to create call tracer: %v", err)
 	}
@@ -392,3 +392,211 @@ func TestZeroValueToNotExitCall(t *testing.T) {
 		t.Error("have != want")
 	}
 }
+
+var (
+	// traceTxKey signs the transactions of traceTx, funded in the traced state.
+	traceTxKey, _  = crypto.HexToECDSA("0000000000000000deadbeef00000000000000000000000000000000deadbeef")
+	traceTxOrigin  = crypto.PubkeyToAddress(traceTxKey.PublicKey)
+	traceTxMiner   = common.HexToAddress("0x00000000000000000000000000000000c0ffee00")
+	traceTxBalance = big.NewInt(500000000000000)
+)
+
+// traceTx executes a transaction from traceTxOrigin to [to] with [input] in
+// the state [alloc], traced by the tracer [tracerName] configured with [cfg],
+// and returns the result of the tracer. The native asset precompiles are
+// active in the executed block.
+func traceTx(t *testing.T, tracerName string, cfg json.RawMessage, alloc core.GenesisAlloc, to common.Address, input []byte) json.RawMessage {
+	signer := types.NewEIP155Signer(big.NewInt(1))
+	tx, err := types.SignNewTx(traceTxKey, signer, &types.LegacyTx{
+		GasPrice: big.NewInt(1),
+		Gas:      100000,
+		To:       &to,
+		Data:     input,
+	})
+	if err != nil {
+		t.Fatalf("err %v", err)
+	}
+	txContext := vm.TxContext{
+		Origin:   traceTxOrigin,
+		GasPrice: big.NewInt(1),
+	}
+	context := vm.BlockContext{
+		CanTransfer:       core.CanTransfer,
+		CanTransferMC:     core.CanTransferMC,
+		Transfer:          core.Transfer,
+		TransferMultiCoin: core.TransferMultiCoin,
+		Coinbase:          traceTxMiner,
+		BlockNumber:       new(big.Int).SetUint64(8000000),
+		Time:              params.AvalancheMainnetChainConfig.ApricotPhase2BlockTimestamp,
+		Difficulty:        big.NewInt(0x30000),
+		GasLimit:          uint64(6000000),
+	}
+	// Keep the native asset balances of the origin
+	origin := alloc[traceTxOrigin]
+	origin.Balance = traceTxBalance
+	alloc[traceTxOrigin] = origin
+	_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), alloc, false)
+
+	tracer, err := tracers.New(tracerName, new(tracers.Context), cfg)
+	if err != nil {
+		t.Fatalf("failed to create tracer: %v", err)
+	}
+	evm := vm.NewEVM(context, txContext, statedb, params.AvalancheMainnetChainConfig, vm.Config{Debug: true, Tracer: tracer})
+	msg, err := tx.AsMessage(signer, nil)
+	if err != nil {
+		t.Fatalf("failed to prepare transaction for tracing: %v", err)
+	}
+	st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+	if _, err = st.TransitionDb(); err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	return res
+}
+
+type callLogResult struct {
+	Address  common.Address `json:"address"`
+	Topics   []common.Hash  `json:"topics"`
+	Data     hexutil.Bytes  `json:"data"`
+	Position hexutil.Uint   `json:"position"`
+}
+
+type assetTransferResult struct {
+	AssetID common.Hash    `json:"assetID"`
+	Amount  *hexutil.Big   `json:"amount"`
+	To      common.Address `json:"to"`
+	Input   hexutil.Bytes  `json:"input"`
+}
+
+type callFrameResult struct {
+	Type          string               `json:"type"`
+	To            common.Address       `json:"to"`
+	Error         string               `json:"error"`
+	AssetTransfer *assetTransferResult `json:"assetTransfer"`
+	Calls         []callFrameResult    `json:"calls"`
+	Logs          []callLogResult      `json:"logs"`
+}
+
+// Tx to A, A emits a log, calls B which emits a log, calls C which emits a log
+// and reverts, then emits another log.
+// Expected: the logs are attached to the frames emitting them with their
+// position among the calls, without the logs of the reverted frame.
+func TestCallTracerConfig(t *testing.T) {
+	var (
+		a = common.HexToAddress("0x00000000000000000000000000000000deadbeef")
+		b = common.HexToAddress("0x00000000000000000000000000000000000000bb")
+		c = common.HexToAddress("0x00000000000000000000000000000000000000cc")
+	)
+	call := func(addr byte) []byte {
+		return []byte{
+			byte(vm.PUSH1), 0x0, byte(vm.DUP1), byte(vm.DUP1), byte(vm.DUP1), // in and outs zero
+			byte(vm.DUP1), byte(vm.PUSH1), addr, byte(vm.GAS), // value=0,address=addr, gas=GAS
+			byte(vm.CALL), byte(vm.POP),
+		}
+	}
+	codeA := []byte{
+		byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), 0x0, byte(vm.MSTORE), // mem[0:32] = 42
+		byte(vm.PUSH1), 0x1, byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x0, byte(vm.LOG1), // log mem[0:32] with topic 1
+	}
+	codeA = append(codeA, call(0xbb)...)
+	codeA = append(codeA, call(0xcc)...)
+	codeA = append(codeA, byte(vm.PUSH1), 0x0, byte(vm.DUP1), byte(vm.LOG0), byte(vm.STOP))
+	alloc := func() core.GenesisAlloc {
+		return core.GenesisAlloc{
+			a: core.GenesisAccount{Nonce: 1, Code: codeA},
+			b: core.GenesisAccount{Nonce: 1, Code: []byte{byte(vm.PUSH1), 0x0, byte(vm.DUP1), byte(vm.LOG0), byte(vm.STOP)}},
+			c: core.GenesisAccount{Nonce: 1, Code: []byte{byte(vm.PUSH1), 0x0, byte(vm.DUP1), byte(vm.LOG0), byte(vm.PUSH1), 0x0, byte(vm.DUP1), byte(vm.REVERT)}},
+		}
+	}
+	topLogs := []callLogResult{
+		{Address: a, Topics: []common.Hash{common.BigToHash(common.Big1)}, Data: common.BigToHash(big.NewInt(42)).Bytes(), Position: 0},
+		{Address: a, Topics: []common.Hash{}, Data: hexutil.Bytes{}, Position: 2},
+	}
+
+	for _, tt := range []struct {
+		name   string
+		config string
+		want   callFrameResult
+	}{
+		{
+			name: "default",
+			want: callFrameResult{Type: "CALL", To: a, Calls: []callFrameResult{
+				{Type: "CALL", To: b},
+				{Type: "CALL", To: c, Error: "execution reverted"},
+			}},
+		},
+		{
+			name:   "withLog",
+			config: `{"withLog": true}`,
+			want: callFrameResult{Type: "CALL", To: a, Logs: topLogs, Calls: []callFrameResult{
+				{Type: "CALL", To: b, Logs: []callLogResult{{Address: b, Topics: []common.Hash{}, Data: hexutil.Bytes{}}}},
+				{Type: "CALL", To: c, Error: "execution reverted"},
+			}},
+		},
+		{
+			name:   "onlyTopCall",
+			config: `{"onlyTopCall": true, "withLog": true}`,
+			want: callFrameResult{Type: "CALL", To: a, Logs: []callLogResult{
+				topLogs[0],
+				{Address: a, Topics: []common.Hash{}, Data: hexutil.Bytes{}, Position: 0}, // subcalls are not traced
+			}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg json.RawMessage
+			if tt.config != "" {
+				cfg = json.RawMessage(tt.config)
+			}
+			have := new(callFrameResult)
+			if err := json.Unmarshal(traceTx(t, "callTracer", cfg, alloc(), a, nil), have); err != nil {
+				t.Fatalf("failed to unmarshal trace result: %v", err)
+			}
+			if !reflect.DeepEqual(*have, tt.want) {
+				haveJSON, _ := json.Marshal(have)
+				wantJSON, _ := json.Marshal(tt.want)
+				t.Fatalf("trace mismatch: \nhave %s\nwant %s", haveJSON, wantJSON)
+			}
+		})
+	}
+}
+
+// Tx to the nativeAssetCall precompile, transferring an asset to B and calling
+// B with some call data.
+// Expected: the asset transfer is decoded in the top call frame, with the
+// inner call to B as its subcall.
+func TestCallTracerNativeAssetCall(t *testing.T) {
+	var (
+		b        = common.HexToAddress("0x00000000000000000000000000000000000000bb")
+		assetID  = common.HexToHash("0x01")
+		amount   = big.NewInt(100)
+		callData = []byte{0x01, 0x02, 0x03, 0x04}
+	)
+	alloc := core.GenesisAlloc{
+		traceTxOrigin: core.GenesisAccount{MCBalance: core.GenesisMultiCoinBalance{assetID: big.NewInt(1000)}},
+		b:             core.GenesisAccount{Nonce: 1, Code: []byte{byte(vm.STOP)}},
+	}
+	input := vm.PackNativeAssetCallInput(b, assetID, amount, callData)
+	have := new(callFrameResult)
+	if err := json.Unmarshal(traceTx(t, "callTracer", nil, alloc, vm.NativeAssetCallAddr, input), have); err != nil {
+		t.Fatalf("failed to unmarshal trace result: %v", err)
+	}
+	want := callFrameResult{
+		Type: "CALL",
+		To:   vm.NativeAssetCallAddr,
+		AssetTransfer: &assetTransferResult{
+			AssetID: assetID,
+			Amount:  (*hexutil.Big)(amount),
+			To:      b,
+			Input:   callData,
+		},
+		Calls: []callFrameResult{{Type: "CALL", To: b}},
+	}
+	if !reflect.DeepEqual(*have, want) {
+		haveJSON, _ := json.Marshal(have)
+		wantJSON, _ := json.Marshal(want)
+		t.Fatalf("trace mismatch: \nhave %s\nwant %s", haveJSON, wantJSON)
+	}
+}
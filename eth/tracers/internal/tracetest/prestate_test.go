@@ -0,0 +1,157 @@
+// (c) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tracetest
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+type prestateAccount struct {
+	Balance   *hexutil.Big                 `json:"balance"`
+	Nonce     uint64                       `json:"nonce"`
+	Code      hexutil.Bytes                `json:"code"`
+	Storage   map[common.Hash]common.Hash  `json:"storage"`
+	MCBalance map[common.Hash]*hexutil.Big `json:"mcbalance"`
+}
+
+// Tx to A, A writes a slot and reads another one.
+// Expected: the default mode returns every touched account and slot, the diff
+// mode only the modified fields, before and after the transaction.
+func TestPrestateTracerDiffMode(t *testing.T) {
+	var (
+		a     = common.HexToAddress("0x00000000000000000000000000000000deadbeef")
+		slot1 = common.BigToHash(big.NewInt(1))
+		slot3 = common.BigToHash(big.NewInt(3))
+		code  = []byte{
+			byte(vm.PUSH1), 0x2, byte(vm.PUSH1), 0x1, byte(vm.SSTORE), // slot 1 = 2
+			byte(vm.PUSH1), 0x3, byte(vm.SLOAD), byte(vm.POP), byte(vm.STOP), // read slot 3
+		}
+	)
+	alloc := func() core.GenesisAlloc {
+		return core.GenesisAlloc{
+			a: core.GenesisAccount{
+				Nonce:   1,
+				Code:    code,
+				Storage: map[common.Hash]common.Hash{slot1: common.BigToHash(big.NewInt(1)), slot3: common.BigToHash(big.NewInt(5))},
+			},
+		}
+	}
+
+	var pre map[common.Address]*prestateAccount
+	if err := json.Unmarshal(traceTx(t, "prestateTracer", nil, alloc(), a, nil), &pre); err != nil {
+		t.Fatalf("failed to unmarshal trace result: %v", err)
+	}
+	if _, ok := pre[traceTxMiner]; ok {
+		t.Fatal("unexpected coinbase in prestate")
+	}
+	if have := len(pre[a].Storage); have != 2 {
+		t.Fatalf("expected 2 slots in prestate, have %d", have)
+	}
+
+	var diff struct {
+		Pre  map[common.Address]*prestateAccount `json:"pre"`
+		Post map[common.Address]*prestateAccount `json:"post"`
+	}
+	if err := json.Unmarshal(traceTx(t, "prestateTracer", json.RawMessage(`{"diffMode": true}`), alloc(), a, nil), &diff); err != nil {
+		t.Fatalf("failed to unmarshal trace result: %v", err)
+	}
+	for _, addr := range []common.Address{traceTxOrigin, a, traceTxMiner} {
+		if diff.Pre[addr] == nil || diff.Post[addr] == nil {
+			t.Fatalf("missing pre or post state of %s", addr)
+		}
+	}
+	if have := diff.Pre[traceTxOrigin].Balance.ToInt(); have.Cmp(traceTxBalance) != 0 {
+		t.Fatalf("pre balance mismatch: have %v, want %v", have, traceTxBalance)
+	}
+	if have := diff.Post[traceTxOrigin].Nonce; have != 1 {
+		t.Fatalf("post nonce mismatch: have %d, want 1", have)
+	}
+	// The fees paid by the sender are received by the coinbase
+	fees := diff.Post[traceTxMiner].Balance.ToInt()
+	if have := new(big.Int).Add(diff.Post[traceTxOrigin].Balance.ToInt(), fees); have.Cmp(traceTxBalance) != 0 {
+		t.Fatalf("post balances mismatch: have %v, want %v", have, traceTxBalance)
+	}
+	if want := map[common.Hash]common.Hash{slot1: common.BigToHash(big.NewInt(1))}; !reflect.DeepEqual(diff.Pre[a].Storage, want) {
+		t.Fatalf("pre storage mismatch: have %v, want %v", diff.Pre[a].Storage, want)
+	}
+	want := &prestateAccount{Storage: map[common.Hash]common.Hash{slot1: common.BigToHash(big.NewInt(2))}}
+	if !reflect.DeepEqual(diff.Post[a], want) {
+		t.Fatalf("post state mismatch: have %+v, want %+v", diff.Post[a], want)
+	}
+}
+
+// Tx to the nativeAssetCall precompile, transferring an asset to B.
+// Expected: the balances of the asset held by the sender and B are returned
+// before the transfer, and after it in diff mode.
+func TestPrestateTracerNativeAssetCall(t *testing.T) {
+	var (
+		b       = common.HexToAddress("0x00000000000000000000000000000000000000bb")
+		assetID = common.HexToHash("0x01")
+		input   = vm.PackNativeAssetCallInput(b, assetID, big.NewInt(100), nil)
+	)
+	alloc := func() core.GenesisAlloc {
+		return core.GenesisAlloc{
+			traceTxOrigin: core.GenesisAccount{MCBalance: core.GenesisMultiCoinBalance{assetID: big.NewInt(1000)}},
+			b:             core.GenesisAccount{Nonce: 1, Code: []byte{byte(vm.STOP)}},
+		}
+	}
+	mcBalance := func(balance int64) map[common.Hash]*hexutil.Big {
+		return map[common.Hash]*hexutil.Big{assetID: (*hexutil.Big)(big.NewInt(balance))}
+	}
+
+	var pre map[common.Address]*prestateAccount
+	if err := json.Unmarshal(traceTx(t, "prestateTracer", nil, alloc(), vm.NativeAssetCallAddr, input), &pre); err != nil {
+		t.Fatalf("failed to unmarshal trace result: %v", err)
+	}
+	for addr, want := range map[common.Address]map[common.Hash]*hexutil.Big{traceTxOrigin: mcBalance(1000), b: mcBalance(0)} {
+		if pre[addr] == nil || !equalBalances(pre[addr].MCBalance, want) {
+			t.Fatalf("prestate asset balances mismatch of %s: have %+v, want %v", addr, pre[addr], want)
+		}
+	}
+
+	var diff struct {
+		Pre  map[common.Address]*prestateAccount `json:"pre"`
+		Post map[common.Address]*prestateAccount `json:"post"`
+	}
+	if err := json.Unmarshal(traceTx(t, "prestateTracer", json.RawMessage(`{"diffMode": true}`), alloc(), vm.NativeAssetCallAddr, input), &diff); err != nil {
+		t.Fatalf("failed to unmarshal trace result: %v", err)
+	}
+	if diff.Pre[b] == nil || diff.Post[b] == nil {
+		t.Fatalf("missing pre or post state of %s", b)
+	}
+	if diff.Pre[b].MCBalance != nil {
+		t.Fatalf("unexpected empty asset balance in pre state: %v", diff.Pre[b].MCBalance)
+	}
+	for _, tt := range []struct {
+		have, want map[common.Hash]*hexutil.Big
+	}{
+		{diff.Pre[traceTxOrigin].MCBalance, mcBalance(1000)},
+		{diff.Post[traceTxOrigin].MCBalance, mcBalance(900)},
+		{diff.Post[b].MCBalance, mcBalance(100)},
+	} {
+		if !equalBalances(tt.have, tt.want) {
+			t.Fatalf("asset balances mismatch: have %v, want %v", tt.have, tt.want)
+		}
+	}
+}
+
+func equalBalances(have, want map[common.Hash]*hexutil.Big) bool {
+	if len(have) != len(want) {
+		return false
+	}
+	for assetID, balance := range want {
+		if have[assetID] == nil || have[assetID].ToInt().Cmp(balance.ToInt()) != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+// (c) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package native
+
+import (
+	"github.com/ava-labs/coreth/core/vm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// assetTransfer is a transfer of a native asset made by the nativeAssetCall
+// precompile, which then calls the recipient with the inner call data.
+type assetTransfer struct {
+	AssetID common.Hash `json:"assetID"`
+	Amount  string      `json:"amount"`
+	To      string      `json:"to"`
+	Input   string      `json:"input"`
+}
+
+// nativeAssetsEnabled returns whether the native asset precompiles are active
+// in the block executed by [env].
+func nativeAssetsEnabled(env *vm.EVM) bool {
+	return env.ChainConfig().IsApricotPhase2(env.Context.Time)
+}
+
+// decodeAssetTransfer returns the asset transfer made by a call to [to] with
+// [input], or nil if [to] is not the nativeAssetCall precompile or the input
+// is malformed, in which case the precompile reverts.
+func decodeAssetTransfer(env *vm.EVM, to common.Address, input []byte) *assetTransfer {
+	if to != vm.NativeAssetCallAddr || !nativeAssetsEnabled(env) {
+		return nil
+	}
+	recipient, assetID, amount, callData, err := vm.UnpackNativeAssetCallInput(input)
+	if err != nil {
+		return nil
+	}
+	return &assetTransfer{
+		AssetID: assetID,
+		Amount:  bigToHex(amount),
+		To:      addrToHex(recipient),
+		Input:   bytesToHex(callData),
+	}
+}
+
+// decodeAssetBalance returns the account and the asset whose balance is read
+// by a call to [to] with [input], if [to] is the nativeAssetBalance precompile.
+func decodeAssetBalance(env *vm.EVM, to common.Address, input []byte) (common.Address, common.Hash, bool) {
+	if to != vm.NativeAssetBalanceAddr || !nativeAssetsEnabled(env) {
+		return common.Address{}, common.Hash{}, false
+	}
+	addr, assetID, err := vm.UnpackNativeAssetBalanceInput(input)
+	if err != nil {
+		return common.Address{}, common.Hash{}, false
+	}
+	return addr, assetID, true
+}
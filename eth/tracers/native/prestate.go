@@ -27,6 +27,7 @@
 package native
 
 import (
+	"bytes"
 	"encoding/json"
 	"math/big"
 	"sync/atomic"
@@ -46,25 +47,68 @@ func init() {
 
 type prestate = map[common.Address]*account
 type account struct {
-	Balance string                      `json:"balance"`
-	Nonce   uint64                      `json:"nonce"`
-	Code    string                      `json:"code"`
-	Storage map[common.Hash]common.Hash `json:"storage"`
+	Balance   string                      `json:"balance"`
+	Nonce     uint64                      `json:"nonce"`
+	Code      string                      `json:"code"`
+	Storage   map[common.Hash]common.Hash `json:"storage"`
+	MCBalance map[common.Hash]string      `json:"mcbalance,omitempty"` // Balances of the native assets transferred or read by the transaction
+}
+
+// exists returns whether the account had any state before the transaction.
+func (a *account) exists() bool {
+	if a.Nonce > 0 || a.Code != "0x" || len(a.Storage) > 0 || hexutil.MustDecodeBig(a.Balance).Sign() != 0 {
+		return true
+	}
+	for _, balance := range a.MCBalance {
+		if hexutil.MustDecodeBig(balance).Sign() != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// poststate holds the fields of the accounts modified by the transaction,
+// as returned in diff mode.
+type poststate = map[common.Address]*accountDiff
+type accountDiff struct {
+	Balance   string                      `json:"balance,omitempty"`
+	Nonce     uint64                      `json:"nonce,omitempty"`
+	Code      string                      `json:"code,omitempty"`
+	Storage   map[common.Hash]common.Hash `json:"storage,omitempty"`
+	MCBalance map[common.Hash]string      `json:"mcbalance,omitempty"`
 }
 
 type prestateTracer struct {
 	env       *vm.EVM
 	prestate  prestate
+	poststate poststate
 	create    bool
 	to        common.Address
+	config    prestateTracerConfig
+	created   map[common.Address]bool
+	deleted   map[common.Address]bool
 	interrupt uint32 // Atomic flag to signal execution interruption
 	reason    error  // Textual reason for the interruption
 }
 
-func newPrestateTracer() tracers.Tracer {
-	// First callframe contains tx context info
-	// and is populated on start and end.
-	return &prestateTracer{prestate: prestate{}}
+type prestateTracerConfig struct {
+	DiffMode bool `json:"diffMode"` // If true, this tracer will return state modifications
+}
+
+func newPrestateTracer(ctx *tracers.Context, cfg json.RawMessage) (tracers.Tracer, error) {
+	var config prestateTracerConfig
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, err
+		}
+	}
+	return &prestateTracer{
+		prestate:  prestate{},
+		poststate: poststate{},
+		config:    config,
+		created:   make(map[common.Address]bool),
+		deleted:   make(map[common.Address]bool),
+	}, nil
 }
 
 // CaptureStart implements the EVMLogger interface to initialize the tracing operation.
@@ -83,6 +127,10 @@ func (t *prestateTracer) CaptureStart(env *vm.EVM, from common.Address, to commo
 
 	t.lookupAccount(from)
 	t.lookupAccount(to)
+	if t.config.DiffMode {
+		// The fees of the transaction are paid to the coinbase
+		t.lookupAccount(env.Context.Coinbase)
+	}
 
 	// The recipient balance includes the value transferred.
 	toBal := hexutil.MustDecodeBig(t.prestate[to].Balance)
@@ -103,10 +151,20 @@ func (t *prestateTracer) CaptureStart(env *vm.EVM, from common.Address, to commo
 	fromBal.Add(fromBal, new(big.Int).Add(value, consumedGas))
 	t.prestate[from].Balance = hexutil.EncodeBig(fromBal)
 	t.prestate[from].Nonce--
+
+	if create && t.config.DiffMode {
+		t.created[to] = true
+	}
+	if !create {
+		t.lookupNativeAssetCall(from, to, input)
+	}
 }
 
 // CaptureEnd is called after the call finishes to finalize the tracing.
 func (t *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) {
+	if t.config.DiffMode {
+		return
+	}
 	if t.create {
 		// Exclude created contract.
 		delete(t.prestate, t.to)
@@ -118,27 +176,43 @@ func (t *prestateTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64,
 	stack := scope.Stack
 	stackData := stack.Data()
 	stackLen := len(stackData)
+	caller := scope.Contract.Address()
 	switch {
 	case stackLen >= 1 && (op == vm.SLOAD || op == vm.SSTORE):
 		slot := common.Hash(stackData[stackLen-1].Bytes32())
-		t.lookupStorage(scope.Contract.Address(), slot)
+		t.lookupStorage(caller, slot)
+	case stackLen >= 2 && op == vm.BALANCEMC:
+		addr := common.Address(stackData[stackLen-1].Bytes20())
+		assetID := common.Hash(stackData[stackLen-2].Bytes32())
+		t.lookupAssetBalance(addr, assetID)
+	case stackLen >= 5 && op == vm.CALLEX:
+		addr := common.Address(stackData[stackLen-2].Bytes20())
+		assetID := common.Hash(stackData[stackLen-4].Bytes32())
+		t.lookupAssetBalance(caller, assetID)
+		t.lookupAssetBalance(addr, assetID)
 	case stackLen >= 1 && (op == vm.EXTCODECOPY || op == vm.EXTCODEHASH || op == vm.EXTCODESIZE || op == vm.BALANCE || op == vm.SELFDESTRUCT):
 		addr := common.Address(stackData[stackLen-1].Bytes20())
 		t.lookupAccount(addr)
+		if op == vm.SELFDESTRUCT {
+			t.deleted[caller] = true
+		}
 	case stackLen >= 5 && (op == vm.DELEGATECALL || op == vm.CALL || op == vm.STATICCALL || op == vm.CALLCODE):
 		addr := common.Address(stackData[stackLen-2].Bytes20())
 		t.lookupAccount(addr)
 	case op == vm.CREATE:
-		addr := scope.Contract.Address()
-		nonce := t.env.StateDB.GetNonce(addr)
-		t.lookupAccount(crypto.CreateAddress(addr, nonce))
+		nonce := t.env.StateDB.GetNonce(caller)
+		addr := crypto.CreateAddress(caller, nonce)
+		t.lookupAccount(addr)
+		t.created[addr] = true
 	case stackLen >= 4 && op == vm.CREATE2:
 		offset := stackData[stackLen-2]
 		size := stackData[stackLen-3]
 		init := scope.Memory.GetCopy(int64(offset.Uint64()), int64(size.Uint64()))
 		inithash := crypto.Keccak256(init)
 		salt := stackData[stackLen-4]
-		t.lookupAccount(crypto.CreateAddress2(scope.Contract.Address(), salt.Bytes32(), inithash))
+		addr := crypto.CreateAddress2(caller, salt.Bytes32(), inithash)
+		t.lookupAccount(addr)
+		t.created[addr] = true
 	}
 }
 
@@ -148,6 +222,7 @@ func (t *prestateTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64,
 
 // CaptureEnter is called when EVM enters a new scope (via call, create or selfdestruct).
 func (t *prestateTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	t.lookupNativeAssetCall(from, to, input)
 }
 
 // CaptureExit is called when EVM exits a scope, even if the scope didn't
@@ -158,7 +233,19 @@ func (t *prestateTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
 // GetResult returns the json-encoded nested list of call traces, and any
 // error arising from the encoding or forceful termination (via `Stop`).
 func (t *prestateTracer) GetResult() (json.RawMessage, error) {
-	res, err := json.Marshal(t.prestate)
+	var (
+		res []byte
+		err error
+	)
+	if t.config.DiffMode {
+		t.processDiffState()
+		res, err = json.Marshal(struct {
+			Post poststate `json:"post"`
+			Pre  prestate  `json:"pre"`
+		}{t.poststate, t.prestate})
+	} else {
+		res, err = json.Marshal(t.prestate)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -171,6 +258,81 @@ func (t *prestateTracer) Stop(err error) {
 	atomic.StoreUint32(&t.interrupt, 1)
 }
 
+// processDiffState compares the touched accounts against the state after the
+// transaction, which is only complete once the gas has been refunded and the
+// fees paid, keeping the modified accounts in the pre and post states.
+func (t *prestateTracer) processDiffState() {
+	for addr, state := range t.prestate {
+		// The deleted account's state is pruned from `post` but kept in `pre`
+		if t.deleted[addr] {
+			continue
+		}
+		modified := false
+		postAccount := &accountDiff{Storage: make(map[common.Hash]common.Hash), MCBalance: make(map[common.Hash]string)}
+		newBalance := bigToHex(t.env.StateDB.GetBalance(addr))
+		newNonce := t.env.StateDB.GetNonce(addr)
+		newCode := t.env.StateDB.GetCode(addr)
+
+		if newBalance != state.Balance {
+			modified = true
+			postAccount.Balance = newBalance
+		}
+		if newNonce != state.Nonce {
+			modified = true
+			postAccount.Nonce = newNonce
+		}
+		if !bytes.Equal(newCode, common.FromHex(state.Code)) {
+			modified = true
+			postAccount.Code = bytesToHex(newCode)
+		}
+		for assetID, balance := range state.MCBalance {
+			// don't include the empty balance
+			if hexutil.MustDecodeBig(balance).Sign() == 0 {
+				delete(state.MCBalance, assetID)
+			}
+			newBalance := t.env.StateDB.GetBalanceMultiCoin(addr, assetID)
+			if newBalance.Cmp(hexutil.MustDecodeBig(balance)) == 0 {
+				// Omit unchanged balances
+				delete(state.MCBalance, assetID)
+			} else {
+				modified = true
+				if newBalance.Sign() != 0 {
+					postAccount.MCBalance[assetID] = bigToHex(newBalance)
+				}
+			}
+		}
+		for key, val := range state.Storage {
+			// don't include the empty slot
+			if val == (common.Hash{}) {
+				delete(state.Storage, key)
+			}
+			newVal := t.env.StateDB.GetState(addr, key)
+			if val == newVal {
+				// Omit unchanged slots
+				delete(state.Storage, key)
+			} else {
+				modified = true
+				if newVal != (common.Hash{}) {
+					postAccount.Storage[key] = newVal
+				}
+			}
+		}
+		if modified {
+			t.poststate[addr] = postAccount
+		} else {
+			// if state is not modified, then no need to include into the pre state
+			delete(t.prestate, addr)
+		}
+	}
+	// the new created contracts' prestate were empty, so delete them
+	for addr := range t.created {
+		// the created contract maybe exists in statedb before the creating tx
+		if state := t.prestate[addr]; state != nil && !state.exists() {
+			delete(t.prestate, addr)
+		}
+	}
+}
+
 // lookupAccount fetches details of an account and adds it to the prestate
 // if it doesn't exist there.
 func (t *prestateTracer) lookupAccount(addr common.Address) {
@@ -194,3 +356,32 @@ func (t *prestateTracer) lookupStorage(addr common.Address, key common.Hash) {
 	}
 	t.prestate[addr].Storage[key] = t.env.StateDB.GetState(addr, key)
 }
+
+// lookupNativeAssetCall adds to the prestate the balances of the native asset
+// transferred or read by a call from [from] to [to] with [input], if [to] is
+// one of the native asset precompiles. Since the call is not executed yet,
+// the balances are the ones before the transfer.
+func (t *prestateTracer) lookupNativeAssetCall(from common.Address, to common.Address, input []byte) {
+	if transfer := decodeAssetTransfer(t.env, to, input); transfer != nil {
+		t.lookupAssetBalance(from, transfer.AssetID)
+		t.lookupAssetBalance(common.HexToAddress(transfer.To), transfer.AssetID)
+		return
+	}
+	if addr, assetID, ok := decodeAssetBalance(t.env, to, input); ok {
+		t.lookupAssetBalance(addr, assetID)
+	}
+}
+
+// lookupAssetBalance fetches the balance of the native asset [assetID] held
+// by an account and adds it to the prestate of the account.
+func (t *prestateTracer) lookupAssetBalance(addr common.Address, assetID common.Hash) {
+	t.lookupAccount(addr)
+	state := t.prestate[addr]
+	if _, ok := state.MCBalance[assetID]; ok {
+		return
+	}
+	if state.MCBalance == nil {
+		state.MCBalance = make(map[common.Hash]string)
+	}
+	state.MCBalance[assetID] = bigToHex(t.env.StateDB.GetBalanceMultiCoin(addr, assetID))
+}
@@ -663,28 +663,31 @@ func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Addre
 		return nil, err
 	}
 
-	storageTrie := state.StorageTrie(address)
+	// The account and the storage values are read from the snapshot if it
+	// holds the state, so the tries are only read to build the proofs.
+	exists := state.Exist(address)
 	storageHash := types.EmptyRootHash
 	codeHash := state.GetCodeHash(address)
 	storageProof := make([]StorageResult, len(storageKeys))
 
-	// if we have a storageTrie, (which means the account exists), we can update the storagehash
-	if storageTrie != nil {
-		storageHash = storageTrie.Hash()
+	// if the account exists, its storage root is known
+	if exists {
+		storageHash = state.GetStorageRoot(address)
 	} else {
-		// no storageTrie means the account does not exist, so the codeHash is the hash of an empty bytearray.
+		// if the account does not exist, the codeHash is the hash of an empty bytearray.
 		codeHash = crypto.Keccak256Hash(nil)
 	}
 
 	// create the proof for the storageKeys
 	for i, key := range storageKeys {
-		if storageTrie != nil {
+		if exists && storageHash != types.EmptyRootHash {
 			proof, storageError := state.GetStorageProof(address, common.HexToHash(key))
 			if storageError != nil {
 				return nil, storageError
 			}
 			storageProof[i] = StorageResult{key, (*hexutil.Big)(state.GetState(address, common.HexToHash(key)).Big()), toHexSlice(proof)}
 		} else {
+			// an empty storage holds no values and is proven by the account alone
 			storageProof[i] = StorageResult{key, &hexutil.Big{}, []string{}}
 		}
 	}
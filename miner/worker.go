@@ -146,8 +146,11 @@ func (w *worker) commitNewWork() (*types.Block, error) {
 	// Set BaseFee and Extra data field if we are post ApricotPhase3
 	bigTimestamp := big.NewInt(timestamp)
 	if w.chainConfig.IsApricotPhase3(bigTimestamp) {
-		var err error
-		header.Extra, header.BaseFee, err = dummy.CalcBaseFee(w.chainConfig, parent.Header(), uint64(timestamp))
+		feeConfig, err := w.chain.GetFeeConfigAt(parent.Header())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get fee config: %w", err)
+		}
+		header.Extra, header.BaseFee, err = dummy.CalcBaseFee(w.chainConfig, feeConfig, parent.Header(), uint64(timestamp))
 		if err != nil {
 			return nil, fmt.Errorf("failed to calculate new base fee: %w", err)
 		}
@@ -167,6 +170,10 @@ func (w *worker) commitNewWork() (*types.Block, error) {
 	if w.chainConfig.DAOForkSupport && w.chainConfig.DAOForkBlock != nil && w.chainConfig.DAOForkBlock.Cmp(header.Number) == 0 {
 		misc.ApplyDAOHardFork(env.state)
 	}
+	// Configure any stateful precompiles that are enabled in this block
+	if err := core.ApplyPrecompileActivations(w.chainConfig, new(big.Int).SetUint64(parent.Time()), header, env.state); err != nil {
+		return nil, fmt.Errorf("failed to configure precompiles: %w", err)
+	}
 
 	// Fill the block with all available pending transactions.
 	pending := w.eth.TxPool().Pending(true)
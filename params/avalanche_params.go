@@ -31,6 +31,12 @@ const (
 	ApricotPhase5TargetGas                uint64 = 15_000_000
 	ApricotPhase5BaseFeeChangeDenominator uint64 = 36
 
+	ApricotPhase4TargetBlockRate  uint64 = 2 // in seconds
+	ApricotPhase4MinBlockGasCost  int64  = 0
+	ApricotPhase4MaxBlockGasCost  int64  = 1_000_000
+	ApricotPhase4BlockGasCostStep int64  = 50_000
+	ApricotPhase5BlockGasCostStep int64  = 200_000
+
 	// The base cost to charge per atomic transaction. Added in Apricot Phase 5.
 	AtomicTxBaseCost uint64 = 10_000
 )
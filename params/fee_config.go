@@ -0,0 +1,107 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package params
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ava-labs/coreth/commontype"
+	"github.com/ava-labs/coreth/precompile"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// ApricotPhase3FeeConfig is the fee config of chains without a FeeConfig
+	// from Apricot Phase 3, which introduces dynamic fees. The block gas cost
+	// parameters are only used from Apricot Phase 4.
+	ApricotPhase3FeeConfig = commontype.FeeConfig{
+		TargetGas:                new(big.Int).SetUint64(ApricotPhase3TargetGas),
+		BaseFeeChangeDenominator: new(big.Int).SetUint64(ApricotPhase4BaseFeeChangeDenominator),
+		MinBaseFee:               big.NewInt(ApricotPhase3MinBaseFee),
+		MaxBaseFee:               big.NewInt(ApricotPhase3MaxBaseFee),
+		TargetBlockRate:          ApricotPhase4TargetBlockRate,
+		MinBlockGasCost:          big.NewInt(ApricotPhase4MinBlockGasCost),
+		MaxBlockGasCost:          big.NewInt(ApricotPhase4MaxBlockGasCost),
+		BlockGasCostStep:         big.NewInt(ApricotPhase4BlockGasCostStep),
+	}
+
+	// ApricotPhase4FeeConfig is the fee config of chains without a FeeConfig
+	// from Apricot Phase 4, which introduces the block gas cost.
+	ApricotPhase4FeeConfig = commontype.FeeConfig{
+		TargetGas:                new(big.Int).SetUint64(ApricotPhase3TargetGas),
+		BaseFeeChangeDenominator: new(big.Int).SetUint64(ApricotPhase4BaseFeeChangeDenominator),
+		MinBaseFee:               big.NewInt(ApricotPhase4MinBaseFee),
+		MaxBaseFee:               big.NewInt(ApricotPhase4MaxBaseFee),
+		TargetBlockRate:          ApricotPhase4TargetBlockRate,
+		MinBlockGasCost:          big.NewInt(ApricotPhase4MinBlockGasCost),
+		MaxBlockGasCost:          big.NewInt(ApricotPhase4MaxBlockGasCost),
+		BlockGasCostStep:         big.NewInt(ApricotPhase4BlockGasCostStep),
+	}
+
+	// ApricotPhase5FeeConfig is the fee config of chains without a FeeConfig
+	// from Apricot Phase 5, which removes the upper bound of the base fee.
+	ApricotPhase5FeeConfig = commontype.FeeConfig{
+		TargetGas:                new(big.Int).SetUint64(ApricotPhase5TargetGas),
+		BaseFeeChangeDenominator: new(big.Int).SetUint64(ApricotPhase5BaseFeeChangeDenominator),
+		MinBaseFee:               big.NewInt(ApricotPhase4MinBaseFee),
+		MaxBaseFee:               nil,
+		TargetBlockRate:          ApricotPhase4TargetBlockRate,
+		MinBlockGasCost:          big.NewInt(ApricotPhase4MinBlockGasCost),
+		MaxBlockGasCost:          big.NewInt(ApricotPhase4MaxBlockGasCost),
+		BlockGasCostStep:         big.NewInt(ApricotPhase5BlockGasCostStep),
+	}
+)
+
+// GetFeeConfig returns the fee config specified by the chain config at
+// [timestamp]: [c.FeeConfig] if set, or the fee config of the Apricot phase
+// active at [timestamp] otherwise.
+// It does not take changes made through the fee manager precompile into
+// account, see GetActiveFeeConfig.
+func (c *ChainConfig) GetFeeConfig(timestamp *big.Int) commontype.FeeConfig {
+	switch {
+	case c.FeeConfig != nil:
+		return *c.FeeConfig
+	case c.IsApricotPhase5(timestamp):
+		return ApricotPhase5FeeConfig
+	case c.IsApricotPhase4(timestamp):
+		return ApricotPhase4FeeConfig
+	default:
+		return ApricotPhase3FeeConfig
+	}
+}
+
+// GetActiveFeeConfig returns the fee config in force after the block with
+// [timestamp] and post-state [state]: the fee config stored by the fee
+// manager precompile if it is enabled and has been set, or GetFeeConfig
+// otherwise.
+func (c *ChainConfig) GetActiveFeeConfig(state precompile.StateDB, timestamp *big.Int) commontype.FeeConfig {
+	if c.IsPrecompileEnabled(precompile.FeeConfigManagerAddress, timestamp) {
+		if feeConfig, ok := precompile.GetStoredFeeConfig(state); ok {
+			return feeConfig
+		}
+	}
+	return c.GetFeeConfig(timestamp)
+}
+
+// verifyFeeConfig returns an error if [c.FeeConfig] is set and invalid.
+func (c *ChainConfig) verifyFeeConfig() error {
+	if c.FeeConfig == nil {
+		return nil
+	}
+	if err := c.FeeConfig.Verify(); err != nil {
+		return fmt.Errorf("invalid fee config: %w", err)
+	}
+	return nil
+}
+
+// checkFeeConfigCompatible returns an error if [newcfg] changes the fee config
+// of a chain that has blocks beyond the genesis, since the fee config is used
+// to verify all of them.
+func (c *ChainConfig) checkFeeConfigCompatible(newcfg *ChainConfig, headHeight *big.Int) *ConfigCompatError {
+	if headHeight.Sign() == 0 || c.FeeConfig.Equal(newcfg.FeeConfig) {
+		return nil
+	}
+	return newCompatError("fee config", common.Big0, common.Big0)
+}
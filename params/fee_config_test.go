@@ -0,0 +1,64 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package params
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ava-labs/coreth/commontype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFeeConfig(t *testing.T) {
+	config := *TestApricotPhase5Config
+	config.ApricotPhase4BlockTimestamp = big.NewInt(10)
+	config.ApricotPhase5BlockTimestamp = big.NewInt(20)
+
+	assert.Equal(t, ApricotPhase3FeeConfig, config.GetFeeConfig(big.NewInt(9)))
+	assert.Equal(t, ApricotPhase4FeeConfig, config.GetFeeConfig(big.NewInt(10)))
+	assert.Equal(t, ApricotPhase5FeeConfig, config.GetFeeConfig(big.NewInt(20)))
+	for _, feeConfig := range []commontype.FeeConfig{ApricotPhase3FeeConfig, ApricotPhase4FeeConfig, ApricotPhase5FeeConfig} {
+		assert.NoError(t, feeConfig.Verify())
+	}
+
+	// the fee config of the chain config replaces the defaults of all phases
+	feeConfig := ApricotPhase5FeeConfig
+	feeConfig.TargetGas = big.NewInt(20_000_000)
+	config.FeeConfig = &feeConfig
+	assert.NoError(t, config.CheckConfigForkOrder())
+	assert.Equal(t, feeConfig, config.GetFeeConfig(big.NewInt(0)))
+	assert.Equal(t, feeConfig, config.GetFeeConfig(big.NewInt(20)))
+
+	feeConfig.BaseFeeChangeDenominator = big.NewInt(0)
+	assert.Error(t, config.CheckConfigForkOrder(), "invalid fee configs should be rejected")
+}
+
+func TestFeeConfigUnmarshalJSON(t *testing.T) {
+	var config ChainConfig
+	assert.NoError(t, json.Unmarshal([]byte(`{"chainId": 1, "feeConfig": {"targetGas": 20000000, "baseFeeChangeDenominator": 36, "minBaseFee": 1000000000, "targetBlockRate": 2, "minBlockGasCost": 0, "maxBlockGasCost": 1000000, "blockGasCostStep": 200000}}`), &config))
+	assert.True(t, config.FeeConfig.Equal(&commontype.FeeConfig{
+		TargetGas:                big.NewInt(20_000_000),
+		BaseFeeChangeDenominator: big.NewInt(36),
+		MinBaseFee:               big.NewInt(1_000_000_000),
+		TargetBlockRate:          2,
+		MinBlockGasCost:          big.NewInt(0),
+		MaxBlockGasCost:          big.NewInt(1_000_000),
+		BlockGasCostStep:         big.NewInt(200_000),
+	}))
+	assert.NoError(t, config.verifyFeeConfig())
+}
+
+func TestFeeConfigCheckCompatible(t *testing.T) {
+	feeConfig := ApricotPhase5FeeConfig
+	feeConfig.TargetGas = big.NewInt(20_000_000)
+	stored, changed := *TestChainConfig, *TestChainConfig
+	changed.FeeConfig = &feeConfig
+
+	// the fee config can only be changed before the first block is accepted
+	assert.Nil(t, stored.CheckCompatible(&changed, 0, 0))
+	assert.NotNil(t, stored.CheckCompatible(&changed, 1, 10))
+	assert.Nil(t, changed.CheckCompatible(&changed, 1, 10))
+}
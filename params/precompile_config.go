@@ -0,0 +1,127 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package params
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ava-labs/coreth/precompile"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Precompiles maps the config key of each registered precompile module
+// enabled by the chain config to its configuration.
+type Precompiles map[string]precompile.StatefulPrecompileConfig
+
+// UnmarshalJSON decodes the configuration of each module into the config type
+// of the module registered with its key.
+func (p *Precompiles) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	precompiles := make(Precompiles, len(raw))
+	for key, rawConfig := range raw {
+		module, ok := precompile.GetModule(key)
+		if !ok {
+			return fmt.Errorf("unknown precompile config key %q", key)
+		}
+		config := module.NewConfig()
+		if err := json.Unmarshal(rawConfig, config); err != nil {
+			return fmt.Errorf("failed to decode config of precompile %s: %w", key, err)
+		}
+		precompiles[key] = config
+	}
+	*p = precompiles
+	return nil
+}
+
+// verifyPrecompiles returns an error if the configuration of any precompile
+// module enabled by the chain config is invalid.
+func (c *ChainConfig) verifyPrecompiles() error {
+	keys := make([]string, 0, len(c.Precompiles))
+	for key := range c.Precompiles {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if _, ok := precompile.GetModule(key); !ok {
+			return fmt.Errorf("unknown precompile config key %q", key)
+		}
+		if err := c.Precompiles[key].Verify(); err != nil {
+			return fmt.Errorf("invalid config of precompile %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// IsPrecompileEnabled returns whether the precompile module at [address] is
+// enabled at [blockTimestamp].
+func (c *ChainConfig) IsPrecompileEnabled(address common.Address, blockTimestamp *big.Int) bool {
+	module, ok := precompile.GetModuleByAddress(address)
+	if !ok {
+		return false
+	}
+	config, ok := c.Precompiles[module.ConfigKey]
+	if !ok {
+		return false
+	}
+	return isForked(config.Timestamp(), blockTimestamp)
+}
+
+// EnabledPrecompiles returns the precompile modules enabled at
+// [blockTimestamp], sorted by address.
+func (c *ChainConfig) EnabledPrecompiles(blockTimestamp *big.Int) []precompile.Module {
+	var modules []precompile.Module
+	for _, module := range precompile.RegisteredModules() {
+		config, ok := c.Precompiles[module.ConfigKey]
+		if ok && isForked(config.Timestamp(), blockTimestamp) {
+			modules = append(modules, module)
+		}
+	}
+	return modules
+}
+
+// GetPrecompileConfig returns the configuration of the precompile module
+// registered with [configKey], if the chain config includes one.
+func (c *ChainConfig) GetPrecompileConfig(configKey string) (precompile.StatefulPrecompileConfig, bool) {
+	config, ok := c.Precompiles[configKey]
+	return config, ok
+}
+
+// checkPrecompilesCompatible returns an error if the activation timestamp of
+// a precompile module in [newcfg] would alter blocks before [headTimestamp].
+func (c *ChainConfig) checkPrecompilesCompatible(newcfg *ChainConfig, headTimestamp *big.Int) *ConfigCompatError {
+	keys := make(map[string]struct{}, len(c.Precompiles)+len(newcfg.Precompiles))
+	for key := range c.Precompiles {
+		keys[key] = struct{}{}
+	}
+	for key := range newcfg.Precompiles {
+		keys[key] = struct{}{}
+	}
+	sortedKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+
+	for _, key := range sortedKeys {
+		var storedTimestamp, newTimestamp *big.Int
+		if config, ok := c.Precompiles[key]; ok {
+			storedTimestamp = config.Timestamp()
+		}
+		if config, ok := newcfg.Precompiles[key]; ok {
+			newTimestamp = config.Timestamp()
+		}
+		if isForkIncompatible(storedTimestamp, newTimestamp, headTimestamp) {
+			return newCompatError(fmt.Sprintf("precompile %s enable block timestamp", key), storedTimestamp, newTimestamp)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package params
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ava-labs/coreth/precompile"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+var testPrecompileAddr = common.HexToAddress("0x02000000000000000000000000000000000000f0")
+
+type testPrecompileConfig struct {
+	precompile.UpgradeableConfig
+	Value uint64 `json:"value"`
+}
+
+func (c *testPrecompileConfig) Verify() error {
+	if c.Value == 0 {
+		return errors.New("value must be set")
+	}
+	return nil
+}
+
+func (*testPrecompileConfig) Configure(precompile.ChainConfig, precompile.StateDB, precompile.BlockContext) error {
+	return nil
+}
+
+type testPrecompileContract struct{}
+
+func (*testPrecompileContract) Run(precompile.PrecompileAccessibleState, common.Address, common.Address, []byte, uint64, bool) ([]byte, uint64, error) {
+	return nil, 0, nil
+}
+
+func init() {
+	if err := precompile.RegisterModule(precompile.Module{
+		ConfigKey: "testPrecompileConfig",
+		Address:   testPrecompileAddr,
+		Contract:  &testPrecompileContract{},
+		NewConfig: func() precompile.StatefulPrecompileConfig { return &testPrecompileConfig{} },
+	}); err != nil {
+		panic(err)
+	}
+}
+
+func TestPrecompilesUnmarshalJSON(t *testing.T) {
+	var config ChainConfig
+	assert.NoError(t, json.Unmarshal([]byte(`{"chainId": 1, "precompiles": {"testPrecompileConfig": {"blockTimestamp": 10, "value": 5}}}`), &config))
+	precompileConfig, ok := config.GetPrecompileConfig("testPrecompileConfig")
+	assert.True(t, ok)
+	assert.Equal(t, &testPrecompileConfig{
+		UpgradeableConfig: precompile.UpgradeableConfig{BlockTimestamp: big.NewInt(10)},
+		Value:             5,
+	}, precompileConfig)
+
+	// the config should survive a round trip through the database encoding
+	encoded, err := json.Marshal(&config)
+	assert.NoError(t, err)
+	var decoded ChainConfig
+	assert.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, config.Precompiles, decoded.Precompiles)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"precompiles": {"unknownConfig": {}}}`), &config))
+}
+
+func TestPrecompileActivation(t *testing.T) {
+	config := *TestChainConfig
+	config.Precompiles = Precompiles{
+		"testPrecompileConfig": &testPrecompileConfig{
+			UpgradeableConfig: precompile.UpgradeableConfig{BlockTimestamp: big.NewInt(10)},
+			Value:             1,
+		},
+	}
+	assert.NoError(t, config.CheckConfigForkOrder())
+
+	assert.False(t, config.IsPrecompileEnabled(testPrecompileAddr, big.NewInt(9)))
+	assert.True(t, config.IsPrecompileEnabled(testPrecompileAddr, big.NewInt(10)))
+	assert.Empty(t, config.AvalancheRules(common.Big0, big.NewInt(9)).Precompiles)
+	assert.Contains(t, config.AvalancheRules(common.Big0, big.NewInt(10)).Precompiles, testPrecompileAddr)
+
+	config.Precompiles["testPrecompileConfig"].(*testPrecompileConfig).Value = 0
+	assert.Error(t, config.CheckConfigForkOrder(), "invalid precompile configs should be rejected")
+}
+
+func TestPrecompilesCheckCompatible(t *testing.T) {
+	newConfig := func(timestamp *big.Int) *ChainConfig {
+		config := *TestChainConfig
+		if timestamp != nil {
+			config.Precompiles = Precompiles{
+				"testPrecompileConfig": &testPrecompileConfig{
+					UpgradeableConfig: precompile.UpgradeableConfig{BlockTimestamp: timestamp},
+					Value:             1,
+				},
+			}
+		}
+		return &config
+	}
+
+	// scheduling or moving an activation in the future is allowed
+	assert.Nil(t, newConfig(nil).CheckCompatible(newConfig(big.NewInt(20)), 0, 10))
+	assert.Nil(t, newConfig(big.NewInt(20)).CheckCompatible(newConfig(big.NewInt(30)), 0, 10))
+	// changing an activation in the past is not
+	assert.NotNil(t, newConfig(big.NewInt(5)).CheckCompatible(newConfig(big.NewInt(30)), 0, 10))
+	assert.NotNil(t, newConfig(big.NewInt(5)).CheckCompatible(newConfig(nil), 0, 10))
+	assert.NotNil(t, newConfig(nil).CheckCompatible(newConfig(big.NewInt(5)), 0, 10))
+}
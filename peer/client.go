@@ -19,12 +19,20 @@ var (
 
 // Client defines ability to send request / response through the Network
 type Client interface {
-	// RequestAny synchronously sends request to the first connected peer that matches the specified minVersion in
-	// random order.
+	// RequestAny synchronously sends request to a connected peer that matches the specified minVersion,
+	// preferring peers that respond quickly and reliably.
 	// A peer is considered a match if its version is greater than or equal to the specified minVersion
+	// Returns the response bytes and the nodeID of the peer that sent them.
 	// Returns errNoPeersMatchingVersion if no peer could be found matching specified version
 	// and errRequestFailed if the request should be retried.
-	RequestAny(minVersion version.Application, request []byte) ([]byte, error)
+	RequestAny(minVersion version.Application, request []byte) ([]byte, ids.ShortID, error)
+
+	// RequestFromN synchronously sends request to up to [n] distinct connected peers that match
+	// the specified minVersion, selected regardless of their score.
+	// Returns the responses keyed by the nodeID of each peer the request was sent to, with a nil
+	// response for the peers whose request failed.
+	// Returns an error if the request could not be sent to any peer.
+	RequestFromN(minVersion version.Application, n int, request []byte) (map[ids.ShortID][]byte, error)
 
 	// Request synchronously sends request to the selected nodeID
 	// Returns response bytes
@@ -33,6 +41,9 @@ type Client interface {
 
 	// Gossip sends given gossip message to peers
 	Gossip(gossip []byte) error
+
+	// TrackInvalidResponse marks the last response from [nodeID] as invalid
+	TrackInvalidResponse(nodeID ids.ShortID)
 }
 
 // client implements Client interface
@@ -41,19 +52,41 @@ type client struct {
 	network Network
 }
 
-// RequestAny synchronously sends request to the first connected peer that matches the specified minVersion in
-// random order and blocks until it receives a response or the request could not be sent or times out.
-// Returns the response bytes from the peer.
-func (c *client) RequestAny(minVersion version.Application, request []byte) ([]byte, error) {
+// RequestAny synchronously sends request to a connected peer that matches the specified minVersion
+// and blocks until it receives a response or the request could not be sent or times out.
+// Returns the response bytes and the nodeID of the peer.
+func (c *client) RequestAny(minVersion version.Application, request []byte) ([]byte, ids.ShortID, error) {
 	waitingHandler := newWaitingResponseHandler()
-	if err := c.network.RequestAny(minVersion, request, waitingHandler); err != nil {
-		return nil, err
+	nodeID, err := c.network.RequestAny(minVersion, request, waitingHandler)
+	if err != nil {
+		return nil, nodeID, err
 	}
 	response := <-waitingHandler.responseChan
 	if waitingHandler.failed {
-		return nil, errRequestFailed
+		return nil, nodeID, errRequestFailed
 	}
-	return response, nil
+	return response, nodeID, nil
+}
+
+// RequestFromN synchronously sends request to up to [n] distinct connected peers that match
+// the specified minVersion and blocks until each of them responded, failed or timed out.
+// Returns the responses of the peers keyed by their nodeID, nil if the request to the peer failed.
+func (c *client) RequestFromN(minVersion version.Application, n int, request []byte) (map[ids.ShortID][]byte, error) {
+	collectingHandler := newCollectingResponseHandler(n)
+	nodeIDs, err := c.network.RequestFromN(minVersion, n, request, collectingHandler)
+	if len(nodeIDs) == 0 {
+		return nil, err
+	}
+	responses := make(map[ids.ShortID][]byte, len(nodeIDs))
+	for range nodeIDs {
+		response := <-collectingHandler.responseChan
+		if response.failed {
+			responses[response.nodeID] = nil
+		} else {
+			responses[response.nodeID] = response.response
+		}
+	}
+	return responses, nil
 }
 
 // Request synchronously sends [request] message to specified [nodeID]
@@ -74,6 +107,10 @@ func (c *client) Gossip(gossip []byte) error {
 	return c.network.Gossip(gossip)
 }
 
+func (c *client) TrackInvalidResponse(nodeID ids.ShortID) {
+	c.network.TrackInvalidResponse(nodeID)
+}
+
 // NewClient returns Client for a given network
 func NewClient(network Network) Client {
 	return &client{
@@ -25,6 +25,10 @@ import (
 // Minimum amount of time to handle a request
 const minRequestHandlingDuration = 100 * time.Millisecond
 
+// throttledResponse is sent in response to requests exceeding the inbound quotas.
+// Responses marshalled by the codec are never empty, so the requester can tell them apart.
+var throttledResponse = []byte{}
+
 var (
 	errAcquiringSemaphore                      = errors.New("error acquiring semaphore")
 	_                     Network              = &network{}
@@ -36,11 +40,19 @@ type Network interface {
 	validators.Connector
 	common.AppHandler
 
-	// RequestAny synchronously sends request to the first connected peer that matches the specified minVersion in
-	// random order.
+	// RequestAny sends request to a connected peer that matches the specified minVersion, preferring
+	// peers that respond quickly and reliably.
 	// A peer is considered a match if its version is greater than or equal to the specified minVersion
+	// Returns the nodeID the request was sent to.
 	// Returns an error if the request could not be sent to a peer with the desired [minVersion].
-	RequestAny(minVersion version.Application, message []byte, handler message.ResponseHandler) error
+	RequestAny(minVersion version.Application, message []byte, handler message.ResponseHandler) (ids.ShortID, error)
+
+	// RequestFromN sends request to up to [n] distinct connected peers that match the specified
+	// minVersion, selected uniformly at random regardless of their score, so that callers can
+	// compare independent responses. [handler] is notified of the response or failure of each peer.
+	// Returns the nodeIDs the request was sent to.
+	// Returns an error if the request could not be sent to any peer with the desired [minVersion].
+	RequestFromN(minVersion version.Application, n int, message []byte, handler message.ResponseHandler) ([]ids.ShortID, error)
 
 	// Request sends message to given nodeID, notifying handler when there's a response or timeout
 	Request(nodeID ids.ShortID, message []byte, handler message.ResponseHandler) error
@@ -48,6 +60,10 @@ type Network interface {
 	// Gossip sends given gossip message to peers
 	Gossip(gossip []byte) error
 
+	// TrackInvalidResponse marks the last response from [nodeID] as invalid, so that
+	// RequestAny backs off from [nodeID] as if the request had failed.
+	TrackInvalidResponse(nodeID ids.ShortID)
+
 	// Shutdown stops all peer channel listeners and marks the node to have stopped
 	// n.Start() can be called again but the peers will have to be reconnected
 	// by calling OnPeerConnected for each peer
@@ -66,54 +82,80 @@ type Network interface {
 // network is an implementation of Network that processes message requests for
 // each peer in linear fashion
 type network struct {
-	lock                          sync.RWMutex                        // lock for mutating state of this Network struct
-	self                          ids.ShortID                         // NodeID of this node
-	requestIDGen                  uint32                              // requestID counter used to track outbound requests
-	outstandingResponseHandlerMap map[uint32]message.ResponseHandler  // maps avalanchego requestID => response handler
-	activeRequests                *semaphore.Weighted                 // controls maximum number of active outbound requests
-	appSender                     common.AppSender                    // avalanchego AppSender for sending messages
-	codec                         codec.Manager                       // Codec used for parsing messages
-	requestHandler                message.RequestHandler              // maps request type => handler
-	gossipHandler                 message.GossipHandler               // maps gossip type => handler
-	peers                         map[ids.ShortID]version.Application // maps nodeID => version.Version
+	lock                          sync.RWMutex                       // lock for mutating state of this Network struct
+	self                          ids.ShortID                        // NodeID of this node
+	requestIDGen                  uint32                             // requestID counter used to track outbound requests
+	outstandingResponseHandlerMap map[uint32]message.ResponseHandler // maps avalanchego requestID => response handler
+	activeRequests                *semaphore.Weighted                // controls maximum number of active outbound requests
+	appSender                     common.AppSender                   // avalanchego AppSender for sending messages
+	codec                         codec.Manager                      // Codec used for parsing messages
+	requestHandler                message.RequestHandler             // maps request type => handler
+	gossipHandler                 message.GossipHandler              // maps gossip type => handler
+	peers                         *peerTracker                       // tracks the version and request stats of connected peers
+	throttler                     *InboundThrottler                  // enforces quotas on inbound requests, nil if disabled
 }
 
-func NewNetwork(appSender common.AppSender, codec codec.Manager, self ids.ShortID, maxActiveRequests int64) Network {
+// NewNetwork returns a Network sending at most [maxActiveRequests] concurrent outbound requests.
+// Inbound requests are subject to the quotas of [throttler] unless it is nil.
+func NewNetwork(appSender common.AppSender, codec codec.Manager, self ids.ShortID, maxActiveRequests int64, throttler *InboundThrottler) Network {
 	return &network{
 		appSender:                     appSender,
 		codec:                         codec,
 		self:                          self,
 		outstandingResponseHandlerMap: make(map[uint32]message.ResponseHandler),
-		peers:                         make(map[ids.ShortID]version.Application),
+		peers:                         newPeerTracker(),
 		activeRequests:                semaphore.NewWeighted(maxActiveRequests),
+		throttler:                     throttler,
 	}
 }
 
-// RequestAny sends given request to the first connected peer that matches the specified minVersion
+// RequestAny sends given request to a connected peer that matches the specified minVersion
 // A peer is considered a match if its version is greater than or equal to the specified minVersion
 // If minVersion is nil, then the request will be sent to any peer regardless of their version
+// The peer is selected by [peerTracker] based on the response time, failure rate and bandwidth
+// of previous requests, backing off from peers that failed recently.
+// Returns the nodeID the request was sent to.
 // Returns a non-nil error if we were not able to send a request to a peer with >= [minVersion]
 // or we fail to send a request to the selected peer.
-func (n *network) RequestAny(minVersion version.Application, request []byte, handler message.ResponseHandler) error {
+func (n *network) RequestAny(minVersion version.Application, request []byte, handler message.ResponseHandler) (ids.ShortID, error) {
 	// Take a slot from total [activeRequests] and block until a slot becomes available.
 	if err := n.activeRequests.Acquire(context.Background(), 1); err != nil {
-		return errAcquiringSemaphore
+		return ids.ShortEmpty, errAcquiringSemaphore
 	}
 
 	n.lock.Lock()
 	defer n.lock.Unlock()
 
-	for nodeID, nodeVersion := range n.peers {
-		// map iteration is sufficiently random to avoid hitting same peer so here
-		// we get a random peerID key that we compare minimum version that
-		// we have
-		if minVersion == nil || nodeVersion.Compare(minVersion) >= 0 {
-			return n.request(nodeID, request, handler)
-		}
+	nodeID, found := n.peers.selectPeer(minVersion)
+	if !found {
+		n.activeRequests.Release(1)
+		return ids.ShortEmpty, fmt.Errorf("no peers found matching version %s out of %d peers", minVersion, len(n.peers.peers))
 	}
+	return nodeID, n.request(nodeID, request, handler)
+}
 
-	n.activeRequests.Release(1)
-	return fmt.Errorf("no peers found matching version %s out of %d peers", minVersion, len(n.peers))
+// RequestFromN sends given request to up to [count] distinct connected peers that match the
+// specified minVersion, notifying [handler] on the response or failure of each of them.
+// If minVersion is nil, then the request will be sent to peers regardless of their version.
+// The peers are selected by [peerTracker] uniformly at random, preferring peers that are not
+// backing off from a failure.
+// Returns the nodeIDs the request was sent to, which may be fewer than [count] if fewer peers
+// are connected or if sending the request to one of the peers fails.
+func (n *network) RequestFromN(minVersion version.Application, count int, request []byte, handler message.ResponseHandler) ([]ids.ShortID, error) {
+	n.lock.Lock()
+	nodeIDs := n.peers.selectPeers(minVersion, count)
+	numPeers := len(n.peers.peers)
+	n.lock.Unlock()
+
+	if len(nodeIDs) == 0 {
+		return nil, fmt.Errorf("no peers found matching version %s out of %d peers", minVersion, numPeers)
+	}
+	for i, nodeID := range nodeIDs {
+		if err := n.Request(nodeID, request, handler); err != nil {
+			return nodeIDs[:i], err
+		}
+	}
+	return nodeIDs, nil
 }
 
 // Request sends request message bytes to specified nodeID, notifying the responseHandler on response or failure
@@ -147,6 +189,7 @@ func (n *network) request(nodeID ids.ShortID, request []byte, responseHandler me
 	n.requestIDGen++
 
 	n.outstandingResponseHandlerMap[requestID] = responseHandler
+	n.peers.sent(nodeID, requestID)
 
 	nodeIDs := ids.NewShortSet(1)
 	nodeIDs.Add(nodeID)
@@ -158,6 +201,7 @@ func (n *network) request(nodeID ids.ShortID, request []byte, responseHandler me
 	if err := n.appSender.SendAppRequest(nodeIDs, requestID, request); err != nil {
 		n.activeRequests.Release(1)
 		delete(n.outstandingResponseHandlerMap, requestID)
+		delete(n.peers.requests, requestID)
 		return err
 	}
 
@@ -170,12 +214,25 @@ func (n *network) request(nodeID ids.ShortID, request []byte, responseHandler me
 // returns error if the requestHandler returns an error
 // sends a response back to the sender if length of response returned by the handler is >0
 // expects the deadline to not have been passed
+// responds with an empty response if the request exceeds the quotas of [throttler]
 func (n *network) AppRequest(nodeID ids.ShortID, requestID uint32, deadline time.Time, request []byte) error {
 	n.lock.RLock()
 	defer n.lock.RUnlock()
 
 	log.Debug("received AppRequest from node", "nodeID", nodeID, "requestID", requestID, "requestLen", len(request))
 
+	if n.throttler != nil {
+		if !n.throttler.acquire(nodeID) {
+			log.Debug("rejecting AppRequest exceeding inbound quota", "nodeID", nodeID, "requestID", requestID, "requestLen", len(request))
+			// Respond rather than drop the request so the peer does not wait for it to time out.
+			return n.appSender.SendAppResponse(nodeID, requestID, throttledResponse) // Propagate fatal error
+		}
+		start := time.Now()
+		defer func() {
+			n.throttler.release(nodeID, time.Since(start))
+		}()
+	}
+
 	var req message.Request
 	if _, err := n.codec.Unmarshal(request, &req); err != nil {
 		log.Debug("failed to unmarshal app request", "nodeID", nodeID, "requestID", requestID, "requestLen", len(request), "err", err)
@@ -216,6 +273,7 @@ func (n *network) AppRequest(nodeID ids.ShortID, requestID uint32, deadline time
 // Error returned by this function is expected to be treated as fatal by the engine
 // If [requestID] is not known, this function will emit a log and return a nil error.
 // If the response handler returns an error it is propagated as a fatal error.
+// An empty response means the peer rejected the request and is handled as a failure.
 func (n *network) AppResponse(nodeID ids.ShortID, requestID uint32, response []byte) error {
 	n.lock.Lock()
 	defer n.lock.Unlock()
@@ -228,6 +286,12 @@ func (n *network) AppResponse(nodeID ids.ShortID, requestID uint32, response []b
 		log.Error("received response to unknown request", "nodeID", nodeID, "requestID", requestID, "responseLen", len(response))
 		return nil
 	}
+	if len(response) == 0 {
+		log.Debug("peer rejected request", "nodeID", nodeID, "requestID", requestID)
+		n.peers.failed(requestID)
+		return handler.OnFailure(nodeID, requestID)
+	}
+	n.peers.responded(requestID, len(response))
 
 	return handler.OnResponse(nodeID, requestID, response)
 }
@@ -249,6 +313,7 @@ func (n *network) AppRequestFailed(nodeID ids.ShortID, requestID uint32) error {
 		log.Error("received request failed to unknown request", "nodeID", nodeID, "requestID", requestID)
 		return nil
 	}
+	n.peers.failed(requestID)
 
 	return handler.OnFailure(nodeID, requestID)
 }
@@ -267,6 +332,15 @@ func (n *network) getRequestHandler(requestID uint32) (message.ResponseHandler,
 	return handler, true
 }
 
+// TrackInvalidResponse penalizes [nodeID] for sending a response that failed verification.
+func (n *network) TrackInvalidResponse(nodeID ids.ShortID) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	log.Debug("received invalid response from peer", "nodeID", nodeID)
+	n.peers.invalidResponse(nodeID)
+}
+
 // Gossip sends given gossip message to peers
 func (n *network) Gossip(gossip []byte) error {
 	return n.appSender.SendAppGossip(gossip)
@@ -298,12 +372,12 @@ func (n *network) Connected(nodeID ids.ShortID, nodeVersion version.Application)
 		return nil
 	}
 
-	if storedVersion, exists := n.peers[nodeID]; exists {
+	if storedPeer, exists := n.peers.peers[nodeID]; exists {
 		// Peer is already connected, update the version if it has changed.
 		// Log a warning message since the consensus engine should never call Connected on a peer
 		// that we have already marked as Connected.
-		if nodeVersion.Compare(storedVersion) != 0 {
-			n.peers[nodeID] = nodeVersion
+		if storedVersion := storedPeer.version; nodeVersion.Compare(storedVersion) != 0 {
+			n.peers.connected(nodeID, nodeVersion)
 			log.Warn("received Connected message for already connected peer, updating node version", "nodeID", nodeID, "storedVersion", storedVersion, "nodeVersion", nodeVersion)
 		} else {
 			log.Warn("ignoring peer connected event for already connected peer with identical version", "nodeID", nodeID)
@@ -311,7 +385,7 @@ func (n *network) Connected(nodeID ids.ShortID, nodeVersion version.Application)
 		return nil
 	}
 
-	n.peers[nodeID] = nodeVersion
+	n.peers.connected(nodeID, nodeVersion)
 	return nil
 }
 
@@ -322,13 +396,16 @@ func (n *network) Disconnected(nodeID ids.ShortID) error {
 	defer n.lock.Unlock()
 
 	// if this peer already exists, log a warning and ignore the request
-	if _, exists := n.peers[nodeID]; !exists {
+	if _, exists := n.peers.peers[nodeID]; !exists {
 		// we're not connected to this peer, nothing to do here
 		log.Warn("received peer disconnect request to unconnected peer", "nodeID", nodeID)
 		return nil
 	}
 
-	delete(n.peers, nodeID)
+	n.peers.disconnected(nodeID)
+	if n.throttler != nil {
+		n.throttler.disconnected(nodeID)
+	}
 	return nil
 }
 
@@ -338,7 +415,7 @@ func (n *network) Shutdown() {
 	defer n.lock.Unlock()
 
 	// reset peers map
-	n.peers = make(map[ids.ShortID]version.Application)
+	n.peers = newPeerTracker()
 }
 
 func (n *network) SetGossipHandler(handler message.GossipHandler) {
@@ -359,5 +436,5 @@ func (n *network) Size() uint32 {
 	n.lock.RLock()
 	defer n.lock.RUnlock()
 
-	return uint32(len(n.peers))
+	return uint32(len(n.peers.peers))
 }
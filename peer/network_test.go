@@ -42,7 +42,7 @@ var (
 
 func TestNetworkDoesNotConnectToItself(t *testing.T) {
 	selfNodeID := ids.GenerateTestShortID()
-	n := NewNetwork(nil, nil, selfNodeID, 1)
+	n := NewNetwork(nil, nil, selfNodeID, 1, nil)
 	assert.NoError(t, n.Connected(selfNodeID, version.NewDefaultApplication("avalanchego", 1, 0, 0)))
 	assert.EqualValues(t, 0, n.Size())
 }
@@ -77,7 +77,7 @@ func TestRequestAnyRequestsRoutingAndResponse(t *testing.T) {
 	}
 
 	codecManager := buildCodec(t, HelloRequest{}, HelloResponse{})
-	net = NewNetwork(sender, codecManager, ids.ShortEmpty, 16)
+	net = NewNetwork(sender, codecManager, ids.ShortEmpty, 16, nil)
 	net.SetRequestHandler(&HelloGreetingRequestHandler{codec: codecManager})
 	client := NewClient(net)
 	nodeID := ids.GenerateTestShortID()
@@ -99,7 +99,7 @@ func TestRequestAnyRequestsRoutingAndResponse(t *testing.T) {
 			defer wg.Done()
 			requestBytes, err := message.RequestToBytes(codecManager, requestMessage)
 			assert.NoError(t, err)
-			responseBytes, err := client.RequestAny(defaultPeerVersion, requestBytes)
+			responseBytes, _, err := client.RequestAny(defaultPeerVersion, requestBytes)
 			assert.NoError(t, err)
 			assert.NotNil(t, responseBytes)
 
@@ -151,7 +151,7 @@ func TestRequestRequestsRoutingAndResponse(t *testing.T) {
 	}
 
 	codecManager := buildCodec(t, HelloRequest{}, HelloResponse{})
-	net = NewNetwork(sender, codecManager, ids.ShortEmpty, 16)
+	net = NewNetwork(sender, codecManager, ids.ShortEmpty, 16, nil)
 	net.SetRequestHandler(&HelloGreetingRequestHandler{codec: codecManager})
 	client := NewClient(net)
 
@@ -210,6 +210,86 @@ func TestRequestRequestsRoutingAndResponse(t *testing.T) {
 	assert.Contains(t, err.Error(), "cannot send request to empty nodeID")
 }
 
+func TestRequestFromNRoutingAndResponse(t *testing.T) {
+	var net Network
+	failingNode := ids.GenerateTestShortID()
+	var lock sync.Mutex
+	contactedNodes := make(map[ids.ShortID]int)
+	sender := testAppSender{
+		sendAppRequestFn: func(nodes ids.ShortSet, requestID uint32, requestBytes []byte) error {
+			assert.Len(t, nodes, 1, "request nodes should contain exactly one node")
+			nodeID, _ := nodes.Pop()
+			lock.Lock()
+			contactedNodes[nodeID]++
+			lock.Unlock()
+			go func() {
+				if nodeID == failingNode {
+					assert.NoError(t, net.AppRequestFailed(nodeID, requestID))
+					return
+				}
+				assert.NoError(t, net.AppRequest(nodeID, requestID, time.Now().Add(5*time.Second), requestBytes))
+			}()
+			return nil
+		},
+		sendAppResponseFn: func(nodeID ids.ShortID, requestID uint32, responseBytes []byte) error {
+			go func() {
+				assert.NoError(t, net.AppResponse(nodeID, requestID, responseBytes))
+			}()
+			return nil
+		},
+	}
+
+	codecManager := buildCodec(t, HelloRequest{}, HelloResponse{})
+	net = NewNetwork(sender, codecManager, ids.ShortEmpty, 16, nil)
+	net.SetRequestHandler(&HelloGreetingRequestHandler{codec: codecManager})
+	client := NewClient(net)
+	defer net.Shutdown()
+
+	requestBytes, err := message.RequestToBytes(codecManager, HelloRequest{Message: "this is a request"})
+	assert.NoError(t, err)
+	_, err = client.RequestFromN(defaultPeerVersion, 3, requestBytes)
+	assert.Error(t, err, "request should fail without peers")
+
+	nodes := []ids.ShortID{
+		ids.GenerateTestShortID(),
+		ids.GenerateTestShortID(),
+		ids.GenerateTestShortID(),
+		failingNode,
+	}
+	for _, nodeID := range nodes {
+		assert.NoError(t, net.Connected(nodeID, defaultPeerVersion))
+	}
+
+	// every peer is contacted once and the failed request has no response
+	responses, err := client.RequestFromN(defaultPeerVersion, 10, requestBytes)
+	assert.NoError(t, err)
+	assert.Len(t, responses, len(nodes))
+	for _, nodeID := range nodes {
+		assert.Equal(t, 1, contactedNodes[nodeID])
+		if nodeID == failingNode {
+			assert.Nil(t, responses[nodeID])
+			continue
+		}
+		var response HelloResponse
+		if _, err := codecManager.Unmarshal(responses[nodeID], &response); err != nil {
+			t.Fatal("unexpected error during unmarshal", err)
+		}
+		assert.Equal(t, "Hi", response.Response)
+	}
+
+	// the request is sent to at most [n] peers
+	responses, err = client.RequestFromN(defaultPeerVersion, 2, requestBytes)
+	assert.NoError(t, err)
+	assert.Len(t, responses, 2)
+	lock.Lock()
+	numRequests := 0
+	for _, count := range contactedNodes {
+		numRequests += count
+	}
+	lock.Unlock()
+	assert.Equal(t, len(nodes)+2, numRequests)
+}
+
 func TestRequestMinVersion(t *testing.T) {
 	callNum := uint32(0)
 	nodeID := ids.GenerateTestShortID()
@@ -237,7 +317,7 @@ func TestRequestMinVersion(t *testing.T) {
 	}
 
 	// passing nil as codec works because the net.AppRequest is never called
-	net = NewNetwork(sender, codecManager, ids.ShortEmpty, 1)
+	net = NewNetwork(sender, codecManager, ids.ShortEmpty, 1, nil)
 	client := NewClient(net)
 	requestMessage := TestMessage{Message: "this is a request"}
 	requestBytes, err := message.RequestToBytes(codecManager, requestMessage)
@@ -245,13 +325,14 @@ func TestRequestMinVersion(t *testing.T) {
 	assert.NoError(t, net.Connected(nodeID, version.NewDefaultApplication("corethtest", 1, 7, 1)))
 
 	// ensure version does not match
-	responseBytes, err := client.RequestAny(version.NewDefaultApplication("corethtest", 2, 0, 0), requestBytes)
+	responseBytes, _, err := client.RequestAny(version.NewDefaultApplication("corethtest", 2, 0, 0), requestBytes)
 	assert.Equal(t, err.Error(), "no peers found matching version corethtest/2.0.0 out of 1 peers")
 	assert.Nil(t, responseBytes)
 
 	// ensure version matches and the request goes through
-	responseBytes, err = client.RequestAny(version.NewDefaultApplication("corethtest", 1, 0, 0), requestBytes)
+	responseBytes, respondingNodeID, err := client.RequestAny(version.NewDefaultApplication("corethtest", 1, 0, 0), requestBytes)
 	assert.NoError(t, err)
+	assert.Equal(t, nodeID, respondingNodeID)
 
 	var response TestMessage
 	if _, err = codecManager.Unmarshal(responseBytes, &response); err != nil {
@@ -281,7 +362,7 @@ func TestOnRequestHonoursDeadline(t *testing.T) {
 	requestHandler := &testRequestHandler{
 		processingDuration: 500 * time.Millisecond,
 	}
-	net = NewNetwork(sender, codecManager, ids.ShortEmpty, 1)
+	net = NewNetwork(sender, codecManager, ids.ShortEmpty, 1, nil)
 	net.SetRequestHandler(requestHandler)
 	nodeID := ids.GenerateTestShortID()
 
@@ -299,6 +380,67 @@ func TestOnRequestHonoursDeadline(t *testing.T) {
 	assert.EqualValues(t, requestHandler.calls, 1)
 }
 
+func TestRequestsExceedingGlobalQuotaAreRejected(t *testing.T) {
+	var net Network
+	senderWg := &sync.WaitGroup{}
+	var responses [][]byte
+	sender := testAppSender{
+		sendAppRequestFn: func(nodes ids.ShortSet, requestID uint32, requestBytes []byte) error {
+			nodeID, _ := nodes.Pop()
+			senderWg.Add(1)
+			go func() {
+				defer senderWg.Done()
+				if err := net.AppRequest(nodeID, requestID, time.Now().Add(5*time.Second), requestBytes); err != nil {
+					panic(err)
+				}
+			}()
+			return nil
+		},
+		sendAppResponseFn: func(nodeID ids.ShortID, requestID uint32, responseBytes []byte) error {
+			responses = append(responses, responseBytes)
+			senderWg.Add(1)
+			go func() {
+				defer senderWg.Done()
+				if err := net.AppResponse(nodeID, requestID, responseBytes); err != nil {
+					panic(err)
+				}
+			}()
+			return nil
+		},
+	}
+
+	codecManager := buildCodec(t, TestMessage{})
+	requestBytes, err := marshalStruct(codecManager, TestMessage{Message: "hello there"})
+	assert.NoError(t, err)
+	requestHandler := &testRequestHandler{processingDuration: 150 * time.Millisecond}
+	requestHandler.response, err = marshalStruct(codecManager, TestMessage{Message: "hi there"})
+	assert.NoError(t, err)
+
+	// The clock of the throttler is frozen so the processing time of the first request does not drain.
+	throttler := newTestThrottler(ThrottlerConfig{CPU: 100 * time.Millisecond})
+	net = NewNetwork(sender, codecManager, ids.ShortEmpty, 16, throttler)
+	net.SetRequestHandler(requestHandler)
+	client := NewClient(net)
+
+	nodeIDs := []ids.ShortID{ids.GenerateTestShortID(), ids.GenerateTestShortID()}
+	for _, nodeID := range nodeIDs {
+		assert.NoError(t, net.Connected(nodeID, defaultPeerVersion))
+	}
+
+	response, err := client.Request(nodeIDs[0], requestBytes)
+	assert.NoError(t, err)
+	assert.Equal(t, requestHandler.response, response)
+
+	// The quota is shared by all peers, so a request from a different peer is rejected.
+	_, err = client.Request(nodeIDs[1], requestBytes)
+	assert.ErrorIs(t, err, errRequestFailed)
+	senderWg.Wait()
+
+	assert.EqualValues(t, 1, requestHandler.calls, "rejected request should not be handled")
+	assert.Len(t, responses, 2)
+	assert.Empty(t, responses[1], "rejected request should be answered with an empty response")
+}
+
 func TestGossip(t *testing.T) {
 	codecManager := buildCodec(t, HelloGossip{})
 
@@ -320,7 +462,7 @@ func TestGossip(t *testing.T) {
 	}
 
 	gossipHandler := &testGossipHandler{}
-	clientNetwork = NewNetwork(sender, codecManager, ids.ShortEmpty, 1)
+	clientNetwork = NewNetwork(sender, codecManager, ids.ShortEmpty, 1, nil)
 	clientNetwork.SetGossipHandler(gossipHandler)
 
 	assert.NoError(t, clientNetwork.Connected(nodeID, defaultPeerVersion))
@@ -346,7 +488,7 @@ func TestHandleInvalidMessages(t *testing.T) {
 	requestID := uint32(1)
 	sender := testAppSender{}
 
-	clientNetwork := NewNetwork(sender, codecManager, ids.ShortEmpty, 1)
+	clientNetwork := NewNetwork(sender, codecManager, ids.ShortEmpty, 1, nil)
 	clientNetwork.SetGossipHandler(message.NoopMempoolGossipHandler{})
 	clientNetwork.SetRequestHandler(&testRequestHandler{})
 
@@ -395,7 +537,7 @@ func TestNetworkPropagatesRequestHandlerError(t *testing.T) {
 	requestID := uint32(1)
 	sender := testAppSender{}
 
-	clientNetwork := NewNetwork(sender, codecManager, ids.ShortEmpty, 1)
+	clientNetwork := NewNetwork(sender, codecManager, ids.ShortEmpty, 1, nil)
 	clientNetwork.SetGossipHandler(message.NoopMempoolGossipHandler{})
 	clientNetwork.SetRequestHandler(&testRequestHandler{err: errors.New("fail")}) // Return an error from the request handler
 
@@ -0,0 +1,264 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package peer
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/timer/mockable"
+	"github.com/ava-labs/avalanchego/version"
+)
+
+const (
+	// statsAlpha is the weight of the most recent observation in the
+	// exponentially weighted moving averages of peer stats.
+	statsAlpha = 0.2
+
+	// randomPeerProbability is the probability that RequestAny sends a request
+	// to a random peer instead of the best scoring peer, so that the stats of
+	// every peer are kept up to date.
+	randomPeerProbability = 0.1
+
+	// initialBackoff and maxBackoff bound the time a peer is not selected by
+	// RequestAny after a failed request or an invalid response. The backoff
+	// doubles with each consecutive failure.
+	initialBackoff = 1 * time.Second
+	maxBackoff     = 1 * time.Minute
+
+	// latencyPenalty is the response time that halves the score of a peer,
+	// so that peers with the same bandwidth are ranked by their latency.
+	latencyPenalty = 1 * time.Second
+)
+
+// averager is an exponentially weighted moving average.
+type averager struct {
+	value       float64
+	initialized bool
+}
+
+func (a *averager) observe(value float64) {
+	if !a.initialized {
+		a.value, a.initialized = value, true
+		return
+	}
+	a.value = statsAlpha*value + (1-statsAlpha)*a.value
+}
+
+// peerInfo contains the version of a connected peer and the stats of the
+// requests sent to it.
+type peerInfo struct {
+	version version.Application
+
+	responseTime averager // seconds until a response is received
+	failureRate  averager // 1 for each failed request or invalid response, 0 for each response
+	bandwidth    averager // response bytes per second
+
+	consecutiveFailures int
+	backoffUntil        time.Time
+}
+
+// score returns the expected throughput of requests sent to the peer,
+// discounted by its response time, or -1 if no response has been received
+// from the peer yet.
+func (p *peerInfo) score() float64 {
+	if !p.bandwidth.initialized {
+		return -1
+	}
+	latency := 1 + p.responseTime.value/latencyPenalty.Seconds()
+	return (1 - p.failureRate.value) * p.bandwidth.value / latency
+}
+
+// outstandingRequest is a request sent to [nodeID] at [sentAt].
+type outstandingRequest struct {
+	nodeID ids.ShortID
+	sentAt time.Time
+}
+
+// peerTracker keeps the stats of the requests sent to each connected peer and
+// selects the peer to send a request to, preferring fast and reliable peers.
+// peerTracker is not thread safe, it is guarded by the lock of network.
+type peerTracker struct {
+	peers    map[ids.ShortID]*peerInfo
+	requests map[uint32]outstandingRequest // maps requestID => request sent to a peer
+	clock    mockable.Clock
+	rand     *rand.Rand
+}
+
+func newPeerTracker() *peerTracker {
+	return &peerTracker{
+		peers:    make(map[ids.ShortID]*peerInfo),
+		requests: make(map[uint32]outstandingRequest),
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())), // #nosec G404
+	}
+}
+
+// connected starts tracking [nodeID] at [nodeVersion], keeping its stats if
+// it is already tracked.
+func (p *peerTracker) connected(nodeID ids.ShortID, nodeVersion version.Application) {
+	if info, exists := p.peers[nodeID]; exists {
+		info.version = nodeVersion
+		return
+	}
+	p.peers[nodeID] = &peerInfo{version: nodeVersion}
+}
+
+// disconnected stops tracking [nodeID].
+func (p *peerTracker) disconnected(nodeID ids.ShortID) {
+	delete(p.peers, nodeID)
+}
+
+// selectPeer returns a peer with version greater than or equal to [minVersion],
+// or any peer if [minVersion] is nil. Peers that have not responded yet are
+// selected first, then the best scoring peer is selected, except with probability
+// [randomPeerProbability] where a random peer is selected instead. Peers in
+// backoff are only selected if every matching peer is in backoff.
+// Returns false if no connected peer matches [minVersion].
+func (p *peerTracker) selectPeer(minVersion version.Application) (ids.ShortID, bool) {
+	now := p.clock.Time()
+
+	var (
+		available []ids.ShortID
+		untried   []ids.ShortID
+		best      ids.ShortID
+		bestScore float64
+		hasBest   bool
+		// the peer whose backoff ends first, in case every peer is in backoff
+		backoffPeer  ids.ShortID
+		backoffUntil time.Time
+		found        bool
+	)
+	for nodeID, info := range p.peers {
+		if minVersion != nil && info.version.Compare(minVersion) < 0 {
+			continue
+		}
+		if info.backoffUntil.After(now) {
+			if !found || info.backoffUntil.Before(backoffUntil) {
+				backoffPeer, backoffUntil, found = nodeID, info.backoffUntil, true
+			}
+			continue
+		}
+
+		available = append(available, nodeID)
+		score := info.score()
+		if score < 0 {
+			untried = append(untried, nodeID)
+		} else if !hasBest || score > bestScore {
+			best, bestScore, hasBest = nodeID, score, true
+		}
+	}
+
+	switch {
+	case len(available) == 0:
+		return backoffPeer, found
+	case len(untried) > 0:
+		return untried[p.rand.Intn(len(untried))], true
+	case p.rand.Float64() < randomPeerProbability:
+		return available[p.rand.Intn(len(available))], true
+	default:
+		return best, true
+	}
+}
+
+// selectPeers returns up to [n] distinct peers with version greater than or
+// equal to [minVersion], or any version if [minVersion] is nil, selected
+// uniformly at random regardless of their score so that the responses of the
+// peers are independent of each other. Peers in backoff are only selected if
+// there are fewer than [n] other matching peers.
+func (p *peerTracker) selectPeers(minVersion version.Application, n int) []ids.ShortID {
+	now := p.clock.Time()
+
+	var available, backoff []ids.ShortID
+	for nodeID, info := range p.peers {
+		if minVersion != nil && info.version.Compare(minVersion) < 0 {
+			continue
+		}
+		if info.backoffUntil.After(now) {
+			backoff = append(backoff, nodeID)
+		} else {
+			available = append(available, nodeID)
+		}
+	}
+	p.rand.Shuffle(len(available), func(i, j int) { available[i], available[j] = available[j], available[i] })
+	p.rand.Shuffle(len(backoff), func(i, j int) { backoff[i], backoff[j] = backoff[j], backoff[i] })
+
+	selected := append(available, backoff...)
+	if len(selected) > n {
+		selected = selected[:n]
+	}
+	return selected
+}
+
+// sent records that the request with [requestID] has been sent to [nodeID].
+func (p *peerTracker) sent(nodeID ids.ShortID, requestID uint32) {
+	p.requests[requestID] = outstandingRequest{
+		nodeID: nodeID,
+		sentAt: p.clock.Time(),
+	}
+}
+
+// responded updates the stats of the peer that responded to [requestID] with
+// [responseLen] bytes.
+func (p *peerTracker) responded(requestID uint32, responseLen int) {
+	request, exists := p.requests[requestID]
+	if !exists {
+		return
+	}
+	delete(p.requests, requestID)
+
+	info, exists := p.peers[request.nodeID]
+	if !exists {
+		return
+	}
+	elapsed := p.clock.Time().Sub(request.sentAt).Seconds()
+	if elapsed <= 0 {
+		// avoid infinite bandwidth when the clock does not advance
+		elapsed = time.Millisecond.Seconds()
+	}
+	info.responseTime.observe(elapsed)
+	info.bandwidth.observe(float64(responseLen) / elapsed)
+	info.failureRate.observe(0)
+	info.consecutiveFailures = 0
+	info.backoffUntil = time.Time{}
+}
+
+// failed updates the stats of the peer that failed to respond to [requestID].
+func (p *peerTracker) failed(requestID uint32) {
+	request, exists := p.requests[requestID]
+	if !exists {
+		return
+	}
+	delete(p.requests, requestID)
+	if info, exists := p.peers[request.nodeID]; exists {
+		p.penalize(info)
+	}
+}
+
+// invalidResponse updates the stats of [nodeID] after it sent a response
+// that failed verification, treating it as a response without any data.
+func (p *peerTracker) invalidResponse(nodeID ids.ShortID) {
+	info, exists := p.peers[nodeID]
+	if !exists {
+		return
+	}
+	info.bandwidth.observe(0)
+	p.penalize(info)
+}
+
+// penalize counts a failure against [info] and backs off from the peer for
+// a duration doubling with each consecutive failure.
+func (p *peerTracker) penalize(info *peerInfo) {
+	info.failureRate.observe(1)
+	info.consecutiveFailures++
+
+	backoff := maxBackoff
+	if shift := info.consecutiveFailures - 1; shift < 7 {
+		backoff = initialBackoff << shift
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	info.backoffUntil = p.clock.Time().Add(backoff)
+}
@@ -0,0 +1,168 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package peer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/version"
+	"github.com/stretchr/testify/assert"
+)
+
+// respond sends a request to [nodeID] and tracks a response of [responseLen]
+// bytes received after [elapsed].
+func respond(p *peerTracker, nodeID ids.ShortID, requestID uint32, elapsed time.Duration, responseLen int) {
+	p.sent(nodeID, requestID)
+	p.clock.Set(p.clock.Time().Add(elapsed))
+	p.responded(requestID, responseLen)
+}
+
+func TestPeerTrackerSelectsUntriedPeers(t *testing.T) {
+	p := newPeerTracker()
+	p.clock.Set(time.Unix(1, 0))
+	fast, untried := ids.GenerateTestShortID(), ids.GenerateTestShortID()
+	p.connected(fast, defaultPeerVersion)
+	p.connected(untried, defaultPeerVersion)
+	respond(p, fast, 0, time.Millisecond, 1024)
+
+	for i := 0; i < 10; i++ {
+		nodeID, found := p.selectPeer(defaultPeerVersion)
+		assert.True(t, found)
+		assert.Equal(t, untried, nodeID)
+	}
+}
+
+func TestPeerTrackerPrefersFastPeers(t *testing.T) {
+	p := newPeerTracker()
+	p.clock.Set(time.Unix(1, 0))
+	fast, slow := ids.GenerateTestShortID(), ids.GenerateTestShortID()
+	p.connected(fast, defaultPeerVersion)
+	p.connected(slow, defaultPeerVersion)
+	respond(p, fast, 0, 10*time.Millisecond, 1024)
+	respond(p, slow, 1, time.Second, 1024)
+
+	selected := make(map[ids.ShortID]int)
+	for i := 0; i < 1000; i++ {
+		nodeID, found := p.selectPeer(defaultPeerVersion)
+		assert.True(t, found)
+		selected[nodeID]++
+	}
+	assert.Greater(t, selected[fast], 800)
+	assert.Greater(t, selected[slow], 0, "slow peer should still be selected occasionally")
+}
+
+func TestPeerTrackerPrefersLowLatencyPeers(t *testing.T) {
+	p := newPeerTracker()
+	p.clock.Set(time.Unix(1, 0))
+	near, far := ids.GenerateTestShortID(), ids.GenerateTestShortID()
+	p.connected(near, defaultPeerVersion)
+	p.connected(far, defaultPeerVersion)
+	// both peers have a bandwidth of 100 KiB/s
+	respond(p, near, 0, 100*time.Millisecond, 10*1024)
+	respond(p, far, 1, time.Second, 100*1024)
+	assert.Equal(t, p.peers[near].bandwidth.value, p.peers[far].bandwidth.value)
+
+	selected := make(map[ids.ShortID]int)
+	for i := 0; i < 1000; i++ {
+		nodeID, found := p.selectPeer(defaultPeerVersion)
+		assert.True(t, found)
+		selected[nodeID]++
+	}
+	assert.Greater(t, selected[near], 800)
+}
+
+func TestPeerTrackerBacksOffFailedPeers(t *testing.T) {
+	p := newPeerTracker()
+	p.clock.Set(time.Unix(1, 0))
+	reliable, failing := ids.GenerateTestShortID(), ids.GenerateTestShortID()
+	p.connected(reliable, defaultPeerVersion)
+	p.connected(failing, defaultPeerVersion)
+	respond(p, reliable, 0, time.Second, 1024)
+	respond(p, failing, 1, time.Millisecond, 1024)
+
+	p.sent(failing, 2)
+	p.failed(2)
+	for i := 0; i < 100; i++ {
+		nodeID, _ := p.selectPeer(defaultPeerVersion)
+		assert.Equal(t, reliable, nodeID)
+	}
+
+	// the failing peer is selected again once its backoff expires
+	p.clock.Set(p.clock.Time().Add(initialBackoff))
+	selected := make(map[ids.ShortID]int)
+	for i := 0; i < 100; i++ {
+		nodeID, _ := p.selectPeer(defaultPeerVersion)
+		selected[nodeID]++
+	}
+	assert.Greater(t, selected[failing], 0)
+
+	// consecutive failures double the backoff
+	p.invalidResponse(failing)
+	p.invalidResponse(failing)
+	assert.Equal(t, p.clock.Time().Add(4*initialBackoff), p.peers[failing].backoffUntil)
+
+	// a peer in backoff is selected if no other peer is available
+	p.sent(reliable, 3)
+	p.failed(3)
+	nodeID, found := p.selectPeer(defaultPeerVersion)
+	assert.True(t, found)
+	assert.Equal(t, reliable, nodeID, "peer with the earliest backoff expiry should be selected")
+}
+
+func TestPeerTrackerMinVersion(t *testing.T) {
+	p := newPeerTracker()
+	oldPeer, newPeer := ids.GenerateTestShortID(), ids.GenerateTestShortID()
+	p.connected(oldPeer, version.NewDefaultApplication("corethtest", 1, 0, 0))
+	p.connected(newPeer, version.NewDefaultApplication("corethtest", 2, 0, 0))
+
+	for i := 0; i < 10; i++ {
+		nodeID, found := p.selectPeer(version.NewDefaultApplication("corethtest", 2, 0, 0))
+		assert.True(t, found)
+		assert.Equal(t, newPeer, nodeID)
+	}
+	_, found := p.selectPeer(version.NewDefaultApplication("corethtest", 3, 0, 0))
+	assert.False(t, found)
+
+	p.disconnected(newPeer)
+	_, found = p.selectPeer(version.NewDefaultApplication("corethtest", 2, 0, 0))
+	assert.False(t, found)
+}
+
+func TestPeerTrackerSelectPeers(t *testing.T) {
+	p := newPeerTracker()
+	p.clock.Set(time.Unix(1, 0))
+	fast, slow, failing := ids.GenerateTestShortID(), ids.GenerateTestShortID(), ids.GenerateTestShortID()
+	oldPeer := ids.GenerateTestShortID()
+	p.connected(fast, defaultPeerVersion)
+	p.connected(slow, defaultPeerVersion)
+	p.connected(failing, defaultPeerVersion)
+	p.connected(oldPeer, version.NewDefaultApplication("corethtest", 0, 1, 0))
+	respond(p, fast, 0, time.Millisecond, 1024)
+	respond(p, slow, 1, time.Second, 1024)
+	p.sent(failing, 2)
+	p.failed(2)
+
+	// the peers are distinct and selected regardless of their score
+	selected := make(map[ids.ShortID]int)
+	for i := 0; i < 100; i++ {
+		nodeIDs := p.selectPeers(defaultPeerVersion, 2)
+		assert.Len(t, nodeIDs, 2)
+		assert.NotEqual(t, nodeIDs[0], nodeIDs[1])
+		for _, nodeID := range nodeIDs {
+			selected[nodeID]++
+		}
+	}
+	assert.Equal(t, 100, selected[fast])
+	assert.Equal(t, 100, selected[slow])
+	assert.Zero(t, selected[failing], "peer in backoff should not be selected while other peers are available")
+	assert.Zero(t, selected[oldPeer])
+
+	// peers in backoff are selected last
+	nodeIDs := p.selectPeers(defaultPeerVersion, 10)
+	assert.Len(t, nodeIDs, 3)
+	assert.Equal(t, failing, nodeIDs[2])
+	assert.Empty(t, p.selectPeers(version.NewDefaultApplication("corethtest", 3, 0, 0), 10))
+}
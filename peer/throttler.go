@@ -0,0 +1,154 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package peer
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/timer/mockable"
+
+	"github.com/ava-labs/coreth/statesync/handlers/stats"
+)
+
+// ThrottlerConfig contains the quotas enforced on inbound requests.
+// A zero value disables the corresponding quota.
+type ThrottlerConfig struct {
+	RequestsPerPeer     float64       // Sustained number of requests per second accepted from a single peer
+	RequestBurstPerPeer int           // Number of requests accepted from a single peer in a burst above [RequestsPerPeer]
+	CPUPerPeer          time.Duration // Processing time per second spent on the requests of a single peer
+	CPU                 time.Duration // Processing time per second spent on the requests of all peers, at most one second
+}
+
+// cpuTracker tracks the processing time spent on requests as a leaky bucket
+// that drains at [budget] per second.
+type cpuTracker struct {
+	used       time.Duration
+	lastUpdate time.Time
+}
+
+// drain removes the processing time that has leaked out of the bucket since
+// the last update at a rate of [budget] per second.
+func (c *cpuTracker) drain(now time.Time, budget time.Duration) {
+	if !c.lastUpdate.IsZero() {
+		elapsed := now.Sub(c.lastUpdate)
+		c.used -= time.Duration(float64(budget) * elapsed.Seconds())
+		if c.used < 0 {
+			c.used = 0
+		}
+	}
+	c.lastUpdate = now
+}
+
+// peerQuota tracks the inbound requests of a single peer.
+type peerQuota struct {
+	requests *rate.Limiter
+	cpu      cpuTracker
+}
+
+// InboundThrottler enforces per-peer and global quotas on the requests handled by
+// network.AppRequest. Requests exceeding a quota are answered with an empty response,
+// shedding load from peers whose requests are frequent or expensive to process.
+// The processing time of each request is measured after it is handled, so a peer
+// exceeding its processing time quota is throttled until its usage drains.
+// Requests are handled one at a time, so the global quota bounds the share of
+// the engine's time spent serving peers.
+type InboundThrottler struct {
+	lock   sync.Mutex
+	config ThrottlerConfig
+	stats  stats.HandlerStats
+	clock  mockable.Clock
+
+	peers map[ids.ShortID]*peerQuota
+	cpu   cpuTracker
+}
+
+func NewInboundThrottler(config ThrottlerConfig, stats stats.HandlerStats) *InboundThrottler {
+	return &InboundThrottler{
+		config: config,
+		stats:  stats,
+		peers:  make(map[ids.ShortID]*peerQuota),
+	}
+}
+
+// acquire returns true if a request from [nodeID] should be handled, in which
+// case the caller must call release once the request has been handled.
+func (t *InboundThrottler) acquire(nodeID ids.ShortID) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.clock.Time()
+	if t.config.CPU > 0 {
+		t.cpu.drain(now, t.config.CPU)
+		if t.cpu.used >= t.config.CPU {
+			t.stats.IncCPULimitedRequest()
+			return false
+		}
+	}
+
+	quota := t.getPeerQuota(nodeID)
+	if t.config.CPUPerPeer > 0 {
+		quota.cpu.drain(now, t.config.CPUPerPeer)
+		if quota.cpu.used >= t.config.CPUPerPeer {
+			t.stats.IncCPULimitedRequest()
+			return false
+		}
+	}
+	if quota.requests != nil && !quota.requests.AllowN(now, 1) {
+		t.stats.IncRateLimitedRequest()
+		return false
+	}
+
+	return true
+}
+
+// release marks a request from [nodeID] acquired by acquire as handled,
+// charging [processingTime] to the processing time quotas.
+func (t *InboundThrottler) release(nodeID ids.ShortID, processingTime time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.stats.UpdateRequestProcessingTime(processingTime)
+
+	now := t.clock.Time()
+	if t.config.CPU > 0 {
+		t.cpu.drain(now, t.config.CPU)
+		t.cpu.used += processingTime
+	}
+	if t.config.CPUPerPeer > 0 {
+		quota := t.getPeerQuota(nodeID)
+		quota.cpu.drain(now, t.config.CPUPerPeer)
+		quota.cpu.used += processingTime
+	}
+}
+
+// disconnected removes the quota of [nodeID].
+func (t *InboundThrottler) disconnected(nodeID ids.ShortID) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.peers, nodeID)
+}
+
+// getPeerQuota returns the quota of [nodeID], creating it if it does not exist.
+// Assumes [t.lock] is held.
+func (t *InboundThrottler) getPeerQuota(nodeID ids.ShortID) *peerQuota {
+	quota, exists := t.peers[nodeID]
+	if exists {
+		return quota
+	}
+	quota = &peerQuota{}
+	if t.config.RequestsPerPeer > 0 {
+		burst := t.config.RequestBurstPerPeer
+		if burst < 1 {
+			burst = 1
+		}
+		quota.requests = rate.NewLimiter(rate.Limit(t.config.RequestsPerPeer), burst)
+	}
+	t.peers[nodeID] = quota
+	return quota
+}
@@ -0,0 +1,82 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package peer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ava-labs/coreth/statesync/handlers/stats"
+)
+
+func newTestThrottler(config ThrottlerConfig) *InboundThrottler {
+	t := NewInboundThrottler(config, stats.NewNoopHandlerStats())
+	t.clock.Set(time.Unix(1, 0))
+	return t
+}
+
+func TestThrottlerRequestsPerPeer(t *testing.T) {
+	throttler := newTestThrottler(ThrottlerConfig{RequestsPerPeer: 1, RequestBurstPerPeer: 2})
+	nodeID, otherNodeID := ids.GenerateTestShortID(), ids.GenerateTestShortID()
+
+	for i := 0; i < 2; i++ {
+		assert.True(t, throttler.acquire(nodeID))
+		throttler.release(nodeID, time.Millisecond)
+	}
+	assert.False(t, throttler.acquire(nodeID), "requests above the burst should be dropped")
+	assert.True(t, throttler.acquire(otherNodeID), "quota should be per peer")
+	throttler.release(otherNodeID, time.Millisecond)
+
+	throttler.clock.Set(throttler.clock.Time().Add(time.Second))
+	assert.True(t, throttler.acquire(nodeID))
+	throttler.release(nodeID, time.Millisecond)
+}
+
+func TestThrottlerCPUPerPeer(t *testing.T) {
+	throttler := newTestThrottler(ThrottlerConfig{CPUPerPeer: 100 * time.Millisecond})
+	nodeID, otherNodeID := ids.GenerateTestShortID(), ids.GenerateTestShortID()
+
+	assert.True(t, throttler.acquire(nodeID))
+	throttler.release(nodeID, 200*time.Millisecond)
+	assert.False(t, throttler.acquire(nodeID), "peer exceeding its processing time should be throttled")
+	assert.True(t, throttler.acquire(otherNodeID))
+	throttler.release(otherNodeID, time.Millisecond)
+
+	// 200ms of processing time drains in 2s at 100ms per second
+	throttler.clock.Set(throttler.clock.Time().Add(time.Second))
+	assert.False(t, throttler.acquire(nodeID))
+	throttler.clock.Set(throttler.clock.Time().Add(time.Second))
+	assert.True(t, throttler.acquire(nodeID))
+	throttler.release(nodeID, time.Millisecond)
+}
+
+func TestThrottlerGlobalQuotas(t *testing.T) {
+	throttler := newTestThrottler(ThrottlerConfig{CPU: time.Second})
+	nodeIDs := []ids.ShortID{ids.GenerateTestShortID(), ids.GenerateTestShortID(), ids.GenerateTestShortID()}
+
+	assert.True(t, throttler.acquire(nodeIDs[0]))
+	throttler.release(nodeIDs[0], 500*time.Millisecond)
+	assert.True(t, throttler.acquire(nodeIDs[1]))
+	throttler.release(nodeIDs[1], 500*time.Millisecond)
+	assert.False(t, throttler.acquire(nodeIDs[2]), "processing time of all peers should be bounded")
+
+	throttler.clock.Set(throttler.clock.Time().Add(100 * time.Millisecond))
+	assert.True(t, throttler.acquire(nodeIDs[2]))
+	throttler.release(nodeIDs[2], time.Millisecond)
+}
+
+func TestThrottlerDisabled(t *testing.T) {
+	throttler := newTestThrottler(ThrottlerConfig{})
+	nodeID := ids.GenerateTestShortID()
+	for i := 0; i < 1_000; i++ {
+		assert.True(t, throttler.acquire(nodeID))
+	}
+	for i := 0; i < 1_000; i++ {
+		throttler.release(nodeID, time.Second)
+	}
+	assert.True(t, throttler.acquire(nodeID))
+}
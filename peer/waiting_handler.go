@@ -8,7 +8,10 @@ import (
 	"github.com/ava-labs/coreth/plugin/evm/message"
 )
 
-var _ message.ResponseHandler = &waitingResponseHandler{}
+var (
+	_ message.ResponseHandler = &waitingResponseHandler{}
+	_ message.ResponseHandler = &collectingResponseHandler{}
+)
 
 // waitingResponseHandler implements the ResponseHandler interface
 // Internally used to wait for response after making a request synchronously
@@ -37,3 +40,35 @@ func (w *waitingResponseHandler) OnFailure(ids.ShortID, uint32) error {
 func newWaitingResponseHandler() *waitingResponseHandler {
 	return &waitingResponseHandler{responseChan: make(chan []byte)}
 }
+
+// peerResponse is the response of [nodeID] received by collectingResponseHandler
+type peerResponse struct {
+	nodeID   ids.ShortID
+	response []byte
+	failed   bool
+}
+
+// collectingResponseHandler implements the ResponseHandler interface
+// Internally used to wait for the responses of a request sent to several peers
+// responseChan receives one peerResponse for each peer, whether its request failed or not
+type collectingResponseHandler struct {
+	responseChan chan peerResponse // buffered channel with a peerResponse per peer
+}
+
+// OnResponse passes the response bytes of [nodeID] to the responseChan
+func (c *collectingResponseHandler) OnResponse(nodeID ids.ShortID, _ uint32, response []byte) error {
+	c.responseChan <- peerResponse{nodeID: nodeID, response: response}
+	return nil
+}
+
+// OnFailure passes the failure of [nodeID] to the responseChan
+func (c *collectingResponseHandler) OnFailure(nodeID ids.ShortID, _ uint32) error {
+	c.responseChan <- peerResponse{nodeID: nodeID, failed: true}
+	return nil
+}
+
+// newCollectingResponseHandler returns new instance of the collectingResponseHandler
+// expecting up to [numPeers] responses
+func newCollectingResponseHandler(numPeers int) *collectingResponseHandler {
+	return &collectingResponseHandler{responseChan: make(chan peerResponse, numPeers)}
+}
@@ -9,19 +9,22 @@ import (
 
 	"github.com/ava-labs/avalanchego/api"
 	"github.com/ava-labs/avalanchego/utils/profiler"
+	"github.com/ava-labs/coreth/core/state/pruner"
 	"github.com/ethereum/go-ethereum/log"
 )
 
 // Admin is the API service for admin API calls
 type Admin struct {
-	vm       *VM
-	profiler profiler.Profiler
+	vm        *VM
+	profiler  profiler.Profiler
+	inspector *databaseInspector
 }
 
 func NewAdminService(vm *VM, performanceDir string) *Admin {
 	return &Admin{
-		vm:       vm,
-		profiler: profiler.New(performanceDir),
+		vm:        vm,
+		profiler:  profiler.New(performanceDir),
+		inspector: &databaseInspector{vm: vm},
 	}
 }
 
@@ -84,3 +87,63 @@ func (p *Admin) GetVMConfig(r *http.Request, args *struct{}, reply *ConfigReply)
 	reply.Config = &p.vm.config
 	return nil
 }
+
+// StartPruning starts an online pruning of the stale trie nodes at the next
+// accepted block
+func (p *Admin) StartPruning(r *http.Request, args *struct{}, reply *api.SuccessResponse) error {
+	log.Info("Admin: StartPruning called")
+
+	err := p.vm.chain.BlockChain().StartOnlinePruning()
+	reply.Success = err == nil
+	return err
+}
+
+// StopPruning stops a running online pruning, which has to be started again
+// from scratch
+func (p *Admin) StopPruning(r *http.Request, args *struct{}, reply *api.SuccessResponse) error {
+	log.Info("Admin: StopPruning called")
+
+	err := p.vm.chain.BlockChain().StopOnlinePruning()
+	reply.Success = err == nil
+	return err
+}
+
+type PruningStatusReply struct {
+	pruner.OnlinePruningStatus
+}
+
+// PruningStatus returns the progress of the current or last online pruning
+func (p *Admin) PruningStatus(r *http.Request, args *struct{}, reply *PruningStatusReply) error {
+	status, err := p.vm.chain.BlockChain().OnlinePruningStatus()
+	if err != nil {
+		return err
+	}
+	reply.OnlinePruningStatus = status
+	return nil
+}
+
+type InspectDatabaseArgs struct {
+	CheckContent bool `json:"checkContent"`
+}
+
+// InspectDatabase starts an inspection of the size of each category of data in
+// the databases in the background, optionally checking that the content of
+// the trie nodes and contract codes matches their hash
+func (p *Admin) InspectDatabase(r *http.Request, args *InspectDatabaseArgs, reply *api.SuccessResponse) error {
+	log.Info("Admin: InspectDatabase called", "checkContent", args.CheckContent)
+
+	err := p.inspector.start(args.CheckContent)
+	reply.Success = err == nil
+	return err
+}
+
+type DatabaseInspectionReply struct {
+	DatabaseInspection
+}
+
+// DatabaseInspection returns the result of the running or last database
+// inspection
+func (p *Admin) DatabaseInspection(r *http.Request, args *struct{}, reply *DatabaseInspectionReply) error {
+	reply.DatabaseInspection = p.inspector.status()
+	return nil
+}
@@ -0,0 +1,180 @@
+// (c) 2021-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package evm
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/avalanchego/database/versiondb"
+	"github.com/ava-labs/avalanchego/utils/wrappers"
+
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/plugin/evm/message"
+	"github.com/ava-labs/coreth/statesync"
+	statesyncclient "github.com/ava-labs/coreth/statesync/client"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var _ statesync.Syncer = &atomicSyncer{}
+
+// atomicSyncer fetches the leafs of the atomic trie at [targetRoot] from peers
+// and writes the resulting trie nodes to the trie database of [atomicTrie].
+// The synced trie only becomes the last committed root of [atomicTrie] once
+// the VM calls finishAtomicSync, so that blocks accepted by the bootstrapper
+// while the sync is running can continue to be indexed.
+type atomicSyncer struct {
+	client     statesyncclient.Client
+	db         *versiondb.Database
+	lock       sync.Locker // held while flushing to [db]
+	atomicTrie AtomicTrie
+	trie       *trie.Trie // trie being built from the synced leafs
+
+	targetRoot   common.Hash
+	targetHeight uint64
+
+	// for progress logging
+	lastHeight  uint64
+	lastLogTime time.Time
+
+	done chan error
+}
+
+func newAtomicSyncer(client statesyncclient.Client, db *versiondb.Database, lock sync.Locker, atomicTrie AtomicTrie, targetRoot common.Hash, targetHeight uint64) (*atomicSyncer, error) {
+	// an atomic trie without any atomic operations is served with an empty root
+	if targetRoot == (common.Hash{}) {
+		targetRoot = types.EmptyRootHash
+	}
+	t, err := trie.New(common.Hash{}, atomicTrie.TrieDB())
+	if err != nil {
+		return nil, err
+	}
+	return &atomicSyncer{
+		client:       client,
+		db:           db,
+		lock:         lock,
+		atomicTrie:   atomicTrie,
+		trie:         t,
+		targetRoot:   targetRoot,
+		targetHeight: targetHeight,
+		done:         make(chan error, 1),
+	}, nil
+}
+
+// Start begins syncing the atomic trie in the background.
+func (s *atomicSyncer) Start(ctx context.Context) error {
+	go func() {
+		s.done <- s.sync(ctx)
+		close(s.done)
+	}()
+	return nil
+}
+
+// Done returns a channel which receives the result of the sync.
+func (s *atomicSyncer) Done() <-chan error { return s.done }
+
+func (s *atomicSyncer) sync(ctx context.Context) error {
+	start := time.Now()
+	s.lastLogTime = start
+	log.Info("starting atomic trie sync", "root", s.targetRoot, "height", s.targetHeight)
+
+	// Keys of the atomic trie are [height]+[blockchainID]
+	keyLength := wrappers.LongLen + common.HashLength
+	if s.targetRoot != types.EmptyRootHash {
+		if err := statesync.SyncLeafs(ctx, s.client, statesync.LeafSyncTask{
+			Root:     s.targetRoot,
+			NodeType: message.AtomicTrieNode,
+			Start:    bytes.Repeat([]byte{0x00}, keyLength),
+			End:      bytes.Repeat([]byte{0xff}, keyLength),
+			OnLeafs:  s.onLeafs,
+		}); err != nil {
+			return err
+		}
+	}
+
+	root, err := s.flush()
+	if err != nil {
+		return err
+	}
+	if root != s.targetRoot {
+		return fmt.Errorf("synced atomic trie root %s does not match expected root %s", root, s.targetRoot)
+	}
+	log.Info("atomic trie sync completed", "root", s.targetRoot, "height", s.targetHeight, "time", time.Since(start))
+	return nil
+}
+
+// onLeafs inserts the synced leafs into [s.trie], flushing the trie nodes
+// to disk once they exceed [trieCommitSizeCap].
+func (s *atomicSyncer) onLeafs(keys, vals [][]byte) error {
+	for i, key := range keys {
+		if len(key) != wrappers.LongLen+common.HashLength {
+			return fmt.Errorf("unexpected atomic trie key length %d", len(key))
+		}
+		s.lastHeight = binary.BigEndian.Uint64(key[:wrappers.LongLen])
+		if s.lastHeight > s.targetHeight {
+			return fmt.Errorf("atomic trie key at height %d exceeds target height %d", s.lastHeight, s.targetHeight)
+		}
+		if err := s.trie.TryUpdate(key, vals[i]); err != nil {
+			return err
+		}
+	}
+
+	if time.Since(s.lastLogTime) > progressLogUpdate {
+		log.Info("atomic trie sync in progress", "root", s.targetRoot, "height", s.lastHeight, "targetHeight", s.targetHeight)
+		s.lastLogTime = time.Now()
+	}
+
+	if storage, _ := s.atomicTrie.TrieDB().Size(); storage > trieCommitSizeCap {
+		if _, err := s.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush commits [s.trie] and writes its nodes to [s.db], returning the root
+// of the trie.
+func (s *atomicSyncer) flush() (common.Hash, error) {
+	root, _, err := s.trie.Commit(nil)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.atomicTrie.TrieDB().Commit(root, false, nil); err != nil {
+		return common.Hash{}, err
+	}
+	return root, s.db.Commit()
+}
+
+// finishAtomicSync marks the atomic trie synced at [height] as the last committed
+// root of the atomic trie. The atomic operations of blocks after
+// [previousLastAcceptedHeight] are applied to shared memory.
+// Note: the atomic trie only contains the atomic operations of each block, so the
+// atomic tx repository cannot be rebuilt from it. Instead, the heights accepted
+// without execution are recorded as not indexed in the repository, and their
+// atomic txs are not available by height or txID after state sync.
+// Assumes the caller holds the context lock.
+func (vm *VM) finishAtomicSync(root common.Hash, height uint64, previousLastAcceptedHeight uint64) error {
+	if err := vm.atomicTrie.UpdateLastCommitted(root, height); err != nil {
+		return err
+	}
+	if err := vm.atomicTxRepository.MarkSyncedHeight(height); err != nil {
+		return err
+	}
+	if err := vm.atomicTrie.MarkApplyToSharedMemoryCursor(previousLastAcceptedHeight); err != nil {
+		return err
+	}
+	if err := vm.db.Commit(); err != nil {
+		return err
+	}
+	return vm.atomicTrie.ApplyToSharedMemory(height)
+}
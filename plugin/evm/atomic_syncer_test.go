@@ -0,0 +1,154 @@
+// (c) 2021-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package evm
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/chains/atomic"
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/database/memdb"
+	"github.com/ava-labs/avalanchego/database/versiondb"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ava-labs/coreth/plugin/evm/message"
+	statesyncclient "github.com/ava-labs/coreth/statesync/client"
+	"github.com/ava-labs/coreth/statesync/handlers"
+	"github.com/ava-labs/coreth/statesync/handlers/stats"
+)
+
+// testAtomicSyncer syncs the atomic trie built from [serverRepo] at [targetHeight] into
+// an empty atomic trie and asserts that the synced trie contains [operationsMap].
+func testAtomicSyncer(t *testing.T, serverDB *versiondb.Database, serverRepo AtomicTxRepository, targetHeight uint64, commitInterval uint64, operationsMap map[uint64]map[ids.ID]*atomic.Requests) {
+	codec := testTxCodec()
+	networkCodec, err := message.BuildCodec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverTrie, err := newAtomicTrie(serverDB, testSharedMemory(), nil, serverRepo, codec, targetHeight, commitInterval)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetRoot, height := serverTrie.LastCommitted()
+	assert.Equal(t, targetHeight, height)
+
+	leafsHandler := handlers.NewLeafsRequestHandler(serverTrie.TrieDB(), stats.NewNoopHandlerStats(), networkCodec)
+	mockClient := statesyncclient.NewMockClient(networkCodec, leafsHandler, nil, nil, nil)
+
+	clientDB := versiondb.New(memdb.New())
+	clientRepo, err := NewAtomicTxRepository(clientDB, codec, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientTrie, err := newAtomicTrie(clientDB, testSharedMemory(), nil, clientRepo, codec, 0, commitInterval)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	syncer, err := newAtomicSyncer(mockClient, clientDB, &sync.Mutex{}, clientTrie, targetRoot, targetHeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, syncer.Start(context.Background()))
+	assert.NoError(t, <-syncer.Done())
+
+	// the synced trie is not used until it is marked as the last committed root
+	_, lastCommittedHeight := clientTrie.LastCommitted()
+	assert.Zero(t, lastCommittedHeight)
+	assert.NoError(t, clientTrie.UpdateLastCommitted(syncer.targetRoot, targetHeight))
+	verifyOperations(t, clientTrie, codec, syncer.targetRoot, 1, targetHeight, operationsMap)
+
+	// the atomic trie must accept the next block after the synced height
+	assert.NoError(t, clientTrie.Index(targetHeight+1, nil))
+}
+
+func TestAtomicSyncer(t *testing.T) {
+	for name, test := range map[string]struct {
+		targetHeight   uint64
+		numTxsPerBlock func(uint64) int
+	}{
+		"single commit": {
+			targetHeight:   testCommitInterval,
+			numTxsPerBlock: constTxsPerHeight(3),
+		},
+		"many leafs": {
+			targetHeight:   2_000,
+			numTxsPerBlock: constTxsPerHeight(1),
+		},
+		"blocks without atomic txs": {
+			targetHeight: 1_000,
+			numTxsPerBlock: func(height uint64) int {
+				if height%7 == 0 {
+					return 2
+				}
+				return 0
+			},
+		},
+		"empty trie": {
+			targetHeight:   100,
+			numTxsPerBlock: constTxsPerHeight(0),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			serverDB := versiondb.New(memdb.New())
+			serverRepo, err := NewAtomicTxRepository(serverDB, testTxCodec(), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			operationsMap := make(map[uint64]map[ids.ID]*atomic.Requests)
+			writeTxs(t, serverRepo, 1, test.targetHeight+1, test.numTxsPerBlock, nil, operationsMap)
+
+			testAtomicSyncer(t, serverDB, serverRepo, test.targetHeight, testCommitInterval, operationsMap)
+		})
+	}
+}
+
+func TestAtomicSyncerMarkSyncedHeight(t *testing.T) {
+	db := versiondb.New(memdb.New())
+	repo, err := NewAtomicTxRepository(db, testTxCodec(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txMap := make(map[uint64][]*Tx)
+	writeTxs(t, repo, 1, 11, constTxsPerHeight(1), txMap, nil)
+
+	_, _, err = repo.GetSyncedHeights()
+	assert.Equal(t, database.ErrNotFound, err)
+	assert.NoError(t, repo.MarkSyncedHeight(4096))
+	assert.Error(t, repo.MarkSyncedHeight(4096), "synced height must be above the index height")
+	assert.NoError(t, db.Commit())
+
+	// reinitializing the repository must not re-index from scratch
+	repo, err = NewAtomicTxRepository(db, testTxCodec(), 4096)
+	assert.NoError(t, err)
+	height, err := repo.GetIndexHeight()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4096, height)
+	from, to, err := repo.GetSyncedHeights()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 11, from)
+	assert.EqualValues(t, 4096, to)
+
+	// the txs indexed before the state sync are still available, while the
+	// synced heights are not reported as having no atomic txs
+	verifyTxs(t, repo, txMap)
+	_, err = repo.GetByHeight(11)
+	assert.ErrorIs(t, err, errAtomicTxsNotIndexed)
+	_, err = repo.GetByHeight(4096)
+	assert.ErrorIs(t, err, errAtomicTxsNotIndexed)
+	_, err = repo.GetByHeight(4097)
+	assert.Equal(t, database.ErrNotFound, err)
+
+	// a later state sync extends the synced heights
+	writeTxs(t, repo, 4097, 4100, constTxsPerHeight(1), txMap, nil)
+	assert.NoError(t, repo.MarkSyncedHeight(8192))
+	from, to, err = repo.GetSyncedHeights()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 11, from)
+	assert.EqualValues(t, 8192, to)
+}
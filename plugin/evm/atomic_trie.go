@@ -445,6 +445,16 @@ func (a *atomicTrie) UpdateLastCommitted(root common.Hash, height uint64) error
 		return err
 	}
 
+	// reopen the trie at [root] if it was built outside of the atomic trie,
+	// as is the case when the atomic trie is synced from peers.
+	if a.trie.Hash() != root {
+		t, err := trie.New(root, a.trieDB)
+		if err != nil {
+			return err
+		}
+		a.trie = t
+	}
+
 	a.lastCommittedHash = root
 	a.lastCommittedHeight = height
 	return nil
@@ -5,6 +5,7 @@ package evm
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"sort"
 	"time"
@@ -31,6 +32,9 @@ var (
 	atomicRepoMetadataDBPrefix = []byte("atomicRepoMetadataDB")
 	maxIndexedHeightKey        = []byte("maxIndexedAtomicTxHeight")
 	bonusBlocksRepairedKey     = []byte("bonusBlocksRepaired")
+	syncedHeightsKey           = []byte("syncedAtomicTxHeights")
+
+	errAtomicTxsNotIndexed = errors.New("atomic txs of state synced block are not indexed")
 )
 
 // AtomicTxRepository defines an entity that manages storage and indexing of
@@ -44,6 +48,15 @@ type AtomicTxRepository interface {
 
 	IterateByHeight(uint64) database.Iterator
 
+	// MarkSyncedHeight records that the blocks above the index height up to
+	// [height] were not indexed and advances the index height to [height].
+	// Used after syncing the atomic trie from peers, as the trie only holds
+	// the atomic operations of the synced blocks and not their atomic txs.
+	MarkSyncedHeight(height uint64) error
+	// GetSyncedHeights returns the range of heights marked by MarkSyncedHeight,
+	// or [database.ErrNotFound] if the atomic txs of all blocks were indexed.
+	GetSyncedHeights() (uint64, uint64, error)
+
 	IsBonusBlocksRepaired() (bool, error)
 	MarkBonusBlocksRepaired(repairedEntries uint64) error
 }
@@ -221,14 +234,30 @@ func (a *atomicTxRepository) GetByTxID(txID ids.ID) (*Tx, uint64, error) {
 // GetByHeight returns all atomic txs processed on block at [height].
 // Returns [database.ErrNotFound] if there are no atomic transactions indexed at [height].
 // Note: if [height] is below the last accepted height, then this means that there were
-// no atomic transactions in the block accepted at [height].
+// no atomic transactions in the block accepted at [height], unless [height] is in the
+// range of heights accepted by state sync, in which case [errAtomicTxsNotIndexed] is
+// returned.
 // If [height] is greater than the last accepted height, then this will always return
 // [database.ErrNotFound]
 func (a *atomicTxRepository) GetByHeight(height uint64) ([]*Tx, error) {
 	heightBytes := make([]byte, wrappers.LongLen)
 	binary.BigEndian.PutUint64(heightBytes, height)
 
-	return a.getByHeightBytes(heightBytes)
+	txs, err := a.getByHeightBytes(heightBytes)
+	if err != database.ErrNotFound {
+		return txs, err
+	}
+	switch from, to, syncedErr := a.GetSyncedHeights(); syncedErr {
+	case nil:
+		if from <= height && height <= to {
+			return nil, fmt.Errorf("%w at height %d", errAtomicTxsNotIndexed, height)
+		}
+		return nil, err
+	case database.ErrNotFound:
+		return nil, err
+	default:
+		return nil, syncedErr
+	}
 }
 
 func (a *atomicTxRepository) getByHeightBytes(heightBytes []byte) ([]*Tx, error) {
@@ -357,6 +386,53 @@ func (a *atomicTxRepository) IterateByHeight(height uint64) database.Iterator {
 	return a.acceptedAtomicTxByHeightDB.NewIteratorWithStart(heightBytes)
 }
 
+// MarkSyncedHeight stores the range of heights from the block above the index
+// height up to [height] at [syncedHeightsKey] and sets the value stored at
+// [maxIndexedHeightKey] to [height].
+// If an earlier state sync was marked, the range is extended to include it.
+// Note: the atomic txs accepted in this range cannot be found by height or txID.
+func (a *atomicTxRepository) MarkSyncedHeight(height uint64) error {
+	indexHeight, err := a.GetIndexHeight()
+	if err != nil {
+		return err
+	}
+	if height <= indexHeight {
+		return fmt.Errorf("synced height %d is not above index height %d", height, indexHeight)
+	}
+	from := indexHeight + 1
+	switch prevFrom, _, err := a.GetSyncedHeights(); err {
+	case nil:
+		if prevFrom < from {
+			from = prevFrom
+		}
+	case database.ErrNotFound:
+	default:
+		return err
+	}
+
+	syncedHeights := wrappers.Packer{Bytes: make([]byte, 2*wrappers.LongLen)}
+	syncedHeights.PackLong(from)
+	syncedHeights.PackLong(height)
+	if err := a.atomicRepoMetadataDB.Put(syncedHeightsKey, syncedHeights.Bytes); err != nil {
+		return err
+	}
+	return a.atomicRepoMetadataDB.Put(maxIndexedHeightKey, syncedHeights.Bytes[wrappers.LongLen:])
+}
+
+// GetSyncedHeights returns the first and last height of the blocks whose atomic
+// txs were not indexed due to state sync.
+func (a *atomicTxRepository) GetSyncedHeights() (uint64, uint64, error) {
+	syncedHeightsBytes, err := a.atomicRepoMetadataDB.Get(syncedHeightsKey)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(syncedHeightsBytes) != 2*wrappers.LongLen {
+		return 0, 0, fmt.Errorf("unexpected length for syncedHeightsBytes %d", len(syncedHeightsBytes))
+	}
+	packer := wrappers.Packer{Bytes: syncedHeightsBytes}
+	return packer.UnpackLong(), packer.UnpackLong(), nil
+}
+
 func (a *atomicTxRepository) IsBonusBlocksRepaired() (bool, error) {
 	return a.atomicRepoMetadataDB.Has(bonusBlocksRepairedKey)
 }
@@ -112,6 +112,10 @@ type Block struct {
 	vm        *VM
 	status    choices.Status
 	atomicTxs []*Tx
+
+	// skipped is set if the block was verified while its state is covered by
+	// a completed state sync, such that it is accepted without execution.
+	skipped bool
 }
 
 // ID implements the snowman.Block interface
@@ -126,7 +130,12 @@ func (b *Block) Accept() error {
 	defer vm.db.Abort()
 
 	b.status = choices.Accepted
+	vm.lastAcceptedTime.Store(vm.clock.Time())
 	log.Debug(fmt.Sprintf("Accepting block %s (%s) at height %d", b.ID().Hex(), b.ID(), b.Height()))
+	if b.skipped {
+		// The state of this block was fetched by state sync
+		return vm.acceptSkippedBlock(b)
+	}
 	if err := vm.chain.Accept(b.ethBlock); err != nil {
 		return fmt.Errorf("chain could not accept %s: %w", b.ID(), err)
 	}
@@ -234,7 +243,20 @@ func (b *Block) syntacticVerify() (params.Rules, error) {
 
 // Verify implements the snowman.Block interface
 func (b *Block) Verify() error {
-	return b.verify(true)
+	skip, err := b.vm.skipBootstrappedBlock(b)
+	if err != nil {
+		return err
+	}
+	if !skip {
+		return b.verify(true)
+	}
+	// The state of this block was fetched by state sync, so it is only
+	// verified syntactically
+	if _, err := b.syntacticVerify(); err != nil {
+		return fmt.Errorf("syntactic block verification failed: %w", err)
+	}
+	b.skipped = true
+	return nil
 }
 
 func (b *Block) verify(writes bool) error {
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/ava-labs/coreth/eth"
+	"github.com/ava-labs/coreth/peer"
 	"github.com/spf13/cast"
 )
 
@@ -28,11 +29,36 @@ const (
 	defaultTxRegossipFrequency                    = 1 * time.Minute
 	defaultTxRegossipMaxSize                      = 15
 	defaultOfflinePruningBloomFilterSize   uint64 = 512 // Default size (MB) for the offline pruner to use
+	defaultOnlinePruningBloomFilterSize    uint64 = 512 // Default size (MB) for the online pruner to use
+	defaultOnlinePruningThrottle                  = 100 * time.Millisecond
 	defaultLogLevel                               = "info"
 	defaultMaxOutboundActiveRequests              = 8
+	defaultInboundRequestsPerPeer                 = 50
+	defaultInboundRequestBurstPerPeer             = 100
+	defaultInboundRequestCPUPerPeer               = 250 * time.Millisecond // Default to spending at most 25% of a core on the requests of a single peer
+	defaultInboundRequestCPU                      = 500 * time.Millisecond // Default to spending at most half of the time handling requests, which are handled one at a time
+	defaultHealthMinConnectedPeers                = 1
+	defaultHealthMaxAtomicTrieCommitLag           = 2 * commitHeightInterval // Default to allowing one missed commit of the atomic trie
 	defaultPopulateMissingTriesParallelism        = 1024
+	defaultStateSyncMinBlocks                     = 300_000 // Default to only state syncing if at least this many blocks behind the syncable block
+	defaultStateSyncSampleSize                    = 5
+	defaultAcceptorQueueLimit                     = 64     // Provides 2 minutes of buffer (2s block target) for a commit delay
+	defaultFreezerThreshold                       = 90_000 // Keeps about 2 days of accepted blocks (2s block target) in the database
+	defaultDatabaseCache                          = 512    // Default size (MB) of the caches of a chain database not provided by avalanchego
+	defaultDatabaseHandles                        = 1024
+	defaultCommitInterval                         = 4096
+	defaultTipBufferSize                          = 16
+	defaultTrieCleanCache                         = 128
+	defaultTrieDirtyCache                         = 256
+	defaultTrieDirtyCommitTarget                  = 20
+	defaultSnapshotCache                          = 128
 )
 
+// pebbleDatabaseType is the database-type storing the chain database in a
+// pebble database in database-directory instead of the database provided by
+// avalanchego.
+const pebbleDatabaseType = "pebble"
+
 var defaultEnabledAPIs = []string{
 	"public-eth",
 	"public-eth-filter",
@@ -72,12 +98,31 @@ type Config struct {
 	SnapshotAsync  bool `json:"snapshot-async"`
 	SnapshotVerify bool `json:"snapshot-verification-enabled"`
 
+	// AcceptorQueueLimit is the number of accepted blocks queued for
+	// processing (indexing, snapshot flattening, accepted events) before
+	// the acceptance of a block blocks.
+	AcceptorQueueLimit int `json:"accepted-queue-limit"`
+
+	// TxLookupLimit is the number of recent accepted blocks for which the
+	// transaction lookup indices are kept. Older indices are removed in the
+	// background. 0 keeps the indices of all blocks.
+	TxLookupLimit uint64 `json:"tx-lookup-limit"`
+
 	// Pruning Settings
-	Pruning                         bool    `json:"pruning-enabled"`                    // If enabled, trie roots are only persisted every 4096 blocks
+	Pruning                         bool    `json:"pruning-enabled"`                    // If enabled, trie roots are only persisted every commit-interval blocks
+	CommitInterval                  uint64  `json:"commit-interval"`                    // Number of accepted blocks between trie commits when pruning, in addition to the commits at the syncable heights (multiples of 4096)
+	TipBufferSize                   int     `json:"tip-buffer-size"`                    // Number of recent accepted states kept in memory when pruning, which can be queried without re-execution
+	StateDiffs                      bool    `json:"state-diffs-enabled"`                // If enabled, per-block state diffs are persisted to serve historical state queries when pruning, which disables online pruning
 	AllowMissingTries               bool    `json:"allow-missing-tries"`                // If enabled, warnings preventing an incomplete trie index are suppressed
 	PopulateMissingTries            *uint64 `json:"populate-missing-tries,omitempty"`   // Sets the starting point for re-populating missing tries. Disables re-generation if nil.
 	PopulateMissingTriesParallelism int     `json:"populate-missing-tries-parallelism"` // Number of concurrent readers to use when re-populating missing tries on startup.
 
+	// Cache Settings
+	TrieCleanCache        int `json:"trie-clean-cache"`         // Size of the trie clean cache (MB)
+	TrieDirtyCache        int `json:"trie-dirty-cache"`         // Size of the trie dirty cache (MB), dirty nodes are flushed to disk above it
+	TrieDirtyCommitTarget int `json:"trie-dirty-commit-target"` // Size of the trie dirty cache (MB) progressively flushed down to before a commit
+	SnapshotCache         int `json:"snapshot-cache"`           // Size of the snapshot disk layer clean cache (MB)
+
 	// Metric Settings
 	MetricsEnabled          bool `json:"metrics-enabled"`
 	MetricsExpensiveEnabled bool `json:"metrics-expensive-enabled"`
@@ -90,6 +135,7 @@ type Config struct {
 	MaxBlocksPerRequest     int64    `json:"api-max-blocks-per-request"`
 	AllowUnfinalizedQueries bool     `json:"allow-unfinalized-queries"`
 	AllowUnprotectedTxs     bool     `json:"allow-unprotected-txs"`
+	StandardTraceDirectory  string   `json:"standard-trace-directory"` // Directory of the standard JSON traces written by debug_standardTraceBlockToFile
 
 	// Keystore Settings
 	KeystoreDirectory             string `json:"keystore-directory"` // both absolute and relative supported
@@ -109,8 +155,41 @@ type Config struct {
 	OfflinePruningBloomFilterSize uint64 `json:"offline-pruning-bloom-filter-size"`
 	OfflinePruningDataDirectory   string `json:"offline-pruning-data-directory"`
 
+	// Trie Regeneration Settings
+	TrieRegeneration bool `json:"trie-regeneration-enabled"` // If enabled, the state trie of the block persisted by the snapshot is rebuilt from the snapshot on startup
+
+	// Online Pruning Settings, online pruning is started through the admin API
+	OnlinePruningBloomFilterSize uint64   `json:"online-pruning-bloom-filter-size"` // Size (MB) of the bloom filter of the state kept by the online pruner
+	OnlinePruningThrottle        Duration `json:"online-pruning-throttle"`          // Pause between the batches of trie nodes deleted by the online pruner
+
+	// Freezer Settings
+	FreezerDirectory string `json:"freezer-directory"` // If set, accepted blocks older than FreezerThreshold are moved from the database to flat files in this directory
+	FreezerThreshold uint64 `json:"freezer-threshold"` // Number of recent accepted blocks kept in the database when the freezer is enabled
+
+	// Database Settings
+	DatabaseType      string `json:"database-type"`      // Key-value store of the chain database: "" to use the database provided by avalanchego, or "pebble"
+	DatabaseDirectory string `json:"database-directory"` // Directory of the chain database, required if database-type is set
+	DatabaseCache     int    `json:"database-cache"`     // Megabytes of memory allocated to the caches of the chain database
+	DatabaseHandles   int    `json:"database-handles"`   // Number of file handles allocated to the chain database
+
 	// VM2VM network
-	MaxOutboundActiveRequests int64 `json:"max-outbound-active-requests"`
+	MaxOutboundActiveRequests  int64    `json:"max-outbound-active-requests"`
+	InboundRequestsPerPeer     float64  `json:"inbound-requests-per-peer"`      // Sustained number of requests per second handled for a single peer (0 to disable)
+	InboundRequestBurstPerPeer int      `json:"inbound-request-burst-per-peer"` // Number of requests handled for a single peer in a burst above inbound-requests-per-peer
+	InboundRequestCPUPerPeer   Duration `json:"inbound-request-cpu-per-peer"`   // Processing time per second spent on the requests of a single peer (0 to disable)
+	InboundRequestCPU          Duration `json:"inbound-request-cpu"`            // Processing time per second spent on the requests of all peers, at most 1s since requests are handled one at a time (0 to disable)
+
+	// Health check settings, a zero value disables the corresponding check
+	HealthMinConnectedPeers        int      `json:"health-min-connected-peers"`          // Minimum number of connected peers
+	HealthMaxTimeSinceLastAccepted Duration `json:"health-max-time-since-last-accepted"` // Maximum time since a block was last accepted once bootstrapped
+	HealthMaxLastAcceptedLag       Duration `json:"health-max-last-accepted-lag"`        // Maximum difference between the wall-clock time and the timestamp of the last accepted block once bootstrapped
+	HealthMaxMempoolUtilization    float64  `json:"health-max-mempool-utilization"`      // Maximum fraction of the tx pool or the atomic mempool in use
+	HealthMaxAtomicTrieCommitLag   uint64   `json:"health-max-atomic-trie-commit-lag"`   // Maximum number of blocks accepted since the last commit of the atomic trie
+
+	// Sync settings
+	StateSyncEnabled    bool   `json:"state-sync-enabled"`     // If enabled, a fresh node fetches the state of a recent block from peers instead of executing every block from genesis
+	StateSyncMinBlocks  uint64 `json:"state-sync-min-blocks"`  // Minimum number of blocks the syncable block must be ahead of the last accepted block to perform state sync
+	StateSyncSampleSize int    `json:"state-sync-sample-size"` // Number of distinct peers asked for a syncable block, which must be served by validators holding more than half of their stake
 }
 
 // EthAPIs returns an array of strings representing the Eth APIs that should be enabled
@@ -122,6 +201,16 @@ func (c Config) EthBackendSettings() eth.Settings {
 	return eth.Settings{MaxBlocksPerRequest: c.MaxBlocksPerRequest}
 }
 
+// InboundThrottlerConfig returns the quotas enforced on the requests of peers
+func (c Config) InboundThrottlerConfig() peer.ThrottlerConfig {
+	return peer.ThrottlerConfig{
+		RequestsPerPeer:     c.InboundRequestsPerPeer,
+		RequestBurstPerPeer: c.InboundRequestBurstPerPeer,
+		CPUPerPeer:          c.InboundRequestCPUPerPeer.Duration,
+		CPU:                 c.InboundRequestCPU.Duration,
+	}
+}
+
 func (c *Config) SetDefaults() {
 	c.EnabledEthAPIs = defaultEnabledAPIs
 	c.RPCGasCap = defaultRpcGasCap
@@ -139,9 +228,29 @@ func (c *Config) SetDefaults() {
 	c.TxRegossipFrequency.Duration = defaultTxRegossipFrequency
 	c.TxRegossipMaxSize = defaultTxRegossipMaxSize
 	c.OfflinePruningBloomFilterSize = defaultOfflinePruningBloomFilterSize
+	c.OnlinePruningBloomFilterSize = defaultOnlinePruningBloomFilterSize
+	c.OnlinePruningThrottle.Duration = defaultOnlinePruningThrottle
 	c.LogLevel = defaultLogLevel
 	c.MaxOutboundActiveRequests = defaultMaxOutboundActiveRequests
+	c.InboundRequestsPerPeer = defaultInboundRequestsPerPeer
+	c.InboundRequestBurstPerPeer = defaultInboundRequestBurstPerPeer
+	c.InboundRequestCPUPerPeer.Duration = defaultInboundRequestCPUPerPeer
+	c.InboundRequestCPU.Duration = defaultInboundRequestCPU
+	c.HealthMinConnectedPeers = defaultHealthMinConnectedPeers
+	c.HealthMaxAtomicTrieCommitLag = defaultHealthMaxAtomicTrieCommitLag
 	c.PopulateMissingTriesParallelism = defaultPopulateMissingTriesParallelism
+	c.StateSyncMinBlocks = defaultStateSyncMinBlocks
+	c.StateSyncSampleSize = defaultStateSyncSampleSize
+	c.AcceptorQueueLimit = defaultAcceptorQueueLimit
+	c.FreezerThreshold = defaultFreezerThreshold
+	c.DatabaseCache = defaultDatabaseCache
+	c.DatabaseHandles = defaultDatabaseHandles
+	c.CommitInterval = defaultCommitInterval
+	c.TipBufferSize = defaultTipBufferSize
+	c.TrieCleanCache = defaultTrieCleanCache
+	c.TrieDirtyCache = defaultTrieDirtyCache
+	c.TrieDirtyCommitTarget = defaultTrieDirtyCommitTarget
+	c.SnapshotCache = defaultSnapshotCache
 }
 
 func (d *Duration) UnmarshalJSON(data []byte) (err error) {
@@ -166,5 +275,59 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("cannot run offline pruning while pruning is disabled")
 	}
 
+	if c.Pruning && (c.CommitInterval == 0 || c.TipBufferSize < 1) {
+		return fmt.Errorf("cannot enable pruning without a positive commit-interval (got %d) and tip-buffer-size (got %d)", c.CommitInterval, c.TipBufferSize)
+	}
+
+	if c.StateDiffs && (!c.Pruning || c.SnapshotCache <= 0) {
+		return fmt.Errorf("cannot enable state diffs without pruning (enabled: %t) and a positive snapshot-cache (got %d)", c.Pruning, c.SnapshotCache)
+	}
+
+	if c.OnlinePruningThrottle.Duration < 0 {
+		return fmt.Errorf("online-pruning-throttle cannot be negative (got %s)", c.OnlinePruningThrottle.Duration)
+	}
+
+	if c.TrieCleanCache < 0 || c.TrieDirtyCache < 0 || c.TrieDirtyCommitTarget < 0 || c.SnapshotCache < 0 {
+		return fmt.Errorf("cache sizes cannot be negative")
+	}
+	if c.TrieDirtyCommitTarget > c.TrieDirtyCache {
+		return fmt.Errorf("trie-dirty-commit-target (%d) cannot exceed trie-dirty-cache (%d)", c.TrieDirtyCommitTarget, c.TrieDirtyCache)
+	}
+
+	if c.InboundRequestsPerPeer < 0 || c.InboundRequestCPUPerPeer.Duration < 0 || c.InboundRequestCPU.Duration < 0 {
+		return fmt.Errorf("inbound request quotas cannot be negative")
+	}
+	if c.InboundRequestCPU.Duration > time.Second {
+		return fmt.Errorf("inbound-request-cpu (%s) cannot exceed 1s since requests are handled one at a time", c.InboundRequestCPU.Duration)
+	}
+
+	if c.HealthMaxMempoolUtilization < 0 || c.HealthMaxMempoolUtilization > 1 {
+		return fmt.Errorf("health-max-mempool-utilization must be between 0 and 1 (got %f)", c.HealthMaxMempoolUtilization)
+	}
+
+	if c.StateSyncEnabled && c.StateSyncSampleSize < 1 {
+		return fmt.Errorf("cannot enable state sync without sampling at least one peer (sample size: %d)", c.StateSyncSampleSize)
+	}
+
+	if c.AcceptorQueueLimit < 0 {
+		return fmt.Errorf("accepted-queue-limit cannot be negative (got %d)", c.AcceptorQueueLimit)
+	}
+
+	// The blocks below the block fetched by state sync are not available, so
+	// they cannot be moved into the freezer.
+	if c.StateSyncEnabled && c.FreezerDirectory != "" {
+		return fmt.Errorf("cannot enable the freezer while state sync is enabled")
+	}
+
+	switch c.DatabaseType {
+	case "":
+	case pebbleDatabaseType:
+		if c.DatabaseDirectory == "" {
+			return fmt.Errorf("database-directory must be set when database-type is %q", c.DatabaseType)
+		}
+	default:
+		return fmt.Errorf("unknown database-type %q", c.DatabaseType)
+	}
+
 	return nil
 }
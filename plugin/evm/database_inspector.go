@@ -0,0 +1,150 @@
+// (c) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package evm
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/avalanchego/database/prefixdb"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	errInspectionRunning = errors.New("database inspection is already running")
+	errInspectionAborted = errors.New("database inspection aborted")
+)
+
+// vmDatabases are the databases of the VM stored next to the chain database,
+// by category.
+var vmDatabases = []struct {
+	category string
+	prefix   []byte
+}{
+	{"Accepted blocks", acceptedPrefix},
+	{"Atomic trie nodes", atomicTrieDBPrefix},
+	{"Atomic trie metadata", atomicTrieMetaDBPrefix},
+	{"Atomic txs", atomicTxIDDBPrefix},
+	{"Atomic txs by height", atomicHeightTxDBPrefix},
+	{"Atomic repository metadata", atomicRepoMetadataDBPrefix},
+}
+
+// DatabaseInspection is the result of the last inspection of the databases of
+// the VM.
+type DatabaseInspection struct {
+	Running  bool                     `json:"running"`
+	Started  time.Time                `json:"started"`
+	Finished time.Time                `json:"finished"`
+	Stats    []rawdb.DatabaseStat     `json:"stats"`             // Size of each category of data
+	Content  *rawdb.StateContentCheck `json:"content,omitempty"` // Result of the state content check, if requested
+
+	// Result of the content check of the atomic trie nodes, if requested
+	AtomicContent *rawdb.StateContentCheck `json:"atomicContent,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// databaseInspector inspects the databases of the VM in the background, since
+// an inspection traverses every key of the databases.
+type databaseInspector struct {
+	vm *VM
+
+	lock       sync.Mutex
+	inspection DatabaseInspection
+}
+
+// start starts an inspection of the databases, checking the content of the
+// trie nodes, contract codes and atomic trie nodes if [checkContent] is true.
+func (i *databaseInspector) start(checkContent bool) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.inspection.Running {
+		return errInspectionRunning
+	}
+	i.inspection = DatabaseInspection{Running: true, Started: time.Now()}
+
+	i.vm.shutdownWg.Add(1)
+	go func() {
+		defer i.vm.shutdownWg.Done()
+
+		inspection := i.inspect(checkContent)
+		i.lock.Lock()
+		i.inspection = inspection
+		i.lock.Unlock()
+	}()
+	return nil
+}
+
+// status returns the running or last inspection.
+func (i *databaseInspector) status() DatabaseInspection {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	return i.inspection
+}
+
+// inspect traverses the databases of the VM, aborting on shutdown.
+func (i *databaseInspector) inspect(checkContent bool) DatabaseInspection {
+	inspection := DatabaseInspection{Started: time.Now()}
+	log.Info("Inspecting databases", "checkContent", checkContent)
+
+	err := func() error {
+		stats, err := rawdb.ReadDatabaseStats(i.vm.chaindb, nil, nil, i.vm.shutdownChan)
+		if err != nil {
+			return err
+		}
+		inspection.Stats = stats
+		for _, db := range vmDatabases {
+			stat, err := i.readVMDatabaseStat(db.category, db.prefix)
+			if err != nil {
+				return err
+			}
+			inspection.Stats = append(inspection.Stats, stat)
+		}
+		if checkContent {
+			if inspection.Content, err = rawdb.CheckStateContent(i.vm.chaindb, i.vm.shutdownChan); err != nil {
+				return err
+			}
+			atomicTrieDB := Database{prefixdb.New(atomicTrieDBPrefix, i.vm.db)}
+			if inspection.AtomicContent, err = rawdb.CheckStateContent(atomicTrieDB, i.vm.shutdownChan); err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+	inspection.Finished = time.Now()
+	if err != nil {
+		log.Error("Database inspection failed", "err", err)
+		inspection.Error = err.Error()
+		return inspection
+	}
+	log.Info("Inspected databases", "elapsed", common.PrettyDuration(inspection.Finished.Sub(inspection.Started)))
+	return inspection
+}
+
+// readVMDatabaseStat returns the size of the database of the VM with the
+// prefix [prefix].
+func (i *databaseInspector) readVMDatabaseStat(category string, prefix []byte) (rawdb.DatabaseStat, error) {
+	stat := rawdb.DatabaseStat{Database: "VM store", Category: category}
+
+	it := prefixdb.New(prefix, i.vm.db).NewIterator()
+	defer it.Release()
+
+	for it.Next() {
+		stat.Size += common.StorageSize(len(it.Key()) + len(it.Value()))
+		stat.Count++
+		if stat.Count%1000 == 0 {
+			select {
+			case <-i.vm.shutdownChan:
+				return stat, errInspectionAborted
+			default:
+			}
+		}
+	}
+	return stat, it.Error()
+}
@@ -0,0 +1,57 @@
+// (c) 2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package evm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/api"
+	"github.com/ava-labs/avalanchego/database/prefixdb"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspectDatabase(t *testing.T) {
+	_, vm, _, _, _ := GenesisVM(t, true, genesisJSONApricotPhase5, "", "")
+	defer func() {
+		assert.NoError(t, vm.Shutdown())
+	}()
+
+	// Corrupt a trie node and an atomic trie node
+	rawdb.WriteTrieNode(vm.chaindb, common.HexToHash("0x01"), []byte{0x01})
+	assert.NoError(t, prefixdb.New(atomicTrieDBPrefix, vm.db).Put(common.HexToHash("0x02").Bytes(), []byte{0x02}))
+
+	admin := NewAdminService(vm, "")
+	assert.NoError(t, admin.InspectDatabase(nil, &InspectDatabaseArgs{CheckContent: true}, &api.SuccessResponse{}))
+
+	reply := &DatabaseInspectionReply{}
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(10 * time.Millisecond) {
+		assert.NoError(t, admin.DatabaseInspection(nil, nil, reply))
+		if !reply.Running {
+			break
+		}
+	}
+	assert.False(t, reply.Running)
+	assert.Empty(t, reply.Error)
+
+	counts := make(map[string]uint64)
+	for _, stat := range reply.Stats {
+		counts[stat.Database+"/"+stat.Category] = stat.Count
+	}
+	assert.Equal(t, uint64(1), counts["Key-Value store/Headers"])
+	assert.NotZero(t, counts["Key-Value store/Trie nodes"])
+	assert.Contains(t, counts, "VM store/Atomic trie nodes")
+	assert.NotZero(t, counts["VM store/Atomic repository metadata"])
+
+	if assert.NotNil(t, reply.Content) {
+		assert.Equal(t, uint64(1), reply.Content.Corrupt)
+		assert.Equal(t, []common.Hash{common.HexToHash("0x01")}, reply.Content.Keys)
+	}
+	if assert.NotNil(t, reply.AtomicContent) {
+		assert.Equal(t, uint64(1), reply.AtomicContent.Corrupt)
+		assert.Equal(t, []common.Hash{common.HexToHash("0x02")}, reply.AtomicContent.Keys)
+	}
+}
@@ -3,10 +3,108 @@
 
 package evm
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 // Health returns nil if this chain is healthy.
 // Also returns details, which should be one of:
 // string, []byte, map[string]string
+// The chain is unhealthy if any of the health thresholds in [vm.config] is
+// crossed. The checks on the last accepted block only apply once the chain
+// is bootstrapped.
 func (vm *VM) HealthCheck() (interface{}, error) {
-	// TODO perform actual health check
-	return nil, nil
+	var (
+		details = make(map[string]string)
+		errs    []string
+		now     = vm.clock.Time()
+		// read without the context lock, so the health check does not wait
+		// for the block being processed
+		bootstrapped     = vm.bootstrapped.GetValue()
+		lastAcceptedTime = vm.lastAcceptedTime.Load().(time.Time)
+	)
+
+	// last accepted block
+	lastAccepted := vm.chain.LastAcceptedBlock()
+	timeSinceLastAccepted := now.Sub(lastAcceptedTime)
+	lastAcceptedLag := now.Sub(time.Unix(int64(lastAccepted.Time()), 0))
+	details["lastAcceptedHeight"] = fmt.Sprint(lastAccepted.NumberU64())
+	details["lastAcceptedHash"] = lastAccepted.Hash().Hex()
+	details["timeSinceLastAccepted"] = timeSinceLastAccepted.String()
+	details["lastAcceptedLag"] = lastAcceptedLag.String()
+	details["bootstrapped"] = fmt.Sprint(bootstrapped)
+	if bootstrapped {
+		if maxTime := vm.config.HealthMaxTimeSinceLastAccepted.Duration; maxTime > 0 && timeSinceLastAccepted > maxTime {
+			errs = append(errs, fmt.Sprintf("no block accepted for %s (max %s)", timeSinceLastAccepted, maxTime))
+		}
+		if maxLag := vm.config.HealthMaxLastAcceptedLag.Duration; maxLag > 0 && lastAcceptedLag > maxLag {
+			errs = append(errs, fmt.Sprintf("last accepted block is %s behind wall-clock time (max %s)", lastAcceptedLag, maxLag))
+		}
+	}
+
+	// peers
+	connectedPeers := int(vm.Network.Size())
+	details["connectedPeers"] = fmt.Sprint(connectedPeers)
+	if minPeers := vm.config.HealthMinConnectedPeers; connectedPeers < minPeers {
+		errs = append(errs, fmt.Sprintf("connected to %d peers (min %d)", connectedPeers, minPeers))
+	}
+
+	// snapshot generation
+	if snaps := vm.chain.BlockChain().Snapshots(); snaps != nil {
+		generating, err := snaps.Generating()
+		switch {
+		case err != nil:
+			details["snapshot"] = fmt.Sprintf("unavailable: %s", err)
+		case generating:
+			details["snapshot"] = "generating"
+		default:
+			details["snapshot"] = "complete"
+		}
+	} else {
+		details["snapshot"] = "disabled"
+	}
+
+	// tx pool and atomic mempool
+	pending, queued := vm.chain.GetTxPool().Stats()
+	txPoolUtilization := utilization(pending+queued, int(vm.txPoolCapacity))
+	mempoolUtilization := utilization(vm.mempool.Len(), vm.mempool.MaxSize())
+	details["txPoolPending"] = fmt.Sprint(pending)
+	details["txPoolQueued"] = fmt.Sprint(queued)
+	details["txPoolUtilization"] = fmt.Sprintf("%.2f", txPoolUtilization)
+	details["atomicMempoolUtilization"] = fmt.Sprintf("%.2f", mempoolUtilization)
+	if maxUtilization := vm.config.HealthMaxMempoolUtilization; maxUtilization > 0 {
+		if txPoolUtilization > maxUtilization {
+			errs = append(errs, fmt.Sprintf("tx pool utilization %.2f (max %.2f)", txPoolUtilization, maxUtilization))
+		}
+		if mempoolUtilization > maxUtilization {
+			errs = append(errs, fmt.Sprintf("atomic mempool utilization %.2f (max %.2f)", mempoolUtilization, maxUtilization))
+		}
+	}
+
+	// atomic trie commits
+	_, lastCommittedHeight := vm.atomicTrie.LastCommitted()
+	var commitLag uint64
+	if lastAccepted.NumberU64() > lastCommittedHeight {
+		commitLag = lastAccepted.NumberU64() - lastCommittedHeight
+	}
+	details["atomicTrieLastCommittedHeight"] = fmt.Sprint(lastCommittedHeight)
+	details["atomicTrieCommitLag"] = fmt.Sprint(commitLag)
+	if maxLag := vm.config.HealthMaxAtomicTrieCommitLag; maxLag > 0 && commitLag > maxLag {
+		errs = append(errs, fmt.Sprintf("atomic trie last committed %d blocks ago (max %d)", commitLag, maxLag))
+	}
+
+	if len(errs) > 0 {
+		return details, fmt.Errorf("unhealthy: %s", strings.Join(errs, "; "))
+	}
+	return details, nil
+}
+
+// utilization returns [used] as a fraction of [capacity].
+func utilization(used int, capacity int) float64 {
+	if capacity <= 0 {
+		return 0
+	}
+	return float64(used) / float64(capacity)
 }
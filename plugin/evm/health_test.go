@@ -0,0 +1,71 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package evm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow"
+	"github.com/ava-labs/avalanchego/version"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthCheckTimeSinceLastAccepted(t *testing.T) {
+	_, vm, _, _, _ := GenesisVM(t, true, genesisJSONApricotPhase5, `{"health-min-connected-peers": 0, "health-max-time-since-last-accepted": "1m"}`, "")
+	defer func() {
+		assert.NoError(t, vm.Shutdown())
+	}()
+
+	details, err := vm.HealthCheck()
+	assert.NoError(t, err)
+	assert.Equal(t, "0", details.(map[string]string)["lastAcceptedHeight"])
+
+	vm.clock.Set(vm.clock.Time().Add(2 * time.Minute))
+	_, err = vm.HealthCheck()
+	assert.Error(t, err)
+
+	// staleness is not reported while bootstrapping
+	assert.NoError(t, vm.SetState(snow.Bootstrapping))
+	_, err = vm.HealthCheck()
+	assert.NoError(t, err)
+}
+
+func TestHealthCheckConnectedPeers(t *testing.T) {
+	_, vm, _, _, _ := GenesisVM(t, true, genesisJSONApricotPhase5, `{"health-min-connected-peers": 1}`, "")
+	defer func() {
+		assert.NoError(t, vm.Shutdown())
+	}()
+
+	_, err := vm.HealthCheck()
+	assert.Error(t, err)
+
+	assert.NoError(t, vm.Connected(ids.GenerateTestShortID(), version.NewDefaultApplication("corethtest", 1, 0, 0)))
+	details, err := vm.HealthCheck()
+	assert.NoError(t, err)
+	assert.Equal(t, "1", details.(map[string]string)["connectedPeers"])
+}
+
+func TestHealthCheckConcurrentStateChanges(t *testing.T) {
+	_, vm, _, _, _ := GenesisVM(t, true, genesisJSONApricotPhase5, `{"health-min-connected-peers": 0}`, "")
+	defer func() {
+		assert.NoError(t, vm.Shutdown())
+	}()
+
+	// the health check runs without the context lock, which is held by the test
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_, err := vm.HealthCheck()
+			assert.NoError(t, err)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, vm.SetState(snow.Bootstrapping))
+		assert.NoError(t, vm.SetState(snow.NormalOp))
+	}
+	<-done
+}
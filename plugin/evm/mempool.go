@@ -68,6 +68,11 @@ func (m *Mempool) Len() int {
 	return m.length()
 }
 
+// MaxSize returns the maximum number of transactions allowed in the mempool
+func (m *Mempool) MaxSize() int {
+	return m.maxSize
+}
+
 // assumes the lock is held
 func (m *Mempool) length() int {
 	return m.txHeap.Len() + len(m.issuedTxs)
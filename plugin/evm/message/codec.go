@@ -33,6 +33,7 @@ func BuildCodec() (codec.Manager, error) {
 		c.RegisterType(CodeRequest{}),
 		c.RegisterType(CodeResponse{}),
 		c.RegisterType(SerializedMap{}),
+		c.RegisterType(SyncableBlockRequest{}),
 
 		codecManager.RegisterCodec(Version, c),
 	)
@@ -41,6 +41,7 @@ type RequestHandler interface {
 	HandleAtomicTrieLeafsRequest(ctx context.Context, nodeID ids.ShortID, requestID uint32, leafsRequest LeafsRequest) ([]byte, error)
 	HandleBlockRequest(ctx context.Context, nodeID ids.ShortID, requestID uint32, request BlockRequest) ([]byte, error)
 	HandleCodeRequest(ctx context.Context, nodeID ids.ShortID, requestID uint32, codeRequest CodeRequest) ([]byte, error)
+	HandleSyncableBlockRequest(ctx context.Context, nodeID ids.ShortID, requestID uint32, request SyncableBlockRequest) ([]byte, error)
 }
 
 // ResponseHandler handles response for a sent request
@@ -4,8 +4,10 @@
 package message
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -21,3 +23,17 @@ type SyncableBlock struct {
 func (s SyncableBlock) String() string {
 	return fmt.Sprintf("SyncableBlock(BlockHash=%s, BlockNumber=%d, BlockRoot=%s, AtomicRoot=%s)", s.BlockHash, s.BlockNumber, s.BlockRoot, s.AtomicRoot)
 }
+
+var _ Request = SyncableBlockRequest{}
+
+// SyncableBlockRequest is a request for the most recent SyncableBlock a peer
+// is able to serve the state for.
+type SyncableBlockRequest struct{}
+
+func (s SyncableBlockRequest) String() string {
+	return "SyncableBlockRequest"
+}
+
+func (s SyncableBlockRequest) Handle(ctx context.Context, nodeID ids.ShortID, requestID uint32, handler RequestHandler) ([]byte, error) {
+	return handler.HandleSyncableBlockRequest(ctx, nodeID, requestID, s)
+}
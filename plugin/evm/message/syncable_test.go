@@ -0,0 +1,49 @@
+// (c) 2021-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package message
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMarshalSyncableBlockRequest asserts that the structure or serialization logic hasn't changed, primarily to
+// ensure compatibility with the network.
+func TestMarshalSyncableBlockRequest(t *testing.T) {
+	base64SyncableBlockRequest := "AAAAAAAK"
+
+	codec, err := BuildCodec()
+	assert.NoError(t, err)
+
+	requestBytes, err := RequestToBytes(codec, SyncableBlockRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, base64SyncableBlockRequest, base64.StdEncoding.EncodeToString(requestBytes))
+
+	request, err := BytesToRequest(codec, requestBytes)
+	assert.NoError(t, err)
+	assert.IsType(t, SyncableBlockRequest{}, request)
+}
+
+func TestMarshalSyncableBlock(t *testing.T) {
+	syncableBlock := SyncableBlock{
+		BlockNumber: 4096,
+		BlockRoot:   common.BytesToHash([]byte("some block root")),
+		AtomicRoot:  common.BytesToHash([]byte("some atomic root")),
+		BlockHash:   common.BytesToHash([]byte("some block hash")),
+	}
+
+	codec, err := BuildCodec()
+	assert.NoError(t, err)
+
+	syncableBlockBytes, err := codec.Marshal(Version, syncableBlock)
+	assert.NoError(t, err)
+
+	var s SyncableBlock
+	_, err = codec.Unmarshal(syncableBlockBytes, &s)
+	assert.NoError(t, err)
+	assert.Equal(t, syncableBlock, s)
+}
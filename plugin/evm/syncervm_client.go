@@ -0,0 +1,487 @@
+// (c) 2021-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package evm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	safemath "github.com/ava-labs/avalanchego/utils/math"
+	"github.com/ava-labs/avalanchego/version"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/plugin/evm/message"
+	"github.com/ava-labs/coreth/statesync"
+	statesyncclient "github.com/ava-labs/coreth/statesync/client"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	stateSyncMaxAttempts   = 32
+	stateSyncMaxRetryDelay = 10 * time.Second
+	stateSyncPeerWait      = time.Second
+
+	// stateSyncParents is the number of ancestors of the synced block fetched
+	// along with it, which the BLOCKHASH opcode may access in the blocks
+	// following the synced block.
+	stateSyncParents = 256
+	// stateSyncParentsPerRequest is the number of blocks requested at once,
+	// matching the limit of the block request handler.
+	stateSyncParentsPerRequest = 64
+)
+
+var (
+	// stateSyncMinVersion is the minimum version of peers that serve
+	// the state sync requests.
+	stateSyncMinVersion = version.NewDefaultApplication("avalanche", 1, 7, 8)
+
+	errNoSyncableBlock    = errors.New("no syncable block served by a quorum of the sampled validators")
+	errNoValidatorState   = errors.New("validator state is not available")
+	errSyncedBlockPending = errors.New("bootstrapping finished before accepting the state synced block")
+)
+
+// stateSyncClient is the client side of state sync. It picks a
+// message.SyncableBlock served by a stake-weighted quorum of validators,
+// fetches the EVM state at that block and then fast-forwards the VM to it
+// once the bootstrapper accepts the block, such that bootstrapping resumes
+// from the synced block rather than from genesis.
+type stateSyncClient struct {
+	vm     *VM
+	client statesyncclient.Client
+
+	// getValidators returns the stake of each validator, used to weigh the
+	// syncable blocks served by the sampled peers.
+	getValidators func() (map[ids.ShortID]uint64, error)
+
+	minBlocks  uint64
+	sampleSize int
+
+	// resumeRoot is the state root of an interrupted state sync, whose
+	// progress is discarded if the state sync is skipped.
+	resumeRoot common.Hash
+}
+
+// stateSyncedBlock is a block whose state was fetched by state sync, which the
+// VM is fast-forwarded to once the bootstrapper accepts the block at its height.
+type stateSyncedBlock struct {
+	block      *types.Block
+	atomicRoot common.Hash
+
+	// parents are the ancestors of [block] fetched along with it, newest first.
+	parents []*types.Block
+}
+
+// hashAt returns the hash of the block at [height] on the chain of the state
+// synced block, or false if the block at [height] was not fetched.
+func (s *stateSyncedBlock) hashAt(height uint64) (common.Hash, bool) {
+	number := s.block.NumberU64()
+	switch {
+	case height > number:
+		return common.Hash{}, false
+	case height == number:
+		return s.block.Hash(), true
+	case number-height-1 < uint64(len(s.parents)):
+		return s.parents[number-height-1].Hash(), true
+	default:
+		return common.Hash{}, false
+	}
+}
+
+// initStateSync starts syncing the state from peers in the background if
+// it is enabled and the node has not yet accepted any blocks, or resumes
+// a state sync interrupted by a previous shutdown.
+func (vm *VM) initStateSync() {
+	lastAcceptedHeight := vm.LastAcceptedBlock().Height()
+	resumeRoot := rawdb.ReadSyncRoot(vm.chaindb)
+	if !vm.config.StateSyncEnabled || (lastAcceptedHeight > 0 && resumeRoot == (common.Hash{})) {
+		log.Info("skipping state sync", "enabled", vm.config.StateSyncEnabled, "lastAcceptedHeight", lastAcceptedHeight)
+		return
+	}
+	if resumeRoot != (common.Hash{}) {
+		log.Info("resuming interrupted state sync", "root", resumeRoot, "lastAcceptedHeight", lastAcceptedHeight)
+	}
+
+	syncClient := &stateSyncClient{
+		vm: vm,
+		client: statesyncclient.NewClient(&statesyncclient.ClientConfig{
+			NetworkClient:    vm.client,
+			Codec:            vm.networkCodec,
+			MaxAttempts:      stateSyncMaxAttempts,
+			MaxRetryDelay:    stateSyncMaxRetryDelay,
+			StateSyncVersion: stateSyncMinVersion,
+		}),
+		getValidators: vm.getValidators,
+		minBlocks:     vm.config.StateSyncMinBlocks,
+		sampleSize:    vm.config.StateSyncSampleSize,
+		resumeRoot:    resumeRoot,
+	}
+
+	// cancel the sync on shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-vm.shutdownChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	vm.shutdownWg.Add(1)
+	go vm.ctx.Log.RecoverAndPanic(func() {
+		defer vm.shutdownWg.Done()
+		defer cancel()
+		if err := syncClient.run(ctx); err != nil {
+			log.Error("state sync failed, bootstrapping from the last accepted block", "err", err)
+		}
+	})
+}
+
+// run performs the state sync, returning once the VM has been
+// fast-forwarded to the synced block or the sync is abandoned.
+// If the sync of a syncable block fails, for instance because peers no
+// longer serve its state, the sync moves to a newer syncable block if
+// one is available, reusing the trie nodes already synced.
+func (s *stateSyncClient) run(ctx context.Context) error {
+	if err := s.waitForPeers(ctx); err != nil {
+		return err
+	}
+
+	syncableBlock, err := s.selectSyncableBlock()
+	if err != nil {
+		return err
+	}
+	lastAcceptedHeight := s.vm.LastAcceptedBlock().Height()
+	if syncableBlock.BlockNumber < lastAcceptedHeight+s.minBlocks {
+		log.Info("syncable block is too close to the last accepted block, skipping state sync", "syncableBlock", syncableBlock, "lastAcceptedHeight", lastAcceptedHeight, "minBlocks", s.minBlocks)
+		return s.clearProgress()
+	}
+
+	for {
+		err := s.syncBlock(ctx, syncableBlock)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+
+		newSyncableBlock, selectErr := s.selectSyncableBlock()
+		if selectErr != nil || newSyncableBlock.BlockNumber <= syncableBlock.BlockNumber {
+			return err
+		}
+		log.Warn("state sync failed, moving to a newer syncable block", "err", err, "syncableBlock", syncableBlock, "newSyncableBlock", newSyncableBlock)
+		syncableBlock = newSyncableBlock
+	}
+}
+
+// syncBlock syncs the EVM state and the atomic trie at [syncableBlock] and
+// marks the block to be fast-forwarded to once the bootstrapper accepts it.
+func (s *stateSyncClient) syncBlock(ctx context.Context, syncableBlock message.SyncableBlock) error {
+	log.Info("starting state sync", "syncableBlock", syncableBlock)
+	block, parents, err := s.fetchBlocks(ctx, syncableBlock)
+	if err != nil {
+		return err
+	}
+
+	// The EVM state and the atomic trie are synced concurrently
+	evmSyncer, err := statesync.NewStateSyncer(&statesync.StateSyncerConfig{
+		Client: s.client,
+		DB:     s.vm.chaindb,
+		Root:   syncableBlock.BlockRoot,
+	})
+	if err != nil {
+		return err
+	}
+	atomicSyncer, err := newAtomicSyncer(s.client, s.vm.db, &s.vm.ctx.Lock, s.vm.atomicTrie, syncableBlock.AtomicRoot, syncableBlock.BlockNumber)
+	if err != nil {
+		return err
+	}
+	if err := evmSyncer.Start(ctx); err != nil {
+		return err
+	}
+	if err := atomicSyncer.Start(ctx); err != nil {
+		return err
+	}
+	evmErr, atomicErr := <-evmSyncer.Done(), <-atomicSyncer.Done()
+	if evmErr != nil {
+		return fmt.Errorf("failed to sync EVM state at %s: %w", syncableBlock, evmErr)
+	}
+	if atomicErr != nil {
+		return fmt.Errorf("failed to sync atomic trie at %s: %w", syncableBlock, atomicErr)
+	}
+
+	return s.finish(block, parents, atomicSyncer.targetRoot)
+}
+
+// clearProgress removes the progress markers of an interrupted state sync
+// that will not be resumed.
+func (s *stateSyncClient) clearProgress() error {
+	if s.resumeRoot == (common.Hash{}) {
+		return nil
+	}
+	if err := rawdb.ClearSyncProgress(s.vm.chaindb); err != nil {
+		return err
+	}
+	rawdb.DeleteSyncRoot(s.vm.chaindb)
+	return nil
+}
+
+// waitForPeers blocks until the VM is connected to enough peers to sample
+// [s.sampleSize] of them.
+func (s *stateSyncClient) waitForPeers(ctx context.Context) error {
+	ticker := time.NewTicker(stateSyncPeerWait)
+	defer ticker.Stop()
+
+	for s.vm.Network.Size() < uint32(s.sampleSize) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// selectSyncableBlock requests a syncable block from [s.sampleSize] distinct
+// peers and returns the one served by a stake-weighted quorum of them.
+func (s *stateSyncClient) selectSyncableBlock() (message.SyncableBlock, error) {
+	validators, err := s.getValidators()
+	if err != nil {
+		return message.SyncableBlock{}, fmt.Errorf("failed to get the validator set: %w", err)
+	}
+	syncableBlocks, err := s.client.GetSyncableBlocks(s.sampleSize)
+	if err != nil {
+		return message.SyncableBlock{}, err
+	}
+	if len(syncableBlocks) < s.sampleSize {
+		return message.SyncableBlock{}, fmt.Errorf("requested a syncable block from %d peers, expected %d", len(syncableBlocks), s.sampleSize)
+	}
+	return selectSyncableBlockByStake(syncableBlocks, validators)
+}
+
+// selectSyncableBlockByStake returns the syncable block served by validators
+// holding more than half of the stake of the sampled peers in [syncableBlocks],
+// including the peers that failed to serve a syncable block. Peers that are not
+// in [validators] have no stake. Peers support the same syncable block only if
+// its number, hash, state root and atomic root all match.
+// Returns errNoSyncableBlock if no syncable block reaches the quorum.
+func selectSyncableBlockByStake(syncableBlocks map[ids.ShortID]message.SyncableBlock, validators map[ids.ShortID]uint64) (message.SyncableBlock, error) {
+	var (
+		sampledWeight uint64
+		support       = make(map[message.SyncableBlock]uint64)
+		err           error
+	)
+	for nodeID, syncableBlock := range syncableBlocks {
+		weight := validators[nodeID]
+		if sampledWeight, err = safemath.Add64(sampledWeight, weight); err != nil {
+			return message.SyncableBlock{}, err
+		}
+		if syncableBlock != (message.SyncableBlock{}) {
+			support[syncableBlock] += weight
+		}
+	}
+	for syncableBlock, weight := range support {
+		if weight > sampledWeight-weight {
+			return syncableBlock, nil
+		}
+	}
+	log.Info("no syncable block served by a quorum of the sampled validators", "sampledWeight", sampledWeight, "syncableBlocks", len(support))
+	return message.SyncableBlock{}, errNoSyncableBlock
+}
+
+// getValidators returns the stake of the current validators of the subnet
+// validating the chain.
+func (vm *VM) getValidators() (map[ids.ShortID]uint64, error) {
+	if vm.ctx.ValidatorState == nil {
+		return nil, errNoValidatorState
+	}
+	height, err := vm.ctx.ValidatorState.GetCurrentHeight()
+	if err != nil {
+		return nil, err
+	}
+	return vm.ctx.ValidatorState.GetValidatorSet(height, vm.ctx.SubnetID)
+}
+
+// fetchBlocks retrieves the block described by [syncableBlock] and up to
+// [stateSyncParents] of its ancestors above the genesis block from peers,
+// returning the block and its ancestors, newest first.
+// Checks that the block matches the root and height of [syncableBlock].
+func (s *stateSyncClient) fetchBlocks(ctx context.Context, syncableBlock message.SyncableBlock) (*types.Block, []*types.Block, error) {
+	var (
+		blocks []*types.Block
+		hash   = syncableBlock.BlockHash
+		height = syncableBlock.BlockNumber
+	)
+	for len(blocks) <= stateSyncParents && height > 0 {
+		count := uint64(stateSyncParents + 1 - len(blocks))
+		if count > height {
+			count = height
+		}
+		if count > stateSyncParentsPerRequest {
+			count = stateSyncParentsPerRequest
+		}
+		response, err := s.client.GetBlocks(ctx, hash, height, uint16(count))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch block %s: %w", hash, err)
+		}
+		// The client checks that each block is the parent of the previous one
+		for _, block := range response {
+			if block.NumberU64() != height {
+				return nil, nil, fmt.Errorf("block %s has height %d, expected %d", block.Hash(), block.NumberU64(), height)
+			}
+			blocks = append(blocks, block)
+			hash, height = block.ParentHash(), height-1
+		}
+	}
+	if len(blocks) == 0 {
+		return nil, nil, fmt.Errorf("cannot sync to the genesis block %s", syncableBlock)
+	}
+	block := blocks[0]
+	if block.Root() != syncableBlock.BlockRoot {
+		return nil, nil, fmt.Errorf("block %s (height %d, root %s) does not match %s", block.Hash(), block.NumberU64(), block.Root(), syncableBlock)
+	}
+	return block, blocks[1:], nil
+}
+
+// finish marks the synced [block] to be fast-forwarded to once the bootstrapper
+// accepts the block at its height, which verifies it against the blocks
+// accepted by consensus. Blocks delivered by the bootstrapper up to the height
+// of [block] are accepted without being executed, as long as they are not known
+// to diverge from [block] and its [parents].
+func (s *stateSyncClient) finish(block *types.Block, parents []*types.Block, atomicRoot common.Hash) error {
+	vm := s.vm
+	vm.ctx.Lock.Lock()
+	defer vm.ctx.Lock.Unlock()
+
+	if vm.bootstrapped.GetValue() || vm.LastAcceptedBlock().Height() >= block.NumberU64() {
+		log.Info("bootstrapping passed the synced block, skipping fast-forward", "height", block.NumberU64(), "bootstrapped", vm.bootstrapped.GetValue())
+		return nil
+	}
+
+	vm.stateSyncedBlock = &stateSyncedBlock{
+		block:      block,
+		atomicRoot: atomicRoot,
+		parents:    parents,
+	}
+	log.Info("state sync completed, waiting for the bootstrapper to accept the synced block", "height", block.NumberU64(), "hash", block.Hash(), "root", block.Root())
+	return nil
+}
+
+// skipBootstrappedBlock returns true if [b] is covered by a completed state
+// sync and should be neither executed nor indexed while the bootstrapper
+// catches up to the synced block.
+// If [b] is not on the chain of the state synced block, the state synced block
+// is abandoned and bootstrapping continues by executing the blocks accepted
+// without execution so far.
+// Assumes the caller holds the context lock.
+func (vm *VM) skipBootstrappedBlock(b *Block) (bool, error) {
+	synced := vm.stateSyncedBlock
+	if vm.bootstrapped.GetValue() || synced == nil || b.Height() > synced.block.NumberU64() {
+		return false, nil
+	}
+	hash, ok := synced.hashAt(b.Height())
+	if !ok || hash == b.ethBlock.Hash() {
+		return true, nil
+	}
+	log.Warn("bootstrapped block is not on the chain of the state synced block, abandoning state synced block", "height", b.Height(), "hash", b.ethBlock.Hash(), "expectedHash", hash, "syncedBlock", synced.block.Hash())
+	return false, vm.abandonStateSyncedBlock(b.ethBlock.ParentHash(), b.Height()-1)
+}
+
+// acceptSkippedBlock accepts [b] without executing it, since its state is
+// covered by the state synced block. [b] is stored such that it can still be
+// executed if the state synced block is abandoned.
+// Assumes the caller holds the context lock.
+func (vm *VM) acceptSkippedBlock(b *Block) error {
+	if b.Height() == vm.stateSyncedBlock.block.NumberU64() {
+		return vm.acceptStateSyncedBlock(b)
+	}
+	rawdb.WriteBlock(vm.chaindb, b.ethBlock)
+	return nil
+}
+
+// acceptStateSyncedBlock is called when the bootstrapper accepts [b], which was
+// verified to be the state synced block. The VM is fast-forwarded to it, writing
+// it and its ancestors as the canonical chain and resetting the chain to its
+// state and the atomic trie to the synced root.
+// Assumes the caller holds the context lock.
+func (vm *VM) acceptStateSyncedBlock(b *Block) error {
+	synced := vm.stateSyncedBlock
+	vm.stateSyncedBlock = nil
+
+	block := synced.block
+	if b.ethBlock.Hash() != block.Hash() {
+		return fmt.Errorf("accepted block %s at height %d does not match state synced block %s", b.ethBlock.Hash(), b.Height(), block.Hash())
+	}
+	// The atomic operations of the blocks accepted without execution are
+	// applied to shared memory from the synced atomic trie.
+	lastExecutedHeight := vm.chain.LastAcceptedBlock().NumberU64()
+
+	batch := vm.chaindb.NewBatch()
+	for _, blk := range append([]*types.Block{block}, synced.parents...) {
+		rawdb.WriteBlock(batch, blk)
+		rawdb.WriteCanonicalHash(batch, blk.Hash(), blk.NumberU64())
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if err := vm.chain.BlockChain().ResetState(block); err != nil {
+		return fmt.Errorf("failed to reset chain to synced block %s: %w", block.Hash(), err)
+	}
+
+	blockID := ids.ID(block.Hash())
+	if err := vm.acceptedBlockDB.Put(lastAcceptedKey, blockID[:]); err != nil {
+		return err
+	}
+	if err := vm.finishAtomicSync(synced.atomicRoot, block.NumberU64(), lastExecutedHeight); err != nil {
+		return fmt.Errorf("failed to reset atomic trie to synced root %s: %w", synced.atomicRoot, err)
+	}
+
+	log.Info("fast-forwarded to state synced block", "height", block.NumberU64(), "hash", block.Hash(), "root", block.Root())
+	return nil
+}
+
+// abandonStateSyncedBlock discards the state synced block once the bootstrapper
+// delivers a block that is not on its chain, and executes the blocks accepted
+// without execution up to the block with [hash] at [height], such that
+// bootstrapping continues as if the state sync had not completed.
+// Assumes the caller holds the context lock.
+func (vm *VM) abandonStateSyncedBlock(hash common.Hash, height uint64) error {
+	vm.stateSyncedBlock = nil
+	// Do not resume syncing the state of the abandoned block after a restart
+	if err := rawdb.ClearSyncProgress(vm.chaindb); err != nil {
+		return err
+	}
+	rawdb.DeleteSyncRoot(vm.chaindb)
+
+	lastExecuted := vm.chain.LastAcceptedBlock()
+	var skipped []*types.Block
+	for height > lastExecuted.NumberU64() {
+		block := rawdb.ReadBlock(vm.chaindb, hash, height)
+		if block == nil {
+			return fmt.Errorf("block %s at height %d accepted during state sync not found", hash, height)
+		}
+		skipped = append(skipped, block)
+		hash, height = block.ParentHash(), height-1
+	}
+	if hash != lastExecuted.Hash() {
+		return fmt.Errorf("blocks accepted during state sync do not descend from the last executed block %s", lastExecuted.Hash())
+	}
+
+	log.Info("executing blocks accepted during state sync", "from", lastExecuted.NumberU64()+1, "count", len(skipped))
+	for i := len(skipped) - 1; i >= 0; i-- {
+		blk, err := vm.newBlock(skipped[i])
+		if err != nil {
+			return err
+		}
+		if err := blk.verify(true); err != nil {
+			return fmt.Errorf("failed to verify block %s accepted during state sync: %w", blk.ID(), err)
+		}
+		if err := blk.Accept(); err != nil {
+			return fmt.Errorf("failed to accept block %s accepted during state sync: %w", blk.ID(), err)
+		}
+	}
+	return nil
+}
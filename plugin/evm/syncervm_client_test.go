@@ -0,0 +1,320 @@
+// (c) 2021-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package evm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow"
+	"github.com/ava-labs/avalanchego/vms/components/chain"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/params"
+	"github.com/ava-labs/coreth/plugin/evm/message"
+	statesyncclient "github.com/ava-labs/coreth/statesync/client"
+	"github.com/ava-labs/coreth/statesync/handlers"
+	"github.com/ava-labs/coreth/statesync/handlers/stats"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestSelectSyncableBlockByStake(t *testing.T) {
+	var (
+		nodeIDs = []ids.ShortID{{1}, {2}, {3}, {4}}
+		honest  = message.SyncableBlock{BlockNumber: 4096, BlockRoot: common.Hash{1}, AtomicRoot: common.Hash{2}, BlockHash: common.Hash{3}}
+		// a syncable block differing from [honest] only by its atomic root
+		forged = message.SyncableBlock{BlockNumber: 4096, BlockRoot: common.Hash{1}, AtomicRoot: common.Hash{4}, BlockHash: common.Hash{3}}
+	)
+	tests := map[string]struct {
+		syncableBlocks map[ids.ShortID]message.SyncableBlock
+		validators     map[ids.ShortID]uint64
+		expected       message.SyncableBlock
+		expectedErr    error
+	}{
+		"majority of stake": {
+			syncableBlocks: map[ids.ShortID]message.SyncableBlock{nodeIDs[0]: honest, nodeIDs[1]: honest, nodeIDs[2]: forged},
+			validators:     map[ids.ShortID]uint64{nodeIDs[0]: 10, nodeIDs[1]: 10, nodeIDs[2]: 10},
+			expected:       honest,
+		},
+		"single peer with most of the stake": {
+			syncableBlocks: map[ids.ShortID]message.SyncableBlock{nodeIDs[0]: honest, nodeIDs[1]: forged, nodeIDs[2]: forged},
+			validators:     map[ids.ShortID]uint64{nodeIDs[0]: 100, nodeIDs[1]: 10, nodeIDs[2]: 10},
+			expected:       honest,
+		},
+		"votes of peers without stake are ignored": {
+			syncableBlocks: map[ids.ShortID]message.SyncableBlock{nodeIDs[0]: honest, nodeIDs[1]: forged, nodeIDs[2]: forged, nodeIDs[3]: forged},
+			validators:     map[ids.ShortID]uint64{nodeIDs[0]: 10},
+			expected:       honest,
+		},
+		"failed peers count against the quorum": {
+			syncableBlocks: map[ids.ShortID]message.SyncableBlock{nodeIDs[0]: forged, nodeIDs[1]: {}, nodeIDs[2]: {}},
+			validators:     map[ids.ShortID]uint64{nodeIDs[0]: 10, nodeIDs[1]: 10, nodeIDs[2]: 10},
+			expectedErr:    errNoSyncableBlock,
+		},
+		"half of the stake is not a quorum": {
+			syncableBlocks: map[ids.ShortID]message.SyncableBlock{nodeIDs[0]: honest, nodeIDs[1]: forged},
+			validators:     map[ids.ShortID]uint64{nodeIDs[0]: 10, nodeIDs[1]: 10},
+			expectedErr:    errNoSyncableBlock,
+		},
+		"no stake sampled": {
+			syncableBlocks: map[ids.ShortID]message.SyncableBlock{nodeIDs[0]: honest, nodeIDs[1]: honest},
+			validators:     map[ids.ShortID]uint64{nodeIDs[2]: 10},
+			expectedErr:    errNoSyncableBlock,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			syncableBlock, err := selectSyncableBlockByStake(test.syncableBlocks, test.validators)
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, syncableBlock)
+		})
+	}
+}
+
+// genesisWithFunds returns [genesisJSON] allocating funds to [addr].
+func genesisWithFunds(t *testing.T, genesisJSON string, addr common.Address) string {
+	genesis := &core.Genesis{}
+	if err := json.Unmarshal([]byte(genesisJSON), genesis); err != nil {
+		t.Fatal(err)
+	}
+	genesis.Alloc[addr] = core.GenesisAccount{Balance: new(big.Int).Mul(big.NewInt(params.Ether), big.NewInt(100))}
+	genesisBytes, err := json.Marshal(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(genesisBytes)
+}
+
+// buildBlockWithTxs adds [txs] to the tx pool of [vm] and builds, verifies and
+// accepts a block including them. The block is built without waiting for the
+// block builder to notify the engine.
+func buildBlockWithTxs(t *testing.T, vm *VM, txs ...*types.Transaction) *types.Block {
+	newTxPoolHeadChan := make(chan core.NewTxPoolReorgEvent, 1)
+	sub := vm.chain.GetTxPool().SubscribeNewReorgEvent(newTxPoolHeadChan)
+	defer sub.Unsubscribe()
+
+	for i, err := range vm.chain.AddRemoteTxsSync(txs) {
+		if err != nil {
+			t.Fatalf("failed to add tx at index %d: %s", i, err)
+		}
+	}
+	// Leave enough time since the parent for the block gas cost to drop
+	vm.clock.Set(vm.clock.Time().Add(10 * time.Second))
+	blk, err := vm.BuildBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := blk.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.SetPreference(blk.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if err := blk.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	<-newTxPoolHeadChan
+	return blk.(*chain.BlockWrapper).Block.(*Block).ethBlock
+}
+
+// newSyncServerVM returns a bootstrapped VM with [numBlocks] accepted blocks,
+// each transferring funds from testEthAddrs[0], and the genesis of the VM.
+func newSyncServerVM(t *testing.T, numBlocks int) (*VM, []*types.Block, string) {
+	genesisJSON := genesisWithFunds(t, genesisJSONApricotPhase5, testEthAddrs[0])
+	_, vm, _, _, _ := GenesisVM(t, true, genesisJSON, "", "")
+	// Build the blocks in the past, so that they are not too far in the future
+	// for the clients
+	vm.clock.Set(time.Now().Add(-time.Hour))
+	blocks := make([]*types.Block, 0, numBlocks)
+	for i := 0; i < numBlocks; i++ {
+		tx := types.NewTransaction(uint64(i), testEthAddrs[1], big.NewInt(1), params.TxGas, big.NewInt(params.LaunchMinGasPrice), nil)
+		signedTx, err := types.SignTx(tx, types.NewEIP155Signer(vm.chainID), testKeys[0].ToECDSA())
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, buildBlockWithTxs(t, vm, signedTx))
+	}
+	return vm, blocks, genesisJSON
+}
+
+// syncFromServer syncs the state of [clientVM] to [block] from [serverVM],
+// marking [block] to be fast-forwarded to.
+func syncFromServer(t *testing.T, serverVM, clientVM *VM, block *types.Block) {
+	codec, err := message.BuildCodec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverChain := serverVM.chain.BlockChain()
+	handlerStats := stats.NewNoopHandlerStats()
+	syncClient := &stateSyncClient{
+		vm: clientVM,
+		client: statesyncclient.NewMockClient(
+			codec,
+			handlers.NewLeafsRequestHandler(serverChain.StateCache().TrieDB(), handlerStats, codec),
+			handlers.NewCodeRequestHandler(serverVM.chaindb, handlerStats, codec),
+			handlers.NewBlockRequestHandler(serverChain.GetBlock, codec, handlerStats),
+			nil,
+		),
+	}
+	// The tests hold the context lock, which the sync acquires once done
+	clientVM.ctx.Lock.Unlock()
+	err = syncClient.syncBlock(context.Background(), message.SyncableBlock{
+		BlockNumber: block.NumberU64(),
+		BlockRoot:   block.Root(),
+		BlockHash:   block.Hash(),
+	})
+	clientVM.ctx.Lock.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// bootstrapBlock parses, verifies and accepts [block] in [vm] as the bootstrapper would.
+func bootstrapBlock(vm *VM, block *types.Block) error {
+	blockBytes, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		return err
+	}
+	blk, err := vm.ParseBlock(blockBytes)
+	if err != nil {
+		return err
+	}
+	if err := blk.Verify(); err != nil {
+		return err
+	}
+	return blk.Accept()
+}
+
+func TestStateSyncBlockHash(t *testing.T) {
+	serverVM, serverBlocks, genesisJSON := newSyncServerVM(t, 20)
+	defer func() {
+		assert.NoError(t, serverVM.Shutdown())
+	}()
+	_, clientVM, _, _, _ := GenesisVM(t, false, genesisJSON, "", "")
+	defer func() {
+		assert.NoError(t, clientVM.Shutdown())
+	}()
+	assert.NoError(t, clientVM.SetState(snow.Bootstrapping))
+
+	synced := serverBlocks[len(serverBlocks)-1]
+	syncFromServer(t, serverVM, clientVM, synced)
+	assert.Len(t, clientVM.stateSyncedBlock.parents, len(serverBlocks)-1, "ancestors above the genesis should be fetched")
+
+	// Deliver only the synced block, such that its ancestors are only
+	// available from state sync
+	assert.NoError(t, bootstrapBlock(clientVM, synced))
+	assert.NoError(t, clientVM.SetState(snow.NormalOp))
+	assert.Equal(t, synced.Hash(), clientVM.chain.LastAcceptedBlock().Hash())
+	for _, block := range serverBlocks {
+		assert.Equal(t, block.Hash(), rawdb.ReadCanonicalHash(clientVM.chaindb, block.NumberU64()))
+	}
+
+	// The next block stores the hash of the block 10 blocks below it in the
+	// storage of a new contract, such that its state root only matches if
+	// BLOCKHASH returns the same hash in the client as in the server.
+	initCode := []byte{
+		0x60, 10, // PUSH1 10
+		0x43,    // NUMBER
+		0x03,    // SUB
+		0x40,    // BLOCKHASH
+		0x60, 0, // PUSH1 0
+		0x55, // SSTORE
+		0x00, // STOP
+	}
+	nonce := uint64(len(serverBlocks))
+	tx := types.NewContractCreation(nonce, big.NewInt(0), 100_000, big.NewInt(params.LaunchMinGasPrice), initCode)
+	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(serverVM.chainID), testKeys[0].ToECDSA())
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := buildBlockWithTxs(t, serverVM, signedTx)
+	assert.NoError(t, bootstrapBlock(clientVM, next))
+	assert.Equal(t, next.Hash(), clientVM.chain.LastAcceptedBlock().Hash())
+
+	statedb, err := clientVM.chain.BlockChain().StateAt(next.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract := crypto.CreateAddress(testEthAddrs[0], nonce)
+	assert.Equal(t, serverBlocks[next.NumberU64()-11].Hash(), statedb.GetState(contract, common.Hash{}))
+}
+
+func TestStateSyncedBlockAbandoned(t *testing.T) {
+	serverVM, serverBlocks, genesisJSON := newSyncServerVM(t, 10)
+	defer func() {
+		assert.NoError(t, serverVM.Shutdown())
+	}()
+
+	lastBlock := serverBlocks[len(serverBlocks)-1]
+	forgedHeader := func(block *types.Block) *types.Block {
+		header := block.Header()
+		header.Extra = []byte("forged")
+		return types.NewBlockWithHeader(header)
+	}
+	tests := map[string]struct {
+		synced          *types.Block
+		parents         []*types.Block
+		divergingHeight uint64
+	}{
+		"at the synced height": {
+			synced:          forgedHeader(lastBlock),
+			parents:         []*types.Block{serverBlocks[8], serverBlocks[7], serverBlocks[6]},
+			divergingHeight: 10,
+		},
+		"below the synced height": {
+			synced:          forgedHeader(lastBlock),
+			parents:         []*types.Block{serverBlocks[8], forgedHeader(serverBlocks[7])},
+			divergingHeight: 8,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, clientVM, _, _, _ := GenesisVM(t, false, genesisJSON, "", "")
+			defer func() {
+				assert.NoError(t, clientVM.Shutdown())
+			}()
+			assert.NoError(t, clientVM.SetState(snow.Bootstrapping))
+			genesisHash := clientVM.chain.LastAcceptedBlock().Hash()
+
+			clientVM.stateSyncedBlock = &stateSyncedBlock{block: test.synced, parents: test.parents}
+			rawdb.WriteSyncRoot(clientVM.chaindb, test.synced.Root())
+
+			// blocks covered by the state sync are still verified syntactically
+			invalid := serverBlocks[0].Header()
+			invalid.Difficulty = big.NewInt(2)
+			invalidBlock := &Block{ethBlock: types.NewBlockWithHeader(invalid), vm: clientVM}
+			assert.Error(t, invalidBlock.Verify())
+
+			// bootstrapping cannot finish before the synced block is accepted
+			assert.True(t, errors.Is(clientVM.SetState(snow.NormalOp), errSyncedBlockPending))
+
+			for _, block := range serverBlocks {
+				assert.NoError(t, bootstrapBlock(clientVM, block))
+				if block.NumberU64() < test.divergingHeight {
+					assert.Equal(t, genesisHash, clientVM.chain.LastAcceptedBlock().Hash(), "blocks covered by the state sync should not be executed")
+				} else {
+					assert.Equal(t, block.Hash(), clientVM.chain.LastAcceptedBlock().Hash(), "blocks should be executed once the synced block is abandoned")
+				}
+			}
+			assert.Nil(t, clientVM.stateSyncedBlock)
+			assert.Equal(t, common.Hash{}, rawdb.ReadSyncRoot(clientVM.chaindb), "sync progress should be discarded")
+			assert.True(t, clientVM.chain.BlockChain().HasState(lastBlock.Root()))
+			assert.NoError(t, clientVM.SetState(snow.NormalOp))
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// (c) 2021-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package evm
+
+import (
+	"github.com/ava-labs/coreth/plugin/evm/message"
+	"github.com/ava-labs/coreth/statesync/handlers"
+	syncStats "github.com/ava-labs/coreth/statesync/handlers/stats"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// newHandlerStats returns the stats reported by the handlers of inbound requests.
+func newHandlerStats() syncStats.HandlerStats {
+	if metrics.Enabled {
+		return syncStats.NewHandlerStats()
+	}
+	return syncStats.NewNoopHandlerStats()
+}
+
+// initStateSyncServer registers the handlers serving the state sync requests
+// of other nodes on [vm.Network].
+func (vm *VM) initStateSyncServer(handlerStats syncStats.HandlerStats) {
+	blockChain := vm.chain.BlockChain()
+	vm.Network.SetRequestHandler(handlers.NewSyncHandler(
+		handlers.NewLeafsRequestHandler(blockChain.StateCache().TrieDB(), handlerStats, vm.networkCodec),
+		handlers.NewLeafsRequestHandler(vm.atomicTrie.TrieDB(), handlerStats, vm.networkCodec),
+		handlers.NewBlockRequestHandler(blockChain.GetBlock, vm.networkCodec, handlerStats),
+		handlers.NewCodeRequestHandler(vm.chaindb, handlerStats, vm.networkCodec),
+		handlers.NewSyncableBlockRequestHandler(vm.getSyncableBlock, vm.networkCodec, handlerStats),
+	))
+}
+
+// getSyncableBlock returns the most recent block this node can serve as a
+// state sync target. This is the block at the last committed height of the
+// atomic trie, which is also the most recent height at which the EVM state
+// trie was committed to disk.
+// Returns false if there is no such block.
+func (vm *VM) getSyncableBlock() (message.SyncableBlock, bool) {
+	atomicRoot, height := vm.atomicTrie.LastCommitted()
+	if height == 0 {
+		return message.SyncableBlock{}, false
+	}
+
+	blockChain := vm.chain.BlockChain()
+	block := blockChain.GetBlockByNumber(height)
+	if block == nil {
+		log.Debug("syncable block not found", "height", height)
+		return message.SyncableBlock{}, false
+	}
+	if !blockChain.HasState(block.Root()) {
+		log.Debug("state of syncable block not available", "height", height, "root", block.Root())
+		return message.SyncableBlock{}, false
+	}
+
+	return message.SyncableBlock{
+		BlockNumber: height,
+		BlockRoot:   block.Root(),
+		AtomicRoot:  atomicRoot,
+		BlockHash:   block.Hash(),
+	}, true
+}
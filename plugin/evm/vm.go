@@ -15,6 +15,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ava-labs/coreth/plugin/evm/message"
@@ -23,9 +24,11 @@ import (
 	coreth "github.com/ava-labs/coreth/chain"
 	"github.com/ava-labs/coreth/consensus/dummy"
 	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/state"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/eth/ethconfig"
+	"github.com/ava-labs/coreth/ethdb"
 	corethPrometheus "github.com/ava-labs/coreth/metrics/prometheus"
 	"github.com/ava-labs/coreth/node"
 	"github.com/ava-labs/coreth/params"
@@ -33,11 +36,12 @@ import (
 	"github.com/ava-labs/coreth/rpc"
 
 	"github.com/prometheus/client_golang/prometheus"
-	// Force-load tracer engine to trigger registration
+	// Force-load tracer engines to trigger registration
 	//
-	// We must import this package (not referenced elsewhere) so that the native "callTracer"
-	// is added to a map of client-accessible tracers. In geth, this is done
-	// inside of cmd/geth.
+	// We must import these packages (not referenced elsewhere) so that the native "callTracer"
+	// and the JavaScript engine evaluating custom tracers are added to the client-accessible
+	// tracers. In geth, this is done inside of cmd/geth.
+	_ "github.com/ava-labs/coreth/eth/tracers/js"
 	_ "github.com/ava-labs/coreth/eth/tracers/native"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -60,6 +64,7 @@ import (
 	"github.com/ava-labs/avalanchego/snow/consensus/snowman"
 	"github.com/ava-labs/avalanchego/snow/engine/snowman/block"
 
+	"github.com/ava-labs/avalanchego/utils"
 	//"github.com/ava-labs/avalanchego/utils/constants"
 	"github.com/ava-labs/avalanchego/utils/constants"
 	"github.com/ava-labs/avalanchego/utils/crypto"
@@ -124,6 +129,10 @@ const (
 	ethWSEndpoint  = "/ws"
 )
 
+// chainDBMetricsPrefix is the prefix of the metrics reported by the chain
+// database and its freezer.
+const chainDBMetricsPrefix = "chaindb/"
+
 var (
 	// Set last accepted key to be longer than the keys used to store accepted block IDs.
 	lastAcceptedKey = []byte("last_accepted_key")
@@ -201,8 +210,9 @@ type VM struct {
 	chainConfig *params.ChainConfig
 	// [db] is the VM's current database managed by ChainState
 	db *versiondb.Database
-	// [chaindb] is the database supplied to the Ethereum backend
-	chaindb Database
+	// [chaindb] is the database supplied to the Ethereum backend, which is
+	// closed by the VM on shutdown
+	chaindb ethdb.Database
 	// [acceptedBlockDB] is the database to store the last accepted
 	// block.
 	acceptedBlockDB database.Database
@@ -240,7 +250,18 @@ type VM struct {
 	// Metrics
 	multiGatherer avalanchegoMetrics.MultiGatherer
 
-	bootstrapped bool
+	// [stateSyncedBlock] is the block whose state was fetched by state sync
+	// until the bootstrapper accepts the block at its height, or nil.
+	stateSyncedBlock *stateSyncedBlock
+
+	// [lastAcceptedTime] holds the time.Time the last block was accepted, or the
+	// time the VM was initialized if no block has been accepted since. It is
+	// read by the health check without holding the context lock.
+	lastAcceptedTime atomic.Value
+	// [txPoolCapacity] is the number of transactions the tx pool can hold.
+	txPoolCapacity uint64
+
+	bootstrapped utils.AtomicBool // read by the health check without holding the context lock
 	IsPlugin     bool
 }
 
@@ -355,7 +376,23 @@ func (vm *VM) Initialize(
 	baseDB := dbManager.Current().Database
 	// Use NewNested rather than New so that the structure of the database
 	// remains the same regardless of the provided baseDB type.
-	vm.chaindb = Database{prefixdb.NewNested(ethDBPrefix, baseDB)}
+	// The chain database is stored in baseDB unless another key-value store is
+	// configured. Note the other databases of the VM remain in baseDB, so the
+	// database-type of a node cannot be changed without bootstrapping again.
+	switch {
+	case vm.config.DatabaseType == pebbleDatabaseType && vm.config.FreezerDirectory != "":
+		vm.chaindb, err = rawdb.NewPebbleDBDatabaseWithFreezer(vm.config.DatabaseDirectory, vm.config.DatabaseCache, vm.config.DatabaseHandles, vm.config.FreezerDirectory, chainDBMetricsPrefix, false, vm.config.FreezerThreshold)
+	case vm.config.DatabaseType == pebbleDatabaseType:
+		vm.chaindb, err = rawdb.NewPebbleDBDatabase(vm.config.DatabaseDirectory, vm.config.DatabaseCache, vm.config.DatabaseHandles, chainDBMetricsPrefix, false)
+	case vm.config.FreezerDirectory != "":
+		chaindb := Database{prefixdb.NewNested(ethDBPrefix, baseDB)}
+		vm.chaindb, err = rawdb.NewDatabaseWithFreezer(chaindb, vm.config.FreezerDirectory, chainDBMetricsPrefix, false, vm.config.FreezerThreshold)
+	default:
+		vm.chaindb = rawdb.NewDatabase(Database{prefixdb.NewNested(ethDBPrefix, baseDB)})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open chain database: %w", err)
+	}
 	vm.db = versiondb.New(baseDB)
 	vm.acceptedBlockDB = prefixdb.New(acceptedPrefix, vm.db)
 	g := new(core.Genesis)
@@ -392,18 +429,34 @@ func (vm *VM) Initialize(
 	ethConfig.RPCEVMTimeout = vm.config.APIMaxDuration.Duration
 	ethConfig.RPCTxFeeCap = vm.config.RPCTxFeeCap
 	ethConfig.TxPool.NoLocals = !vm.config.LocalTxsEnabled
+	vm.txPoolCapacity = ethConfig.TxPool.GlobalSlots + ethConfig.TxPool.GlobalQueue
 	ethConfig.AllowUnfinalizedQueries = vm.config.AllowUnfinalizedQueries
 	ethConfig.AllowUnprotectedTxs = vm.config.AllowUnprotectedTxs
 	ethConfig.Preimages = vm.config.Preimages
 	ethConfig.Pruning = vm.config.Pruning
+	ethConfig.CommitInterval = vm.config.CommitInterval
+	// Syncable blocks are served at the commit heights of the atomic trie
+	ethConfig.SyncableInterval = commitHeightInterval
+	ethConfig.TipBufferSize = vm.config.TipBufferSize
+	ethConfig.StateDiffs = vm.config.StateDiffs
+	ethConfig.TrieCleanCache = vm.config.TrieCleanCache
+	ethConfig.TrieDirtyCache = vm.config.TrieDirtyCache
+	ethConfig.TrieDirtyCommitTarget = vm.config.TrieDirtyCommitTarget
+	ethConfig.SnapshotCache = vm.config.SnapshotCache
 	ethConfig.PopulateMissingTries = vm.config.PopulateMissingTries
 	ethConfig.PopulateMissingTriesParallelism = vm.config.PopulateMissingTriesParallelism
 	ethConfig.AllowMissingTries = vm.config.AllowMissingTries
 	ethConfig.SnapshotAsync = vm.config.SnapshotAsync
 	ethConfig.SnapshotVerify = vm.config.SnapshotVerify
+	ethConfig.AcceptorQueueLimit = vm.config.AcceptorQueueLimit
+	ethConfig.TxLookupLimit = vm.config.TxLookupLimit
 	ethConfig.OfflinePruning = vm.config.OfflinePruning
 	ethConfig.OfflinePruningBloomFilterSize = vm.config.OfflinePruningBloomFilterSize
 	ethConfig.OfflinePruningDataDirectory = vm.config.OfflinePruningDataDirectory
+	ethConfig.TrieRegeneration = vm.config.TrieRegeneration
+	ethConfig.OnlinePruningBloomFilterSize = vm.config.OnlinePruningBloomFilterSize
+	ethConfig.OnlinePruningThrottle = vm.config.OnlinePruningThrottle.Duration
+	ethConfig.StandardTraceDirectory = vm.config.StandardTraceDirectory
 
 	// Create directory for offline pruning
 	if len(ethConfig.OfflinePruningDataDirectory) != 0 {
@@ -413,6 +466,14 @@ func (vm *VM) Initialize(
 		}
 	}
 
+	// Create directory for standard traces
+	if len(ethConfig.StandardTraceDirectory) != 0 {
+		if err := os.MkdirAll(ethConfig.StandardTraceDirectory, perms.ReadWriteExecute); err != nil {
+			log.Error("failed to create standard trace directory", "error", err)
+			return err
+		}
+	}
+
 	vm.chainConfig = g.Config
 	vm.networkID = ethConfig.NetworkId
 	vm.secpFactory = crypto.FactorySECP256K1R{Cache: cache.LRU{Size: secpFactoryCacheSize}}
@@ -482,9 +543,12 @@ func (vm *VM) Initialize(
 	}
 
 	// initialize peer network
-	vm.Network = peer.NewNetwork(appSender, vm.networkCodec, ctx.NodeID, vm.config.MaxOutboundActiveRequests)
+	handlerStats := newHandlerStats()
+	throttler := peer.NewInboundThrottler(vm.config.InboundThrottlerConfig(), handlerStats)
+	vm.Network = peer.NewNetwork(appSender, vm.networkCodec, ctx.NodeID, vm.config.MaxOutboundActiveRequests, throttler)
 	vm.client = peer.NewClient(vm.Network)
 	vm.initGossipHandling()
+	vm.initStateSyncServer(handlerStats)
 
 	// start goroutines to manage block building
 	//
@@ -495,6 +559,7 @@ func (vm *VM) Initialize(
 	vm.chain.Start()
 
 	vm.genesisHash = vm.chain.GetGenesisBlock().Hash()
+	vm.lastAcceptedTime.Store(vm.clock.Time())
 	log.Info(fmt.Sprintf("lastAccepted = %s", lastAccepted.Hash().Hex()))
 
 	isApricotPhase5 := vm.chainConfig.IsApricotPhase5(new(big.Int).SetUint64(lastAccepted.Time()))
@@ -518,6 +583,7 @@ func (vm *VM) Initialize(
 
 	vm.builder.awaitSubmittedTxs()
 	go vm.ctx.Log.RecoverAndPanic(vm.startContinuousProfiler)
+	vm.initStateSync()
 
 	// The Codec explicitly registers the types it requires from the secp256k1fx
 	// so [vm.baseCodec] is a dummy codec use to fulfill the secp256k1fx VM
@@ -814,10 +880,13 @@ func (vm *VM) pruneChain() error {
 func (vm *VM) SetState(state snow.State) error {
 	switch state {
 	case snow.Bootstrapping:
-		vm.bootstrapped = false
+		vm.bootstrapped.SetValue(false)
 		return vm.fx.Bootstrapping()
 	case snow.NormalOp:
-		vm.bootstrapped = true
+		if vm.stateSyncedBlock != nil {
+			return fmt.Errorf("%w at height %d", errSyncedBlockPending, vm.stateSyncedBlock.block.NumberU64())
+		}
+		vm.bootstrapped.SetValue(true)
 		return vm.fx.Bootstrapped()
 	default:
 		return snow.ErrUnknownState
@@ -833,6 +902,11 @@ func (vm *VM) Shutdown() error {
 	close(vm.shutdownChan)
 	vm.chain.Stop()
 	vm.shutdownWg.Wait()
+	// Close [chaindb] only once the goroutines of the VM, which may still
+	// access it, have stopped.
+	if err := vm.chaindb.Close(); err != nil {
+		return fmt.Errorf("failed to close chain database: %w", err)
+	}
 	return nil
 }
 
@@ -919,19 +993,23 @@ func (vm *VM) getBlock(id ids.ID) (snowman.Block, error) {
 	if ethBlock == nil {
 		return nil, database.ErrNotFound
 	}
+	// Note: the status of block is set by ChainState
+	return vm.newBlock(ethBlock)
+}
+
+// newBlock wraps [ethBlock] with the atomic transactions it contains.
+func (vm *VM) newBlock(ethBlock *types.Block) (*Block, error) {
 	isApricotPhase5 := vm.chainConfig.IsApricotPhase5(new(big.Int).SetUint64(ethBlock.Time()))
 	atomicTxs, err := ExtractAtomicTxs(ethBlock.ExtData(), isApricotPhase5, vm.codec)
 	if err != nil {
 		return nil, err
 	}
-	// Note: the status of block is set by ChainState
-	blk := &Block{
+	return &Block{
 		id:        ids.ID(ethBlock.Hash()),
 		ethBlock:  ethBlock,
 		vm:        vm,
 		atomicTxs: atomicTxs,
-	}
-	return blk, nil
+	}, nil
 }
 
 // SetPreference sets what the current tail of the chain is
@@ -1197,7 +1275,11 @@ func (vm *VM) verifyTxAtTip(tx *Tx) error {
 	timestamp := vm.clock.Time().Unix()
 	bigTimestamp := big.NewInt(timestamp)
 	if vm.chainConfig.IsApricotPhase3(bigTimestamp) {
-		_, nextBaseFee, err = dummy.EstimateNextBaseFee(vm.chainConfig, parentHeader, uint64(timestamp))
+		feeConfig, err := vm.chain.BlockChain().GetFeeConfigAt(parentHeader)
+		if err != nil {
+			return fmt.Errorf("failed to get fee config at parent %s: %w", parentHeader.Hash(), err)
+		}
+		_, nextBaseFee, err = dummy.EstimateNextBaseFee(vm.chainConfig, feeConfig, parentHeader, uint64(timestamp))
 		if err != nil {
 			// Return extremely detailed error since CalcBaseFee should never encounter an issue here
 			return fmt.Errorf("failed to calculate base fee with parent timestamp (%d), parent ExtraData: (0x%x), and current timestamp (%d): %w", parentHeader.Time, parentHeader.Extra, timestamp, err)
@@ -46,6 +46,7 @@ import (
 
 	"github.com/ava-labs/coreth/consensus/dummy"
 	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/eth"
 	"github.com/ava-labs/coreth/params"
@@ -330,6 +331,89 @@ func TestVMContinuosProfiler(t *testing.T) {
 	assert.NoError(t, err, "Expected continuous profiler to generate the first CPU profile at %s", expectedFileName)
 }
 
+func TestVMPebbleChainDatabase(t *testing.T) {
+	dbDir := t.TempDir()
+	configJSON := fmt.Sprintf("{\"database-type\": \"pebble\",\"database-directory\": %q}", dbDir)
+	_, vm, dbManager, _, _ := GenesisVM(t, false, genesisJSONApricotPhase0, configJSON, "")
+	genesisHash := vm.chain.GetGenesisBlock().Hash()
+	assert.NoError(t, vm.Shutdown())
+
+	// The chain must be stored in the pebble database rather than in the
+	// database provided by avalanchego.
+	chaindb := rawdb.NewDatabase(Database{prefixdb.NewNested(ethDBPrefix, dbManager.Current().Database)})
+	assert.Equal(t, common.Hash{}, rawdb.ReadCanonicalHash(chaindb, 0))
+
+	// Shutdown must release the pebble database so it can be opened again.
+	pebbledb, err := rawdb.NewPebbleDBDatabase(dbDir, 0, 0, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pebbledb.Close()
+	assert.Equal(t, genesisHash, rawdb.ReadCanonicalHash(pebbledb, 0))
+}
+
+func TestTrieRegeneration(t *testing.T) {
+	issuer, vm, dbManager, _, _ := GenesisVMWithUTXOs(t, true, genesisJSONApricotPhase2, "{\"snapshot-async\": false}", "", map[ids.ShortID]uint64{
+		testShortIDAddrs[0]: 20000000,
+	})
+	importTx, err := vm.newImportTx(vm.ctx.XChainID, testEthAddrs[0], initialBaseFee, []*crypto.PrivateKeySECP256K1R{testKeys[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.issueTx(importTx, true /*=local*/); err != nil {
+		t.Fatal(err)
+	}
+	<-issuer
+	blk, err := vm.BuildBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := blk.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.SetPreference(blk.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if err := blk.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	root := blk.(*chain.BlockWrapper).Block.(*Block).ethBlock.Root()
+	assert.NoError(t, vm.Shutdown())
+
+	// Lose the root node of the state trie of the snapshot block
+	chaindb := rawdb.NewDatabase(Database{prefixdb.NewNested(ethDBPrefix, dbManager.Current().Database)})
+	assert.Equal(t, root, rawdb.ReadSnapshotRoot(chaindb))
+	assert.Equal(t, common.Hash(blk.ID()), rawdb.ReadSnapshotBlockHash(chaindb))
+	assert.NoError(t, chaindb.Delete(root[:]))
+
+	restart := func(configJSON string) (*VM, error) {
+		vm := &VM{}
+		err := vm.Initialize(
+			NewContext(),
+			dbManager,
+			[]byte(genesisJSONApricotPhase2),
+			[]byte(""),
+			[]byte(configJSON),
+			make(chan engCommon.Message, 1),
+			[]*engCommon.Fx{},
+			nil,
+		)
+		return vm, err
+	}
+	vm, err = restart("{\"trie-regeneration-enabled\": true}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, vm.chain.BlockChain().HasState(root))
+	assert.NoError(t, vm.Shutdown())
+
+	// The trie is not regenerated from a snapshot of another state
+	rawdb.WriteSnapshotRoot(chaindb, common.HexToHash("0x01"))
+	if _, err := restart("{\"trie-regeneration-enabled\": true}"); err == nil || !strings.Contains(err.Error(), "does not match the root") {
+		t.Fatalf("expected an error for a mismatched snapshot root, have %v", err)
+	}
+}
+
 func TestVMUpgrades(t *testing.T) {
 	genesisTests := []struct {
 		name             string
@@ -0,0 +1,150 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package precompile
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// ModifyAllowListGasCost is the gas charged to change the role of an address.
+	ModifyAllowListGasCost = 20_000
+	// ReadAllowListGasCost is the gas charged to read the role of an address.
+	ReadAllowListGasCost = 5_000
+
+	allowListInputLen = common.HashLength
+
+	// AllowListABI is the ABI of the IAllowList interface implemented by the
+	// allow list precompiles, see precompile/contracts/IAllowList.sol.
+	AllowListABI = `[{"inputs":[{"internalType":"address","name":"addr","type":"address"}],"name":"readAllowList","outputs":[{"internalType":"uint256","name":"role","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"addr","type":"address"}],"name":"setAdmin","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"addr","type":"address"}],"name":"setEnabled","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"addr","type":"address"}],"name":"setNone","outputs":[],"stateMutability":"nonpayable","type":"function"}]`
+)
+
+// AllowListRole is the role of an address in an allow list, stored in the
+// state of the allow list precompile under the key of the address.
+type AllowListRole common.Hash
+
+var (
+	AllowListNoRole  = AllowListRole(common.BigToHash(common.Big0)) // No role assigned, this is the default for all addresses
+	AllowListEnabled = AllowListRole(common.BigToHash(common.Big1)) // Allowed to use the feature guarded by the allow list
+	AllowListAdmin   = AllowListRole(common.BigToHash(common.Big2)) // Allowed to use the feature and to modify the allow list
+
+	// allowListABI is the parsed [AllowListABI]
+	allowListABI = mustParseABI(AllowListABI)
+
+	ErrCannotModifyAllowList = errors.New("non-admin cannot modify allow list")
+)
+
+// IsEnabled returns true if [r] allows the use of the feature guarded by the
+// allow list.
+func (r AllowListRole) IsEnabled() bool {
+	return r == AllowListEnabled || r == AllowListAdmin
+}
+
+// IsAdmin returns true if [r] allows modifying the allow list.
+func (r AllowListRole) IsAdmin() bool {
+	return r == AllowListAdmin
+}
+
+// AllowListConfig is the configuration of an allow list shared by the allow
+// list precompiles.
+type AllowListConfig struct {
+	AdminAddresses []common.Address `json:"adminAddresses"` // Addresses given the admin role when the precompile is enabled
+}
+
+// Verify returns an error if an admin address is listed more than once.
+func (c *AllowListConfig) Verify() error {
+	seen := make(map[common.Address]struct{}, len(c.AdminAddresses))
+	for _, addr := range c.AdminAddresses {
+		if _, exists := seen[addr]; exists {
+			return fmt.Errorf("duplicate admin address %s", addr)
+		}
+		seen[addr] = struct{}{}
+	}
+	return nil
+}
+
+// configure gives the admin role to each of [c.AdminAddresses] in the allow
+// list stored at [precompileAddr].
+func (c *AllowListConfig) configure(state StateDB, precompileAddr common.Address) {
+	for _, addr := range c.AdminAddresses {
+		SetAllowListRole(state, precompileAddr, addr, AllowListAdmin)
+	}
+}
+
+// GetAllowListStatus returns the role of [address] in the allow list stored
+// at [precompileAddr].
+func GetAllowListStatus(state StateDB, precompileAddr common.Address, address common.Address) AllowListRole {
+	return AllowListRole(state.GetState(precompileAddr, address.Hash()))
+}
+
+// SetAllowListRole sets the role of [address] in the allow list stored at
+// [precompileAddr] to [role].
+func SetAllowListRole(state StateDB, precompileAddr common.Address, address common.Address, role AllowListRole) {
+	state.SetState(precompileAddr, address.Hash(), common.Hash(role))
+}
+
+// createAllowListRoleSetter returns a function that sets the role of the
+// address in its input to [role], which can only be called by an admin.
+func createAllowListRoleSetter(precompileAddr common.Address, role AllowListRole) RunStatefulPrecompileFunc {
+	return func(accessibleState PrecompileAccessibleState, caller common.Address, addr common.Address, input []byte, suppliedGas uint64, readOnly bool) ([]byte, uint64, error) {
+		remainingGas, err := DeductGas(suppliedGas, ModifyAllowListGasCost)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(input) != allowListInputLen {
+			return nil, remainingGas, fmt.Errorf("%w: invalid input length for modifying allow list: %d", ErrExecutionReverted, len(input))
+		}
+		if readOnly {
+			return nil, remainingGas, ErrWriteProtection
+		}
+
+		stateDB := accessibleState.GetStateDB()
+		if !GetAllowListStatus(stateDB, precompileAddr, caller).IsAdmin() {
+			return nil, remainingGas, fmt.Errorf("%w: %s: %s", ErrExecutionReverted, ErrCannotModifyAllowList, caller)
+		}
+		SetAllowListRole(stateDB, precompileAddr, common.BytesToAddress(input), role)
+		return []byte{}, remainingGas, nil
+	}
+}
+
+// createReadAllowList returns a function that returns the role of the address
+// in its input.
+func createReadAllowList(precompileAddr common.Address) RunStatefulPrecompileFunc {
+	return func(accessibleState PrecompileAccessibleState, caller common.Address, addr common.Address, input []byte, suppliedGas uint64, readOnly bool) ([]byte, uint64, error) {
+		remainingGas, err := DeductGas(suppliedGas, ReadAllowListGasCost)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(input) != allowListInputLen {
+			return nil, remainingGas, fmt.Errorf("%w: invalid input length for read allow list: %d", ErrExecutionReverted, len(input))
+		}
+
+		role := GetAllowListStatus(accessibleState.GetStateDB(), precompileAddr, common.BytesToAddress(input))
+		return common.Hash(role).Bytes(), remainingGas, nil
+	}
+}
+
+// createAllowListFunctions returns the functions of the IAllowList interface
+// for the allow list stored at [precompileAddr].
+func createAllowListFunctions(precompileAddr common.Address) []*StatefulPrecompileFunction {
+	return []*StatefulPrecompileFunction{
+		NewStatefulPrecompileFunction(allowListABI.Methods["setAdmin"].ID, createAllowListRoleSetter(precompileAddr, AllowListAdmin)),
+		NewStatefulPrecompileFunction(allowListABI.Methods["setEnabled"].ID, createAllowListRoleSetter(precompileAddr, AllowListEnabled)),
+		NewStatefulPrecompileFunction(allowListABI.Methods["setNone"].ID, createAllowListRoleSetter(precompileAddr, AllowListNoRole)),
+		NewStatefulPrecompileFunction(allowListABI.Methods["readAllowList"].ID, createReadAllowList(precompileAddr)),
+	}
+}
+
+// createAllowListPrecompile returns the contract of an allow list stored at
+// [precompileAddr] implementing the IAllowList interface.
+func createAllowListPrecompile(precompileAddr common.Address) StatefulPrecompiledContract {
+	contract, err := NewStatefulPrecompileContract(nil, createAllowListFunctions(precompileAddr))
+	if err != nil {
+		panic(err)
+	}
+	return contract
+}
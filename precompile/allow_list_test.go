@@ -0,0 +1,125 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package precompile
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// testStateDB is an in-memory StateDB for testing precompiles.
+type testStateDB struct {
+	storage map[common.Address]map[common.Hash]common.Hash
+	nonces  map[common.Address]uint64
+}
+
+func newTestStateDB() *testStateDB {
+	return &testStateDB{
+		storage: make(map[common.Address]map[common.Hash]common.Hash),
+		nonces:  make(map[common.Address]uint64),
+	}
+}
+
+func (s *testStateDB) GetState(addr common.Address, key common.Hash) common.Hash {
+	return s.storage[addr][key]
+}
+
+func (s *testStateDB) SetState(addr common.Address, key common.Hash, value common.Hash) {
+	if s.storage[addr] == nil {
+		s.storage[addr] = make(map[common.Hash]common.Hash)
+	}
+	s.storage[addr][key] = value
+}
+
+func (s *testStateDB) GetBalance(common.Address) *big.Int  { return new(big.Int) }
+func (s *testStateDB) AddBalance(common.Address, *big.Int) {}
+func (s *testStateDB) GetNonce(addr common.Address) uint64 { return s.nonces[addr] }
+func (s *testStateDB) SetNonce(addr common.Address, nonce uint64) {
+	s.nonces[addr] = nonce
+}
+func (s *testStateDB) CreateAccount(common.Address)   {}
+func (s *testStateDB) Exist(addr common.Address) bool { return true }
+
+type testBlockContext struct {
+	number, timestamp *big.Int
+}
+
+func (b *testBlockContext) Number() *big.Int    { return b.number }
+func (b *testBlockContext) Timestamp() *big.Int { return b.timestamp }
+
+type testAccessibleState struct {
+	state        *testStateDB
+	blockContext BlockContext
+}
+
+func (t *testAccessibleState) GetStateDB() StateDB { return t.state }
+
+func (t *testAccessibleState) GetBlockContext() BlockContext { return t.blockContext }
+
+func TestAllowListPrecompile(t *testing.T) {
+	var (
+		adminAddr   = common.HexToAddress("0x0000000000000000000000000000000000000001")
+		enabledAddr = common.HexToAddress("0x0000000000000000000000000000000000000002")
+		noRoleAddr  = common.HexToAddress("0x0000000000000000000000000000000000000003")
+		state       = newTestStateDB()
+		contract    = createAllowListPrecompile(TxAllowListAddress)
+	)
+	config := &TxAllowListConfig{AllowListConfig: AllowListConfig{AdminAddresses: []common.Address{adminAddr}}}
+	assert.NoError(t, config.Verify())
+	assert.NoError(t, config.Configure(nil, state, nil))
+
+	pack := func(method string, addr common.Address) []byte {
+		input, err := allowListABI.Pack(method, addr)
+		assert.NoError(t, err)
+		return input
+	}
+	run := func(caller common.Address, input []byte, readOnly bool) ([]byte, uint64, error) {
+		return contract.Run(&testAccessibleState{state: state}, caller, TxAllowListAddress, input, 100_000, readOnly)
+	}
+
+	// the admin can enable an address
+	_, remainingGas, err := run(adminAddr, pack("setEnabled", enabledAddr), false)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100_000-ModifyAllowListGasCost), remainingGas)
+	assert.Equal(t, AllowListEnabled, GetTxAllowListStatus(state, enabledAddr))
+	assert.True(t, GetTxAllowListStatus(state, enabledAddr).IsEnabled())
+	assert.False(t, GetTxAllowListStatus(state, enabledAddr).IsAdmin())
+
+	// enabled addresses cannot modify the allow list
+	_, _, err = run(enabledAddr, pack("setAdmin", enabledAddr), false)
+	assert.ErrorIs(t, err, ErrExecutionReverted)
+	assert.Equal(t, AllowListEnabled, GetTxAllowListStatus(state, enabledAddr))
+
+	// the allow list cannot be modified in a read only call
+	_, _, err = run(adminAddr, pack("setNone", enabledAddr), true)
+	assert.ErrorIs(t, err, ErrWriteProtection)
+
+	// anyone can read the allow list
+	ret, remainingGas, err := run(noRoleAddr, pack("readAllowList", adminAddr), true)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100_000-ReadAllowListGasCost), remainingGas)
+	assert.Equal(t, common.Hash(AllowListAdmin).Bytes(), ret)
+
+	// the admin can remove the role of an address
+	_, _, err = run(adminAddr, pack("setNone", enabledAddr), false)
+	assert.NoError(t, err)
+	assert.Equal(t, AllowListNoRole, GetTxAllowListStatus(state, enabledAddr))
+
+	// the allow lists are stored separately
+	assert.Equal(t, AllowListNoRole, GetContractDeployerAllowListStatus(state, adminAddr))
+
+	_, _, err = run(adminAddr, pack("setEnabled", enabledAddr), false)
+	assert.NoError(t, err)
+	_, _, err = contract.Run(&testAccessibleState{state: state}, adminAddr, TxAllowListAddress, pack("setEnabled", enabledAddr), ModifyAllowListGasCost-1, false)
+	assert.ErrorIs(t, err, ErrOutOfGas)
+}
+
+func TestAllowListConfigVerify(t *testing.T) {
+	addr := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	config := &AllowListConfig{AdminAddresses: []common.Address{addr, addr}}
+	assert.Error(t, config.Verify())
+}
@@ -0,0 +1,401 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package bindings
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/ava-labs/coreth/accounts/abi"
+	"github.com/ava-labs/coreth/accounts/abi/bind"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/interfaces"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = errors.New
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = interfaces.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+)
+
+// IFeeManagerMetaData contains all meta data concerning the IFeeManager contract.
+var IFeeManagerMetaData = &bind.MetaData{
+	ABI: "[{\"inputs\":[],\"name\":\"getFeeConfig\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"targetGas\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"baseFeeChangeDenominator\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"minBaseFee\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"maxBaseFee\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"targetBlockRate\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"minBlockGasCost\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"maxBlockGasCost\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"blockGasCostStep\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"getFeeConfigLastChangedAt\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"blockNumber\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"addr\",\"type\":\"address\"}],\"name\":\"readAllowList\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"role\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"addr\",\"type\":\"address\"}],\"name\":\"setAdmin\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"addr\",\"type\":\"address\"}],\"name\":\"setEnabled\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"uint256\",\"name\":\"targetGas\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"baseFeeChangeDenominator\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"minBaseFee\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"maxBaseFee\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"targetBlockRate\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"minBlockGasCost\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"maxBlockGasCost\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"blockGasCostStep\",\"type\":\"uint256\"}],\"name\":\"setFeeConfig\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"addr\",\"type\":\"address\"}],\"name\":\"setNone\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"}]",
+}
+
+// IFeeManagerABI is the input ABI used to generate the binding from.
+// Deprecated: Use IFeeManagerMetaData.ABI instead.
+var IFeeManagerABI = IFeeManagerMetaData.ABI
+
+// IFeeManager is an auto generated Go binding around an Ethereum contract.
+type IFeeManager struct {
+	IFeeManagerCaller     // Read-only binding to the contract
+	IFeeManagerTransactor // Write-only binding to the contract
+	IFeeManagerFilterer   // Log filterer for contract events
+}
+
+// IFeeManagerCaller is an auto generated read-only Go binding around an Ethereum contract.
+type IFeeManagerCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// IFeeManagerTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type IFeeManagerTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// IFeeManagerFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type IFeeManagerFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// IFeeManagerSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type IFeeManagerSession struct {
+	Contract     *IFeeManager      // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// IFeeManagerCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type IFeeManagerCallerSession struct {
+	Contract *IFeeManagerCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts      // Call options to use throughout this session
+}
+
+// IFeeManagerTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type IFeeManagerTransactorSession struct {
+	Contract     *IFeeManagerTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts      // Transaction auth options to use throughout this session
+}
+
+// IFeeManagerRaw is an auto generated low-level Go binding around an Ethereum contract.
+type IFeeManagerRaw struct {
+	Contract *IFeeManager // Generic contract binding to access the raw methods on
+}
+
+// IFeeManagerCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type IFeeManagerCallerRaw struct {
+	Contract *IFeeManagerCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// IFeeManagerTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type IFeeManagerTransactorRaw struct {
+	Contract *IFeeManagerTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewIFeeManager creates a new instance of IFeeManager, bound to a specific deployed contract.
+func NewIFeeManager(address common.Address, backend bind.ContractBackend) (*IFeeManager, error) {
+	contract, err := bindIFeeManager(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &IFeeManager{IFeeManagerCaller: IFeeManagerCaller{contract: contract}, IFeeManagerTransactor: IFeeManagerTransactor{contract: contract}, IFeeManagerFilterer: IFeeManagerFilterer{contract: contract}}, nil
+}
+
+// NewIFeeManagerCaller creates a new read-only instance of IFeeManager, bound to a specific deployed contract.
+func NewIFeeManagerCaller(address common.Address, caller bind.ContractCaller) (*IFeeManagerCaller, error) {
+	contract, err := bindIFeeManager(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &IFeeManagerCaller{contract: contract}, nil
+}
+
+// NewIFeeManagerTransactor creates a new write-only instance of IFeeManager, bound to a specific deployed contract.
+func NewIFeeManagerTransactor(address common.Address, transactor bind.ContractTransactor) (*IFeeManagerTransactor, error) {
+	contract, err := bindIFeeManager(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &IFeeManagerTransactor{contract: contract}, nil
+}
+
+// NewIFeeManagerFilterer creates a new log filterer instance of IFeeManager, bound to a specific deployed contract.
+func NewIFeeManagerFilterer(address common.Address, filterer bind.ContractFilterer) (*IFeeManagerFilterer, error) {
+	contract, err := bindIFeeManager(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &IFeeManagerFilterer{contract: contract}, nil
+}
+
+// bindIFeeManager binds a generic wrapper to an already deployed contract.
+func bindIFeeManager(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(IFeeManagerABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_IFeeManager *IFeeManagerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _IFeeManager.Contract.IFeeManagerCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_IFeeManager *IFeeManagerRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _IFeeManager.Contract.IFeeManagerTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_IFeeManager *IFeeManagerRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _IFeeManager.Contract.IFeeManagerTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_IFeeManager *IFeeManagerCallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _IFeeManager.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_IFeeManager *IFeeManagerTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _IFeeManager.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_IFeeManager *IFeeManagerTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _IFeeManager.Contract.contract.Transact(opts, method, params...)
+}
+
+// GetFeeConfig is a free data retrieval call binding the contract method 0x5fbbc0d2.
+//
+// Solidity: function getFeeConfig() view returns(uint256 targetGas, uint256 baseFeeChangeDenominator, uint256 minBaseFee, uint256 maxBaseFee, uint256 targetBlockRate, uint256 minBlockGasCost, uint256 maxBlockGasCost, uint256 blockGasCostStep)
+func (_IFeeManager *IFeeManagerCaller) GetFeeConfig(opts *bind.CallOpts) (struct {
+	TargetGas                *big.Int
+	BaseFeeChangeDenominator *big.Int
+	MinBaseFee               *big.Int
+	MaxBaseFee               *big.Int
+	TargetBlockRate          *big.Int
+	MinBlockGasCost          *big.Int
+	MaxBlockGasCost          *big.Int
+	BlockGasCostStep         *big.Int
+}, error) {
+	var out []interface{}
+	err := _IFeeManager.contract.Call(opts, &out, "getFeeConfig")
+
+	outstruct := new(struct {
+		TargetGas                *big.Int
+		BaseFeeChangeDenominator *big.Int
+		MinBaseFee               *big.Int
+		MaxBaseFee               *big.Int
+		TargetBlockRate          *big.Int
+		MinBlockGasCost          *big.Int
+		MaxBlockGasCost          *big.Int
+		BlockGasCostStep         *big.Int
+	})
+	if err != nil {
+		return *outstruct, err
+	}
+
+	outstruct.TargetGas = *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+	outstruct.BaseFeeChangeDenominator = *abi.ConvertType(out[1], new(*big.Int)).(**big.Int)
+	outstruct.MinBaseFee = *abi.ConvertType(out[2], new(*big.Int)).(**big.Int)
+	outstruct.MaxBaseFee = *abi.ConvertType(out[3], new(*big.Int)).(**big.Int)
+	outstruct.TargetBlockRate = *abi.ConvertType(out[4], new(*big.Int)).(**big.Int)
+	outstruct.MinBlockGasCost = *abi.ConvertType(out[5], new(*big.Int)).(**big.Int)
+	outstruct.MaxBlockGasCost = *abi.ConvertType(out[6], new(*big.Int)).(**big.Int)
+	outstruct.BlockGasCostStep = *abi.ConvertType(out[7], new(*big.Int)).(**big.Int)
+
+	return *outstruct, err
+
+}
+
+// GetFeeConfig is a free data retrieval call binding the contract method 0x5fbbc0d2.
+//
+// Solidity: function getFeeConfig() view returns(uint256 targetGas, uint256 baseFeeChangeDenominator, uint256 minBaseFee, uint256 maxBaseFee, uint256 targetBlockRate, uint256 minBlockGasCost, uint256 maxBlockGasCost, uint256 blockGasCostStep)
+func (_IFeeManager *IFeeManagerSession) GetFeeConfig() (struct {
+	TargetGas                *big.Int
+	BaseFeeChangeDenominator *big.Int
+	MinBaseFee               *big.Int
+	MaxBaseFee               *big.Int
+	TargetBlockRate          *big.Int
+	MinBlockGasCost          *big.Int
+	MaxBlockGasCost          *big.Int
+	BlockGasCostStep         *big.Int
+}, error) {
+	return _IFeeManager.Contract.GetFeeConfig(&_IFeeManager.CallOpts)
+}
+
+// GetFeeConfig is a free data retrieval call binding the contract method 0x5fbbc0d2.
+//
+// Solidity: function getFeeConfig() view returns(uint256 targetGas, uint256 baseFeeChangeDenominator, uint256 minBaseFee, uint256 maxBaseFee, uint256 targetBlockRate, uint256 minBlockGasCost, uint256 maxBlockGasCost, uint256 blockGasCostStep)
+func (_IFeeManager *IFeeManagerCallerSession) GetFeeConfig() (struct {
+	TargetGas                *big.Int
+	BaseFeeChangeDenominator *big.Int
+	MinBaseFee               *big.Int
+	MaxBaseFee               *big.Int
+	TargetBlockRate          *big.Int
+	MinBlockGasCost          *big.Int
+	MaxBlockGasCost          *big.Int
+	BlockGasCostStep         *big.Int
+}, error) {
+	return _IFeeManager.Contract.GetFeeConfig(&_IFeeManager.CallOpts)
+}
+
+// GetFeeConfigLastChangedAt is a free data retrieval call binding the contract method 0x9e05549a.
+//
+// Solidity: function getFeeConfigLastChangedAt() view returns(uint256 blockNumber)
+func (_IFeeManager *IFeeManagerCaller) GetFeeConfigLastChangedAt(opts *bind.CallOpts) (*big.Int, error) {
+	var out []interface{}
+	err := _IFeeManager.contract.Call(opts, &out, "getFeeConfigLastChangedAt")
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// GetFeeConfigLastChangedAt is a free data retrieval call binding the contract method 0x9e05549a.
+//
+// Solidity: function getFeeConfigLastChangedAt() view returns(uint256 blockNumber)
+func (_IFeeManager *IFeeManagerSession) GetFeeConfigLastChangedAt() (*big.Int, error) {
+	return _IFeeManager.Contract.GetFeeConfigLastChangedAt(&_IFeeManager.CallOpts)
+}
+
+// GetFeeConfigLastChangedAt is a free data retrieval call binding the contract method 0x9e05549a.
+//
+// Solidity: function getFeeConfigLastChangedAt() view returns(uint256 blockNumber)
+func (_IFeeManager *IFeeManagerCallerSession) GetFeeConfigLastChangedAt() (*big.Int, error) {
+	return _IFeeManager.Contract.GetFeeConfigLastChangedAt(&_IFeeManager.CallOpts)
+}
+
+// ReadAllowList is a free data retrieval call binding the contract method 0xeb54dae1.
+//
+// Solidity: function readAllowList(address addr) view returns(uint256 role)
+func (_IFeeManager *IFeeManagerCaller) ReadAllowList(opts *bind.CallOpts, addr common.Address) (*big.Int, error) {
+	var out []interface{}
+	err := _IFeeManager.contract.Call(opts, &out, "readAllowList", addr)
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// ReadAllowList is a free data retrieval call binding the contract method 0xeb54dae1.
+//
+// Solidity: function readAllowList(address addr) view returns(uint256 role)
+func (_IFeeManager *IFeeManagerSession) ReadAllowList(addr common.Address) (*big.Int, error) {
+	return _IFeeManager.Contract.ReadAllowList(&_IFeeManager.CallOpts, addr)
+}
+
+// ReadAllowList is a free data retrieval call binding the contract method 0xeb54dae1.
+//
+// Solidity: function readAllowList(address addr) view returns(uint256 role)
+func (_IFeeManager *IFeeManagerCallerSession) ReadAllowList(addr common.Address) (*big.Int, error) {
+	return _IFeeManager.Contract.ReadAllowList(&_IFeeManager.CallOpts, addr)
+}
+
+// SetAdmin is a paid mutator transaction binding the contract method 0x704b6c02.
+//
+// Solidity: function setAdmin(address addr) returns()
+func (_IFeeManager *IFeeManagerTransactor) SetAdmin(opts *bind.TransactOpts, addr common.Address) (*types.Transaction, error) {
+	return _IFeeManager.contract.Transact(opts, "setAdmin", addr)
+}
+
+// SetAdmin is a paid mutator transaction binding the contract method 0x704b6c02.
+//
+// Solidity: function setAdmin(address addr) returns()
+func (_IFeeManager *IFeeManagerSession) SetAdmin(addr common.Address) (*types.Transaction, error) {
+	return _IFeeManager.Contract.SetAdmin(&_IFeeManager.TransactOpts, addr)
+}
+
+// SetAdmin is a paid mutator transaction binding the contract method 0x704b6c02.
+//
+// Solidity: function setAdmin(address addr) returns()
+func (_IFeeManager *IFeeManagerTransactorSession) SetAdmin(addr common.Address) (*types.Transaction, error) {
+	return _IFeeManager.Contract.SetAdmin(&_IFeeManager.TransactOpts, addr)
+}
+
+// SetEnabled is a paid mutator transaction binding the contract method 0x0aaf7043.
+//
+// Solidity: function setEnabled(address addr) returns()
+func (_IFeeManager *IFeeManagerTransactor) SetEnabled(opts *bind.TransactOpts, addr common.Address) (*types.Transaction, error) {
+	return _IFeeManager.contract.Transact(opts, "setEnabled", addr)
+}
+
+// SetEnabled is a paid mutator transaction binding the contract method 0x0aaf7043.
+//
+// Solidity: function setEnabled(address addr) returns()
+func (_IFeeManager *IFeeManagerSession) SetEnabled(addr common.Address) (*types.Transaction, error) {
+	return _IFeeManager.Contract.SetEnabled(&_IFeeManager.TransactOpts, addr)
+}
+
+// SetEnabled is a paid mutator transaction binding the contract method 0x0aaf7043.
+//
+// Solidity: function setEnabled(address addr) returns()
+func (_IFeeManager *IFeeManagerTransactorSession) SetEnabled(addr common.Address) (*types.Transaction, error) {
+	return _IFeeManager.Contract.SetEnabled(&_IFeeManager.TransactOpts, addr)
+}
+
+// SetFeeConfig is a paid mutator transaction binding the contract method 0x8f10b586.
+//
+// Solidity: function setFeeConfig(uint256 targetGas, uint256 baseFeeChangeDenominator, uint256 minBaseFee, uint256 maxBaseFee, uint256 targetBlockRate, uint256 minBlockGasCost, uint256 maxBlockGasCost, uint256 blockGasCostStep) returns()
+func (_IFeeManager *IFeeManagerTransactor) SetFeeConfig(opts *bind.TransactOpts, targetGas *big.Int, baseFeeChangeDenominator *big.Int, minBaseFee *big.Int, maxBaseFee *big.Int, targetBlockRate *big.Int, minBlockGasCost *big.Int, maxBlockGasCost *big.Int, blockGasCostStep *big.Int) (*types.Transaction, error) {
+	return _IFeeManager.contract.Transact(opts, "setFeeConfig", targetGas, baseFeeChangeDenominator, minBaseFee, maxBaseFee, targetBlockRate, minBlockGasCost, maxBlockGasCost, blockGasCostStep)
+}
+
+// SetFeeConfig is a paid mutator transaction binding the contract method 0x8f10b586.
+//
+// Solidity: function setFeeConfig(uint256 targetGas, uint256 baseFeeChangeDenominator, uint256 minBaseFee, uint256 maxBaseFee, uint256 targetBlockRate, uint256 minBlockGasCost, uint256 maxBlockGasCost, uint256 blockGasCostStep) returns()
+func (_IFeeManager *IFeeManagerSession) SetFeeConfig(targetGas *big.Int, baseFeeChangeDenominator *big.Int, minBaseFee *big.Int, maxBaseFee *big.Int, targetBlockRate *big.Int, minBlockGasCost *big.Int, maxBlockGasCost *big.Int, blockGasCostStep *big.Int) (*types.Transaction, error) {
+	return _IFeeManager.Contract.SetFeeConfig(&_IFeeManager.TransactOpts, targetGas, baseFeeChangeDenominator, minBaseFee, maxBaseFee, targetBlockRate, minBlockGasCost, maxBlockGasCost, blockGasCostStep)
+}
+
+// SetFeeConfig is a paid mutator transaction binding the contract method 0x8f10b586.
+//
+// Solidity: function setFeeConfig(uint256 targetGas, uint256 baseFeeChangeDenominator, uint256 minBaseFee, uint256 maxBaseFee, uint256 targetBlockRate, uint256 minBlockGasCost, uint256 maxBlockGasCost, uint256 blockGasCostStep) returns()
+func (_IFeeManager *IFeeManagerTransactorSession) SetFeeConfig(targetGas *big.Int, baseFeeChangeDenominator *big.Int, minBaseFee *big.Int, maxBaseFee *big.Int, targetBlockRate *big.Int, minBlockGasCost *big.Int, maxBlockGasCost *big.Int, blockGasCostStep *big.Int) (*types.Transaction, error) {
+	return _IFeeManager.Contract.SetFeeConfig(&_IFeeManager.TransactOpts, targetGas, baseFeeChangeDenominator, minBaseFee, maxBaseFee, targetBlockRate, minBlockGasCost, maxBlockGasCost, blockGasCostStep)
+}
+
+// SetNone is a paid mutator transaction binding the contract method 0x8c6bfb3b.
+//
+// Solidity: function setNone(address addr) returns()
+func (_IFeeManager *IFeeManagerTransactor) SetNone(opts *bind.TransactOpts, addr common.Address) (*types.Transaction, error) {
+	return _IFeeManager.contract.Transact(opts, "setNone", addr)
+}
+
+// SetNone is a paid mutator transaction binding the contract method 0x8c6bfb3b.
+//
+// Solidity: function setNone(address addr) returns()
+func (_IFeeManager *IFeeManagerSession) SetNone(addr common.Address) (*types.Transaction, error) {
+	return _IFeeManager.Contract.SetNone(&_IFeeManager.TransactOpts, addr)
+}
+
+// SetNone is a paid mutator transaction binding the contract method 0x8c6bfb3b.
+//
+// Solidity: function setNone(address addr) returns()
+func (_IFeeManager *IFeeManagerTransactorSession) SetNone(addr common.Address) (*types.Transaction, error) {
+	return _IFeeManager.Contract.SetNone(&_IFeeManager.TransactOpts, addr)
+}
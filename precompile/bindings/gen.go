@@ -0,0 +1,10 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package bindings contains the Go bindings of the Solidity interfaces of the
+// precompiles in the precompile package, generated with abigen from the ABIs
+// in precompile/contracts.
+package bindings
+
+//go:generate go run ../../cmd/abigen --abi ../contracts/IAllowList.abi --pkg bindings --type IAllowList --out allow_list.go
+//go:generate go run ../../cmd/abigen --abi ../contracts/IFeeManager.abi --pkg bindings --type IFeeManager --out fee_manager.go
@@ -0,0 +1,41 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package precompile
+
+import (
+	"math/big"
+
+	"github.com/ava-labs/coreth/commontype"
+)
+
+// StatefulPrecompileConfig is the configuration of a module in the chain
+// config. The module is enabled from the block timestamp returned by
+// Timestamp onwards.
+type StatefulPrecompileConfig interface {
+	// Timestamp returns the timestamp of the first block at which the module
+	// is enabled (nil = never enabled, 0 = enabled at genesis).
+	Timestamp() *big.Int
+	// Verify returns an error if the configuration is invalid.
+	Verify() error
+	// Configure initializes the state of the module in [state] when the
+	// module is enabled in the block described by [blockContext].
+	Configure(chainConfig ChainConfig, state StateDB, blockContext BlockContext) error
+}
+
+// ChainConfig is the part of the chain config made available to the
+// configurations of modules.
+type ChainConfig interface {
+	// GetFeeConfig returns the fee config specified by the chain config at
+	// [timestamp].
+	GetFeeConfig(timestamp *big.Int) commontype.FeeConfig
+}
+
+// UpgradeableConfig contains the timestamp at which a module is enabled.
+// It is embedded in the configuration of modules to implement Timestamp.
+type UpgradeableConfig struct {
+	BlockTimestamp *big.Int `json:"blockTimestamp"`
+}
+
+// Timestamp implements StatefulPrecompileConfig
+func (c *UpgradeableConfig) Timestamp() *big.Int { return c.BlockTimestamp }
@@ -0,0 +1,156 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package precompile
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ava-labs/coreth/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const selectorLen = 4
+
+var (
+	// ErrOutOfGas is returned when a precompile is supplied insufficient gas.
+	// It is translated to vm.ErrOutOfGas by the EVM.
+	ErrOutOfGas = errors.New("out of gas")
+
+	// ErrExecutionReverted is returned when a precompile reverts, refunding
+	// the remaining gas. It is translated to vm.ErrExecutionReverted by the EVM.
+	ErrExecutionReverted = errors.New("execution reverted")
+
+	// ErrWriteProtection is returned when a precompile is asked to modify
+	// state during a read only call. It is translated to vm.ErrWriteProtection
+	// by the EVM.
+	ErrWriteProtection = errors.New("write protection")
+)
+
+// StateDB is the interface of the state made available to stateful precompiles.
+type StateDB interface {
+	GetState(common.Address, common.Hash) common.Hash
+	SetState(common.Address, common.Hash, common.Hash)
+
+	GetBalance(common.Address) *big.Int
+	AddBalance(common.Address, *big.Int)
+
+	GetNonce(common.Address) uint64
+	SetNonce(common.Address, uint64)
+
+	CreateAccount(common.Address)
+	Exist(common.Address) bool
+}
+
+// BlockContext is the information about the block being processed made
+// available to stateful precompiles.
+type BlockContext interface {
+	Number() *big.Int
+	Timestamp() *big.Int
+}
+
+// PrecompileAccessibleState is the environment in which a stateful precompile
+// is executed. It is implemented by the EVM.
+type PrecompileAccessibleState interface {
+	GetStateDB() StateDB
+	GetBlockContext() BlockContext
+}
+
+// StatefulPrecompiledContract is the interface implemented by the contracts of
+// the modules added to the registry.
+type StatefulPrecompiledContract interface {
+	// Run executes the precompiled contract in [accessibleState] on behalf of [caller].
+	Run(accessibleState PrecompileAccessibleState, caller common.Address, addr common.Address, input []byte, suppliedGas uint64, readOnly bool) (ret []byte, remainingGas uint64, err error)
+}
+
+// RunStatefulPrecompileFunc is the signature of a function of a stateful
+// precompile. The input passed to the function does not include the function
+// selector.
+type RunStatefulPrecompileFunc func(accessibleState PrecompileAccessibleState, caller common.Address, addr common.Address, input []byte, suppliedGas uint64, readOnly bool) (ret []byte, remainingGas uint64, err error)
+
+// StatefulPrecompileFunction is a function of a stateful precompile, called
+// when the input begins with [selector].
+type StatefulPrecompileFunction struct {
+	selector []byte
+	execute  RunStatefulPrecompileFunc
+}
+
+// NewStatefulPrecompileFunction returns a function called when the input to
+// the precompile begins with [selector], usually the ID of a method in the ABI
+// of the module.
+func NewStatefulPrecompileFunction(selector []byte, execute RunStatefulPrecompileFunc) *StatefulPrecompileFunction {
+	return &StatefulPrecompileFunction{
+		selector: selector,
+		execute:  execute,
+	}
+}
+
+// statefulPrecompileWithFunctionSelectors dispatches calls to its functions
+// based on the function selector at the beginning of the input.
+type statefulPrecompileWithFunctionSelectors struct {
+	fallback  RunStatefulPrecompileFunc
+	functions map[string]*StatefulPrecompileFunction
+}
+
+// NewStatefulPrecompileContract returns a StatefulPrecompiledContract that calls
+// the function of [functions] matching the function selector of the input.
+// [fallback] is called if the input is empty, and may be nil in which case
+// such calls revert.
+func NewStatefulPrecompileContract(fallback RunStatefulPrecompileFunc, functions []*StatefulPrecompileFunction) (StatefulPrecompiledContract, error) {
+	contract := &statefulPrecompileWithFunctionSelectors{
+		fallback:  fallback,
+		functions: make(map[string]*StatefulPrecompileFunction, len(functions)),
+	}
+	for _, function := range functions {
+		if len(function.selector) != selectorLen {
+			return nil, fmt.Errorf("function selector %x must be %d bytes long", function.selector, selectorLen)
+		}
+		key := string(function.selector)
+		if _, exists := contract.functions[key]; exists {
+			return nil, fmt.Errorf("duplicate function selector %x", function.selector)
+		}
+		contract.functions[key] = function
+	}
+	return contract, nil
+}
+
+// Run implements StatefulPrecompiledContract
+func (s *statefulPrecompileWithFunctionSelectors) Run(accessibleState PrecompileAccessibleState, caller common.Address, addr common.Address, input []byte, suppliedGas uint64, readOnly bool) (ret []byte, remainingGas uint64, err error) {
+	if len(input) == 0 {
+		if s.fallback == nil {
+			return nil, suppliedGas, ErrExecutionReverted
+		}
+		return s.fallback(accessibleState, caller, addr, nil, suppliedGas, readOnly)
+	}
+	if len(input) < selectorLen {
+		return nil, suppliedGas, fmt.Errorf("%w: missing function selector in input %x", ErrExecutionReverted, input)
+	}
+
+	function, ok := s.functions[string(input[:selectorLen])]
+	if !ok {
+		return nil, suppliedGas, fmt.Errorf("%w: invalid function selector %x", ErrExecutionReverted, input[:selectorLen])
+	}
+	return function.execute(accessibleState, caller, addr, input[selectorLen:], suppliedGas, readOnly)
+}
+
+// DeductGas returns the gas remaining after charging [gasCost] to [suppliedGas],
+// or ErrOutOfGas if [suppliedGas] is insufficient.
+func DeductGas(suppliedGas uint64, gasCost uint64) (uint64, error) {
+	if suppliedGas < gasCost {
+		return 0, ErrOutOfGas
+	}
+	return suppliedGas - gasCost, nil
+}
+
+// mustParseABI returns the ABI described by [abiJSON], panicking if it is
+// invalid. It is intended for the ABIs of the modules defined in this package.
+func mustParseABI(abiJSON string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
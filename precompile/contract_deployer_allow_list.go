@@ -0,0 +1,46 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package precompile
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const ContractDeployerAllowListConfigKey = "contractDeployerAllowListConfig"
+
+// ContractDeployerAllowListAddress is the address of the allow list of the
+// addresses permitted to deploy contracts. Once enabled, EVM.Create and
+// EVM.Create2 fail unless the origin of the transaction is enabled.
+var ContractDeployerAllowListAddress = common.HexToAddress("0x0200000000000000000000000000000000000000")
+
+// ContractDeployerAllowListConfig is the configuration of the contract
+// deployer allow list in the chain config.
+type ContractDeployerAllowListConfig struct {
+	UpgradeableConfig
+	AllowListConfig
+}
+
+// Configure implements StatefulPrecompileConfig
+func (c *ContractDeployerAllowListConfig) Configure(_ ChainConfig, state StateDB, _ BlockContext) error {
+	c.AllowListConfig.configure(state, ContractDeployerAllowListAddress)
+	return nil
+}
+
+// GetContractDeployerAllowListStatus returns the role of [address] in the
+// contract deployer allow list.
+func GetContractDeployerAllowListStatus(state StateDB, address common.Address) AllowListRole {
+	return GetAllowListStatus(state, ContractDeployerAllowListAddress, address)
+}
+
+func init() {
+	if err := RegisterModule(Module{
+		ConfigKey: ContractDeployerAllowListConfigKey,
+		Address:   ContractDeployerAllowListAddress,
+		ABI:       allowListABI,
+		Contract:  createAllowListPrecompile(ContractDeployerAllowListAddress),
+		NewConfig: func() StatefulPrecompileConfig { return &ContractDeployerAllowListConfig{} },
+	}); err != nil {
+		panic(err)
+	}
+}
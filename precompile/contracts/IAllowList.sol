@@ -0,0 +1,28 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// SPDX-License-Identifier: MIT
+
+pragma solidity >=0.8.0;
+
+// IAllowList is the interface of the allow list precompiles:
+//   - the contract deployer allow list at 0x0200000000000000000000000000000000000000
+//   - the transaction allow list at 0x0200000000000000000000000000000000000002
+//
+// Roles returned by readAllowList:
+//   0: none, the address is not allowed
+//   1: enabled, the address is allowed
+//   2: admin, the address is allowed and can change the role of any address
+interface IAllowList {
+  // Set [addr] to have the admin role over the allow list
+  function setAdmin(address addr) external;
+
+  // Set [addr] to be enabled on the allow list
+  function setEnabled(address addr) external;
+
+  // Set [addr] to have no role over the allow list
+  function setNone(address addr) external;
+
+  // Read the role of [addr]
+  function readAllowList(address addr) external view returns (uint256 role);
+}
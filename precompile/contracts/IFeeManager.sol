@@ -0,0 +1,47 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// SPDX-License-Identifier: MIT
+
+pragma solidity >=0.8.0;
+
+import "./IAllowList.sol";
+
+// IFeeManager is the interface of the fee manager precompile at
+// 0x0200000000000000000000000000000000000003, which allows enabled addresses
+// to change the parameters of the dynamic fee algorithm.
+//
+// The parameters set by setFeeConfig are used from the block following the
+// block including the transaction. A maxBaseFee of 0 means the base fee has
+// no upper bound.
+interface IFeeManager is IAllowList {
+  // Set the parameters of the dynamic fee algorithm
+  function setFeeConfig(
+    uint256 targetGas,
+    uint256 baseFeeChangeDenominator,
+    uint256 minBaseFee,
+    uint256 maxBaseFee,
+    uint256 targetBlockRate,
+    uint256 minBlockGasCost,
+    uint256 maxBlockGasCost,
+    uint256 blockGasCostStep
+  ) external;
+
+  // Get the parameters of the dynamic fee algorithm
+  function getFeeConfig()
+    external
+    view
+    returns (
+      uint256 targetGas,
+      uint256 baseFeeChangeDenominator,
+      uint256 minBaseFee,
+      uint256 maxBaseFee,
+      uint256 targetBlockRate,
+      uint256 minBlockGasCost,
+      uint256 maxBlockGasCost,
+      uint256 blockGasCostStep
+    );
+
+  // Get the number of the last block in which the parameters were changed
+  function getFeeConfigLastChangedAt() external view returns (uint256 blockNumber);
+}
@@ -0,0 +1,262 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package precompile
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ava-labs/coreth/commontype"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	FeeConfigManagerConfigKey = "feeManagerConfig"
+
+	// SetFeeConfigGasCost is the gas charged to change the fee config.
+	SetFeeConfigGasCost = 50_000
+	// GetFeeConfigGasCost is the gas charged to read the fee config.
+	GetFeeConfigGasCost = 5_000
+	// GetLastChangedAtGasCost is the gas charged to read the number of the
+	// block in which the fee config was last changed.
+	GetLastChangedAtGasCost = 2_500
+
+	numFeeConfigFields = 8
+
+	// FeeConfigManagerABI is the ABI of the IFeeManager interface implemented
+	// by the fee manager precompile, see precompile/contracts/IFeeManager.sol.
+	FeeConfigManagerABI = `[{"inputs":[],"name":"getFeeConfig","outputs":[{"internalType":"uint256","name":"targetGas","type":"uint256"},{"internalType":"uint256","name":"baseFeeChangeDenominator","type":"uint256"},{"internalType":"uint256","name":"minBaseFee","type":"uint256"},{"internalType":"uint256","name":"maxBaseFee","type":"uint256"},{"internalType":"uint256","name":"targetBlockRate","type":"uint256"},{"internalType":"uint256","name":"minBlockGasCost","type":"uint256"},{"internalType":"uint256","name":"maxBlockGasCost","type":"uint256"},{"internalType":"uint256","name":"blockGasCostStep","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"getFeeConfigLastChangedAt","outputs":[{"internalType":"uint256","name":"blockNumber","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"addr","type":"address"}],"name":"readAllowList","outputs":[{"internalType":"uint256","name":"role","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"addr","type":"address"}],"name":"setAdmin","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"addr","type":"address"}],"name":"setEnabled","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"uint256","name":"targetGas","type":"uint256"},{"internalType":"uint256","name":"baseFeeChangeDenominator","type":"uint256"},{"internalType":"uint256","name":"minBaseFee","type":"uint256"},{"internalType":"uint256","name":"maxBaseFee","type":"uint256"},{"internalType":"uint256","name":"targetBlockRate","type":"uint256"},{"internalType":"uint256","name":"minBlockGasCost","type":"uint256"},{"internalType":"uint256","name":"maxBlockGasCost","type":"uint256"},{"internalType":"uint256","name":"blockGasCostStep","type":"uint256"}],"name":"setFeeConfig","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"addr","type":"address"}],"name":"setNone","outputs":[],"stateMutability":"nonpayable","type":"function"}]`
+)
+
+var (
+	// FeeConfigManagerAddress is the address of the fee manager precompile.
+	// Once enabled, the fee config stored by the precompile replaces the fee
+	// config of the chain config from the block following each change.
+	FeeConfigManagerAddress = common.HexToAddress("0x0200000000000000000000000000000000000003")
+
+	// feeConfigManagerABI is the parsed [FeeConfigManagerABI]
+	feeConfigManagerABI = mustParseABI(FeeConfigManagerABI)
+
+	// The fee config is stored under keys that cannot collide with the keys
+	// of the allow list, which are left padded addresses.
+	feeConfigLastChangedAtKey = common.Hash{'l', 'c', 'a'}
+
+	ErrCannotChangeFee = errors.New("non-enabled cannot change fee config")
+)
+
+// FeeConfigManagerConfig is the configuration of the fee manager precompile in
+// the chain config. The fee config is changed by the addresses enabled in its
+// allow list.
+type FeeConfigManagerConfig struct {
+	UpgradeableConfig
+	AllowListConfig
+	// InitialFeeConfig is stored when the precompile is enabled. If nil, the
+	// fee config specified by the chain config at that time is stored instead.
+	InitialFeeConfig *commontype.FeeConfig `json:"initialFeeConfig,omitempty"`
+}
+
+// Verify implements StatefulPrecompileConfig
+func (c *FeeConfigManagerConfig) Verify() error {
+	if err := c.AllowListConfig.Verify(); err != nil {
+		return err
+	}
+	if c.InitialFeeConfig == nil {
+		return nil
+	}
+	if err := c.InitialFeeConfig.Verify(); err != nil {
+		return fmt.Errorf("invalid initial fee config: %w", err)
+	}
+	return nil
+}
+
+// Configure implements StatefulPrecompileConfig
+func (c *FeeConfigManagerConfig) Configure(chainConfig ChainConfig, state StateDB, blockContext BlockContext) error {
+	c.AllowListConfig.configure(state, FeeConfigManagerAddress)
+
+	feeConfig := chainConfig.GetFeeConfig(blockContext.Timestamp())
+	if c.InitialFeeConfig != nil {
+		feeConfig = *c.InitialFeeConfig
+	}
+	return StoreFeeConfig(state, feeConfig, blockContext.Number())
+}
+
+// GetFeeConfigManagerStatus returns the role of [address] in the allow list
+// of the fee manager.
+func GetFeeConfigManagerStatus(state StateDB, address common.Address) AllowListRole {
+	return GetAllowListStatus(state, FeeConfigManagerAddress, address)
+}
+
+// GetStoredFeeConfig returns the fee config stored by the fee manager, or
+// false if no fee config has been stored.
+func GetStoredFeeConfig(state StateDB) (commontype.FeeConfig, bool) {
+	var values [numFeeConfigFields]*big.Int
+	for i := range values {
+		values[i] = state.GetState(FeeConfigManagerAddress, feeConfigKey(i)).Big()
+	}
+	if values[0].Sign() == 0 {
+		// a valid fee config has a positive target gas
+		return commontype.FeeConfig{}, false
+	}
+	return feeConfigFromValues(values), true
+}
+
+// GetFeeConfigLastChangedAt returns the number of the block in which the fee
+// config stored by the fee manager was last changed.
+func GetFeeConfigLastChangedAt(state StateDB) *big.Int {
+	return state.GetState(FeeConfigManagerAddress, feeConfigLastChangedAtKey).Big()
+}
+
+// StoreFeeConfig verifies [feeConfig] and stores it as the fee config of the
+// fee manager, changed in the block with number [blockNumber].
+func StoreFeeConfig(state StateDB, feeConfig commontype.FeeConfig, blockNumber *big.Int) error {
+	if err := feeConfig.Verify(); err != nil {
+		return err
+	}
+	for i, value := range feeConfigToValues(feeConfig) {
+		state.SetState(FeeConfigManagerAddress, feeConfigKey(i), common.BigToHash(value))
+	}
+	state.SetState(FeeConfigManagerAddress, feeConfigLastChangedAtKey, common.BigToHash(blockNumber))
+	return nil
+}
+
+// feeConfigKey returns the key of the [i]th field of the fee config, in the
+// order of the arguments of setFeeConfig.
+func feeConfigKey(i int) common.Hash {
+	return common.Hash{'f', 'c', 'k', byte(i)}
+}
+
+// feeConfigToValues returns the fields of [feeConfig] in the order of the
+// arguments of setFeeConfig. A nil MaxBaseFee is represented by 0.
+func feeConfigToValues(feeConfig commontype.FeeConfig) [numFeeConfigFields]*big.Int {
+	maxBaseFee := feeConfig.MaxBaseFee
+	if maxBaseFee == nil {
+		maxBaseFee = new(big.Int)
+	}
+	return [numFeeConfigFields]*big.Int{
+		feeConfig.TargetGas,
+		feeConfig.BaseFeeChangeDenominator,
+		feeConfig.MinBaseFee,
+		maxBaseFee,
+		new(big.Int).SetUint64(feeConfig.TargetBlockRate),
+		feeConfig.MinBlockGasCost,
+		feeConfig.MaxBlockGasCost,
+		feeConfig.BlockGasCostStep,
+	}
+}
+
+// feeConfigFromValues is the inverse of feeConfigToValues. Values that do not
+// fit in the fields are left for FeeConfig.Verify to reject.
+func feeConfigFromValues(values [numFeeConfigFields]*big.Int) commontype.FeeConfig {
+	feeConfig := commontype.FeeConfig{
+		TargetGas:                values[0],
+		BaseFeeChangeDenominator: values[1],
+		MinBaseFee:               values[2],
+		MaxBaseFee:               values[3],
+		MinBlockGasCost:          values[5],
+		MaxBlockGasCost:          values[6],
+		BlockGasCostStep:         values[7],
+	}
+	if feeConfig.MaxBaseFee.Sign() == 0 {
+		feeConfig.MaxBaseFee = nil
+	}
+	if values[4].IsUint64() {
+		feeConfig.TargetBlockRate = values[4].Uint64()
+	}
+	return feeConfig
+}
+
+// setFeeConfig stores the fee config in its input, which can only be called
+// by an enabled address.
+func setFeeConfig(accessibleState PrecompileAccessibleState, caller common.Address, addr common.Address, input []byte, suppliedGas uint64, readOnly bool) ([]byte, uint64, error) {
+	remainingGas, err := DeductGas(suppliedGas, SetFeeConfigGasCost)
+	if err != nil {
+		return nil, 0, err
+	}
+	args, err := feeConfigManagerABI.Methods["setFeeConfig"].Inputs.Unpack(input)
+	if err != nil {
+		return nil, remainingGas, fmt.Errorf("%w: invalid input for setFeeConfig: %v", ErrExecutionReverted, err)
+	}
+	if readOnly {
+		return nil, remainingGas, ErrWriteProtection
+	}
+
+	stateDB := accessibleState.GetStateDB()
+	if !GetFeeConfigManagerStatus(stateDB, caller).IsEnabled() {
+		return nil, remainingGas, fmt.Errorf("%w: %s: %s", ErrExecutionReverted, ErrCannotChangeFee, caller)
+	}
+	var values [numFeeConfigFields]*big.Int
+	for i := range values {
+		values[i] = args[i].(*big.Int)
+	}
+	if !values[4].IsUint64() {
+		return nil, remainingGas, fmt.Errorf("%w: targetBlockRate %d does not fit in a uint64", ErrExecutionReverted, values[4])
+	}
+	if err := StoreFeeConfig(stateDB, feeConfigFromValues(values), accessibleState.GetBlockContext().Number()); err != nil {
+		return nil, remainingGas, fmt.Errorf("%w: invalid fee config: %v", ErrExecutionReverted, err)
+	}
+	return []byte{}, remainingGas, nil
+}
+
+// getFeeConfig returns the fee config stored by the fee manager.
+func getFeeConfig(accessibleState PrecompileAccessibleState, caller common.Address, addr common.Address, input []byte, suppliedGas uint64, readOnly bool) ([]byte, uint64, error) {
+	remainingGas, err := DeductGas(suppliedGas, GetFeeConfigGasCost)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	feeConfig, _ := GetStoredFeeConfig(accessibleState.GetStateDB())
+	values := feeConfigToValues(feeConfig)
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		if value == nil {
+			value = new(big.Int)
+		}
+		args[i] = value
+	}
+	ret, err := feeConfigManagerABI.Methods["getFeeConfig"].Outputs.Pack(args...)
+	if err != nil {
+		return nil, remainingGas, err
+	}
+	return ret, remainingGas, nil
+}
+
+// getFeeConfigLastChangedAt returns the number of the block in which the fee
+// config was last changed.
+func getFeeConfigLastChangedAt(accessibleState PrecompileAccessibleState, caller common.Address, addr common.Address, input []byte, suppliedGas uint64, readOnly bool) ([]byte, uint64, error) {
+	remainingGas, err := DeductGas(suppliedGas, GetLastChangedAtGasCost)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	lastChangedAt := GetFeeConfigLastChangedAt(accessibleState.GetStateDB())
+	return common.BigToHash(lastChangedAt).Bytes(), remainingGas, nil
+}
+
+// createFeeConfigManagerPrecompile returns the contract of the fee manager
+// implementing the IFeeManager interface.
+func createFeeConfigManagerPrecompile() StatefulPrecompiledContract {
+	functions := append(createAllowListFunctions(FeeConfigManagerAddress),
+		NewStatefulPrecompileFunction(feeConfigManagerABI.Methods["setFeeConfig"].ID, setFeeConfig),
+		NewStatefulPrecompileFunction(feeConfigManagerABI.Methods["getFeeConfig"].ID, getFeeConfig),
+		NewStatefulPrecompileFunction(feeConfigManagerABI.Methods["getFeeConfigLastChangedAt"].ID, getFeeConfigLastChangedAt),
+	)
+	contract, err := NewStatefulPrecompileContract(nil, functions)
+	if err != nil {
+		panic(err)
+	}
+	return contract
+}
+
+func init() {
+	if err := RegisterModule(Module{
+		ConfigKey: FeeConfigManagerConfigKey,
+		Address:   FeeConfigManagerAddress,
+		ABI:       feeConfigManagerABI,
+		Contract:  createFeeConfigManagerPrecompile(),
+		NewConfig: func() StatefulPrecompileConfig { return &FeeConfigManagerConfig{} },
+	}); err != nil {
+		panic(err)
+	}
+}
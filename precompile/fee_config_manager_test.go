@@ -0,0 +1,144 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package precompile
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ava-labs/coreth/commontype"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+var testFeeConfig = commontype.FeeConfig{
+	TargetGas:                big.NewInt(15_000_000),
+	BaseFeeChangeDenominator: big.NewInt(36),
+	MinBaseFee:               big.NewInt(25_000_000_000),
+	MaxBaseFee:               nil,
+	TargetBlockRate:          2,
+	MinBlockGasCost:          big.NewInt(0),
+	MaxBlockGasCost:          big.NewInt(1_000_000),
+	BlockGasCostStep:         big.NewInt(200_000),
+}
+
+// testChainConfig returns the same fee config at all timestamps.
+type testChainConfig struct {
+	feeConfig commontype.FeeConfig
+}
+
+func (c *testChainConfig) GetFeeConfig(*big.Int) commontype.FeeConfig { return c.feeConfig }
+
+func TestFeeConfigManagerConfigure(t *testing.T) {
+	adminAddr := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	blockContext := &testBlockContext{number: big.NewInt(3), timestamp: big.NewInt(10)}
+
+	// without an initial fee config, the fee config of the chain config is stored
+	state := newTestStateDB()
+	config := &FeeConfigManagerConfig{AllowListConfig: AllowListConfig{AdminAddresses: []common.Address{adminAddr}}}
+	assert.NoError(t, config.Verify())
+	assert.NoError(t, config.Configure(&testChainConfig{feeConfig: testFeeConfig}, state, blockContext))
+	assert.Equal(t, AllowListAdmin, GetFeeConfigManagerStatus(state, adminAddr))
+	feeConfig, ok := GetStoredFeeConfig(state)
+	assert.True(t, ok)
+	assert.True(t, testFeeConfig.Equal(&feeConfig))
+	assert.Equal(t, big.NewInt(3), GetFeeConfigLastChangedAt(state))
+
+	// the initial fee config takes precedence over the chain config
+	initialFeeConfig := testFeeConfig
+	initialFeeConfig.MaxBaseFee = big.NewInt(1_000_000_000_000)
+	state = newTestStateDB()
+	config.InitialFeeConfig = &initialFeeConfig
+	assert.NoError(t, config.Verify())
+	assert.NoError(t, config.Configure(&testChainConfig{feeConfig: testFeeConfig}, state, blockContext))
+	feeConfig, ok = GetStoredFeeConfig(state)
+	assert.True(t, ok)
+	assert.True(t, initialFeeConfig.Equal(&feeConfig))
+
+	// an invalid initial fee config is rejected
+	config.InitialFeeConfig = &commontype.FeeConfig{}
+	assert.Error(t, config.Verify())
+
+	_, ok = GetStoredFeeConfig(newTestStateDB())
+	assert.False(t, ok)
+}
+
+func TestFeeConfigManagerPrecompile(t *testing.T) {
+	var (
+		adminAddr   = common.HexToAddress("0x0000000000000000000000000000000000000001")
+		enabledAddr = common.HexToAddress("0x0000000000000000000000000000000000000002")
+		noRoleAddr  = common.HexToAddress("0x0000000000000000000000000000000000000003")
+		state       = newTestStateDB()
+		contract    = createFeeConfigManagerPrecompile()
+	)
+	config := &FeeConfigManagerConfig{AllowListConfig: AllowListConfig{AdminAddresses: []common.Address{adminAddr}}}
+	assert.NoError(t, config.Configure(&testChainConfig{feeConfig: testFeeConfig}, state, &testBlockContext{number: big.NewInt(0), timestamp: big.NewInt(0)}))
+
+	pack := func(method string, args ...interface{}) []byte {
+		input, err := feeConfigManagerABI.Pack(method, args...)
+		assert.NoError(t, err)
+		return input
+	}
+	packSetFeeConfig := func(feeConfig commontype.FeeConfig) []byte {
+		maxBaseFee := feeConfig.MaxBaseFee
+		if maxBaseFee == nil {
+			maxBaseFee = new(big.Int)
+		}
+		return pack("setFeeConfig", feeConfig.TargetGas, feeConfig.BaseFeeChangeDenominator, feeConfig.MinBaseFee, maxBaseFee,
+			new(big.Int).SetUint64(feeConfig.TargetBlockRate), feeConfig.MinBlockGasCost, feeConfig.MaxBlockGasCost, feeConfig.BlockGasCostStep)
+	}
+	run := func(caller common.Address, input []byte, readOnly bool) ([]byte, uint64, error) {
+		accessibleState := &testAccessibleState{state: state, blockContext: &testBlockContext{number: big.NewInt(7), timestamp: big.NewInt(20)}}
+		return contract.Run(accessibleState, caller, FeeConfigManagerAddress, input, 100_000, readOnly)
+	}
+
+	newFeeConfig := testFeeConfig
+	newFeeConfig.TargetGas = big.NewInt(20_000_000)
+	newFeeConfig.MaxBaseFee = big.NewInt(500_000_000_000)
+
+	// the fee manager implements the allow list
+	_, _, err := run(adminAddr, pack("setEnabled", enabledAddr), false)
+	assert.NoError(t, err)
+	assert.Equal(t, AllowListEnabled, GetFeeConfigManagerStatus(state, enabledAddr))
+
+	// addresses without a role cannot change the fee config
+	_, _, err = run(noRoleAddr, packSetFeeConfig(newFeeConfig), false)
+	assert.ErrorIs(t, err, ErrExecutionReverted)
+
+	// the fee config cannot be changed in a read only call
+	_, _, err = run(enabledAddr, packSetFeeConfig(newFeeConfig), true)
+	assert.ErrorIs(t, err, ErrWriteProtection)
+
+	// invalid fee configs are rejected
+	invalidFeeConfig := newFeeConfig
+	invalidFeeConfig.BaseFeeChangeDenominator = big.NewInt(0)
+	_, _, err = run(enabledAddr, packSetFeeConfig(invalidFeeConfig), false)
+	assert.ErrorIs(t, err, ErrExecutionReverted)
+
+	// enabled addresses can change the fee config
+	_, remainingGas, err := run(enabledAddr, packSetFeeConfig(newFeeConfig), false)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100_000-SetFeeConfigGasCost), remainingGas)
+	feeConfig, ok := GetStoredFeeConfig(state)
+	assert.True(t, ok)
+	assert.True(t, newFeeConfig.Equal(&feeConfig))
+
+	// anyone can read the fee config and when it was last changed
+	ret, remainingGas, err := run(noRoleAddr, pack("getFeeConfig"), true)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100_000-GetFeeConfigGasCost), remainingGas)
+	assert.Equal(t, packSetFeeConfig(newFeeConfig)[4:], ret)
+
+	ret, remainingGas, err = run(noRoleAddr, pack("getFeeConfigLastChangedAt"), true)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100_000-GetLastChangedAtGasCost), remainingGas)
+	assert.Equal(t, common.BigToHash(big.NewInt(7)).Bytes(), ret)
+
+	// a max base fee of 0 removes the upper bound of the base fee
+	_, _, err = run(enabledAddr, packSetFeeConfig(testFeeConfig), false)
+	assert.NoError(t, err)
+	feeConfig, ok = GetStoredFeeConfig(state)
+	assert.True(t, ok)
+	assert.Nil(t, feeConfig.MaxBaseFee)
+}
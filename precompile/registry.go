@@ -0,0 +1,131 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package precompile
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ava-labs/coreth/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// AddressRange is the range of addresses [Start, End].
+type AddressRange struct {
+	Start common.Address
+	End   common.Address
+}
+
+// Contains returns true if [addr] is within the range.
+func (a *AddressRange) Contains(addr common.Address) bool {
+	return bytes.Compare(addr[:], a.Start[:]) >= 0 && bytes.Compare(addr[:], a.End[:]) <= 0
+}
+
+// ReservedRanges contains the addresses modules may be registered at. They
+// do not overlap with the addresses of the Ethereum precompiles or the native
+// asset precompiles in core/vm.
+var ReservedRanges = []AddressRange{
+	{
+		Start: common.HexToAddress("0x0200000000000000000000000000000000000000"),
+		End:   common.HexToAddress("0x02000000000000000000000000000000000000ff"),
+	},
+}
+
+// Module is a stateful precompile added to the EVM at [Address] once it is
+// enabled by its configuration in the chain config.
+type Module struct {
+	// ConfigKey is the key of the configuration of the module in the
+	// precompiles of the chain config.
+	ConfigKey string
+	// Address is the address of the precompile, within one of [ReservedRanges].
+	Address common.Address
+	// ABI is the interface of the contract, used by callers of the precompile.
+	ABI abi.ABI
+	// Contract is the contract executed when the precompile is called.
+	Contract StatefulPrecompiledContract
+	// NewConfig returns an empty configuration of the module to decode the
+	// chain config into.
+	NewConfig func() StatefulPrecompileConfig
+}
+
+var (
+	registryLock      sync.RWMutex
+	modulesByKey      = make(map[string]Module)
+	modulesByAddress  = make(map[common.Address]Module)
+	registeredModules []Module // sorted by address
+)
+
+// RegisterModule adds [module] to the registry. It is expected to be called
+// from the init function of the package defining the module, so the module
+// is registered before any chain config is parsed.
+func RegisterModule(module Module) error {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	switch {
+	case len(module.ConfigKey) == 0:
+		return fmt.Errorf("module at %s has no config key", module.Address)
+	case module.Contract == nil:
+		return fmt.Errorf("module %s has no contract", module.ConfigKey)
+	case module.NewConfig == nil:
+		return fmt.Errorf("module %s has no config constructor", module.ConfigKey)
+	}
+	if !isReserved(module.Address) {
+		return fmt.Errorf("address %s of module %s is not within a reserved range", module.Address, module.ConfigKey)
+	}
+	if _, exists := modulesByKey[module.ConfigKey]; exists {
+		return fmt.Errorf("module with config key %s is already registered", module.ConfigKey)
+	}
+	if existing, exists := modulesByAddress[module.Address]; exists {
+		return fmt.Errorf("address %s of module %s is already used by module %s", module.Address, module.ConfigKey, existing.ConfigKey)
+	}
+
+	modulesByKey[module.ConfigKey] = module
+	modulesByAddress[module.Address] = module
+	registeredModules = append(registeredModules, module)
+	sort.Slice(registeredModules, func(i, j int) bool {
+		return bytes.Compare(registeredModules[i].Address[:], registeredModules[j].Address[:]) < 0
+	})
+	return nil
+}
+
+// GetModule returns the module registered with [configKey].
+func GetModule(configKey string) (Module, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	module, ok := modulesByKey[configKey]
+	return module, ok
+}
+
+// GetModuleByAddress returns the module registered at [address].
+func GetModuleByAddress(address common.Address) (Module, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	module, ok := modulesByAddress[address]
+	return module, ok
+}
+
+// RegisteredModules returns the registered modules sorted by address, which
+// is the order in which modules enabled in the same block are configured.
+func RegisteredModules() []Module {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	modules := make([]Module, len(registeredModules))
+	copy(modules, registeredModules)
+	return modules
+}
+
+func isReserved(addr common.Address) bool {
+	for _, reservedRange := range ReservedRanges {
+		if reservedRange.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
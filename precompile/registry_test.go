@@ -0,0 +1,108 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package precompile
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type testConfig struct {
+	UpgradeableConfig
+}
+
+func (*testConfig) Verify() error { return nil }
+
+func (*testConfig) Configure(ChainConfig, StateDB, BlockContext) error { return nil }
+
+func newTestContract(t *testing.T) StatefulPrecompiledContract {
+	echo := func(accessibleState PrecompileAccessibleState, caller common.Address, addr common.Address, input []byte, suppliedGas uint64, readOnly bool) ([]byte, uint64, error) {
+		remainingGas, err := DeductGas(suppliedGas, 10)
+		if err != nil {
+			return nil, 0, err
+		}
+		return input, remainingGas, nil
+	}
+	contract, err := NewStatefulPrecompileContract(nil, []*StatefulPrecompileFunction{
+		NewStatefulPrecompileFunction([]byte{1, 2, 3, 4}, echo),
+	})
+	assert.NoError(t, err)
+	return contract
+}
+
+func TestStatefulPrecompileContract(t *testing.T) {
+	contract := newTestContract(t)
+	addr := common.HexToAddress("0x0200000000000000000000000000000000000001")
+
+	ret, remainingGas, err := contract.Run(nil, common.Address{}, addr, []byte{1, 2, 3, 4, 5}, 100, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{5}, ret)
+	assert.Equal(t, uint64(90), remainingGas)
+
+	_, _, err = contract.Run(nil, common.Address{}, addr, []byte{1, 2, 3, 4}, 5, false)
+	assert.ErrorIs(t, err, ErrOutOfGas)
+
+	_, remainingGas, err = contract.Run(nil, common.Address{}, addr, []byte{4, 3, 2, 1}, 100, false)
+	assert.ErrorIs(t, err, ErrExecutionReverted)
+	assert.Equal(t, uint64(100), remainingGas)
+
+	_, _, err = contract.Run(nil, common.Address{}, addr, []byte{1, 2}, 100, false)
+	assert.ErrorIs(t, err, ErrExecutionReverted)
+
+	_, _, err = contract.Run(nil, common.Address{}, addr, nil, 100, false)
+	assert.ErrorIs(t, err, ErrExecutionReverted, "calls without input should revert without a fallback")
+
+	_, err = NewStatefulPrecompileContract(nil, []*StatefulPrecompileFunction{
+		NewStatefulPrecompileFunction([]byte{1, 2, 3, 4}, nil),
+		NewStatefulPrecompileFunction([]byte{1, 2, 3, 4}, nil),
+	})
+	assert.Error(t, err, "duplicate selectors should be rejected")
+}
+
+func TestRegisterModule(t *testing.T) {
+	contract := newTestContract(t)
+	newConfig := func() StatefulPrecompileConfig { return &testConfig{} }
+	module := Module{
+		ConfigKey: "testRegisterModuleConfig",
+		Address:   common.HexToAddress("0x02000000000000000000000000000000000000f0"),
+		Contract:  contract,
+		NewConfig: newConfig,
+	}
+	assert.NoError(t, RegisterModule(module))
+
+	registered, ok := GetModule(module.ConfigKey)
+	assert.True(t, ok)
+	assert.Equal(t, module.Address, registered.Address)
+	registered, ok = GetModuleByAddress(module.Address)
+	assert.True(t, ok)
+	assert.Equal(t, module.ConfigKey, registered.ConfigKey)
+
+	// the config key and the address must be unique
+	assert.Error(t, RegisterModule(Module{
+		ConfigKey: module.ConfigKey,
+		Address:   common.HexToAddress("0x02000000000000000000000000000000000000f1"),
+		Contract:  contract,
+		NewConfig: newConfig,
+	}))
+	assert.Error(t, RegisterModule(Module{
+		ConfigKey: "otherConfig",
+		Address:   module.Address,
+		Contract:  contract,
+		NewConfig: newConfig,
+	}))
+	// the address must be reserved
+	assert.Error(t, RegisterModule(Module{
+		ConfigKey: "otherConfig",
+		Address:   common.HexToAddress("0x0100000000000000000000000000000000000001"),
+		Contract:  contract,
+		NewConfig: newConfig,
+	}))
+
+	modules := RegisteredModules()
+	for i := 1; i < len(modules); i++ {
+		assert.Less(t, modules[i-1].Address.Hex(), modules[i].Address.Hex())
+	}
+}
@@ -0,0 +1,46 @@
+// (c) 2019-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package precompile
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const TxAllowListConfigKey = "txAllowListConfig"
+
+// TxAllowListAddress is the address of the allow list of the addresses
+// permitted to send transactions. Once enabled, transactions from addresses
+// that are not enabled are rejected before execution.
+var TxAllowListAddress = common.HexToAddress("0x0200000000000000000000000000000000000002")
+
+// TxAllowListConfig is the configuration of the transaction allow list in the
+// chain config.
+type TxAllowListConfig struct {
+	UpgradeableConfig
+	AllowListConfig
+}
+
+// Configure implements StatefulPrecompileConfig
+func (c *TxAllowListConfig) Configure(_ ChainConfig, state StateDB, _ BlockContext) error {
+	c.AllowListConfig.configure(state, TxAllowListAddress)
+	return nil
+}
+
+// GetTxAllowListStatus returns the role of [address] in the transaction
+// allow list.
+func GetTxAllowListStatus(state StateDB, address common.Address) AllowListRole {
+	return GetAllowListStatus(state, TxAllowListAddress, address)
+}
+
+func init() {
+	if err := RegisterModule(Module{
+		ConfigKey: TxAllowListConfigKey,
+		Address:   TxAllowListAddress,
+		ABI:       allowListABI,
+		Contract:  createAllowListPrecompile(TxAllowListAddress),
+		NewConfig: func() StatefulPrecompileConfig { return &TxAllowListConfig{} },
+	}); err != nil {
+		panic(err)
+	}
+}
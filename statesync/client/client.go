@@ -0,0 +1,359 @@
+// (c) 2021-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package statesyncclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ava-labs/avalanchego/codec"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/version"
+
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/ethdb"
+	"github.com/ava-labs/coreth/ethdb/memorydb"
+	"github.com/ava-labs/coreth/peer"
+	"github.com/ava-labs/coreth/plugin/evm/message"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	_ Client = &client{}
+
+	errEmptyResponse          = errors.New("empty response")
+	errTooManyBlocks          = errors.New("response contains more blocks than requested")
+	errHashMismatch           = errors.New("hash does not match expected value")
+	errInvalidRangeProof      = errors.New("failed to verify range proof")
+	errTooManyLeaves          = errors.New("response contains more than requested leaves")
+	errUnmatchedLeafsResponse = errors.New("response contains a different number of keys and values")
+	errExceededRetryLimit     = errors.New("exceeded request retry limit")
+)
+
+// Client synchronously fetches data from the network to fulfill state sync requests.
+// Every response is verified before it is returned and invalid responses are retried
+// against another peer.
+type Client interface {
+	// GetLeafs synchronously sends the given request, returning a verified LeafsResponse.
+	// The More flag of the response is set from the range proof.
+	GetLeafs(ctx context.Context, request message.LeafsRequest) (message.LeafsResponse, error)
+
+	// GetBlocks synchronously retrieves the block with [blockHash] at [height] followed
+	// by up to [parents]-1 of its ancestors, newest first.
+	GetBlocks(ctx context.Context, blockHash common.Hash, height uint64, parents uint16) ([]*types.Block, error)
+
+	// GetCode synchronously retrieves the contract code with [codeHash].
+	GetCode(ctx context.Context, codeHash common.Hash) ([]byte, error)
+
+	// GetSyncableBlocks synchronously retrieves the most recent syncable block
+	// served by each of up to [n] distinct peers, keyed by the nodeID of the peer.
+	// Peers that failed to respond or sent an invalid syncable block map to an
+	// empty message.SyncableBlock.
+	GetSyncableBlocks(n int) (map[ids.ShortID]message.SyncableBlock, error)
+}
+
+// parseResponseFn parses [response] to the type expected by [request] and verifies it.
+// Returns the parsed response or an error if the response is invalid.
+type parseResponseFn func(codec codec.Manager, request message.Request, response []byte) (interface{}, error)
+
+type ClientConfig struct {
+	NetworkClient    peer.Client
+	Codec            codec.Manager
+	MaxAttempts      uint8
+	MaxRetryDelay    time.Duration
+	StateSyncVersion version.Application
+}
+
+// client implements the Client interface on top of a peer.Client
+type client struct {
+	networkClient    peer.Client
+	codec            codec.Manager
+	maxAttempts      uint8
+	maxRetryDelay    time.Duration
+	stateSyncVersion version.Application
+}
+
+func NewClient(config *ClientConfig) Client {
+	return &client{
+		networkClient:    config.NetworkClient,
+		codec:            config.Codec,
+		maxAttempts:      config.MaxAttempts,
+		maxRetryDelay:    config.MaxRetryDelay,
+		stateSyncVersion: config.StateSyncVersion,
+	}
+}
+
+// GetLeafs synchronously retrieves leafs as per given [message.LeafsRequest]
+// Retries the request until a response passes range proof verification
+// or the retry limit is reached.
+func (c *client) GetLeafs(ctx context.Context, request message.LeafsRequest) (message.LeafsResponse, error) {
+	data, err := c.get(ctx, request, parseLeafsResponse)
+	if err != nil {
+		return message.LeafsResponse{}, err
+	}
+	return data.(message.LeafsResponse), nil
+}
+
+// parseLeafsResponse validates given object as message.LeafsResponse
+// assumes reqIntf is of type message.LeafsRequest
+// returns a non-nil error if the request should be retried
+// returns error when:
+// - response bytes could not be marshalled into message.LeafsResponse
+// - number of response keys is not equal to the response values
+// - response contains more leaves than the request limit
+// - response contains keys outside of the requested range
+// - range proof fails to verify against the requested root
+func parseLeafsResponse(codec codec.Manager, reqIntf message.Request, data []byte) (interface{}, error) {
+	var leafsResponse message.LeafsResponse
+	if _, err := codec.Unmarshal(data, &leafsResponse); err != nil {
+		return nil, err
+	}
+
+	leafsRequest := reqIntf.(message.LeafsRequest)
+
+	if len(leafsResponse.Keys) > int(leafsRequest.Limit) {
+		return nil, fmt.Errorf("%w: (%d) > %d)", errTooManyLeaves, len(leafsResponse.Keys), leafsRequest.Limit)
+	}
+	if len(leafsResponse.Keys) != len(leafsResponse.Vals) {
+		return nil, fmt.Errorf("%w: (%d) != (%d)", errUnmatchedLeafsResponse, len(leafsResponse.Keys), len(leafsResponse.Vals))
+	}
+	if len(leafsResponse.ProofKeys) != len(leafsResponse.ProofVals) {
+		return nil, fmt.Errorf("%w: proof keys (%d) != proof vals (%d)", errUnmatchedLeafsResponse, len(leafsResponse.ProofKeys), len(leafsResponse.ProofVals))
+	}
+
+	// An empty proof is only valid if the response contains the entire trie.
+	var proof ethdb.KeyValueReader
+	if len(leafsResponse.ProofKeys) > 0 {
+		proofDB := memorydb.New()
+		defer proofDB.Close() // Closing the memorydb should never error
+		for i, proofKey := range leafsResponse.ProofKeys {
+			if err := proofDB.Put(proofKey, leafsResponse.ProofVals[i]); err != nil {
+				return nil, err
+			}
+		}
+		proof = proofDB
+	}
+
+	firstKey := leafsRequest.Start
+	lastKey := leafsRequest.End
+	if len(leafsResponse.Keys) > 0 {
+		lastKey = leafsResponse.Keys[len(leafsResponse.Keys)-1]
+	}
+	if len(leafsResponse.Keys) > 0 && bytes.Compare(leafsResponse.Keys[0], firstKey) < 0 {
+		return nil, fmt.Errorf("%w: first key %x before requested start %x", errInvalidRangeProof, leafsResponse.Keys[0], firstKey)
+	}
+	if len(leafsRequest.End) > 0 && bytes.Compare(lastKey, leafsRequest.End) > 0 {
+		return nil, fmt.Errorf("%w: last key %x after requested end %x", errInvalidRangeProof, lastKey, leafsRequest.End)
+	}
+
+	// An empty response to a request for a range ending before the last key
+	// of the trie proves there are no leafs within the range, while there
+	// may be leafs to the right of it.
+	if len(leafsResponse.Keys) == 0 && proof != nil && len(leafsRequest.End) > 0 {
+		if err := trie.VerifyEmptyRangeProof(leafsRequest.Root, firstKey, leafsRequest.End, proof); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidRangeProof, err)
+		}
+		leafsResponse.More = false
+		return leafsResponse, nil
+	}
+
+	more, err := trie.VerifyRangeProof(leafsRequest.Root, firstKey, lastKey, leafsResponse.Keys, leafsResponse.Vals, proof)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidRangeProof, err)
+	}
+	leafsResponse.More = more
+	return leafsResponse, nil
+}
+
+// GetBlocks synchronously retrieves blocks starting with specified common.Hash and height up to specified parents
+// Retries the request until a response contains a valid chain of blocks or the retry limit is reached.
+func (c *client) GetBlocks(ctx context.Context, hash common.Hash, height uint64, parents uint16) ([]*types.Block, error) {
+	req := message.BlockRequest{
+		Hash:    hash,
+		Height:  height,
+		Parents: parents,
+	}
+
+	data, err := c.get(ctx, req, parseBlocks)
+	if err != nil {
+		return nil, err
+	}
+	return data.(types.Blocks), nil
+}
+
+// parseBlocks validates given object as message.BlockResponse
+// assumes req is of type message.BlockRequest
+// returns types.Blocks as interface{}
+// returns a non-nil error if the request should be retried
+func parseBlocks(codec codec.Manager, req message.Request, data []byte) (interface{}, error) {
+	var response message.BlockResponse
+	if _, err := codec.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Blocks) == 0 {
+		return nil, errEmptyResponse
+	}
+	blockRequest := req.(message.BlockRequest)
+	numParentsRequested := blockRequest.Parents
+	if len(response.Blocks) > int(numParentsRequested) {
+		return nil, errTooManyBlocks
+	}
+
+	hash := blockRequest.Hash
+
+	// attempt to decode blocks
+	blocks := make(types.Blocks, len(response.Blocks))
+	for i, blkBytes := range response.Blocks {
+		block := new(types.Block)
+		if err := rlp.DecodeBytes(blkBytes, block); err != nil {
+			return nil, err
+		}
+
+		if block.Hash() != hash {
+			return nil, fmt.Errorf("%w for block: (got %v) (expected %v)", errHashMismatch, block.Hash(), hash)
+		}
+
+		blocks[i] = block
+		hash = block.ParentHash()
+	}
+
+	// return decoded blocks
+	return blocks, nil
+}
+
+// GetCode synchronously retrieves the code with [codeHash]
+// Retries the request until the returned code hashes to [codeHash] or the retry limit is reached.
+func (c *client) GetCode(ctx context.Context, codeHash common.Hash) ([]byte, error) {
+	data, err := c.get(ctx, message.NewCodeRequest(codeHash), parseCode)
+	if err != nil {
+		return nil, err
+	}
+	return data.([]byte), nil
+}
+
+// parseCode validates given object as a code object
+// assumes req is of type message.CodeRequest
+// returns a non-nil error if the request should be retried
+func parseCode(codec codec.Manager, req message.Request, data []byte) (interface{}, error) {
+	var response message.CodeResponse
+	if _, err := codec.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+
+	codeRequest := req.(message.CodeRequest)
+	if hash := crypto.Keccak256Hash(response.Data); hash != codeRequest.Hash {
+		return nil, fmt.Errorf("%w for code: (got %v) (expected %v)", errHashMismatch, hash, codeRequest.Hash)
+	}
+	return response.Data, nil
+}
+
+// GetSyncableBlocks synchronously retrieves the most recent syncable block of up to [n]
+// distinct peers selected regardless of their score, so that the syncable blocks of
+// the peers are independent of each other.
+// The returned blocks are not verified against the chain, callers are expected to
+// select between the syncable blocks of the peers and verify the selected block.
+func (c *client) GetSyncableBlocks(n int) (map[ids.ShortID]message.SyncableBlock, error) {
+	request := message.SyncableBlockRequest{}
+	requestBytes, err := message.RequestToBytes(c.codec, request)
+	if err != nil {
+		return nil, err
+	}
+	responses, err := c.networkClient.RequestFromN(c.stateSyncVersion, n, requestBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	syncableBlocks := make(map[ids.ShortID]message.SyncableBlock, len(responses))
+	for nodeID, response := range responses {
+		syncableBlocks[nodeID] = message.SyncableBlock{}
+		if response == nil {
+			continue
+		}
+		syncableBlock, err := parseSyncableBlock(c.codec, request, response)
+		if err != nil {
+			log.Info("could not validate syncable block", "nodeID", nodeID, "err", err)
+			c.networkClient.TrackInvalidResponse(nodeID)
+			continue
+		}
+		syncableBlocks[nodeID] = syncableBlock.(message.SyncableBlock)
+	}
+	return syncableBlocks, nil
+}
+
+// parseSyncableBlock validates given object as message.SyncableBlock
+// returns a non-nil error if the request should be retried
+func parseSyncableBlock(codec codec.Manager, _ message.Request, data []byte) (interface{}, error) {
+	var syncableBlock message.SyncableBlock
+	if _, err := codec.Unmarshal(data, &syncableBlock); err != nil {
+		return nil, err
+	}
+	if syncableBlock.BlockHash == (common.Hash{}) || syncableBlock.BlockRoot == (common.Hash{}) {
+		return nil, fmt.Errorf("invalid syncable block: %s", syncableBlock)
+	}
+	return syncableBlock, nil
+}
+
+// get submits given request and blockingly returns with either a parsed response object or error
+// retry is made if there is a network error or if the [parseResponseFn] returns a non-nil error
+// returns parsed struct as interface{} returned by parseResponseFn
+// returns errExceededRetryLimit if the request could not be fulfilled within [maxAttempts]
+// returns ctx.Err() if [ctx] is cancelled before the request is fulfilled
+func (c *client) get(ctx context.Context, request message.Request, parseFn parseResponseFn) (interface{}, error) {
+	requestBytes, err := message.RequestToBytes(c.codec, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for attempt := uint8(0); attempt < c.maxAttempts; attempt++ {
+		// If this is a retry attempt, wait before sending the request to
+		// avoid hammering the network with invalid requests.
+		if attempt > 0 {
+			timer := time.NewTimer(c.retryDelay(attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		response, nodeID, err := c.networkClient.RequestAny(c.stateSyncVersion, requestBytes)
+		if err != nil {
+			log.Debug("request failed, retrying", "request", request, "attempt", attempt, "err", err)
+			lastErr = err
+			continue
+		}
+
+		responseIntf, err := parseFn(c.codec, request, response)
+		if err != nil {
+			log.Info("could not validate response, retrying", "nodeID", nodeID, "request", request, "attempt", attempt, "err", err)
+			c.networkClient.TrackInvalidResponse(nodeID)
+			lastErr = err
+			continue
+		}
+		return responseIntf, nil
+	}
+
+	return nil, fmt.Errorf("%s %w, last error: %v", request, errExceededRetryLimit, lastErr)
+}
+
+// retryDelay returns the time to wait before making retry [attempt].
+// The delay grows linearly with [attempt] and is capped at [maxRetryDelay].
+func (c *client) retryDelay(attempt uint8) time.Duration {
+	delay := time.Duration(attempt) * 100 * time.Millisecond
+	if delay > c.maxRetryDelay {
+		return c.maxRetryDelay
+	}
+	return delay
+}
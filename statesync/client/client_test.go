@@ -0,0 +1,361 @@
+// (c) 2021-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package statesyncclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"math/rand"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/version"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ava-labs/coreth/ethdb/memorydb"
+	"github.com/ava-labs/coreth/plugin/evm/message"
+	"github.com/ava-labs/coreth/statesync/handlers"
+	"github.com/ava-labs/coreth/statesync/handlers/stats"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testNetworkClient is a peer.Client that returns the given responses in order
+type testNetworkClient struct {
+	responses [][]byte
+	errs      []error
+	requests  int32
+
+	invalidResponses int
+}
+
+func (t *testNetworkClient) RequestAny(_ version.Application, _ []byte) ([]byte, ids.ShortID, error) {
+	i := int(atomic.AddInt32(&t.requests, 1)) - 1
+	if i < len(t.errs) && t.errs[i] != nil {
+		return nil, ids.ShortEmpty, t.errs[i]
+	}
+	return t.responses[i], ids.ShortEmpty, nil
+}
+
+func (t *testNetworkClient) RequestFromN(_ version.Application, n int, request []byte) (map[ids.ShortID][]byte, error) {
+	responses := make(map[ids.ShortID][]byte, n)
+	for i := 0; i < n; i++ {
+		response, _, _ := t.RequestAny(nil, request)
+		responses[ids.ShortID{byte(i + 1)}] = response
+	}
+	return responses, nil
+}
+
+func (t *testNetworkClient) Request(_ ids.ShortID, request []byte) ([]byte, error) {
+	response, _, err := t.RequestAny(nil, request)
+	return response, err
+}
+
+func (t *testNetworkClient) Gossip([]byte) error { return nil }
+
+func (t *testNetworkClient) TrackInvalidResponse(ids.ShortID) { t.invalidResponses++ }
+
+func TestGetCode(t *testing.T) {
+	codec, err := message.BuildCodec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := []byte("this is the code")
+	codeHash := crypto.Keccak256Hash(code)
+	validResponse, err := codec.Marshal(message.Version, message.CodeResponse{Data: code})
+	assert.NoError(t, err)
+	invalidResponse, err := codec.Marshal(message.Version, message.CodeResponse{Data: []byte("not the code")})
+	assert.NoError(t, err)
+
+	networkClient := &testNetworkClient{
+		responses: [][]byte{nil, invalidResponse, validResponse},
+		errs:      []error{errors.New("network failure"), nil, nil},
+	}
+	client := NewClient(&ClientConfig{
+		NetworkClient: networkClient,
+		Codec:         codec,
+		MaxAttempts:   3,
+	})
+
+	response, err := client.GetCode(context.Background(), codeHash)
+	assert.NoError(t, err)
+	assert.Equal(t, code, response)
+	assert.EqualValues(t, 3, networkClient.requests)
+	assert.Equal(t, 1, networkClient.invalidResponses)
+
+	// exhaust the retry limit with invalid responses
+	networkClient = &testNetworkClient{responses: [][]byte{invalidResponse, invalidResponse}}
+	client = NewClient(&ClientConfig{
+		NetworkClient: networkClient,
+		Codec:         codec,
+		MaxAttempts:   2,
+	})
+	_, err = client.GetCode(context.Background(), codeHash)
+	assert.ErrorIs(t, err, errExceededRetryLimit)
+}
+
+func TestGetCancelled(t *testing.T) {
+	codec, err := message.BuildCodec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	networkClient := &testNetworkClient{
+		errs: []error{errors.New("network failure"), errors.New("network failure")},
+	}
+	client := NewClient(&ClientConfig{
+		NetworkClient: networkClient,
+		Codec:         codec,
+		MaxAttempts:   32,
+		MaxRetryDelay: time.Hour,
+	})
+
+	// the retry delay is interrupted by the cancellation of the context
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for atomic.LoadInt32(&networkClient.requests) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	_, err = client.GetCode(ctx, common.Hash{1})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&networkClient.requests))
+
+	// no request is sent with a cancelled context
+	_, err = client.GetCode(ctx, common.Hash{1})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&networkClient.requests))
+}
+
+func TestGetSyncableBlocks(t *testing.T) {
+	codec, err := message.BuildCodec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	syncableBlock := message.SyncableBlock{
+		BlockNumber: 4096,
+		BlockRoot:   common.Hash{1},
+		AtomicRoot:  common.Hash{2},
+		BlockHash:   common.Hash{3},
+	}
+	validResponse, err := codec.Marshal(message.Version, syncableBlock)
+	assert.NoError(t, err)
+	invalidResponse, err := codec.Marshal(message.Version, message.SyncableBlock{BlockNumber: 4096})
+	assert.NoError(t, err)
+
+	networkClient := &testNetworkClient{
+		responses: [][]byte{validResponse, invalidResponse, nil, validResponse},
+		errs:      []error{nil, nil, errors.New("network failure"), nil},
+	}
+	client := NewClient(&ClientConfig{
+		NetworkClient: networkClient,
+		Codec:         codec,
+		MaxAttempts:   1,
+	})
+
+	// failed requests and invalid syncable blocks map to an empty syncable block
+	syncableBlocks, err := client.GetSyncableBlocks(4)
+	assert.NoError(t, err)
+	assert.Equal(t, map[ids.ShortID]message.SyncableBlock{
+		{1}: syncableBlock,
+		{2}: {},
+		{3}: {},
+		{4}: syncableBlock,
+	}, syncableBlocks)
+	assert.Equal(t, 1, networkClient.invalidResponses)
+}
+
+func TestParseLeafsResponse(t *testing.T) {
+	codec, err := message.BuildCodec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trieDB := trie.NewDatabase(memorydb.New())
+	tr, err := trie.New(common.Hash{}, trieDB)
+	assert.NoError(t, err)
+
+	rand.Seed(1)
+	for i := 0; i < 2_000; i++ {
+		data := make([]byte, rand.Intn(32)+32)
+		_, err := rand.Read(data)
+		assert.NoError(t, err)
+		key := crypto.Keccak256Hash(data)
+		assert.NoError(t, tr.TryUpdate(key[:], data))
+	}
+	root, _, err := tr.Commit(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, trieDB.Commit(root, false, nil))
+
+	handler := handlers.NewLeafsRequestHandler(trieDB, stats.NewNoopHandlerStats(), codec)
+	request := message.LeafsRequest{
+		Root:     root,
+		Start:    bytes.Repeat([]byte{0x00}, common.HashLength),
+		End:      bytes.Repeat([]byte{0xff}, common.HashLength),
+		Limit:    1024,
+		NodeType: message.StateTrieNode,
+	}
+
+	tests := map[string]struct {
+		modifyResponse func(*message.LeafsResponse)
+		expectedErr    error
+		expectedMore   bool
+	}{
+		"valid partial response": {
+			modifyResponse: func(*message.LeafsResponse) {},
+			expectedMore:   true,
+		},
+		"modified value": {
+			modifyResponse: func(response *message.LeafsResponse) {
+				response.Vals[10] = []byte("invalid value")
+			},
+			expectedErr: errInvalidRangeProof,
+		},
+		"removed key": {
+			modifyResponse: func(response *message.LeafsResponse) {
+				response.Keys = append(response.Keys[:10], response.Keys[11:]...)
+				response.Vals = append(response.Vals[:10], response.Vals[11:]...)
+			},
+			expectedErr: errInvalidRangeProof,
+		},
+		"unmatched keys and values": {
+			modifyResponse: func(response *message.LeafsResponse) {
+				response.Vals = response.Vals[1:]
+			},
+			expectedErr: errUnmatchedLeafsResponse,
+		},
+		"removed proof": {
+			modifyResponse: func(response *message.LeafsResponse) {
+				response.ProofKeys = nil
+				response.ProofVals = nil
+			},
+			expectedErr: errInvalidRangeProof,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			responseBytes, err := handler.OnLeafsRequest(context.Background(), ids.GenerateTestShortID(), 1, request)
+			assert.NoError(t, err)
+
+			var response message.LeafsResponse
+			_, err = codec.Unmarshal(responseBytes, &response)
+			assert.NoError(t, err)
+			test.modifyResponse(&response)
+			responseBytes, err = codec.Marshal(message.Version, response)
+			assert.NoError(t, err)
+
+			parsed, err := parseLeafsResponse(codec, request, responseBytes)
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedMore, parsed.(message.LeafsResponse).More)
+		})
+	}
+}
+
+func TestParseEmptyLeafsResponse(t *testing.T) {
+	codec, err := message.BuildCodec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trieDB := trie.NewDatabase(memorydb.New())
+	tr, err := trie.New(common.Hash{}, trieDB)
+	assert.NoError(t, err)
+
+	rand.Seed(1)
+	keys := make([]common.Hash, 0, 500)
+	for i := 0; i < 500; i++ {
+		data := make([]byte, rand.Intn(32)+32)
+		_, err := rand.Read(data)
+		assert.NoError(t, err)
+		key := crypto.Keccak256Hash(data)
+		assert.NoError(t, tr.TryUpdate(key[:], data))
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i][:], keys[j][:]) < 0 })
+	root, _, err := tr.Commit(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, trieDB.Commit(root, false, nil))
+
+	handler := handlers.NewLeafsRequestHandler(trieDB, stats.NewNoopHandlerStats(), codec)
+
+	tests := map[string]struct {
+		start, end     []byte
+		modifyResponse func(*message.LeafsResponse)
+		expectedErr    error
+	}{
+		"empty range between leafs": {
+			start:          nextKeyForTest(keys[100][:]),
+			end:            previousKeyForTest(keys[101][:]),
+			modifyResponse: func(*message.LeafsResponse) {},
+		},
+		"empty range after the last leaf": {
+			start:          nextKeyForTest(keys[len(keys)-1][:]),
+			end:            bytes.Repeat([]byte{0xff}, common.HashLength),
+			modifyResponse: func(*message.LeafsResponse) {},
+		},
+		"omitted leafs within range": {
+			start: nextKeyForTest(keys[100][:]),
+			end:   keys[120][:],
+			modifyResponse: func(response *message.LeafsResponse) {
+				response.Keys = nil
+				response.Vals = nil
+			},
+			expectedErr: errInvalidRangeProof,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			request := message.LeafsRequest{
+				Root:     root,
+				Start:    test.start,
+				End:      test.end,
+				Limit:    1024,
+				NodeType: message.StateTrieNode,
+			}
+			responseBytes, err := handler.OnLeafsRequest(context.Background(), ids.GenerateTestShortID(), 1, request)
+			assert.NoError(t, err)
+
+			var response message.LeafsResponse
+			_, err = codec.Unmarshal(responseBytes, &response)
+			assert.NoError(t, err)
+			test.modifyResponse(&response)
+			responseBytes, err = codec.Marshal(message.Version, response)
+			assert.NoError(t, err)
+
+			parsed, err := parseLeafsResponse(codec, request, responseBytes)
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Empty(t, parsed.(message.LeafsResponse).Keys)
+			assert.False(t, parsed.(message.LeafsResponse).More)
+		})
+	}
+}
+
+// nextKeyForTest returns [key] incremented by one.
+func nextKeyForTest(key []byte) []byte {
+	return new(big.Int).Add(new(big.Int).SetBytes(key), common.Big1).FillBytes(make([]byte, len(key)))
+}
+
+// previousKeyForTest returns [key] decremented by one.
+func previousKeyForTest(key []byte) []byte {
+	return new(big.Int).Sub(new(big.Int).SetBytes(key), common.Big1).FillBytes(make([]byte, len(key)))
+}
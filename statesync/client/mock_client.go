@@ -0,0 +1,163 @@
+// (c) 2021-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package statesyncclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ava-labs/avalanchego/codec"
+	"github.com/ava-labs/avalanchego/ids"
+
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/plugin/evm/message"
+	"github.com/ava-labs/coreth/statesync/handlers"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	_ Client = &MockClient{}
+
+	errNoHandler = errors.New("no handler configured for request")
+)
+
+// MockClient is a mock implementation of Client that serves requests directly
+// from the given handlers, verifying responses as the real client would.
+// Intended for use in tests.
+type MockClient struct {
+	codec                codec.Manager
+	leafsHandler         *handlers.LeafsRequestHandler
+	codeHandler          *handlers.CodeRequestHandler
+	blocksHandler        *handlers.BlockRequestHandler
+	syncableBlockHandler *handlers.SyncableBlockRequestHandler
+
+	// GetLeafsIntercept is called on every response from [leafsHandler]
+	// and may be used to modify or fail the response.
+	GetLeafsIntercept func(message.LeafsRequest, message.LeafsResponse) (message.LeafsResponse, error)
+
+	leafsReceived int32
+	codeReceived  int32
+}
+
+func NewMockClient(
+	codec codec.Manager,
+	leafHandler *handlers.LeafsRequestHandler,
+	codeHandler *handlers.CodeRequestHandler,
+	blocksHandler *handlers.BlockRequestHandler,
+	syncableBlockHandler *handlers.SyncableBlockRequestHandler,
+) *MockClient {
+	return &MockClient{
+		codec:                codec,
+		leafsHandler:         leafHandler,
+		codeHandler:          codeHandler,
+		blocksHandler:        blocksHandler,
+		syncableBlockHandler: syncableBlockHandler,
+	}
+}
+
+func (ml *MockClient) GetLeafs(ctx context.Context, request message.LeafsRequest) (message.LeafsResponse, error) {
+	if ml.leafsHandler == nil {
+		return message.LeafsResponse{}, errNoHandler
+	}
+	response, err := ml.leafsHandler.OnLeafsRequest(ctx, ids.GenerateTestShortID(), 1, request)
+	if err != nil {
+		return message.LeafsResponse{}, err
+	}
+	if response == nil {
+		return message.LeafsResponse{}, fmt.Errorf("%s: %w", request, errEmptyResponse)
+	}
+
+	leafResponseIntf, err := parseLeafsResponse(ml.codec, request, response)
+	if err != nil {
+		return message.LeafsResponse{}, err
+	}
+	leafsResponse := leafResponseIntf.(message.LeafsResponse)
+	if ml.GetLeafsIntercept != nil {
+		leafsResponse, err = ml.GetLeafsIntercept(request, leafsResponse)
+	}
+	// Increment the number of leaf responses received
+	atomic.AddInt32(&ml.leafsReceived, 1)
+	return leafsResponse, err
+}
+
+func (ml *MockClient) LeafsReceived() int32 {
+	return atomic.LoadInt32(&ml.leafsReceived)
+}
+
+func (ml *MockClient) GetCode(ctx context.Context, codeHash common.Hash) ([]byte, error) {
+	if ml.codeHandler == nil {
+		return nil, errNoHandler
+	}
+	request := message.NewCodeRequest(codeHash)
+	response, err := ml.codeHandler.OnCodeRequest(ctx, ids.GenerateTestShortID(), 1, request)
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, fmt.Errorf("%s: %w", request, errEmptyResponse)
+	}
+
+	code, err := parseCode(ml.codec, request, response)
+	if err != nil {
+		return nil, err
+	}
+	// Increment the number of code responses received
+	atomic.AddInt32(&ml.codeReceived, 1)
+	return code.([]byte), nil
+}
+
+func (ml *MockClient) CodeReceived() int32 {
+	return atomic.LoadInt32(&ml.codeReceived)
+}
+
+func (ml *MockClient) GetBlocks(ctx context.Context, blockHash common.Hash, height uint64, parents uint16) ([]*types.Block, error) {
+	if ml.blocksHandler == nil {
+		return nil, errNoHandler
+	}
+	request := message.BlockRequest{
+		Hash:    blockHash,
+		Height:  height,
+		Parents: parents,
+	}
+	response, err := ml.blocksHandler.OnBlockRequest(ctx, ids.GenerateTestShortID(), 1, request)
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, fmt.Errorf("%s: %w", request, errEmptyResponse)
+	}
+
+	blocks, err := parseBlocks(ml.codec, request, response)
+	if err != nil {
+		return nil, err
+	}
+	return blocks.(types.Blocks), nil
+}
+
+func (ml *MockClient) GetSyncableBlocks(n int) (map[ids.ShortID]message.SyncableBlock, error) {
+	if ml.syncableBlockHandler == nil {
+		return nil, errNoHandler
+	}
+	request := message.SyncableBlockRequest{}
+	syncableBlocks := make(map[ids.ShortID]message.SyncableBlock, n)
+	for i := 0; i < n; i++ {
+		nodeID := ids.GenerateTestShortID()
+		response, err := ml.syncableBlockHandler.OnSyncableBlockRequest(context.Background(), nodeID, 1, request)
+		if err != nil {
+			return nil, err
+		}
+		if response == nil {
+			syncableBlocks[nodeID] = message.SyncableBlock{}
+			continue
+		}
+		syncableBlock, err := parseSyncableBlock(ml.codec, request, response)
+		if err != nil {
+			return nil, err
+		}
+		syncableBlocks[nodeID] = syncableBlock.(message.SyncableBlock)
+	}
+	return syncableBlocks, nil
+}
@@ -17,6 +17,7 @@ type syncHandler struct {
 	atomicTrieLeafsRequestHandler *LeafsRequestHandler
 	blockRequestHandler           *BlockRequestHandler
 	codeRequestHandler            *CodeRequestHandler
+	syncableBlockRequestHandler   *SyncableBlockRequestHandler
 }
 
 func NewSyncHandler(
@@ -24,12 +25,14 @@ func NewSyncHandler(
 	atomicTrieLeafsRequestHandler *LeafsRequestHandler,
 	blockRequestHandler *BlockRequestHandler,
 	codeRequestHandler *CodeRequestHandler,
+	syncableBlockRequestHandler *SyncableBlockRequestHandler,
 ) message.RequestHandler {
 	return &syncHandler{
 		stateTrieLeafsRequestHandler:  stateTrieLeafsRequestHandler,
 		atomicTrieLeafsRequestHandler: atomicTrieLeafsRequestHandler,
 		blockRequestHandler:           blockRequestHandler,
 		codeRequestHandler:            codeRequestHandler,
+		syncableBlockRequestHandler:   syncableBlockRequestHandler,
 	}
 }
 
@@ -48,3 +51,7 @@ func (s *syncHandler) HandleBlockRequest(ctx context.Context, nodeID ids.ShortID
 func (s *syncHandler) HandleCodeRequest(ctx context.Context, nodeID ids.ShortID, requestID uint32, codeRequest message.CodeRequest) ([]byte, error) {
 	return s.codeRequestHandler.OnCodeRequest(ctx, nodeID, requestID, codeRequest)
 }
+
+func (s *syncHandler) HandleSyncableBlockRequest(ctx context.Context, nodeID ids.ShortID, requestID uint32, request message.SyncableBlockRequest) ([]byte, error) {
+	return s.syncableBlockRequestHandler.OnSyncableBlockRequest(ctx, nodeID, requestID, request)
+}
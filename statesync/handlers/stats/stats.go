@@ -28,6 +28,15 @@ type HandlerStats interface {
 	UpdateLeafsReturned(numLeafs uint16)
 	UpdateLeafsRequestProcessingTime(duration time.Duration)
 	IncMissingRoot()
+
+	// SyncableBlockRequestHandler stats
+	IncSyncableBlockRequest()
+	IncMissingSyncableBlock()
+
+	// Inbound request throttling stats
+	IncRateLimitedRequest()
+	IncCPULimitedRequest()
+	UpdateRequestProcessingTime(duration time.Duration)
 }
 
 type handlerStats struct {
@@ -48,6 +57,15 @@ type handlerStats struct {
 	leafsReturned              metrics.Histogram
 	leafsRequestProcessingTime metrics.Timer
 	missingRoot                metrics.Counter
+
+	// SyncableBlockRequestHandler stats
+	syncableBlockRequest metrics.Counter
+	missingSyncableBlock metrics.Counter
+
+	// Inbound request throttling stats
+	rateLimitedRequest    metrics.Counter
+	cpuLimitedRequest     metrics.Counter
+	requestProcessingTime metrics.Timer
 }
 
 func (h *handlerStats) IncBlockRequest() {
@@ -98,6 +116,26 @@ func (h *handlerStats) IncMissingRoot() {
 	h.missingRoot.Inc(1)
 }
 
+func (h *handlerStats) IncSyncableBlockRequest() {
+	h.syncableBlockRequest.Inc(1)
+}
+
+func (h *handlerStats) IncMissingSyncableBlock() {
+	h.missingSyncableBlock.Inc(1)
+}
+
+func (h *handlerStats) IncRateLimitedRequest() {
+	h.rateLimitedRequest.Inc(1)
+}
+
+func (h *handlerStats) IncCPULimitedRequest() {
+	h.cpuLimitedRequest.Inc(1)
+}
+
+func (h *handlerStats) UpdateRequestProcessingTime(duration time.Duration) {
+	h.requestProcessingTime.Update(duration)
+}
+
 func NewHandlerStats() HandlerStats {
 	return &handlerStats{
 		// initialise block request stats
@@ -117,6 +155,15 @@ func NewHandlerStats() HandlerStats {
 		leafsRequestProcessingTime: metrics.GetOrRegisterTimer("leafs_request_processing_time", nil),
 		leafsReturned:              metrics.GetOrRegisterHistogram("leafs_returned", nil, metrics.NewExpDecaySample(1028, 0.015)),
 		missingRoot:                metrics.GetOrRegisterCounter("missing_root", nil),
+
+		// initialize syncable block request stats
+		syncableBlockRequest: metrics.GetOrRegisterCounter("syncable_block_request", nil),
+		missingSyncableBlock: metrics.GetOrRegisterCounter("missing_syncable_block", nil),
+
+		// initialize inbound request throttling stats
+		rateLimitedRequest:    metrics.GetOrRegisterCounter("rate_limited_request", nil),
+		cpuLimitedRequest:     metrics.GetOrRegisterCounter("cpu_limited_request", nil),
+		requestProcessingTime: metrics.GetOrRegisterTimer("request_processing_time", nil),
 	}
 }
 
@@ -140,3 +187,8 @@ func (n *noopHandlerStats) IncLeafsRequest()                               {}
 func (n *noopHandlerStats) UpdateLeafsRequestProcessingTime(time.Duration) {}
 func (n *noopHandlerStats) UpdateLeafsReturned(uint16)                     {}
 func (n *noopHandlerStats) IncMissingRoot()                                {}
+func (n *noopHandlerStats) IncSyncableBlockRequest()                       {}
+func (n *noopHandlerStats) IncMissingSyncableBlock()                       {}
+func (n *noopHandlerStats) IncRateLimitedRequest()                         {}
+func (n *noopHandlerStats) IncCPULimitedRequest()                          {}
+func (n *noopHandlerStats) UpdateRequestProcessingTime(time.Duration)      {}
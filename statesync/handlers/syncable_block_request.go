@@ -0,0 +1,50 @@
+// (c) 2021-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package handlers
+
+import (
+	"context"
+
+	"github.com/ava-labs/avalanchego/codec"
+	"github.com/ava-labs/avalanchego/ids"
+
+	"github.com/ava-labs/coreth/plugin/evm/message"
+	"github.com/ava-labs/coreth/statesync/handlers/stats"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// SyncableBlockRequestHandler is a peer.RequestHandler for message.SyncableBlockRequest
+// serving the most recent block this node can serve the state tries for
+type SyncableBlockRequestHandler struct {
+	getter func() (message.SyncableBlock, bool)
+	codec  codec.Manager
+	stats  stats.HandlerStats
+}
+
+func NewSyncableBlockRequestHandler(getter func() (message.SyncableBlock, bool), codec codec.Manager, handlerStats stats.HandlerStats) *SyncableBlockRequestHandler {
+	return &SyncableBlockRequestHandler{getter: getter, codec: codec, stats: handlerStats}
+}
+
+// OnSyncableBlockRequest handles incoming message.SyncableBlockRequest, returning
+// the encoded message.SyncableBlock provided by getter
+// Never returns error
+// Expects returned errors to be treated as FATAL
+// Returns nothing if there is no block that can be served as a state sync target
+func (s *SyncableBlockRequestHandler) OnSyncableBlockRequest(_ context.Context, nodeID ids.ShortID, requestID uint32, _ message.SyncableBlockRequest) ([]byte, error) {
+	s.stats.IncSyncableBlockRequest()
+
+	syncableBlock, ok := s.getter()
+	if !ok {
+		s.stats.IncMissingSyncableBlock()
+		log.Debug("no syncable block available, dropping request", "nodeID", nodeID, "requestID", requestID)
+		return nil, nil
+	}
+
+	responseBytes, err := s.codec.Marshal(message.Version, syncableBlock)
+	if err != nil {
+		log.Warn("failed to marshal SyncableBlock, dropping request", "nodeID", nodeID, "requestID", requestID, "syncableBlock", syncableBlock, "err", err)
+		return nil, nil
+	}
+	return responseBytes, nil
+}
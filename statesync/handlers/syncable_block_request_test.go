@@ -0,0 +1,48 @@
+// (c) 2021-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/coreth/plugin/evm/message"
+	"github.com/ava-labs/coreth/statesync/handlers/stats"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncableBlockRequestHandler(t *testing.T) {
+	codec, err := message.BuildCodec()
+	if err != nil {
+		t.Fatal("unexpected error when building codec", err)
+	}
+
+	expected := message.SyncableBlock{
+		BlockNumber: 4096,
+		BlockRoot:   common.HexToHash("0x01"),
+		AtomicRoot:  common.HexToHash("0x02"),
+		BlockHash:   common.HexToHash("0x03"),
+	}
+	available := true
+	handler := NewSyncableBlockRequestHandler(func() (message.SyncableBlock, bool) {
+		return expected, available
+	}, codec, stats.NewNoopHandlerStats())
+
+	responseBytes, err := handler.OnSyncableBlockRequest(context.Background(), ids.GenerateTestShortID(), 1, message.SyncableBlockRequest{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, responseBytes)
+
+	var response message.SyncableBlock
+	_, err = codec.Unmarshal(responseBytes, &response)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, response)
+
+	// drop the request when there is nothing to serve
+	available = false
+	responseBytes, err = handler.OnSyncableBlockRequest(context.Background(), ids.GenerateTestShortID(), 2, message.SyncableBlockRequest{})
+	assert.NoError(t, err)
+	assert.Nil(t, responseBytes)
+}
@@ -0,0 +1,96 @@
+// (c) 2021-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package statesync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/ava-labs/coreth/plugin/evm/message"
+	statesyncclient "github.com/ava-labs/coreth/statesync/client"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// LeafsRequestLimit is the maximum number of leafs requested from a peer in
+// a single message.LeafsRequest.
+const LeafsRequestLimit = uint16(1024)
+
+// LeafSyncTask describes a single trie (or a key range of a trie) to be
+// fetched from peers one verified batch of leafs at a time.
+type LeafSyncTask struct {
+	Root     common.Hash      // Root of the trie to sync
+	NodeType message.NodeType // Which trie the leafs should be served from
+	Start    []byte           // Inclusive lower bound of the keys to sync
+	End      []byte           // Inclusive upper bound of the keys to sync
+
+	// OnLeafs is called with each verified batch of leafs, in key order.
+	OnLeafs func(keys, vals [][]byte) error
+}
+
+// SyncLeafs fetches every leaf of [task] in the range [task.Start, task.End]
+// using [client], passing each verified batch to [task.OnLeafs].
+// Returns once the range proof of a response indicates there are no more
+// leafs in the range, or on the first error.
+func SyncLeafs(ctx context.Context, client statesyncclient.Client, task LeafSyncTask) error {
+	start := task.Start
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Peers drop requests with Start equal to End, so the single remaining
+		// key is requested along with the key preceding it, which is skipped.
+		requestStart, skip := start, []byte(nil)
+		if bytes.Equal(start, task.End) {
+			if prev := previousKey(start); prev != nil {
+				requestStart, skip = prev, prev
+			}
+		}
+		leafsResponse, err := client.GetLeafs(ctx, message.LeafsRequest{
+			Root:     task.Root,
+			Start:    requestStart,
+			End:      task.End,
+			Limit:    LeafsRequestLimit,
+			NodeType: task.NodeType,
+		})
+		if err != nil {
+			return fmt.Errorf("could not get leafs for root %s: %w", task.Root, err)
+		}
+		keys, vals := leafsResponse.Keys, leafsResponse.Vals
+		if len(keys) > 0 && skip != nil && bytes.Equal(keys[0], skip) {
+			keys, vals = keys[1:], vals[1:]
+		}
+
+		if len(keys) > 0 {
+			if err := task.OnLeafs(keys, vals); err != nil {
+				return err
+			}
+		}
+
+		// The range proof guarantees there are no leafs to the right of
+		// the last key in the response when More is false.
+		if !leafsResponse.More || len(keys) == 0 {
+			return nil
+		}
+
+		start = nextKey(keys[len(keys)-1])
+		if start == nil || bytes.Compare(start, task.End) > 0 {
+			return nil
+		}
+	}
+}
+
+// nextKey returns the key immediately following [key] in lexicographical
+// order among keys of the same length, or nil if [key] is the last such key.
+func nextKey(key []byte) []byte {
+	next := common.CopyBytes(key)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			return next
+		}
+	}
+	return nil
+}
@@ -0,0 +1,434 @@
+// (c) 2021-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package statesync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/ethdb"
+	"github.com/ava-labs/coreth/plugin/evm/message"
+	statesyncclient "github.com/ava-labs/coreth/statesync/client"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const progressLogFrequency = 30 * time.Second
+
+var _ Syncer = &stateSyncer{}
+
+// StateSyncerConfig contains the parameters required to create a Syncer
+// for the EVM state at [Root].
+type StateSyncerConfig struct {
+	Client statesyncclient.Client
+	DB     ethdb.Database
+	Root   common.Hash
+
+	// SegmentThreshold is the estimated number of leafs above which a storage
+	// trie is split into NumSegments segments fetched concurrently.
+	// Defaults to DefaultSegmentThreshold and DefaultNumSegments if zero.
+	SegmentThreshold uint64
+	NumSegments      int
+}
+
+// stateSyncer fetches the account trie at [root], every storage trie it
+// references and the contract code of every account from peers, writing
+// the trie nodes and code to [db].
+//
+// Progress is saved to [db] along with the trie nodes, such that a sync
+// interrupted by a shutdown resumes from where it left off:
+// - the root being synced (rawdb.WriteSyncRoot)
+// - the last key synced of each segment of the tries that are not complete (rawdb.WriteSyncSegment)
+// - the trie.StackTrie of the leafs synced in order, holding the trie nodes not yet written (rawdb.WriteSyncStackTrie)
+// - the leafs of the segments of large storage tries fetched concurrently, until the trie is complete (rawdb.WriteSyncLeaf)
+// - the code hashes referenced by synced accounts and not yet fetched (rawdb.WriteCodeToFetch)
+// If the sync is restarted with a different root, the progress of the previous
+// root is discarded and the account trie is synced again from its first key.
+// The trie nodes and code already on disk are reused: storage tries whose root
+// is on disk are not fetched again and neither is code that is present. Trie
+// nodes of the previous root that the new root does not reference remain on
+// disk until they are removed by offline pruning.
+type stateSyncer struct {
+	client statesyncclient.Client
+	db     ethdb.Database
+	root   common.Hash
+
+	batch ethdb.Batch
+
+	segmentThreshold uint64
+	numSegments      int
+
+	// syncedStorageRoots and codeToFetch track what has been written
+	// in this run, since it may not have been flushed to [db] yet.
+	syncedStorageRoots map[common.Hash]struct{}
+	codeToFetch        map[common.Hash]struct{}
+
+	// stats for progress logging
+	accounts     uint64
+	storageLeafs uint64
+	code         uint64
+	lastLogTime  time.Time
+
+	done chan error
+}
+
+func NewStateSyncer(config *StateSyncerConfig) (Syncer, error) {
+	if config.Client == nil || config.DB == nil {
+		return nil, fmt.Errorf("state syncer requires a client and database")
+	}
+	segmentThreshold, numSegments := config.SegmentThreshold, config.NumSegments
+	if segmentThreshold == 0 {
+		segmentThreshold = DefaultSegmentThreshold
+	}
+	if numSegments <= 0 {
+		numSegments = DefaultNumSegments
+	}
+	return &stateSyncer{
+		client:             config.Client,
+		db:                 config.DB,
+		root:               config.Root,
+		batch:              config.DB.NewBatch(),
+		segmentThreshold:   segmentThreshold,
+		numSegments:        numSegments,
+		syncedStorageRoots: make(map[common.Hash]struct{}),
+		codeToFetch:        make(map[common.Hash]struct{}),
+		done:               make(chan error, 1),
+	}, nil
+}
+
+// Start begins syncing the state trie in the background.
+func (s *stateSyncer) Start(ctx context.Context) error {
+	go func() {
+		s.done <- s.sync(ctx)
+		close(s.done)
+	}()
+	return nil
+}
+
+// Done returns a channel which receives the result of the sync.
+func (s *stateSyncer) Done() <-chan error { return s.done }
+
+func (s *stateSyncer) sync(ctx context.Context) error {
+	start := time.Now()
+	s.lastLogTime = start
+	if err := s.loadProgress(); err != nil {
+		return err
+	}
+	log.Info("starting state sync", "root", s.root)
+
+	// The root of the account trie is only written once the storage tries of
+	// all accounts are, so the account trie is not synced again if a previous
+	// run was interrupted while fetching code.
+	if !rawdb.HasTrieNode(s.db, s.root) {
+		if err := s.syncTrie(ctx, common.Hash{}, s.root, s.onAccountLeafs); err != nil {
+			return err
+		}
+	}
+	if err := s.flush(); err != nil {
+		return err
+	}
+	if err := s.syncCode(ctx); err != nil {
+		return err
+	}
+
+	// The trie nodes and code are on disk, remove the progress markers
+	rawdb.DeleteSyncRoot(s.batch)
+	if err := s.flush(); err != nil {
+		return err
+	}
+
+	log.Info("state sync completed", "root", s.root, "accounts", s.accounts, "storageLeafs", s.storageLeafs, "code", s.code, "time", time.Since(start))
+	return nil
+}
+
+// loadProgress checks the progress markers left by a previous run, discarding
+// them if they belong to a different root, and marks [s.root] as the root
+// being synced.
+func (s *stateSyncer) loadProgress() error {
+	switch previousRoot := rawdb.ReadSyncRoot(s.db); previousRoot {
+	case s.root:
+		log.Info("resuming state sync", "root", s.root)
+		return nil
+	case common.Hash{}:
+	default:
+		log.Info("state sync root changed, discarding progress of the previous root", "previousRoot", previousRoot, "root", s.root)
+		if err := rawdb.ClearSyncProgress(s.db); err != nil {
+			return err
+		}
+	}
+	rawdb.WriteSyncRoot(s.db, s.root)
+	return nil
+}
+
+// syncTrie fetches all of the leafs of the trie at [root], passing them to [onLeafs]
+// and inserting them into a trie.StackTrie that writes the trie nodes to [s.batch].
+// [account] is the hash of the account owning the storage trie at [root] or an empty
+// hash for the account trie.
+// If the trie was partially synced before a restart, the stack trie saved along
+// with the synced range is restored and only the remaining leafs are fetched.
+// Storage tries estimated to be large after the first leafs are fetched are split
+// into segments synced concurrently (see syncSegments), in which case [onLeafs] is
+// not called for the remaining leafs.
+// Returns an error if the trie built from the fetched leafs does not hash to [root].
+func (s *stateSyncer) syncTrie(ctx context.Context, account common.Hash, root common.Hash, onLeafs func(ctx context.Context, keys, vals [][]byte) error) error {
+	if root == types.EmptyRootHash {
+		return nil
+	}
+
+	segments, err := s.loadSegments(account, root)
+	if err != nil {
+		return err
+	}
+	first := segments[0]
+	stackTrie, err := s.loadStackTrie(account, root, first)
+	if err != nil {
+		return fmt.Errorf("could not restore synced leafs of trie %s: %w", root, err)
+	}
+	if len(segments) > 1 {
+		// the trie was split before a restart
+		return s.syncSegments(ctx, account, root, stackTrie, segments)
+	}
+
+	start := first.start
+	if first.last != nil {
+		start = nextKey(first.last)
+	}
+	if start != nil {
+		var (
+			last  []byte
+			leafs int
+		)
+		err := SyncLeafs(ctx, s.client, LeafSyncTask{
+			Root:     root,
+			NodeType: message.StateTrieNode,
+			Start:    start,
+			End:      first.end,
+			OnLeafs: func(keys, vals [][]byte) error {
+				for i, key := range keys {
+					if err := stackTrie.TryUpdate(key, vals[i]); err != nil {
+						return err
+					}
+				}
+				if onLeafs != nil {
+					if err := onLeafs(ctx, keys, vals); err != nil {
+						return err
+					}
+				}
+				// Save the synced range only once [onLeafs] has completed, so
+				// that a restart processes these leafs again.
+				last = keys[len(keys)-1]
+				if err := s.saveStackTrie(account, root, last, stackTrie); err != nil {
+					return err
+				}
+				leafs += len(keys)
+				// A response with fewer leafs than requested is likely the end of the trie
+				if account != (common.Hash{}) && len(keys) >= int(LeafsRequestLimit) && estimateLeafs(leafs, last) >= s.segmentThreshold {
+					return errSplitTrie
+				}
+				return s.maybeFlush()
+			},
+		})
+		switch {
+		case errors.Is(err, errSplitTrie):
+			log.Debug("splitting large storage trie", "account", account, "root", root, "leafs", leafs, "last", common.Bytes2Hex(last))
+			return s.syncSegments(ctx, account, root, stackTrie, s.splitSegments(account, root, last))
+		case err != nil:
+			return err
+		}
+	}
+
+	syncedRoot, err := stackTrie.Commit()
+	if err != nil {
+		return err
+	}
+	if syncedRoot != root {
+		return fmt.Errorf("synced trie root %s does not match expected root %s", syncedRoot, root)
+	}
+	return s.clearTrieProgress(account, root)
+}
+
+// loadStackTrie returns the trie.StackTrie holding the leafs of [first], the
+// first segment of the trie at [root], synced before a restart, or an empty
+// trie.StackTrie if no leaf of the segment has been synced.
+func (s *stateSyncer) loadStackTrie(account common.Hash, root common.Hash, first *trieSegment) (*trie.StackTrie, error) {
+	if first.last == nil {
+		return trie.NewStackTrie(s.batch), nil
+	}
+	data := rawdb.ReadSyncStackTrie(s.db, account, root)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("missing stack trie of segment ending at %s", common.Bytes2Hex(first.last))
+	}
+	return trie.NewFromBinary(data, s.batch)
+}
+
+// saveStackTrie writes [last] as the last key synced of the first segment of
+// the trie at [root] to [s.batch], along with [stackTrie] holding the leafs of
+// the segment. The trie nodes written by [stackTrie] precede them in [s.batch],
+// so they are on disk whenever the synced range is.
+func (s *stateSyncer) saveStackTrie(account common.Hash, root common.Hash, last []byte, stackTrie *trie.StackTrie) error {
+	data, err := stackTrie.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	rawdb.WriteSyncStackTrie(s.batch, account, root, data)
+	rawdb.WriteSyncSegment(s.batch, account, root, bytes.Repeat([]byte{0x00}, common.HashLength), last)
+	return nil
+}
+
+// clearTrieProgress removes the segments, stack trie and leafs saved while
+// syncing the trie at [root] once its trie nodes have been written to [s.batch].
+func (s *stateSyncer) clearTrieProgress(account common.Hash, root common.Hash) error {
+	rawdb.DeleteSyncStackTrie(s.batch, account, root)
+	if err := s.flush(); err != nil {
+		return err
+	}
+	if err := rawdb.ClearSyncSegments(s.db, account, root); err != nil {
+		return err
+	}
+	return rawdb.ClearSyncLeafs(s.db, account)
+}
+
+// insertSyncedLeafs inserts the leafs of the trie owned by [account] saved by
+// rawdb.WriteSyncLeaf into [stackTrie] in key order.
+func (s *stateSyncer) insertSyncedLeafs(account common.Hash, stackTrie *trie.StackTrie) error {
+	it := rawdb.IterateSyncLeafs(s.db, account)
+	defer it.Release()
+
+	inserted := 0
+	for it.Next() {
+		key := it.Key()[rawdb.SyncLeafsPrefixLength:]
+		if len(key) != common.HashLength {
+			continue
+		}
+		if err := stackTrie.TryUpdate(key, it.Value()); err != nil {
+			return err
+		}
+		inserted++
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	log.Debug("inserted synced leafs", "account", account, "leafs", inserted)
+	return nil
+}
+
+// onAccountLeafs syncs the storage trie of each account in [vals] and marks
+// its code to be fetched.
+func (s *stateSyncer) onAccountLeafs(ctx context.Context, keys, vals [][]byte) error {
+	for i, val := range vals {
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(val, &acc); err != nil {
+			return fmt.Errorf("could not decode account %x: %w", keys[i], err)
+		}
+		s.accounts++
+
+		if err := s.syncStorageTrie(ctx, common.BytesToHash(keys[i]), acc.Root); err != nil {
+			return fmt.Errorf("could not sync storage trie %s of account %x: %w", acc.Root, keys[i], err)
+		}
+		s.addCodeToFetch(common.BytesToHash(acc.CodeHash))
+	}
+	s.logProgress()
+	return nil
+}
+
+// syncStorageTrie syncs the storage trie at [root] of [account] unless it
+// is empty or has already been written.
+func (s *stateSyncer) syncStorageTrie(ctx context.Context, account common.Hash, root common.Hash) error {
+	if root == types.EmptyRootHash {
+		return nil
+	}
+	if _, synced := s.syncedStorageRoots[root]; synced || rawdb.HasTrieNode(s.db, root) {
+		return nil
+	}
+
+	if err := s.syncTrie(ctx, account, root, func(_ context.Context, keys, _ [][]byte) error {
+		s.storageLeafs += uint64(len(keys))
+		return nil
+	}); err != nil {
+		return err
+	}
+	s.syncedStorageRoots[root] = struct{}{}
+	return nil
+}
+
+// addCodeToFetch marks [codeHash] to be fetched unless it is empty or
+// already present.
+func (s *stateSyncer) addCodeToFetch(codeHash common.Hash) {
+	if codeHash == types.EmptyCodeHash {
+		return
+	}
+	if _, queued := s.codeToFetch[codeHash]; queued || rawdb.HasCodeWithPrefix(s.db, codeHash) {
+		return
+	}
+	rawdb.WriteCodeToFetch(s.batch, codeHash)
+	s.codeToFetch[codeHash] = struct{}{}
+}
+
+// syncCode fetches all of the code marked to be fetched, including
+// code marked before a restart.
+// Assumes [s.batch] has been flushed.
+func (s *stateSyncer) syncCode(ctx context.Context) error {
+	it := rawdb.IterateCodeToFetch(s.db)
+	defer it.Release()
+
+	for it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		key := it.Key()[rawdb.CodeToFetchPrefixLength:]
+		if len(key) != common.HashLength {
+			continue
+		}
+		codeHash := common.BytesToHash(key)
+		if !rawdb.HasCodeWithPrefix(s.db, codeHash) {
+			code, err := s.client.GetCode(ctx, codeHash)
+			if err != nil {
+				return fmt.Errorf("could not sync code %s: %w", codeHash, err)
+			}
+			rawdb.WriteCode(s.batch, codeHash, code)
+			s.code++
+		}
+		rawdb.DeleteCodeToFetch(s.batch, codeHash)
+		if err := s.maybeFlush(); err != nil {
+			return err
+		}
+		s.logProgress()
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+// maybeFlush writes [s.batch] to disk once it reaches ethdb.IdealBatchSize.
+func (s *stateSyncer) maybeFlush() error {
+	if s.batch.ValueSize() < ethdb.IdealBatchSize {
+		return nil
+	}
+	return s.flush()
+}
+
+// flush writes [s.batch] to disk.
+func (s *stateSyncer) flush() error {
+	if err := s.batch.Write(); err != nil {
+		return err
+	}
+	s.batch.Reset()
+	return nil
+}
+
+func (s *stateSyncer) logProgress() {
+	if time.Since(s.lastLogTime) < progressLogFrequency {
+		return
+	}
+	log.Info("state sync in progress", "root", s.root, "accounts", s.accounts, "storageLeafs", s.storageLeafs, "code", s.code, "codeToFetch", len(s.codeToFetch))
+	s.lastLogTime = time.Now()
+}
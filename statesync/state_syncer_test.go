@@ -0,0 +1,505 @@
+// (c) 2021-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package statesync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/state"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/ethdb"
+	"github.com/ava-labs/coreth/plugin/evm/message"
+	statesyncclient "github.com/ava-labs/coreth/statesync/client"
+	"github.com/ava-labs/coreth/statesync/handlers"
+	"github.com/ava-labs/coreth/statesync/handlers/stats"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/assert"
+)
+
+// fillState creates [numAccounts] accounts in a new state on top of [db],
+// giving every third account storage and every fifth account code.
+// Returns the committed state root.
+func fillState(t *testing.T, db ethdb.Database, numAccounts int) common.Hash {
+	stateDB := state.NewDatabase(db)
+	statedb, err := state.New(common.Hash{}, stateDB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rand.Seed(1)
+	for i := 0; i < numAccounts; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+		statedb.SetBalance(addr, big.NewInt(rand.Int63()))
+		statedb.SetNonce(addr, uint64(i))
+		if i%3 == 0 {
+			// every 30th account has a storage trie large enough to need
+			// several leafs requests
+			numSlots := 1 + rand.Intn(100)
+			if i%30 == 0 {
+				numSlots += 2_000
+			}
+			for j := 0; j < numSlots; j++ {
+				statedb.SetState(addr, common.BigToHash(big.NewInt(int64(j))), common.BigToHash(big.NewInt(rand.Int63())))
+			}
+		}
+		if i%5 == 0 {
+			statedb.SetCode(addr, []byte{byte(i % 256), byte(i / 256), 0x60, 0x00})
+		}
+	}
+
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stateDB.TrieDB().Commit(root, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func newTestClient(t *testing.T, serverDB ethdb.Database) *statesyncclient.MockClient {
+	codec, err := message.BuildCodec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlerStats := stats.NewNoopHandlerStats()
+	return statesyncclient.NewMockClient(
+		codec,
+		handlers.NewLeafsRequestHandler(trie.NewDatabase(serverDB), handlerStats, codec),
+		handlers.NewCodeRequestHandler(serverDB, handlerStats, codec),
+		nil,
+		nil,
+	)
+}
+
+// assertDBConsistency asserts that the account trie at [root], the storage tries
+// and the code of its accounts are the same in [clientDB] as in [serverDB], and
+// that every trie node reachable from [root] is present in [clientDB].
+func assertDBConsistency(t *testing.T, root common.Hash, serverDB, clientDB ethdb.Database) {
+	serverTrieDB := trie.NewDatabase(serverDB)
+	clientTrieDB := trie.NewDatabase(clientDB)
+
+	numAccounts := assertTrieEqual(t, root, serverTrieDB, clientTrieDB, func(key, val []byte) {
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(val, &acc); err != nil {
+			t.Fatal(err)
+		}
+		if acc.Root != types.EmptyRootHash {
+			assertTrieEqual(t, acc.Root, serverTrieDB, clientTrieDB, nil)
+		}
+		if codeHash := common.BytesToHash(acc.CodeHash); codeHash != types.EmptyCodeHash {
+			assert.Equal(t, rawdb.ReadCode(serverDB, codeHash), rawdb.ReadCode(clientDB, codeHash))
+		}
+	})
+	assert.Greater(t, numAccounts, 0)
+
+	clientState, err := state.New(root, state.NewDatabase(clientDB), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it := state.NewNodeIterator(clientState)
+	for it.Next() {
+	}
+	assert.NoError(t, it.Error)
+}
+
+// assertTrieEqual asserts that the trie at [root] has the same leafs in
+// [serverTrieDB] as in [clientTrieDB], calling [onLeaf] for each leaf if it
+// is non-nil. Returns the number of leafs.
+func assertTrieEqual(t *testing.T, root common.Hash, serverTrieDB, clientTrieDB *trie.Database, onLeaf func(key, val []byte)) int {
+	serverTrie, err := trie.New(root, serverTrieDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientTrie, err := trie.New(root, clientTrieDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverIt := trie.NewIterator(serverTrie.NodeIterator(nil))
+	clientIt := trie.NewIterator(clientTrie.NodeIterator(nil))
+	numLeafs := 0
+	for serverIt.Next() {
+		if !assert.True(t, clientIt.Next(), "missing leaf %x of trie %s", serverIt.Key, root) {
+			return numLeafs
+		}
+		assert.Equal(t, serverIt.Key, clientIt.Key)
+		assert.Equal(t, serverIt.Value, clientIt.Value)
+		if onLeaf != nil {
+			onLeaf(serverIt.Key, serverIt.Value)
+		}
+		numLeafs++
+	}
+	assert.False(t, clientIt.Next(), "unexpected leaf %x in trie %s", clientIt.Key, root)
+	assert.NoError(t, serverIt.Err)
+	assert.NoError(t, clientIt.Err)
+	return numLeafs
+}
+
+func TestStateSyncer(t *testing.T) {
+	tests := map[string]struct {
+		numAccounts    int
+		prepareForTest func(t *testing.T, serverDB, clientDB ethdb.Database, root common.Hash)
+	}{
+		"single account": {
+			numAccounts: 1,
+		},
+		"many accounts": {
+			numAccounts: 1_000,
+		},
+		"client has code": {
+			numAccounts: 100,
+			prepareForTest: func(t *testing.T, serverDB, clientDB ethdb.Database, _ common.Hash) {
+				it := serverDB.NewIterator(rawdb.CodePrefix, nil)
+				defer it.Release()
+				for it.Next() {
+					assert.NoError(t, clientDB.Put(it.Key(), it.Value()))
+				}
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			serverDB := rawdb.NewMemoryDatabase()
+			clientDB := rawdb.NewMemoryDatabase()
+			root := fillState(t, serverDB, test.numAccounts)
+			if test.prepareForTest != nil {
+				test.prepareForTest(t, serverDB, clientDB, root)
+			}
+
+			client := newTestClient(t, serverDB)
+			syncer, err := NewStateSyncer(&StateSyncerConfig{
+				Client: client,
+				DB:     clientDB,
+				Root:   root,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.NoError(t, syncer.Start(context.Background()))
+			assert.NoError(t, <-syncer.Done())
+
+			assertDBConsistency(t, root, serverDB, clientDB)
+			assertSyncProgressCleared(t, clientDB)
+		})
+	}
+}
+
+func TestStateSyncerInvalidLeafs(t *testing.T) {
+	serverDB := rawdb.NewMemoryDatabase()
+	root := fillState(t, serverDB, 100)
+
+	client := newTestClient(t, serverDB)
+	client.GetLeafsIntercept = func(request message.LeafsRequest, response message.LeafsResponse) (message.LeafsResponse, error) {
+		// drop the last leaf and clear the More flag, so that the
+		// synced trie cannot hash to the expected root.
+		if len(response.Keys) > 1 {
+			response.Keys = response.Keys[:len(response.Keys)-1]
+			response.Vals = response.Vals[:len(response.Vals)-1]
+		}
+		response.More = false
+		return response, nil
+	}
+
+	syncer, err := NewStateSyncer(&StateSyncerConfig{
+		Client: client,
+		DB:     rawdb.NewMemoryDatabase(),
+		Root:   root,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, syncer.Start(context.Background()))
+	assert.Error(t, <-syncer.Done())
+}
+
+// assertSyncProgressCleared asserts that no state sync progress markers remain in [db].
+func assertSyncProgressCleared(t *testing.T, db ethdb.Database) {
+	assert.Equal(t, common.Hash{}, rawdb.ReadSyncRoot(db))
+	for _, prefix := range []string{"sync_segments", "sync_stacktrie", "sync_leafs"} {
+		it := db.NewIterator([]byte(prefix), nil)
+		assert.False(t, it.Next(), "%s should be removed", prefix)
+		it.Release()
+	}
+	codeIt := rawdb.IterateCodeToFetch(db)
+	defer codeIt.Release()
+	for codeIt.Next() {
+		// trie nodes with a hash beginning with the prefix share the iterator
+		assert.NotEqual(t, rawdb.CodeToFetchPrefixLength+common.HashLength, len(codeIt.Key()), "code markers should be removed")
+	}
+}
+
+// interruptAfter makes [client] fail every leafs request after the first [numRequests].
+func interruptAfter(client *statesyncclient.MockClient, numRequests int32) {
+	client.GetLeafsIntercept = func(_ message.LeafsRequest, response message.LeafsResponse) (message.LeafsResponse, error) {
+		if client.LeafsReceived() >= numRequests {
+			return message.LeafsResponse{}, errors.New("interrupted")
+		}
+		return response, nil
+	}
+}
+
+func syncState(t *testing.T, client statesyncclient.Client, db ethdb.Database, root common.Hash) error {
+	return syncStateWithConfig(t, &StateSyncerConfig{
+		Client: client,
+		DB:     db,
+		Root:   root,
+	})
+}
+
+func syncStateWithConfig(t *testing.T, config *StateSyncerConfig) error {
+	syncer, err := NewStateSyncer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, syncer.Start(context.Background()))
+	return <-syncer.Done()
+}
+
+func TestStateSyncerResume(t *testing.T) {
+	serverDB := rawdb.NewMemoryDatabase()
+	clientDB := rawdb.NewMemoryDatabase()
+	root := fillState(t, serverDB, 1_000)
+
+	// count the requests of an uninterrupted sync
+	fullClient := newTestClient(t, serverDB)
+	assert.NoError(t, syncState(t, fullClient, rawdb.NewMemoryDatabase(), root))
+	fullRequests := fullClient.LeafsReceived()
+
+	client := newTestClient(t, serverDB)
+	interruptAfter(client, fullRequests/2)
+	assert.Error(t, syncState(t, client, clientDB, root))
+	assert.Equal(t, root, rawdb.ReadSyncRoot(clientDB))
+
+
+	// the restarted sync must only fetch the leafs that were not saved
+	client = newTestClient(t, serverDB)
+	assert.NoError(t, syncState(t, client, clientDB, root))
+	assert.Less(t, client.LeafsReceived(), fullRequests)
+
+	assertDBConsistency(t, root, serverDB, clientDB)
+	assertSyncProgressCleared(t, clientDB)
+}
+
+func TestStateSyncerResumeAccountTrie(t *testing.T) {
+	serverDB := rawdb.NewMemoryDatabase()
+	clientDB := rawdb.NewMemoryDatabase()
+
+	// accounts without storage, so that the account trie needs several
+	// leafs requests and its progress is flushed between them
+	stateDB := state.NewDatabase(serverDB)
+	statedb, err := state.New(common.Hash{}, stateDB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5_000; i++ {
+		statedb.SetBalance(common.BigToAddress(big.NewInt(int64(i+1))), big.NewInt(int64(i+1)))
+	}
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stateDB.TrieDB().Commit(root, false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	client := newTestClient(t, serverDB)
+	interruptAfter(client, 3)
+	assert.Error(t, syncState(t, client, clientDB, root))
+
+	// the progress of the account trie is saved without a copy of its leafs
+	assert.NotEmpty(t, rawdb.ReadSyncStackTrie(clientDB, common.Hash{}, root))
+	leafsIt := rawdb.IterateSyncLeafs(clientDB, common.Hash{})
+	defer leafsIt.Release()
+	assert.False(t, leafsIt.Next(), "account trie leafs should not be saved")
+
+	client = newTestClient(t, serverDB)
+	assert.NoError(t, syncState(t, client, clientDB, root))
+	assert.Less(t, client.LeafsReceived(), int32(5))
+
+	assertDBConsistency(t, root, serverDB, clientDB)
+	assertSyncProgressCleared(t, clientDB)
+}
+
+func TestStateSyncerResumeNewRoot(t *testing.T) {
+	serverDB := rawdb.NewMemoryDatabase()
+	clientDB := rawdb.NewMemoryDatabase()
+	root := fillState(t, serverDB, 1_000)
+
+	client := newTestClient(t, serverDB)
+	interruptAfter(client, 50)
+	assert.Error(t, syncState(t, client, clientDB, root))
+
+	// modify some of the accounts, producing a new root sharing most
+	// of its trie nodes with [root]
+	stateDB := state.NewDatabase(serverDB)
+	statedb, err := state.New(root, stateDB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1_000; i += 50 {
+		addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+		statedb.AddBalance(addr, big.NewInt(1))
+		statedb.SetState(addr, common.Hash{0x01}, common.Hash{0x02})
+	}
+	newRoot, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stateDB.TrieDB().Commit(newRoot, false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	client = newTestClient(t, serverDB)
+	assert.NoError(t, syncState(t, client, clientDB, newRoot))
+
+	assertDBConsistency(t, newRoot, serverDB, clientDB)
+	assertSyncProgressCleared(t, clientDB)
+}
+
+func TestStateSyncerSegments(t *testing.T) {
+	serverDB := rawdb.NewMemoryDatabase()
+	clientDB := rawdb.NewMemoryDatabase()
+	root := fillState(t, serverDB, 300)
+
+	// split every storage trie with more than one full response
+	client := newTestClient(t, serverDB)
+	var segmentRequests int32
+	client.GetLeafsIntercept = func(request message.LeafsRequest, response message.LeafsResponse) (message.LeafsResponse, error) {
+		if !bytes.Equal(request.End, bytes.Repeat([]byte{0xff}, common.HashLength)) {
+			atomic.AddInt32(&segmentRequests, 1)
+		}
+		return response, nil
+	}
+	assert.NoError(t, syncStateWithConfig(t, &StateSyncerConfig{
+		Client:           client,
+		DB:               clientDB,
+		Root:             root,
+		SegmentThreshold: 1,
+		NumSegments:      4,
+	}))
+	assert.Greater(t, atomic.LoadInt32(&segmentRequests), int32(0), "large storage tries should be split")
+
+	assertDBConsistency(t, root, serverDB, clientDB)
+	assertSyncProgressCleared(t, clientDB)
+}
+
+func TestStateSyncerSegmentsResume(t *testing.T) {
+	serverDB := rawdb.NewMemoryDatabase()
+	clientDB := rawdb.NewMemoryDatabase()
+	root := fillState(t, serverDB, 300)
+
+	config := func(client statesyncclient.Client) *StateSyncerConfig {
+		return &StateSyncerConfig{
+			Client:           client,
+			DB:               clientDB,
+			Root:             root,
+			SegmentThreshold: 1,
+			NumSegments:      4,
+		}
+	}
+	client := newTestClient(t, serverDB)
+	interruptAfter(client, 40)
+	assert.Error(t, syncStateWithConfig(t, config(client)))
+
+	assert.NoError(t, syncStateWithConfig(t, config(newTestClient(t, serverDB))))
+	assertDBConsistency(t, root, serverDB, clientDB)
+	assertSyncProgressCleared(t, clientDB)
+}
+
+func TestSyncLeafsSingleKeyRange(t *testing.T) {
+	serverDB := rawdb.NewMemoryDatabase()
+	root := fillState(t, serverDB, 10)
+	tr, err := trie.New(root, trie.NewDatabase(serverDB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys [][]byte
+	it := trie.NewIterator(tr.NodeIterator(nil))
+	for it.Next() {
+		keys = append(keys, common.CopyBytes(it.Key))
+	}
+	assert.NoError(t, it.Err)
+
+	// requests with Start equal to End are dropped by peers
+	client := newTestClient(t, serverDB)
+	client.GetLeafsIntercept = func(request message.LeafsRequest, response message.LeafsResponse) (message.LeafsResponse, error) {
+		assert.Less(t, bytes.Compare(request.Start, request.End), 0, "request should have Start before End")
+		return response, nil
+	}
+	for _, test := range []struct {
+		key      []byte
+		expected [][]byte
+	}{
+		{key: keys[3], expected: [][]byte{keys[3]}},
+		{key: nextKey(keys[3]), expected: nil},
+	} {
+		var synced [][]byte
+		assert.NoError(t, SyncLeafs(context.Background(), client, LeafSyncTask{
+			Root:     root,
+			NodeType: message.StateTrieNode,
+			Start:    test.key,
+			End:      test.key,
+			OnLeafs: func(keys, vals [][]byte) error {
+				synced = append(synced, keys...)
+				return nil
+			},
+		}))
+		assert.Equal(t, test.expected, synced)
+	}
+}
+
+func TestSplitSegments(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	s := &stateSyncer{db: db, batch: db.NewBatch(), numSegments: 4}
+	account, root := common.Hash{0x01}, common.Hash{0x02}
+
+	last := common.Hash{0x3f, 0xff}.Bytes()
+	segments := s.splitSegments(account, root, last)
+	assert.NoError(t, s.flush())
+	assert.Len(t, segments, 5)
+
+	// the segments are contiguous and cover all keys
+	assert.Equal(t, common.Hash{}.Bytes(), segments[0].start)
+	assert.Equal(t, last, segments[0].last)
+	for i := 1; i < len(segments); i++ {
+		assert.Equal(t, nextKey(segments[i-1].end), segments[i].start)
+		assert.Nil(t, segments[i].last)
+	}
+	assert.Equal(t, bytes.Repeat([]byte{0xff}, common.HashLength), segments[len(segments)-1].end)
+
+	loaded, err := s.loadSegments(account, root)
+	assert.NoError(t, err)
+	assert.Equal(t, segments, loaded)
+}
+
+func TestEstimateLeafs(t *testing.T) {
+	half := common.Hash{0x80}.Bytes()
+	assert.EqualValues(t, 2048, estimateLeafs(1024, half))
+	assert.EqualValues(t, 1024, estimateLeafs(1024, bytes.Repeat([]byte{0xff}, common.HashLength)))
+	assert.EqualValues(t, uint64(math.MaxUint64), estimateLeafs(1024, common.Hash{}.Bytes()))
+}
+
+func TestPreviousKey(t *testing.T) {
+	assert.Equal(t, []byte{0x00, 0x00}, previousKey([]byte{0x00, 0x01}))
+	assert.Equal(t, []byte{0x00, 0xff}, previousKey([]byte{0x01, 0x00}))
+	assert.Nil(t, previousKey([]byte{0x00, 0x00}))
+}
+
+func TestNextKey(t *testing.T) {
+	assert.Equal(t, []byte{0x00, 0x01}, nextKey([]byte{0x00, 0x00}))
+	assert.Equal(t, []byte{0x01, 0x00}, nextKey([]byte{0x00, 0xff}))
+	assert.Nil(t, nextKey([]byte{0xff, 0xff}))
+}
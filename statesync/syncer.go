@@ -0,0 +1,21 @@
+// (c) 2021-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package statesync
+
+import (
+	"context"
+)
+
+// Syncer represents a step in state sync,
+// along with Start/Done methods to control
+// and monitor progress.
+// Error returns an error if any was encountered.
+type Syncer interface {
+	// Start begins syncing in the background. Start must be called at most once.
+	Start(ctx context.Context) error
+
+	// Done returns a channel that receives the result of the sync once it
+	// has finished. A nil error indicates the sync completed successfully.
+	Done() <-chan error
+}
@@ -0,0 +1,234 @@
+// (c) 2021-2022, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package statesync
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sync/atomic"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/ethdb"
+	"github.com/ava-labs/coreth/plugin/evm/message"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// DefaultSegmentThreshold is the default estimated number of leafs above
+	// which a storage trie is split into segments fetched concurrently.
+	DefaultSegmentThreshold = 500_000
+
+	// DefaultNumSegments is the default number of segments a large storage
+	// trie is split into.
+	DefaultNumSegments = 8
+)
+
+// errSplitTrie is returned from OnLeafs to stop the sequential sync of a trie
+// that should be split into segments.
+var errSplitTrie = errors.New("split trie into segments")
+
+// trieSegment is the range of keys [start, end] of a trie. [last] is the last
+// key synced in the segment, or nil if no key of the segment has been synced.
+type trieSegment struct {
+	start, end, last []byte
+}
+
+// loadSegments returns the segments of the trie at [root] saved on disk, or
+// a single segment covering all keys if the sync of the trie has not started.
+func (s *stateSyncer) loadSegments(account common.Hash, root common.Hash) ([]*trieSegment, error) {
+	it := rawdb.IterateSyncSegments(s.db, account, root)
+	defer it.Release()
+
+	var segments []*trieSegment
+	for it.Next() {
+		start := common.CopyBytes(it.Key()[rawdb.SyncSegmentsPrefixLength:])
+		if len(start) != common.HashLength {
+			continue
+		}
+		segment := &trieSegment{start: start}
+		if last := it.Value(); len(last) > 0 {
+			segment.last = common.CopyBytes(last)
+		}
+		segments = append(segments, segment)
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+
+	if len(segments) == 0 || !bytes.Equal(segments[0].start, bytes.Repeat([]byte{0x00}, common.HashLength)) {
+		// the first segment always begins at the first key, any other
+		// segments are stale and are synced again.
+		segments = []*trieSegment{{start: bytes.Repeat([]byte{0x00}, common.HashLength)}}
+	}
+	// each segment ends right before the start of the next one
+	for i, segment := range segments {
+		if i+1 < len(segments) {
+			segment.end = previousKey(segments[i+1].start)
+		} else {
+			segment.end = bytes.Repeat([]byte{0xff}, common.HashLength)
+		}
+	}
+	return segments, nil
+}
+
+// splitSegments splits the keys of the trie at [root] after [last] into
+// [s.numSegments] segments of equal size, writing the segments to [s.batch].
+// The keys up to and including [last] are already synced and form the first
+// segment.
+func (s *stateSyncer) splitSegments(account common.Hash, root common.Hash, last []byte) []*trieSegment {
+	first := &trieSegment{
+		start: bytes.Repeat([]byte{0x00}, common.HashLength),
+		end:   last,
+		last:  last,
+	}
+	segments := []*trieSegment{first}
+
+	start := nextKey(last)
+	if start == nil {
+		return segments
+	}
+	startInt := new(big.Int).SetBytes(start)
+	maxInt := new(big.Int).SetBytes(bytes.Repeat([]byte{0xff}, common.HashLength))
+	step := new(big.Int).Sub(maxInt, startInt)
+	step.Div(step, big.NewInt(int64(s.numSegments)))
+
+	for i := 0; i < s.numSegments; i++ {
+		segmentStart := new(big.Int).Add(startInt, new(big.Int).Mul(step, big.NewInt(int64(i))))
+		segment := &trieSegment{start: common.BigToHash(segmentStart).Bytes()}
+		if i+1 < s.numSegments {
+			segmentEnd := new(big.Int).Add(segmentStart, step)
+			segment.end = common.BigToHash(segmentEnd.Sub(segmentEnd, big.NewInt(1))).Bytes()
+		} else {
+			segment.end = maxInt.FillBytes(make([]byte, common.HashLength))
+		}
+		if bytes.Compare(segment.start, segment.end) > 0 {
+			// fewer keys than segments
+			continue
+		}
+		segments = append(segments, segment)
+	}
+
+	for _, segment := range segments {
+		rawdb.WriteSyncSegment(s.batch, account, root, segment.start, segment.last)
+	}
+	return segments
+}
+
+// syncSegments fetches the leafs of each incomplete segment of the trie at [root]
+// concurrently, each from a peer selected independently by the client. The leafs
+// of the first segment are already in [stackTrie]. The leafs of the following
+// segments are saved with rawdb.WriteSyncLeaf, since they arrive out of order,
+// and once every segment is complete they are inserted in order into [stackTrie],
+// which writes the trie nodes to [s.batch], and removed.
+// Returns an error if the resulting trie does not hash to [root].
+func (s *stateSyncer) syncSegments(ctx context.Context, account common.Hash, root common.Hash, stackTrie *trie.StackTrie, segments []*trieSegment) error {
+	// the segments and leafs are read back from [s.db]
+	if err := s.flush(); err != nil {
+		return err
+	}
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	for _, segment := range segments {
+		segment := segment
+		eg.Go(func() error {
+			return s.syncSegment(egCtx, account, root, segment)
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	if err := s.insertSyncedLeafs(account, stackTrie); err != nil {
+		return fmt.Errorf("could not insert synced leafs of trie %s: %w", root, err)
+	}
+	syncedRoot, err := stackTrie.Commit()
+	if err != nil {
+		return err
+	}
+	if syncedRoot != root {
+		return fmt.Errorf("synced trie root %s does not match expected root %s", syncedRoot, root)
+	}
+	return s.clearTrieProgress(account, root)
+}
+
+// syncSegment fetches the leafs of [segment] following [segment.last] and saves
+// them along with the progress of the segment using a batch of its own.
+// The range proof of each response is checked against the bounds of the segment
+// by the client.
+func (s *stateSyncer) syncSegment(ctx context.Context, account common.Hash, root common.Hash, segment *trieSegment) error {
+	start := segment.start
+	if segment.last != nil {
+		start = nextKey(segment.last)
+	}
+	if start == nil || bytes.Compare(start, segment.end) > 0 {
+		return nil
+	}
+
+	batch := s.db.NewBatch()
+	err := SyncLeafs(ctx, s.client, LeafSyncTask{
+		Root:     root,
+		NodeType: message.StateTrieNode,
+		Start:    start,
+		End:      segment.end,
+		OnLeafs: func(keys, vals [][]byte) error {
+			for i, key := range keys {
+				rawdb.WriteSyncLeaf(batch, account, key, vals[i])
+			}
+			rawdb.WriteSyncSegment(batch, account, root, segment.start, keys[len(keys)-1])
+			atomic.AddUint64(&s.storageLeafs, uint64(len(keys)))
+			if batch.ValueSize() < ethdb.IdealBatchSize {
+				return nil
+			}
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+	// mark the segment as complete
+	rawdb.WriteSyncSegment(batch, account, root, segment.start, segment.end)
+	return batch.Write()
+}
+
+// estimateLeafs estimates the number of leafs of a trie from the [leafs] keys
+// up to and including [last], assuming the keys are uniformly distributed.
+func estimateLeafs(leafs int, last []byte) uint64 {
+	if len(last) < 8 {
+		return uint64(leafs)
+	}
+	position := binary.BigEndian.Uint64(last[:8])
+	if position == 0 {
+		return math.MaxUint64
+	}
+	estimate := float64(leafs) * (float64(math.MaxUint64) / float64(position))
+	if estimate >= float64(math.MaxUint64) {
+		return math.MaxUint64
+	}
+	return uint64(estimate)
+}
+
+// previousKey returns the key immediately preceding [key] in lexicographical
+// order among keys of the same length, or nil if [key] is the first such key.
+func previousKey(key []byte) []byte {
+	prev := common.CopyBytes(key)
+	for i := len(prev) - 1; i >= 0; i-- {
+		prev[i]--
+		if prev[i] != 0xff {
+			return prev
+		}
+	}
+	return nil
+}
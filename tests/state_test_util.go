@@ -160,6 +160,9 @@ func MakePreState(db ethdb.Database, accounts core.GenesisAlloc, snapshotter boo
 		for k, v := range a.Storage {
 			statedb.SetState(addr, k, v)
 		}
+		for coinID, v := range a.MCBalance {
+			statedb.AddBalanceMultiCoin(addr, coinID, v)
+		}
 	}
 	// Commit and re-open to start with a clean state.
 	root, _ := statedb.Commit(false)
@@ -482,6 +482,20 @@ func (db *Database) Nodes() []common.Hash {
 	return hashes
 }
 
+// Roots retrieves the roots of the tries referenced by the meta-root, which
+// are the tries still held in memory through Reference(root, common.Hash{}).
+func (db *Database) Roots() []common.Hash {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	children := db.dirties[common.Hash{}].children
+	roots := make([]common.Hash, 0, len(children))
+	for root := range children {
+		roots = append(roots, root)
+	}
+	return roots
+}
+
 // Reference adds a new reference from a parent node to a child node.
 // This function is used to add reference between internal trie node
 // and external node(e.g. storage trie root), all internal trie nodes
@@ -38,6 +38,10 @@ import (
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
+// errEmptyRange is returned by unsetInternal if the edge paths do not enclose
+// any part of the trie.
+var errEmptyRange = errors.New("empty range")
+
 // Prove constructs a merkle proof for key. The result contains all encoded nodes
 // on the path to the value at key. The value itself is also included in the last
 // node and can be retrieved by verifying the proof.
@@ -288,10 +292,10 @@ findFork:
 		// - left proof points to the shortnode, but right proof is greater
 		// - right proof points to the shortnode, but left proof is less
 		if shortForkLeft == -1 && shortForkRight == -1 {
-			return false, errors.New("empty range")
+			return false, errEmptyRange
 		}
 		if shortForkLeft == 1 && shortForkRight == 1 {
-			return false, errors.New("empty range")
+			return false, errEmptyRange
 		}
 		if shortForkLeft != 0 && shortForkRight != 0 {
 			// The fork point is root node, unset the entire trie
@@ -576,6 +580,54 @@ func VerifyRangeProof(rootHash common.Hash, firstKey []byte, lastKey []byte, key
 	return hasRightElement(tr.root, keys[len(keys)-1]), nil
 }
 
+// VerifyEmptyRangeProof checks whether the given edge proofs of firstKey and
+// lastKey prove that the trie with the given root has no entries within the
+// range [firstKey, lastKey]. Unlike VerifyRangeProof with zero key/value
+// pairs, entries to the right of lastKey are allowed.
+func VerifyEmptyRangeProof(rootHash common.Hash, firstKey []byte, lastKey []byte, proof ethdb.KeyValueReader) error {
+	if bytes.Compare(firstKey, lastKey) > 0 {
+		return errors.New("invalid edge keys")
+	}
+	if len(firstKey) != len(lastKey) {
+		return errors.New("inconsistent edge keys")
+	}
+	root, val, err := proofToPath(rootHash, nil, firstKey, proof, true)
+	if err != nil {
+		return err
+	}
+	if val != nil {
+		return errors.New("entry within range")
+	}
+	if bytes.Equal(firstKey, lastKey) {
+		return nil
+	}
+	root, val, err = proofToPath(rootHash, root, lastKey, proof, true)
+	if err != nil {
+		return err
+	}
+	if val != nil {
+		return errors.New("entry within range")
+	}
+	// Remove all internal references between the edge paths, the trie
+	// has the same hash only if nothing was removed.
+	empty, err := unsetInternal(root, firstKey, lastKey)
+	if err == errEmptyRange {
+		// Both edges diverge on the same side of the trie path
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	tr := &Trie{root: root, db: NewDatabase(memorydb.New())}
+	if empty {
+		tr.root = nil
+	}
+	if tr.Hash() != rootHash {
+		return fmt.Errorf("entries within range, want hash %x, got %x", rootHash, tr.Hash())
+	}
+	return nil
+}
+
 // get returns the child of the given node. Return nil if the
 // node with specified key doesn't exist at all.
 //
@@ -788,6 +788,62 @@ func TestEmptyRangeProof(t *testing.T) {
 	}
 }
 
+// TestEmptyRangeProofWithRightElements tests the proofs of ranges between two
+// adjacent entries, with entries on both sides of the range.
+func TestEmptyRangeProofWithRightElements(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	var entries entrySlice
+	for _, kv := range vals {
+		entries = append(entries, kv)
+	}
+	sort.Sort(entries)
+
+	for i := 0; i < 200; i++ {
+		pos := mrand.Intn(len(entries) - 1)
+		first := increseKey(common.CopyBytes(entries[pos].k))
+		last := decreseKey(common.CopyBytes(entries[pos+1].k))
+		if bytes.Compare(first, last) > 0 {
+			continue
+		}
+		proof := memorydb.New()
+		if err := trie.Prove(first, 0, proof); err != nil {
+			t.Fatalf("Failed to prove the first node %v", err)
+		}
+		if err := trie.Prove(last, 0, proof); err != nil {
+			t.Fatalf("Failed to prove the last node %v", err)
+		}
+		if err := VerifyEmptyRangeProof(trie.Hash(), first, last, proof); err != nil {
+			t.Fatalf("Case %d(%d) expected no error, got %v", i, pos, err)
+		}
+
+		// Extend the range to include the next entry
+		last = common.CopyBytes(entries[pos+1].k)
+		proof = memorydb.New()
+		if err := trie.Prove(first, 0, proof); err != nil {
+			t.Fatalf("Failed to prove the first node %v", err)
+		}
+		if err := trie.Prove(last, 0, proof); err != nil {
+			t.Fatalf("Failed to prove the last node %v", err)
+		}
+		if err := VerifyEmptyRangeProof(trie.Hash(), first, last, proof); err == nil {
+			t.Fatalf("Case %d(%d) expected error, got nil", i, pos)
+		}
+
+		// Extend the range beyond the next entry
+		last = increseKey(common.CopyBytes(entries[pos+1].k))
+		proof = memorydb.New()
+		if err := trie.Prove(first, 0, proof); err != nil {
+			t.Fatalf("Failed to prove the first node %v", err)
+		}
+		if err := trie.Prove(last, 0, proof); err != nil {
+			t.Fatalf("Failed to prove the last node %v", err)
+		}
+		if err := VerifyEmptyRangeProof(trie.Hash(), first, last, proof); err == nil {
+			t.Fatalf("Case %d(%d) expected error, got nil", i, pos)
+		}
+	}
+}
+
 // TestBloatedProof tests a malicious proof, where the proof is more or less the
 // whole trie. Previously we didn't accept such packets, but the new APIs do, so
 // lets leave this test as a bit weird, but present.